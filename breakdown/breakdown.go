@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// result contains the fields of a vegeta json result the breakdown needs
+type result struct {
+	Method  string        `json:"method"`
+	URL     string        `json:"url"`
+	Latency time.Duration `json:"latency"`
+	Code    uint16        `json:"code"`
+	Error   string        `json:"error"`
+}
+
+// failed returns true if the request is not counted as a success by vegeta
+func (r *result) failed() bool {
+	return r.Error != "" || r.Code < 200 || r.Code >= 400
+}
+
+// Latencies are the latencies of the requests to a target. The json field names match the ones of vegeta report -type json.
+type Latencies struct {
+	Total time.Duration `json:"total"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"50th"`
+	P90   time.Duration `json:"90th"`
+	P95   time.Duration `json:"95th"`
+	P99   time.Duration `json:"99th"`
+	Max   time.Duration `json:"max"`
+	Min   time.Duration `json:"min"`
+}
+
+// Summary summarizes the results of the requests to a target. The json field names match the ones of vegeta report -type json.
+type Summary struct {
+	Method    string    `json:"method"`
+	URL       string    `json:"url"`
+	Requests  uint64    `json:"requests"`
+	Success   float64   `json:"success"`
+	Latencies Latencies `json:"latencies"`
+}
+
+// Record is the json record of the breakdown: the total number of requests and the summaries of the targets
+type Record struct {
+	Requests uint64    `json:"requests"`
+	Targets  []Summary `json:"targets"`
+}
+
+// latencyBucketRatio is the ratio between the bounds of the buckets of the latency histograms.
+// A quantile is computed from the geometric middle of its bucket, hence within 1% of the exact latency,
+// and a histogram has less than 2000 buckets for latencies between 1µs and 1h whatever the number of requests.
+const latencyBucketRatio = 1.02
+
+// logLatencyBucketRatio is the width of the buckets in the logarithmic scale
+var logLatencyBucketRatio = math.Log(latencyBucketRatio)
+
+// target accumulates the results of the requests with a method and a url
+type target struct {
+	method    string
+	url       string
+	requests  uint64
+	successes uint64
+	total     time.Duration
+	min       time.Duration
+	max       time.Duration
+	// buckets counts the latencies per bucket of the logarithmic histogram, see bucketOf
+	buckets map[int]uint64
+}
+
+// bucketOf returns the index of the bucket of the latency in the histogram
+func bucketOf(l time.Duration) int {
+	if l < 1 {
+		return 0
+	}
+	return int(math.Floor(math.Log(float64(l)) / logLatencyBucketRatio))
+}
+
+// latencyOf returns the geometric middle of the bucket
+func latencyOf(bucket int) time.Duration {
+	return time.Duration(math.Round(math.Exp((float64(bucket) + 0.5) * logLatencyBucketRatio)))
+}
+
+// Breakdown splits the results of the attack per method and url
+type Breakdown struct {
+	requests uint64
+	targets  map[string]*target
+}
+
+// Add accounts a result to the target of its method and url
+func (b *Breakdown) Add(r result) {
+	key := r.Method + " " + r.URL
+	t, ok := b.targets[key]
+	if !ok {
+		t = &target{method: r.Method, url: r.URL, buckets: map[int]uint64{}}
+		b.targets[key] = t
+	}
+	if !r.failed() {
+		t.successes++
+	}
+	if t.requests == 0 || r.Latency < t.min {
+		t.min = r.Latency
+	}
+	if r.Latency > t.max {
+		t.max = r.Latency
+	}
+	t.requests++
+	t.total += r.Latency
+	t.buckets[bucketOf(r.Latency)]++
+	b.requests++
+}
+
+// Summaries returns the summaries of the targets, the slowest ones by 99th percentile first, limited to max entries
+func (b *Breakdown) Summaries(max int) []Summary {
+	summaries := make([]Summary, 0, len(b.targets))
+	for _, t := range b.targets {
+		summaries = append(summaries, t.summary())
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Latencies.P99 != summaries[j].Latencies.P99 {
+			return summaries[i].Latencies.P99 > summaries[j].Latencies.P99
+		}
+		if summaries[i].URL != summaries[j].URL {
+			return summaries[i].URL < summaries[j].URL
+		}
+		return summaries[i].Method < summaries[j].Method
+	})
+	if max > 0 && len(summaries) > max {
+		summaries = summaries[:max]
+	}
+	return summaries
+}
+
+// summary computes the summary of the results of the target
+func (t *target) summary() Summary {
+	s := Summary{
+		Method:   t.method,
+		URL:      t.url,
+		Requests: t.requests,
+	}
+	if s.Requests == 0 {
+		return s
+	}
+	s.Success = float64(t.successes) / float64(s.Requests)
+	s.Latencies.Total = t.total
+	s.Latencies.Mean = t.total / time.Duration(s.Requests)
+	buckets := make([]int, 0, len(t.buckets))
+	for b := range t.buckets {
+		buckets = append(buckets, b)
+	}
+	sort.Ints(buckets)
+	s.Latencies.P50 = t.quantile(buckets, 0.50)
+	s.Latencies.P90 = t.quantile(buckets, 0.90)
+	s.Latencies.P95 = t.quantile(buckets, 0.95)
+	s.Latencies.P99 = t.quantile(buckets, 0.99)
+	s.Latencies.Min = t.min
+	s.Latencies.Max = t.max
+	return s
+}
+
+// quantile returns the nearest rank quantile of the latencies from the sorted buckets of the histogram, kept between the minimum and the maximum
+func (t *target) quantile(buckets []int, q float64) time.Duration {
+	rank := uint64(math.Ceil(q * float64(t.requests)))
+	var count uint64
+	l := t.max
+	for _, b := range buckets {
+		count += t.buckets[b]
+		if count >= rank {
+			l = latencyOf(b)
+			break
+		}
+	}
+	if l < t.min {
+		return t.min
+	}
+	if l > t.max {
+		return t.max
+	}
+	return l
+}
+
+func main() {
+	var output string
+	var max int
+	flag.StringVar(&output, "output", "/tmp/vegeta-breakdown", "File the json record of the breakdown is written to")
+	flag.IntVar(&max, "max", 10, "Maximal number of targets in the record, the slowest ones are kept, 0 for all")
+	flag.Parse()
+
+	// The results are passed through unchanged so that the breakdown can be inserted in front of the report or the result file
+	b := &Breakdown{targets: map[string]*target{}}
+	in := bufio.NewReader(os.Stdin)
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for {
+		line, err := in.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, werr := out.Write(line); werr != nil {
+				log.Fatalln("Unable to write the results", werr)
+			}
+			var r result
+			if json.Unmarshal(line, &r) == nil && r.URL != "" {
+				b.Add(r)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if len(b.targets) == 0 {
+		return
+	}
+	record, _ := json.Marshal(struct {
+		Breakdown Record `json:"breakdown"`
+	}{Record{Requests: b.requests, Targets: b.Summaries(max)}})
+	if err := ioutil.WriteFile(output, append(record, '\n'), 0644); err != nil {
+		log.Println("Unable to write the breakdown record", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// results returns the results of n requests to the target with the latencies 1ms to n ms, every failedEvery request failing, none if 0
+func results(method, url string, n, failedEvery int) []result {
+	rs := make([]result, n)
+	for i := range rs {
+		rs[i] = result{Method: method, URL: url, Latency: time.Duration(i+1) * time.Millisecond, Code: 200}
+		if failedEvery > 0 && (i+1)%failedEvery == 0 {
+			rs[i].Code = 503
+		}
+	}
+	return rs
+}
+
+// merged returns the concatenation of the results
+func merged(rs ...[]result) []result {
+	var all []result
+	for _, r := range rs {
+		all = append(all, r...)
+	}
+	return all
+}
+
+// targets returns the method and url of the summaries
+func targets(summaries []Summary) []string {
+	keys := make([]string, len(summaries))
+	for i, s := range summaries {
+		keys[i] = s.Method + " " + s.URL
+	}
+	return keys
+}
+
+func TestSummaries(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name    string
+		results []result
+		max     int
+		// expected targets of the summaries, in order
+		targets []string
+		// expected summary of the first target, not checked if nil. The quantiles are expected within 1%.
+		first *Summary
+	}{
+		{
+			name:    "single target",
+			results: results("GET", "https://shop.example.com/items", 100, 0),
+			targets: []string{"GET https://shop.example.com/items"},
+			first: &Summary{Method: "GET", URL: "https://shop.example.com/items", Requests: 100, Success: 1,
+				Latencies: Latencies{Total: 5050 * ms, Mean: 50500 * time.Microsecond, P50: 50 * ms, P90: 90 * ms, P95: 95 * ms, P99: 99 * ms, Max: 100 * ms, Min: 1 * ms}},
+		},
+		{
+			name:    "failed requests",
+			results: merged(results("POST", "https://shop.example.com/orders", 10, 5), []result{{Method: "POST", URL: "https://shop.example.com/orders", Latency: 30 * ms, Error: "connection reset"}}),
+			targets: []string{"POST https://shop.example.com/orders"},
+			first: &Summary{Method: "POST", URL: "https://shop.example.com/orders", Requests: 11, Success: 8.0 / 11,
+				Latencies: Latencies{Total: 85 * ms, Mean: 85 * ms / 11, P50: 6 * ms, P90: 10 * ms, P95: 30 * ms, P99: 30 * ms, Max: 30 * ms, Min: 1 * ms}},
+		},
+		{
+			name: "slowest targets first",
+			results: merged(
+				results("GET", "https://shop.example.com/items", 10, 0),
+				results("GET", "https://shop.example.com/search", 50, 0),
+				results("PUT", "https://shop.example.com/items", 10, 0),
+				results("GET", "https://shop.example.com/cart", 10, 0)),
+			targets: []string{"GET https://shop.example.com/search", "GET https://shop.example.com/cart", "GET https://shop.example.com/items", "PUT https://shop.example.com/items"},
+		},
+		{
+			name: "limited to the slowest targets",
+			results: merged(
+				results("GET", "https://shop.example.com/items", 10, 0),
+				results("GET", "https://shop.example.com/search", 50, 0),
+				results("GET", "https://shop.example.com/cart", 20, 0)),
+			max:     2,
+			targets: []string{"GET https://shop.example.com/search", "GET https://shop.example.com/cart"},
+		},
+		{
+			name:    "no result",
+			targets: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Breakdown{targets: map[string]*target{}}
+			for _, r := range tt.results {
+				b.Add(r)
+			}
+			if b.requests != uint64(len(tt.results)) {
+				t.Errorf("%d requests accounted, expected %d", b.requests, len(tt.results))
+			}
+			summaries := b.Summaries(tt.max)
+			if got := targets(summaries); !reflect.DeepEqual(got, tt.targets) {
+				t.Fatalf("got targets %v, expected %v", got, tt.targets)
+			}
+			if tt.first == nil {
+				return
+			}
+			got, expected := summaries[0], *tt.first
+			for _, q := range []struct{ got, expected *time.Duration }{
+				{&got.Latencies.P50, &expected.Latencies.P50},
+				{&got.Latencies.P90, &expected.Latencies.P90},
+				{&got.Latencies.P95, &expected.Latencies.P95},
+				{&got.Latencies.P99, &expected.Latencies.P99},
+			} {
+				if math.Abs(float64(*q.got-*q.expected)) > 0.01*float64(*q.expected) {
+					t.Errorf("got quantile %s, expected %s within 1%%", *q.got, *q.expected)
+				}
+				*q.got = *q.expected
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("got summary %+v, expected %+v", got, expected)
+			}
+		})
+	}
+}
+
+func TestAddBoundsMemory(t *testing.T) {
+	b := &Breakdown{targets: map[string]*target{}}
+	// A million distinct latencies between 1µs and 1h
+	for i := 0; i < 1000000; i++ {
+		b.Add(result{Method: "GET", URL: "https://shop.example.com/items", Latency: time.Microsecond + time.Duration(i)*3600*time.Microsecond, Code: 200})
+	}
+	if n := len(b.targets["GET https://shop.example.com/items"].buckets); n > 2000 {
+		t.Errorf("%d buckets for the latencies, expected less than 2000", n)
+	}
+	s := b.Summaries(0)[0]
+	if s.Requests != 1000000 || s.Latencies.Min != time.Microsecond || s.Latencies.Max != time.Microsecond+999999*3600*time.Microsecond {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if p50 := 1800 * time.Second; math.Abs(float64(s.Latencies.P50-p50)) > 0.01*float64(p50) {
+		t.Errorf("got p50 %s, expected %s within 1%%", s.Latencies.P50, p50)
+	}
+}
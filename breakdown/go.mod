@@ -0,0 +1,3 @@
+module github.com/fgiloux/vegeta-operator/breakdown
+
+go 1.15
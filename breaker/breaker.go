@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// ErrorRatioExceeded is the reason recorded when the ratio of failed requests in the window is above the maximum
+	ErrorRatioExceeded = "ErrorRatioExceeded"
+	// P99Exceeded is the reason recorded when the 99th percentile of the latencies in the window is above the maximum
+	P99Exceeded = "P99Exceeded"
+)
+
+// result contains the fields of a vegeta json result the breaker needs
+type result struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Latency   time.Duration `json:"latency"`
+	Code      uint16        `json:"code"`
+	Error     string        `json:"error"`
+}
+
+// failed returns true if the request is not counted as a success by vegeta
+func (r *result) failed() bool {
+	return r.Error != "" || r.Code < 200 || r.Code >= 400
+}
+
+// Trip records the window that tripped the breaker. The json field names match the ones of the status of the vegeta resource.
+type Trip struct {
+	Reason      string    `json:"reason"`
+	Message     string    `json:"message"`
+	WindowStart time.Time `json:"windowStart"`
+	WindowEnd   time.Time `json:"windowEnd"`
+	Requests    int       `json:"requests"`
+	ErrorRatio  string    `json:"errorRatio"`
+	P99         string    `json:"p99"`
+}
+
+// Breaker evaluates the results of the attack over a sliding window
+type Breaker struct {
+	window        time.Duration
+	maxErrorRatio float64
+	maxP99        time.Duration
+	minRequests   int
+	// evaluation interval, in time of the results
+	interval time.Duration
+
+	results  []result
+	nextEval time.Time
+}
+
+// Add appends a result to the window and evaluates the window once per interval.
+// It returns the trip when one of the maximums has been exceeded.
+func (b *Breaker) Add(r result) *Trip {
+	b.results = append(b.results, r)
+	if b.nextEval.IsZero() {
+		b.nextEval = r.Timestamp.Add(b.interval)
+	}
+	if r.Timestamp.Before(b.nextEval) {
+		return nil
+	}
+	b.nextEval = r.Timestamp.Add(b.interval)
+	return b.evaluate(r.Timestamp)
+}
+
+// evaluate drops the results that are out of the window ending at end and checks the remaining ones
+func (b *Breaker) evaluate(end time.Time) *Trip {
+	start := end.Add(-b.window)
+	i := 0
+	for i < len(b.results) && b.results[i].Timestamp.Before(start) {
+		i++
+	}
+	b.results = b.results[i:]
+	if len(b.results) < b.minRequests || len(b.results) == 0 {
+		return nil
+	}
+
+	failed := 0
+	latencies := make([]time.Duration, 0, len(b.results))
+	for i := range b.results {
+		if b.results[i].failed() {
+			failed++
+		}
+		latencies = append(latencies, b.results[i].Latency)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p99 := latencies[(len(latencies)*99-1)/100]
+	ratio := float64(failed) / float64(len(b.results))
+
+	trip := &Trip{
+		WindowStart: start,
+		WindowEnd:   end,
+		Requests:    len(b.results),
+		ErrorRatio:  formatRatio(ratio),
+		P99:         p99.String(),
+	}
+	switch {
+	case b.maxErrorRatio > 0 && ratio > b.maxErrorRatio:
+		trip.Reason = ErrorRatioExceeded
+		trip.Message = fmt.Sprintf("The error ratio %s of the %d requests of the last %s is above %s", trip.ErrorRatio, trip.Requests, b.window, formatRatio(b.maxErrorRatio))
+	case b.maxP99 > 0 && p99 > b.maxP99:
+		trip.Reason = P99Exceeded
+		trip.Message = fmt.Sprintf("The p99 latency %s of the %d requests of the last %s is above %s", trip.P99, trip.Requests, b.window, b.maxP99)
+	default:
+		return nil
+	}
+	return trip
+}
+
+// formatRatio formats a ratio as a percentage with at most two decimals
+func formatRatio(r float64) string {
+	return strconv.FormatFloat(math.Round(r*10000)/100, 'f', -1, 64) + "%"
+}
+
+func main() {
+	b := &Breaker{interval: time.Second}
+	var tripFile, marker string
+	flag.DurationVar(&b.window, "window", 10*time.Second, "Duration of the sliding window the results are evaluated over")
+	flag.Float64Var(&b.maxErrorRatio, "max-error-ratio", 0, "Ratio of failed requests in the window above which the breaker trips, 0 to disable")
+	flag.DurationVar(&b.maxP99, "max-p99", 0, "99th percentile of the latencies in the window above which the breaker trips, 0 to disable")
+	flag.IntVar(&b.minRequests, "min-requests", 10, "Minimal number of requests in the window for it to be evaluated")
+	flag.StringVar(&tripFile, "trip-file", "/tmp/vegeta-breaker-trip", "File the json record of the trip is written to")
+	flag.StringVar(&marker, "marker", "/tmp/vegeta-aborted", "File created when the breaker trips to get the attack interrupted")
+	flag.Parse()
+	if b.window <= 0 {
+		log.Fatalln("The window needs to be a positive duration")
+	}
+
+	// The results are passed through unchanged so that the breaker can be inserted in front of the report or the result file
+	in := bufio.NewReader(os.Stdin)
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	tripped := false
+	for {
+		line, err := in.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, werr := out.Write(line); werr != nil {
+				log.Fatalln("Unable to write the results", werr)
+			}
+			var r result
+			if !tripped && json.Unmarshal(line, &r) == nil {
+				if trip := b.Add(r); trip != nil {
+					tripped = true
+					log.Println("Circuit breaker tripped:", trip.Message)
+					record, _ := json.Marshal(struct {
+						CircuitBreaker *Trip `json:"circuitBreaker"`
+					}{trip})
+					if err := ioutil.WriteFile(tripFile, append(record, '\n'), 0644); err != nil {
+						log.Println("Unable to write the trip record", err)
+					}
+					// The abort watcher of the attack container interrupts the attacks once the marker exists
+					if err := ioutil.WriteFile(marker, nil, 0644); err != nil {
+						log.Println("Unable to create the abort marker", err)
+					}
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+// series returns n results, one every 250ms from start, with the given status code and latency
+func series(n int, code func(i int) uint16, latency time.Duration) []result {
+	results := make([]result, n)
+	for i := range results {
+		results[i] = result{Timestamp: start.Add(time.Duration(i) * 250 * time.Millisecond), Latency: latency, Code: code(i)}
+	}
+	return results
+}
+
+func ok(int) uint16 { return 200 }
+
+// halfFailed fails every second request
+func halfFailed(i int) uint16 {
+	if i%2 == 1 {
+		return 500
+	}
+	return 200
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		breaker Breaker
+		results []result
+		// expected trip, nil if the breaker is not expected to trip
+		trip *Trip
+	}{
+		{
+			name:    "healthy results",
+			breaker: Breaker{window: 5 * time.Second, maxErrorRatio: 0.05, maxP99: 100 * time.Millisecond, minRequests: 10},
+			results: series(40, ok, 10*time.Millisecond),
+		},
+		{
+			// The window is first evaluated with enough requests after 3s, 13 requests of which 6 have failed
+			name:    "error ratio exceeded",
+			breaker: Breaker{window: 5 * time.Second, maxErrorRatio: 0.05, minRequests: 10},
+			results: series(40, halfFailed, 10*time.Millisecond),
+			trip: &Trip{Reason: ErrorRatioExceeded, Message: "The error ratio 46.15% of the 13 requests of the last 5s is above 5%",
+				WindowStart: start.Add(-2 * time.Second), WindowEnd: start.Add(3 * time.Second), Requests: 13, ErrorRatio: "46.15%", P99: "10ms"},
+		},
+		{
+			name:    "p99 exceeded",
+			breaker: Breaker{window: 5 * time.Second, maxP99: 100 * time.Millisecond, minRequests: 10},
+			results: series(40, ok, 200*time.Millisecond),
+			trip: &Trip{Reason: P99Exceeded, Message: "The p99 latency 200ms of the 13 requests of the last 5s is above 100ms",
+				WindowStart: start.Add(-2 * time.Second), WindowEnd: start.Add(3 * time.Second), Requests: 13, ErrorRatio: "0%", P99: "200ms"},
+		},
+		{
+			name:    "error ratio reported before the p99",
+			breaker: Breaker{window: 5 * time.Second, maxErrorRatio: 0.05, maxP99: 100 * time.Millisecond, minRequests: 10},
+			results: series(40, halfFailed, 200*time.Millisecond),
+			trip: &Trip{Reason: ErrorRatioExceeded, Message: "The error ratio 46.15% of the 13 requests of the last 5s is above 5%",
+				WindowStart: start.Add(-2 * time.Second), WindowEnd: start.Add(3 * time.Second), Requests: 13, ErrorRatio: "46.15%", P99: "200ms"},
+		},
+		{
+			name:    "not enough requests in the window",
+			breaker: Breaker{window: 5 * time.Second, maxErrorRatio: 0.05, minRequests: 100},
+			results: series(40, halfFailed, 10*time.Millisecond),
+		},
+		{
+			name:    "maximums disabled",
+			breaker: Breaker{window: 5 * time.Second, minRequests: 10},
+			results: series(40, halfFailed, 200*time.Millisecond),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.breaker
+			b.interval = time.Second
+			var trip *Trip
+			for _, r := range tt.results {
+				if trip = b.Add(r); trip != nil {
+					break
+				}
+			}
+			switch {
+			case tt.trip == nil && trip != nil:
+				t.Fatalf("unexpected trip: %+v", trip)
+			case tt.trip != nil && trip == nil:
+				t.Fatalf("the breaker did not trip, expected: %+v", tt.trip)
+			case tt.trip != nil && *trip != *tt.trip:
+				t.Errorf("got trip %+v, expected %+v", trip, tt.trip)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	failed := result{Timestamp: start, Latency: time.Millisecond, Error: "connection refused"}
+	tests := []struct {
+		name    string
+		results []result
+		end     time.Time
+		// expected number of results kept in the window
+		kept    int
+		tripped bool
+	}{
+		{name: "transport errors are failures", results: []result{failed, failed, failed, {Timestamp: start, Code: 200}}, end: start, kept: 4, tripped: true},
+		{name: "redirections are successes", results: []result{{Timestamp: start, Code: 302}, {Timestamp: start, Code: 304}}, end: start, kept: 2},
+		{name: "results out of the window are dropped", results: append([]result{failed, failed, failed}, series(4, ok, time.Millisecond)[1:]...), end: start.Add(2100 * time.Millisecond), kept: 3},
+		{name: "empty window", results: []result{failed}, end: start.Add(time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Breaker{window: 2 * time.Second, maxErrorRatio: 0.5, minRequests: 1, results: tt.results}
+			trip := b.evaluate(tt.end)
+			if len(b.results) != tt.kept {
+				t.Errorf("%d results kept in the window, expected %d", len(b.results), tt.kept)
+			}
+			if (trip != nil) != tt.tripped {
+				t.Errorf("tripped: %v, expected: %v", trip != nil, tt.tripped)
+			}
+		})
+	}
+}
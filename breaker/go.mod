@@ -0,0 +1,3 @@
+module github.com/fgiloux/vegeta-operator/breaker
+
+go 1.15
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// target is a vegeta target in the json format
+type target struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Body   []byte      `json:"body,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+}
+
+// headers collects the values of the repeated -header flag
+type headers []string
+
+func (h *headers) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headers) Set(value string) error {
+	*h = append(*h, value)
+	return nil
+}
+
+// Generator renders the targets from the templates of the request
+type Generator struct {
+	method  string
+	url     *template.Template
+	headers []*template.Template
+	body    *template.Template
+	rnd     *rand.Rand
+}
+
+// NewGenerator parses the templates of the request. A field of a row missing in the dataset is an error rather than an empty value.
+func NewGenerator(method, url string, headerTemplates []string, body string, seed int64) (*Generator, error) {
+	g := &Generator{method: method, rnd: rand.New(rand.NewSource(seed))}
+	parse := func(name, text string) (*template.Template, error) {
+		t, err := template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+			"uuid":    g.uuid,
+			"randInt": g.randInt,
+		}).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s template: %v", name, err)
+		}
+		return t, nil
+	}
+	var err error
+	if g.url, err = parse("url", url); err != nil {
+		return nil, err
+	}
+	for _, h := range headerTemplates {
+		t, err := parse("header", h)
+		if err != nil {
+			return nil, err
+		}
+		g.headers = append(g.headers, t)
+	}
+	if body != "" {
+		if g.body, err = parse("body", body); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// Render renders the target for a row of the dataset
+func (g *Generator) Render(row map[string]interface{}) (*target, error) {
+	var buf bytes.Buffer
+	if err := g.url.Execute(&buf, row); err != nil {
+		return nil, err
+	}
+	t := &target{Method: g.method, URL: buf.String()}
+	for _, h := range g.headers {
+		buf.Reset()
+		if err := h.Execute(&buf, row); err != nil {
+			return nil, err
+		}
+		kv := strings.SplitN(buf.String(), ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("The header %q doesn't match the Key: Value format", buf.String())
+		}
+		if t.Header == nil {
+			t.Header = http.Header{}
+		}
+		t.Header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	if g.body != nil {
+		buf.Reset()
+		if err := g.body.Execute(&buf, row); err != nil {
+			return nil, err
+		}
+		t.Body = append([]byte(nil), buf.Bytes()...)
+	}
+	return t, nil
+}
+
+// uuid returns a random version 4 UUID
+func (g *Generator) uuid() string {
+	var b [16]byte
+	g.rnd.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// randInt returns a random integer between min and max included
+func (g *Generator) randInt(min, max int) int {
+	if max < min {
+		min, max = max, min
+	}
+	return min + g.rnd.Intn(max-min+1)
+}
+
+// Rows reads the rows of a dataset one after the other. Next returns io.EOF once the dataset is exhausted.
+type Rows interface {
+	Next() (map[string]interface{}, error)
+}
+
+// csvRows reads a dataset in the csv format, whose first line names the fields
+type csvRows struct {
+	r      *csv.Reader
+	fields []string
+}
+
+func newCSVRows(in io.Reader) (*csvRows, error) {
+	r := csv.NewReader(in)
+	fields, err := r.Read()
+	if err == io.EOF {
+		return &csvRows{r: r}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the header of the dataset: %v", err)
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	r.ReuseRecord = true
+	return &csvRows{r: r, fields: fields}, nil
+}
+
+func (c *csvRows) Next() (map[string]interface{}, error) {
+	if c.fields == nil {
+		return nil, io.EOF
+	}
+	record, err := c.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	row := make(map[string]interface{}, len(c.fields))
+	for i, f := range c.fields {
+		row[f] = record[i]
+	}
+	return row, nil
+}
+
+// jsonRows reads a dataset made of json objects, either in an array or one after the other, e.g. one per line
+type jsonRows struct {
+	dec   *json.Decoder
+	array bool
+}
+
+func newJSONRows(in io.Reader) (*jsonRows, error) {
+	br := bufio.NewReader(in)
+	j := &jsonRows{}
+	for {
+		c, err := br.Peek(1)
+		if err != nil || !strings.ContainsRune(" \t\r\n", rune(c[0])) {
+			j.array = err == nil && c[0] == '['
+			break
+		}
+		br.ReadByte()
+	}
+	j.dec = json.NewDecoder(br)
+	// Numbers are rendered as they are written in the dataset
+	j.dec.UseNumber()
+	if j.array {
+		// Skip the opening bracket
+		if _, err := j.dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+func (j *jsonRows) Next() (map[string]interface{}, error) {
+	if j.array && !j.dec.More() {
+		return nil, io.EOF
+	}
+	row := map[string]interface{}{}
+	if err := j.dec.Decode(&row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// openDataset opens the dataset in the given format, derived from the extension of the file when empty
+func openDataset(file, format string) (Rows, io.Closer, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if format == "" {
+		format = "csv"
+		switch filepath.Ext(file) {
+		case ".json", ".jsonl", ".ndjson":
+			format = "json"
+		}
+	}
+	var rows Rows
+	switch format {
+	case "csv":
+		rows, err = newCSVRows(f)
+	case "json":
+		rows, err = newJSONRows(f)
+	default:
+		err = fmt.Errorf("Unsupported dataset format %s", format)
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return rows, f, nil
+}
+
+func main() {
+	var method, url, body, dataset, format string
+	var once bool
+	var replica, replicas int
+	var headerTemplates headers
+	flag.StringVar(&method, "method", http.MethodGet, "HTTP method of the requests")
+	flag.StringVar(&url, "url", "", "Template of the url of the requests")
+	flag.Var(&headerTemplates, "header", "Template of a request header with the Key: Value format, can be repeated")
+	flag.StringVar(&body, "body", "", "Template of the body of the requests")
+	flag.StringVar(&dataset, "dataset", "", "File containing the rows feeding the templates")
+	flag.StringVar(&format, "format", "", "Format of the dataset, csv or json, derived from the extension of the file when not specified")
+	flag.BoolVar(&once, "once", false, "Use every row of the dataset once instead of starting again with the first row once it is exhausted")
+	flag.IntVar(&replica, "replica", 0, "Index of the replica, starting from 0, which only uses its share of the rows")
+	flag.IntVar(&replicas, "replicas", 1, "Number of replicas the rows are divided across")
+	flag.Parse()
+	if url == "" {
+		log.Fatalln("The url template needs to be specified")
+	}
+	if replicas < 1 || replica < 0 || replica >= replicas {
+		log.Fatalln("The replica needs to be between 0 and the number of replicas excluded")
+	}
+
+	g, err := NewGenerator(method, url, headerTemplates, body, time.Now().UnixNano()+int64(replica))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+	// The targets are written until vegeta stops reading them, which closes the pipe
+	emit := func(row map[string]interface{}) {
+		t, err := g.Render(row)
+		if err != nil {
+			out.Flush()
+			log.Fatalln("Unable to render the target", err)
+		}
+		if err := enc.Encode(t); err != nil {
+			log.Fatalln("Unable to write the target", err)
+		}
+	}
+
+	if dataset == "" {
+		for {
+			emit(nil)
+		}
+	}
+	for {
+		rows, f, err := openDataset(dataset, format)
+		if err != nil {
+			out.Flush()
+			log.Fatalln("Unable to open the dataset", err)
+		}
+		emitted := false
+		for i := 0; ; i++ {
+			row, err := rows.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				out.Flush()
+				log.Fatalln("Unable to read the dataset", err)
+			}
+			if i%replicas != replica {
+				continue
+			}
+			emit(row)
+			emitted = true
+		}
+		f.Close()
+		if once {
+			return
+		}
+		if !emitted {
+			out.Flush()
+			log.Fatalln("The dataset has no row for this replica")
+		}
+	}
+}
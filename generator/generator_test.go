@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	user := map[string]interface{}{"userId": "42", "quantity": json.Number("3")}
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		headers []string
+		body    string
+		row     map[string]interface{}
+		// expected target, the body is matched against a regular expression when bodyPattern is set
+		target      *target
+		bodyPattern string
+		// expected error, empty if none
+		err string
+	}{
+		{
+			name:   "fields of the row",
+			method: http.MethodPost, url: "https://api.example.com/users/{{.userId}}/orders", headers: []string{"Content-Type: application/json", "X-User:{{.userId}}"},
+			body: `{"quantity": {{.quantity}}}`, row: user,
+			target: &target{Method: http.MethodPost, URL: "https://api.example.com/users/42/orders",
+				Header: http.Header{"Content-Type": {"application/json"}, "X-User": {"42"}}, Body: []byte(`{"quantity": 3}`)},
+		},
+		{
+			name:   "without body",
+			method: http.MethodGet, url: "https://api.example.com/users/{{.userId}}", row: user,
+			target: &target{Method: http.MethodGet, URL: "https://api.example.com/users/42"},
+		},
+		{
+			name:   "functions without dataset",
+			method: http.MethodPut, url: "https://api.example.com/items/{{randInt 5 5}}", body: `{"id": "{{uuid}}"}`,
+			target:      &target{Method: http.MethodPut, URL: "https://api.example.com/items/5"},
+			bodyPattern: `^\{"id": "[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}"\}$`,
+		},
+		{
+			name:   "missing field",
+			method: http.MethodGet, url: "https://api.example.com/users/{{.userId}}/orders/{{.orderId}}", row: user,
+			err: `map has no entry for key "orderId"`,
+		},
+		{
+			name:   "header without value",
+			method: http.MethodGet, url: "https://api.example.com/users", headers: []string{"X-User {{.userId}}"}, row: user,
+			err: `The header "X-User 42" doesn't match the Key: Value format`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := NewGenerator(tt.method, tt.url, tt.headers, tt.body, 1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := g.Render(tt.row)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, expected %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.bodyPattern != "" {
+				if !regexp.MustCompile(tt.bodyPattern).Match(got.Body) {
+					t.Errorf("got body %s, expected to match %s", got.Body, tt.bodyPattern)
+				}
+				got.Body = nil
+			}
+			if !reflect.DeepEqual(got, tt.target) {
+				t.Errorf("got target %+v, expected %+v", got, tt.target)
+			}
+		})
+	}
+}
+
+func TestNewGenerator(t *testing.T) {
+	if _, err := NewGenerator(http.MethodGet, "https://api.example.com/users/{{.userId", nil, "", 1); err == nil || !strings.HasPrefix(err.Error(), "Invalid url template") {
+		t.Errorf("got error %v, expected an invalid url template", err)
+	}
+	if _, err := NewGenerator(http.MethodGet, "https://api.example.com/users", nil, "{{unknown}}", 1); err == nil || !strings.HasPrefix(err.Error(), "Invalid body template") {
+		t.Errorf("got error %v, expected an invalid body template", err)
+	}
+}
+
+func TestRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		dataset string
+		rows    []map[string]interface{}
+		// expected error when reading the rows, empty if none
+		err string
+	}{
+		{
+			name: "csv", format: "csv", dataset: "userId, name\n42,Alice\n43,\"Bob, Jr\"\n",
+			rows: []map[string]interface{}{{"userId": "42", "name": "Alice"}, {"userId": "43", "name": "Bob, Jr"}},
+		},
+		{name: "csv header only", format: "csv", dataset: "userId,name\n"},
+		{name: "empty csv", format: "csv"},
+		{
+			name: "csv row with a missing field", format: "csv", dataset: "userId,name\n42\n",
+			err: "wrong number of fields",
+		},
+		{
+			name: "json array", format: "json", dataset: "\n [{\"userId\": 42, \"price\": 9.90}, {\"userId\": \"43\", \"tags\": [\"new\"]}]",
+			rows: []map[string]interface{}{{"userId": json.Number("42"), "price": json.Number("9.90")}, {"userId": "43", "tags": []interface{}{"new"}}},
+		},
+		{
+			name: "json lines", format: "json", dataset: "{\"userId\": 42}\n{\"userId\": 43}\n",
+			rows: []map[string]interface{}{{"userId": json.Number("42")}, {"userId": json.Number("43")}},
+		},
+		{name: "empty json", format: "json"},
+		{name: "empty json array", format: "json", dataset: "[]"},
+		{
+			name: "invalid json", format: "json", dataset: "{\"userId\": 42}\n{\"userId\": \n",
+			rows: []map[string]interface{}{{"userId": json.Number("42")}}, err: "unexpected EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows Rows
+			var err error
+			if tt.format == "csv" {
+				rows, err = newCSVRows(strings.NewReader(tt.dataset))
+			} else {
+				rows, err = newJSONRows(strings.NewReader(tt.dataset))
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []map[string]interface{}
+			for {
+				row, err := rows.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if tt.err == "" || !strings.Contains(err.Error(), tt.err) {
+						t.Fatalf("got error %v, expected %q", err, tt.err)
+					}
+					tt.err = ""
+					break
+				}
+				got = append(got, row)
+			}
+			if tt.err != "" {
+				t.Errorf("expected error %q", tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.rows) {
+				t.Errorf("got rows %v, expected %v", got, tt.rows)
+			}
+		})
+	}
+}
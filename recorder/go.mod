@@ -0,0 +1,3 @@
+module github.com/fgiloux/vegeta-operator/recorder
+
+go 1.15
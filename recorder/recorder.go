@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// Recorder assembles the records read by the operator from the termination message of the container within its size limit
+type Recorder struct {
+	// maximal size of the termination message
+	maxBytes int
+	// maximal number of distinct errors kept in the report
+	maxErrors int
+	// maximal length of an error of the report
+	maxErrorLength int
+}
+
+// unusedReportFields are the fields of the vegeta json report the operator does not read, which are dropped to save space
+var unusedReportFields = []string{"earliest", "latest", "end"}
+
+// Record returns the records in the order they are decoded by the operator: the report, the record of the trip of the circuit breaker, the record of the load profile and the breakdown.
+// Each of them is optional. The report takes precedence: its errors are trimmed first, then the trip and the load profile are left out and the breakdown loses its last targets so that the records fit into maxBytes.
+func (rec *Recorder) Record(report, trip, profile, breakdown []byte) ([]byte, error) {
+	var out bytes.Buffer
+	if len(bytes.TrimSpace(report)) > 0 {
+		compact, err := rec.compactReport(report, rec.maxErrors)
+		if err != nil {
+			return nil, err
+		}
+		// Errors are dropped one after the other until the report fits
+		for errors := rec.maxErrors - 1; len(compact) > rec.maxBytes && errors >= 0; errors-- {
+			if compact, err = rec.compactReport(report, errors); err != nil {
+				return nil, err
+			}
+		}
+		if len(compact) > rec.maxBytes {
+			return nil, fmt.Errorf("the report of %d bytes exceeds the maximum of %d bytes", len(compact), rec.maxBytes)
+		}
+		out.Write(compact)
+	}
+	if record := compactRecord(trip); record != nil {
+		if out.Len()+len(record) <= rec.maxBytes {
+			out.Write(record)
+		} else {
+			log.Println("The record of the trip of the circuit breaker has been left out, it exceeds the size of the termination message")
+		}
+	}
+	if record := compactRecord(profile); record != nil {
+		if out.Len()+len(record) <= rec.maxBytes {
+			out.Write(record)
+		} else {
+			log.Println("The record of the load profile has been left out, it exceeds the size of the termination message")
+		}
+	}
+	if len(bytes.TrimSpace(breakdown)) > 0 {
+		record, err := fitBreakdown(breakdown, rec.maxBytes-out.Len())
+		if err != nil {
+			log.Println("The breakdown has been left out:", err)
+		} else {
+			out.Write(record)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// compactReport removes the unused fields from a vegeta json report and keeps at most maxErrors errors, truncated to maxErrorLength
+func (rec *Recorder) compactReport(report []byte, maxErrors int) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(report, &fields); err != nil {
+		return nil, fmt.Errorf("unable to parse the report: %v", err)
+	}
+	for _, f := range unusedReportFields {
+		delete(fields, f)
+	}
+	var errors []string
+	if raw, ok := fields["errors"]; ok {
+		if err := json.Unmarshal(raw, &errors); err != nil {
+			return nil, fmt.Errorf("unable to parse the errors of the report: %v", err)
+		}
+	}
+	if len(errors) > maxErrors {
+		errors = errors[:maxErrors]
+	}
+	for i, e := range errors {
+		if len(e) > rec.maxErrorLength {
+			errors[i] = e[:rec.maxErrorLength]
+		}
+	}
+	if errors == nil {
+		// vegeta always writes the list of errors, even when empty
+		errors = []string{}
+	}
+	fields["errors"], _ = json.Marshal(errors)
+	compact, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return append(compact, '\n'), nil
+}
+
+// compactRecord re-encodes a json record on a single line, nil if it is empty or invalid
+func compactRecord(record []byte) []byte {
+	if len(bytes.TrimSpace(record)) == 0 {
+		return nil
+	}
+	var out bytes.Buffer
+	if err := json.Compact(&out, bytes.TrimSpace(record)); err != nil {
+		log.Println("Invalid record left out:", err)
+		return nil
+	}
+	out.WriteByte('\n')
+	return out.Bytes()
+}
+
+// fitBreakdown drops the last targets of the breakdown record, the fastest ones, until it fits into budget bytes.
+// The number of targets left out is recorded so that the operator knows that the breakdown is partial.
+func fitBreakdown(breakdown []byte, budget int) ([]byte, error) {
+	var record struct {
+		Breakdown map[string]json.RawMessage `json:"breakdown"`
+	}
+	if err := json.Unmarshal(breakdown, &record); err != nil || record.Breakdown == nil {
+		return nil, fmt.Errorf("invalid breakdown record")
+	}
+	var targets []json.RawMessage
+	if raw, ok := record.Breakdown["targets"]; ok {
+		if err := json.Unmarshal(raw, &targets); err != nil {
+			return nil, fmt.Errorf("invalid targets of the breakdown: %v", err)
+		}
+	}
+	var omitted int
+	if raw, ok := record.Breakdown["omitted"]; ok {
+		json.Unmarshal(raw, &omitted)
+	}
+	for kept := len(targets); kept >= 0; kept-- {
+		record.Breakdown["targets"], _ = json.Marshal(targets[:kept])
+		if dropped := omitted + len(targets) - kept; dropped > 0 {
+			record.Breakdown["omitted"], _ = json.Marshal(dropped)
+		}
+		encoded, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		if len(encoded)+1 <= budget {
+			return append(encoded, '\n'), nil
+		}
+	}
+	return nil, fmt.Errorf("it exceeds the %d bytes left in the termination message", budget)
+}
+
+// readOptional reads a file, which may not exist
+func readOptional(name string) []byte {
+	if name == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("Unable to read", name, err)
+	}
+	return data
+}
+
+func main() {
+	rec := &Recorder{}
+	var output, report, trip, profile, breakdown string
+	flag.StringVar(&output, "output", "/dev/termination-log", "File the records are written to")
+	flag.IntVar(&rec.maxBytes, "max-bytes", 4096, "Maximal size of the records")
+	flag.IntVar(&rec.maxErrors, "max-errors", 10, "Maximal number of errors kept in the report")
+	flag.IntVar(&rec.maxErrorLength, "max-error-length", 200, "Maximal length of an error kept in the report")
+	flag.StringVar(&report, "report", "", "File containing the report generated by vegeta report -type json")
+	flag.StringVar(&trip, "trip", "", "File containing the record of the trip of the circuit breaker")
+	flag.StringVar(&profile, "profile", "", "File containing the record of the load profile")
+	flag.StringVar(&breakdown, "breakdown", "", "File containing the record of the breakdown")
+	flag.Parse()
+
+	records, err := rec.Record(readOptional(report), readOptional(trip), readOptional(profile), readOptional(breakdown))
+	if err != nil {
+		log.Fatalln("Unable to assemble the records:", err)
+	}
+	if len(records) == 0 {
+		return
+	}
+	if err := ioutil.WriteFile(output, records, 0644); err != nil {
+		log.Fatalln("Unable to write the records", err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// report returns a vegeta json report with the given number of distinct errors
+func report(errors int) []byte {
+	errs := make([]string, errors)
+	for i := range errs {
+		errs[i] = fmt.Sprintf("Get \"https://api.example.com/users/%d\": dial tcp 10.0.0.%d:443: connect: connection refused", i, i%256)
+	}
+	encoded, _ := json.Marshal(errs)
+	return []byte(`{"latencies":{"total":2500000000,"mean":5000000,"50th":4000000,"90th":8000000,"95th":10000000,"99th":12000000,"max":20000000,"min":1000000},` +
+		`"bytes_in":{"total":10000,"mean":20},"bytes_out":{"total":0,"mean":0},` +
+		`"earliest":"2021-03-01T10:00:00Z","latest":"2021-03-01T10:00:10Z","end":"2021-03-01T10:00:10.005Z",` +
+		`"duration":10000000000,"wait":5000000,"requests":500,"rate":50.0,"throughput":49.87,"success":0.998,` +
+		`"status_codes":{"0":` + fmt.Sprint(errors) + `,"200":499},"errors":` + string(encoded) + "}\n")
+}
+
+const tripRecord = `{"circuitBreaker":{"reason":"ErrorRatioExceeded","message":"The error ratio 50% of the 20 requests of the last 5s is above 5%","windowStart":"2021-03-01T10:00:00Z","windowEnd":"2021-03-01T10:00:05Z","requests":20,"errorRatio":"50%","p99":"10ms"}}` + "\n"
+
+const profileRecord = `{"loadProfile":{"drift":"1250ms"}}` + "\n"
+
+// breakdown returns a breakdown record with the given number of targets
+func breakdown(targets int) []byte {
+	var sb strings.Builder
+	sb.WriteString(`{"breakdown":{"requests":1000,"targets":[`)
+	for i := 0; i < targets; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, `{"method":"GET","url":"https://10.0.0.%d:8443/api/v1/users","requests":100,"success":1,"latencies":{"total":500000000,"mean":5000000,"50th":4000000,"90th":8000000,"95th":10000000,"99th":12000000,"max":20000000,"min":1000000}}`, i)
+	}
+	sb.WriteString("]}}\n")
+	return []byte(sb.String())
+}
+
+// decoded decodes the sequence of json documents of the records
+func decoded(t *testing.T, records []byte) []map[string]json.RawMessage {
+	var docs []map[string]json.RawMessage
+	dec := json.NewDecoder(strings.NewReader(string(records)))
+	for dec.More() {
+		doc := map[string]json.RawMessage{}
+		if err := dec.Decode(&doc); err != nil {
+			t.Fatalf("records cannot be decoded: %v\n%s", err, records)
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+func TestRecord(t *testing.T) {
+	tests := []struct {
+		name      string
+		report    []byte
+		trip      []byte
+		profile   []byte
+		breakdown []byte
+		// expected number of errors in the report, -1 if no report is expected
+		errors  int
+		tripped bool
+		drifted bool
+		// expected number of targets in the breakdown and targets reported as omitted, -1 if no breakdown is expected
+		targets int
+		omitted int
+	}{
+		{name: "small report", report: report(2), errors: 2, targets: -1},
+		{name: "report with many errors", report: report(200), errors: 10, targets: -1},
+		{name: "report, trip and breakdown", report: report(1), trip: []byte(tripRecord), breakdown: breakdown(3), errors: 1, tripped: true, targets: 3},
+		{name: "breakdown trimmed to fit", report: report(200), trip: []byte(tripRecord), breakdown: breakdown(16), errors: 10, tripped: true, targets: 11, omitted: 5},
+		{name: "report, load profile and breakdown", report: report(1), profile: []byte(profileRecord), breakdown: breakdown(3), errors: 1, drifted: true, targets: 3},
+		{name: "trip and breakdown without report", trip: []byte(tripRecord), breakdown: breakdown(2), errors: -1, tripped: true, targets: 2},
+		{name: "nothing to record", errors: -1, targets: -1},
+	}
+	rec := &Recorder{maxBytes: 4096, maxErrors: 10, maxErrorLength: 200}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := rec.Record(tt.report, tt.trip, tt.profile, tt.breakdown)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(records) > rec.maxBytes {
+				t.Fatalf("records of %d bytes exceed %d bytes", len(records), rec.maxBytes)
+			}
+			docs := decoded(t, records)
+			i := 0
+			if tt.errors >= 0 {
+				var r struct {
+					Requests uint64   `json:"requests"`
+					Errors   []string `json:"errors"`
+				}
+				if i >= len(docs) {
+					t.Fatalf("missing report in %s", records)
+				}
+				raw, _ := json.Marshal(docs[i])
+				json.Unmarshal(raw, &r)
+				if r.Requests != 500 || len(r.Errors) != tt.errors {
+					t.Errorf("got a report with %d requests and %d errors, expected 500 requests and %d errors", r.Requests, len(r.Errors), tt.errors)
+				}
+				if _, ok := docs[i]["earliest"]; ok {
+					t.Errorf("unused fields are kept in the report")
+				}
+				i++
+			}
+			if tt.tripped {
+				if i >= len(docs) || docs[i]["circuitBreaker"] == nil {
+					t.Fatalf("missing trip in %s", records)
+				}
+				i++
+			}
+			if tt.drifted {
+				if i >= len(docs) || docs[i]["loadProfile"] == nil {
+					t.Fatalf("missing load profile in %s", records)
+				}
+				i++
+			}
+			if tt.targets >= 0 {
+				var b struct {
+					Targets []json.RawMessage `json:"targets"`
+					Omitted int               `json:"omitted"`
+				}
+				if i >= len(docs) || docs[i]["breakdown"] == nil {
+					t.Fatalf("missing breakdown in %s", records)
+				}
+				json.Unmarshal(docs[i]["breakdown"], &b)
+				if len(b.Targets) != tt.targets || b.Omitted != tt.omitted {
+					t.Errorf("got %d targets and %d omitted, expected %d and %d", len(b.Targets), b.Omitted, tt.targets, tt.omitted)
+				}
+				i++
+			}
+			if i != len(docs) {
+				t.Errorf("got %d records, expected %d", len(docs), i)
+			}
+		})
+	}
+}
+
+func TestRecordErrors(t *testing.T) {
+	rec := &Recorder{maxBytes: 4096, maxErrors: 10, maxErrorLength: 20}
+	long := []byte(strings.Replace(string(report(1)), "connection refused", strings.Repeat("x", 5000), 1))
+	if len(long) <= 4096 {
+		t.Fatalf("the test report should exceed 4096 bytes")
+	}
+	records, err := rec.Record(long, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var r struct {
+		Errors []string `json:"errors"`
+	}
+	if err := json.Unmarshal(records, &r); err != nil || len(r.Errors) != 1 || len(r.Errors[0]) != 20 {
+		t.Errorf("expected one error truncated to 20 characters, got %v (%v)", r.Errors, err)
+	}
+
+	// Errors are dropped when the report does not fit otherwise
+	rec = &Recorder{maxBytes: 700, maxErrors: 10, maxErrorLength: 200}
+	if records, err = rec.Record(report(10), nil, nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) > 700 {
+		t.Errorf("records of %d bytes exceed 700 bytes", len(records))
+	}
+
+	if _, err := rec.Record([]byte(`{"requests":`), nil, nil, nil); err == nil {
+		t.Errorf("expected an error for a truncated report")
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metrics that thresholds can be defined for
+const (
+	// Latency metrics, whose values are durations
+	MinMetric  = "min"
+	MeanMetric = "mean"
+	P50Metric  = "p50"
+	P90Metric  = "p90"
+	P95Metric  = "p95"
+	P99Metric  = "p99"
+	MaxMetric  = "max"
+	// SuccessMetric is the ratio of successful requests
+	SuccessMetric = "success"
+	// StatusMetric is the ratio of responses with a status code or class, e.g. 503 or 5xx
+	StatusMetric = "status"
+	// ThroughputMetric is the rate of successful requests per second
+	ThroughputMetric = "throughput"
+	// RateMetric is the rate of sent requests per second
+	RateMetric = "rate"
+	// RequestsMetric is the number of requests
+	RequestsMetric = "requests"
+)
+
+var statusPattern = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+
+// Threshold is a parsed assertion of the thresholds of a vegeta resource.
+// Values are in seconds for latencies, ratios between 0 and 1 for success and status, requests per second for rates.
+//
+// +kubebuilder:object:generate=false
+type Threshold struct {
+	// Expression is the assertion as specified in the vegeta resource
+	Expression string
+	// Metric is the name of the metric the assertion is about
+	Metric string
+	// Status is the status code or class of the status metric, e.g. 503 or 5xx
+	Status string
+	// Operator is one of <, <=, >, >=, ==
+	Operator string
+	// Value is the value the metric is compared to
+	Value float64
+	// OfRate is true when the value is a factor of the requested rate, e.g. throughput >= 0.95 * rate
+	OfRate bool
+}
+
+// ParseThreshold parses an assertion with the format "<metric> <operator> <value>", e.g. "p99 < 250ms", "success >= 99.9%", "status 5xx < 0.1%" or "throughput >= 0.95 * rate"
+func ParseThreshold(expr string) (*Threshold, error) {
+	fields := strings.Fields(strings.ReplaceAll(expr, "*", " * "))
+	t := &Threshold{Expression: expr}
+	if len(fields) > 0 && fields[0] == StatusMetric {
+		if len(fields) < 2 || !statusPattern.MatchString(fields[1]) {
+			return nil, fmt.Errorf("the status metric requires a status code or class, e.g. status 5xx < 0.1%%")
+		}
+		t.Status = fields[1]
+		fields = append(fields[:1], fields[2:]...)
+	}
+	if len(fields) != 3 && len(fields) != 5 {
+		return nil, fmt.Errorf("the threshold %q doesn't match the <metric> <operator> <value> format, e.g. p99 < 250ms", expr)
+	}
+	t.Metric = fields[0]
+	switch fields[1] {
+	case "<", "<=", ">", ">=", "==":
+		t.Operator = fields[1]
+	default:
+		return nil, fmt.Errorf("the operator %q is not one of <, <=, >, >=, ==", fields[1])
+	}
+	if len(fields) == 5 {
+		if t.Metric != ThroughputMetric || fields[3] != "*" || fields[4] != RateMetric {
+			return nil, fmt.Errorf("only the throughput can be compared to a factor of the rate, e.g. throughput >= 0.95 * rate")
+		}
+		t.OfRate = true
+	}
+
+	value := fields[2]
+	var err error
+	switch t.Metric {
+	case MinMetric, MeanMetric, P50Metric, P90Metric, P95Metric, P99Metric, MaxMetric:
+		var d time.Duration
+		if d, err = time.ParseDuration(value); err == nil {
+			t.Value = d.Seconds()
+		}
+	case SuccessMetric, StatusMetric:
+		t.Value, err = ParseRatio(value)
+	case ThroughputMetric, RateMetric, RequestsMetric:
+		t.Value, err = strconv.ParseFloat(value, 64)
+	default:
+		return nil, fmt.Errorf("unknown metric %q, valid metrics are min, mean, p50, p90, p95, p99, max, success, status, throughput, rate and requests", t.Metric)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q for the %s metric", value, t.Metric)
+	}
+	return t, nil
+}
+
+// ParseRatio parses a ratio specified as a percentage, e.g. "0.1%", or as a number between 0 and 1, e.g. "0.001"
+func ParseRatio(value string) (float64, error) {
+	var r float64
+	var err error
+	if strings.HasSuffix(value, "%") {
+		r, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		r /= 100
+	} else {
+		r, err = strconv.ParseFloat(value, 64)
+	}
+	if err == nil && (r < 0 || r > 1) {
+		err = fmt.Errorf("out of range")
+	}
+	return r, err
+}
+
+// Holds returns true if the actual value of the metric satisfies the assertion. The rate is only used for assertions relative to it.
+func (t *Threshold) Holds(actual, rate float64) bool {
+	expected := t.Value
+	if t.OfRate {
+		expected *= rate
+	}
+	switch t.Operator {
+	case "<":
+		return actual < expected
+	case "<=":
+		return actual <= expected
+	case ">":
+		return actual > expected
+	case ">=":
+		return actual >= expected
+	default:
+		return actual == expected
+	}
+}
+
+// FormatValue formats a value of the metric of the assertion the way it is specified
+func (t *Threshold) FormatValue(v float64) string {
+	switch t.Metric {
+	case SuccessMetric, StatusMetric:
+		return strconv.FormatFloat(v*100, 'f', -1, 64) + "%"
+	case ThroughputMetric, RateMetric, RequestsMetric:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	default:
+		return time.Duration(v * float64(time.Second)).String()
+	}
+}
@@ -23,11 +23,13 @@ import (
 
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 // NOTE: Run "make generate" to regenerate code and "make manifests" to regenerate the CRD manifests after modifying this file
+// NOTE: Fields documented as "Defaulted to" are set by the mutating admission webhook so that the stored resource shows the effective configuration of the attack.
 
 // AttackSpec defines the desired attacks.
 type AttackSpec struct {
 	// Specifies a config map containing the body of every request unless overridden per attack target.
 	// The config  map should contain a file named body.txt
+	// It cannot be used together with TargetsConfigMap unless WeightedTargets is set, as both config maps are mounted under /opt/config/.
 	//
 	// +optional
 	BodyConfigMap string `json:"bodyConfigMap,omitempty"`
@@ -37,19 +39,26 @@ type AttackSpec struct {
 	// +optional
 	Chunked bool `json:"chunked,omitempty"`
 
-	// Specifies the maximum number of idle open connections per target host (defaults to 10000).
+	// Specifies a secret of type kubernetes.io/tls containing the PEM encoded TLS client certificate (tls.crt) and its private key (tls.key) to be used with HTTPS requests, e.g. for targets requiring mutual TLS.
+	// Secrets generated by cert-manager can directly be referenced. It cannot be used together with KeySecret.
+	//
+	// +optional
+	ClientCertSecret string `json:"clientCertSecret,omitempty"`
+
+	// Specifies the maximum number of idle open connections per target host. Defaulted to 10000.
 	//
 	// +kubebuilder:validation:Minimum=1
 	// +optional
 	Connections uint32 `json:"connections,omitempty"`
 
 	// Specifies the amount of time to issue request to the targets. The internal concurrency structure's setup has this value as a variable. The actual run time of the test can be longer than specified due to the responses delay. Use 0 for an infinite attack.
+	// It cannot be used together with Stages.
 	//
 	// +kubebuilder:validation:Format=duration
 	// +optional
 	Duration string `json:"duration,omitempty"`
 
-	// Specifies the format of the target provided in the targets file, see below. Valid values are: json and http.
+	// Specifies the format of the target provided in the targets file, see below. Valid values are: json and http. Defaulted to http, or json with inline, generated and OpenAPI targets.
 	// +optional
 	Format TargetFormatEnum `json:"format,omitempty"`
 
@@ -74,12 +83,13 @@ type AttackSpec struct {
 	// +optional
 	Insecure bool `json:"insecure,omitempty"`
 
-	// Specifies whether to reuse TCP connections between HTTP requests (defaults to true).
+	// Specifies whether to reuse TCP connections between HTTP requests. Defaulted to true, set it to false to disable keep-alive.
 	//
 	// +optional
-	KeepAlive bool `json:"keepAlive,omitempty"`
+	KeepAlive *bool `json:"keepAlive,omitempty"`
 
 	// Specifies the secret containing the PEM encoded TLS client certificate private key file to be used with HTTPS requests. The secret should contain a file named client.key.
+	// Use ClientCertSecret to provide the client certificate together with its private key.
 	//
 	// +optional
 	KeySecret string `json:"keySecret,omitempty"`
@@ -92,7 +102,7 @@ type AttackSpec struct {
 	// optional
 	//LAddr string `json:"laddr,omitempty"`
 
-	// Specifies whether to read the input targets lazily instead of eagerly.
+	// Specifies whether to read the input targets lazily instead of eagerly. Defaulted to true with generated targets.
 	//
 	// +optional
 	Lazy bool `json:"lazy,omitempty"`
@@ -114,6 +124,13 @@ type AttackSpec struct {
 	// +optional
 	Name string `json:"name,omitempty"`
 
+	// Specifies an OpenAPI 3 document the targets are derived from when the attack pods get created: one target per operation, with sample parameters and example request bodies.
+	// The operator renders them in the vegeta json format into a secret mounted by the attack pods, so that new operations get load coverage automatically.
+	// This is an alternative to Target, Targets, TargetsConfigMap, TargetRef and TargetGenerator, which cannot be used together with it.
+	//
+	// +optional
+	OpenAPI *OpenAPISource `json:"openAPI,omitempty"`
+
 	// TODO: I am not sure it is a good idea to have it configurable (at least in a first iteration). For now the output is directly piped into the result processing command.
 	// Specifies the output file to which the binary results will be written to. Made to be piped to the report command input. Defaults to stdout.
 	//
@@ -126,26 +143,27 @@ type AttackSpec struct {
 	ProxyHeader string `json:"proxyHeader,omitempty"`
 
 	// Specifies the request rate per time unit to issue against the targets.
-	// 0 or infinity means vegeta will send requests as fast as possible. Use together with MaxWorkers to model a fixed set of concurrent users sending requests serially (i.e. waiting for a response before sending the next request).Defaults to 50/1s.
+	// 0 or infinity means vegeta will send requests as fast as possible. Use together with MaxWorkers to model a fixed set of concurrent users sending requests serially (i.e. waiting for a response before sending the next request). Defaulted to 50/1s unless Stages are specified.
 	//
 	// +optional
 	Rate string `json:"rate,omitempty"`
 
-	// Specifies the max number of redirects followed on each request. The default is 10. When the value is -1, redirects are not followed but the response is marked as successful.
+	// Specifies the max number of redirects followed on each request. Defaulted to 10. When the value is -1, redirects are not followed but the response is marked as successful.
 	//
+	// +kubebuilder:validation:Minimum=-1
 	// +optional
-	Redirects int32 `json:"redirects,omitempty"`
+	Redirects *int32 `json:"redirects,omitempty"`
 
 	// Specifies custom DNS resolver addresses to use for name resolution instead of the ones configured by the operating system.
 	// It is of no interest as pods allow more ellaborate DNS configuration:
 	// https://kubernetes.io/docs/concepts/services-networking/dns-pod-service/#pod-dns-config
 	// Resolvers string `json:"resolvers,omitempty"`
 
-	// TODO: Supporting additional pod configuration could be considered
-	// - priorityClassName
-	// - schedulerName
-	// - serviceAccountName
-	// - tolerations
+	// Specifies a load profile as a sequence of stages run one after the other, e.g. a ramp up followed by a hold and a step down. It replaces Rate and Duration.
+	// The results of all the stages are combined into a single results stream and report.
+	//
+	// +optional
+	Stages []Stage `json:"stages,omitempty"`
 
 	// Specifies a config map containing the trusted TLS root CAs certificate files. If unspecified, the default kubernetes and system CAs certificates will be used.
 	// The key for the file can be specified by RootCertsFile. If not specified it defaults to ca-bundle.crt
@@ -168,12 +186,36 @@ type AttackSpec struct {
 	// +optional
 	Target string `json:"target"`
 
+	// Specifies a request template from which unique targets are generated during the attack, e.g. one per row of a dataset, instead of replaying the same requests.
+	// The targets are streamed into vegeta, which reads them lazily. This is an alternative to Target, Targets, TargetsConfigMap and TargetRef, which cannot be used together with it.
+	//
+	// +optional
+	TargetGenerator *TargetGenerator `json:"targetGenerator,omitempty"`
+
+	// Specifies a Service, an OpenShift Route, an Ingress or a Gateway API HTTPRoute the target is resolved from when the attack pods get created.
+	// The scheme, host and port are discovered, as well as the CA of the certificate when it is available, so that the attack keeps working when they change.
+	// The resolved target is recorded in the status. This is an alternative to Target, Targets and TargetsConfigMap, which cannot be used together with it.
+	//
+	// +optional
+	TargetRef *TargetRef `json:"targetRef,omitempty"`
+
+	// Specifies the targets of the attack inline. The operator renders them in the vegeta json format into a secret mounted by the attack pods. This is an alternative to Target and TargetsConfigMap, which cannot be used together with it.
+	//
+	// +optional
+	Targets []AttackTarget `json:"targets,omitempty"`
+
 	// Specifies a config map containing the file from which to read targets. The config map should contain a single file named targets with the format as extension, i.e. targets.json. See the format section to learn about the different target formats.
 	//
 	// +optional
 	TargetsConfigMap string `json:"targetsConfigMap,omitempty"`
 
-	// Specifies the timeout for each request. The default is 0 which disables timeouts.
+	// Specifies that the targets of TargetsConfigMap carry weights: a weight field in the json format or a "# weight: N" line within the target in the http format.
+	// Targets without weight have a weight of 1. The operator expands the targets according to their weights into a secret mounted by the attack pods.
+	//
+	// +optional
+	WeightedTargets bool `json:"weightedTargets,omitempty"`
+
+	// Specifies the timeout for each request. Defaulted to 30s, 0 disables timeouts.
 	//
 	// +kubebuilder:validation:Format=duration
 	// +optional
@@ -183,13 +225,246 @@ type AttackSpec struct {
 	// - vegeta pod would run on the same host as the target
 	// - the unix socket would be mounted in both pods
 
-	// Specifies the initial number of workers, i.e. goroutines, used in the attack. It defaults to 10. The actual number of workers will increase if necessary in order to sustain the requested rate, unless it'd go beyond MaxWorkers.
+	// Specifies the initial number of workers, i.e. goroutines, used in the attack. Defaulted to 10, or MaxWorkers if lower. The actual number of workers will increase if necessary in order to sustain the requested rate, unless it'd go beyond MaxWorkers.
 	//
 	// +kubebuilder:validation:Minimum=1
 	// +optional
 	Workers uint64 `json:"workers,omitempty"`
 }
 
+// Stage defines a stage of the load profile of an attack.
+// The vegeta command line only supports a constant rate. Linear and sine stages are therefore run as a sequence of constant rate steps of StepDuration, whose rate is the one of the shape in the middle of the step.
+// Each step is a vegeta attack of its own, which waits for the responses to its requests, up to the timeout of the attack, before the next step starts.
+// The steps end at their time in the schedule of the profile, so that the next step is shortened by the wait rather than the rest of the profile delayed.
+// The start of a step can therefore drift behind the schedule by up to the timeout, 30s per default. The largest drift is recorded in status.loadProfileDrift.
+type Stage struct {
+	// Shape of the rate during the stage. Valid values are constant, linear and sine. Defaulted to constant.
+	//
+	// +optional
+	Shape StageShapeEnum `json:"shape,omitempty"`
+
+	// Duration of the stage.
+	//
+	// +kubebuilder:validation:Format=duration
+	Duration string `json:"duration"`
+
+	// Rate is the request rate of a constant stage, the start rate of a linear stage and the mean rate of a sine stage.
+	// It has the freq/duration format of the attack rate, e.g. 50/1s.
+	Rate string `json:"rate"`
+
+	// TargetRate is the rate reached at the end of a linear stage.
+	//
+	// +optional
+	TargetRate string `json:"targetRate,omitempty"`
+
+	// Amplitude is the difference between the highest and the mean rate of a sine stage. It cannot be greater than Rate.
+	//
+	// +optional
+	Amplitude string `json:"amplitude,omitempty"`
+
+	// Period is the duration of a full sine cycle.
+	//
+	// +kubebuilder:validation:Format=duration
+	// +optional
+	Period string `json:"period,omitempty"`
+
+	// StepDuration is the duration of the constant rate steps approximating linear and sine stages. Defaulted to 10s for these shapes.
+	//
+	// +kubebuilder:validation:Format=duration
+	// +optional
+	StepDuration string `json:"stepDuration,omitempty"`
+}
+
+// TargetRef references the resource exposing the target of the attack
+type TargetRef struct {
+	// Kind of the referenced resource. Valid values are Service, Route, Ingress and HTTPRoute.
+	Kind TargetRefKindEnum `json:"kind"`
+
+	// Name of the referenced resource, in the namespace of the vegeta resource.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Port of the Service, by name or number. Defaulted to the first port of the Service. Ignored for other kinds.
+	//
+	// +optional
+	Port string `json:"port,omitempty"`
+
+	// Scheme of the Service, http or https. Defaulted to https when the application protocol or the name of the port is https or when the port is 443 or 8443, to http otherwise.
+	// Ignored for other kinds, for which it is derived from their TLS configuration.
+	//
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+
+	// Path of the requests, e.g. /api/health. Defaulted to the path of the Route, of the first rule of the Ingress or of the first match of the HTTPRoute, if any.
+	//
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Method is the HTTP method of the requests. Defaulted to GET.
+	//
+	// +optional
+	Method string `json:"method,omitempty"`
+
+	// PerEndpoint attacks every ready endpoint of the Service directly rather than its cluster IP, so that an imbalance between the backing pods shows up in the results.
+	// The endpoints are read from the EndpointSlices of the Service when the run starts and the requests keep the Host header of the Service. Only valid for the Service kind.
+	//
+	// +optional
+	PerEndpoint bool `json:"perEndpoint,omitempty"`
+}
+
+// AttackTarget defines a target of the attack
+type AttackTarget struct {
+	// Method is the HTTP method of the requests. Defaulted to GET.
+	//
+	// +optional
+	Method string `json:"method,omitempty"`
+
+	// URL of the target, e.g. https://kubernetes.default.svc.cluster.local:443/healthz
+	//
+	// +kubebuilder:validation:MinLength=1
+	URL string `json:"url"`
+
+	// Specifies request headers for this target in addition to the headers defined for the attack.
+	// Headers have the Key: Value format.
+	//
+	// +optional
+	Headers []string `json:"headers,omitempty"`
+
+	// Body of the requests.
+	//
+	// +optional
+	Body string `json:"body,omitempty"`
+
+	// Specifies a config map or secret key containing the body of the requests. It cannot be used together with Body.
+	//
+	// +optional
+	BodyFrom *BodySource `json:"bodyFrom,omitempty"`
+
+	// Weight of the target in the traffic mix, relative to the weights of the other targets, e.g. 70, 25 and 5 for 70% of reads, 25% of searches and 5% of writes. Defaulted to 1.
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1000
+	// +optional
+	Weight uint32 `json:"weight,omitempty"`
+}
+
+// BodySource references the key of a config map or of a secret containing the body of requests. Exactly one of them must be specified.
+type BodySource struct {
+	// Selects a key of a config map in the namespace of the vegeta resource.
+	//
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	// Selects a key of a secret in the namespace of the vegeta resource. The secret is mounted by the attack pods, it is not read by the operator.
+	//
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// TargetGenerator defines the template of the generated requests.
+// The url, the headers and the body are Go templates. The fields of the current row of the dataset are available by name, e.g. {{.userId}}, together with the functions uuid, which returns a random UUID, and randInt, which returns a random integer between its two arguments included, e.g. {{randInt 1 1000}}.
+type TargetGenerator struct {
+	// Method is the HTTP method of the requests. Defaulted to GET.
+	//
+	// +optional
+	Method string `json:"method,omitempty"`
+
+	// URL template of the requests, e.g. https://api.example.com/users/{{.userId}}
+	//
+	// +kubebuilder:validation:MinLength=1
+	URL string `json:"url"`
+
+	// Specifies templates of request headers in addition to the headers defined for the attack.
+	// Headers have the Key: Value format.
+	//
+	// +optional
+	Headers []string `json:"headers,omitempty"`
+
+	// Template of the body of the requests.
+	//
+	// +optional
+	Body string `json:"body,omitempty"`
+
+	// Specifies the dataset whose rows feed the templates. The rows are used in turn, starting again with the first one once the dataset is exhausted.
+	// With several replicas the rows are divided across them so that they don't send the same requests. Without dataset only the functions are available to the templates.
+	//
+	// +optional
+	Dataset *DatasetSource `json:"dataset,omitempty"`
+
+	// Specifies that every row of the dataset is used once: the attack stops when the dataset is exhausted, even if its duration has not elapsed.
+	//
+	// +optional
+	Once bool `json:"once,omitempty"`
+}
+
+// DatasetSource references a dataset in a config map or on a persistent volume. Exactly one of them must be specified.
+type DatasetSource struct {
+	// Selects a key of a config map in the namespace of the vegeta resource.
+	//
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	// Selects a file on a persistent volume claim, which gets mounted read-only by the attack pods. Large datasets exceeding the size limit of a config map are to be stored this way.
+	//
+	// +optional
+	PersistentVolumeClaim *DatasetClaim `json:"persistentVolumeClaim,omitempty"`
+
+	// Format of the dataset. Valid values are csv, whose first line names the fields, and json, either an array of objects or a sequence of objects, e.g. one per line.
+	// Defaulted to json when the name of the key or of the file ends with .json, .jsonl or .ndjson, to csv otherwise.
+	//
+	// +optional
+	Format DatasetFormatEnum `json:"format,omitempty"`
+}
+
+// DatasetClaim references a file on a persistent volume claim
+type DatasetClaim struct {
+	// Name of the persistent volume claim, in the namespace of the vegeta resource.
+	//
+	// +kubebuilder:validation:MinLength=1
+	ClaimName string `json:"claimName"`
+
+	// Path of the dataset file, relative to the root of the volume, e.g. datasets/users.csv
+	//
+	// +kubebuilder:validation:MinLength=1
+	Path string `json:"path"`
+}
+
+// OpenAPISource references an OpenAPI 3 document in json or yaml and selects the operations to derive targets from. Exactly one of ConfigMapKeyRef or URL must be specified.
+type OpenAPISource struct {
+	// Selects a key of a config map in the namespace of the vegeta resource containing the document.
+	//
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	// URL the document is downloaded from by the operator, e.g. https://api.example.com/openapi.json. It must resolve to a public address, documents served inside the cluster are provided in a config map.
+	//
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Server is the base url of the requests, e.g. https://api.example.com/v1. Defaulted to the first server of the document, resolved against URL when it is relative.
+	//
+	// +optional
+	Server string `json:"server,omitempty"`
+
+	// Include selects the operations to derive targets from. All the operations are selected when it is empty.
+	// An entry matches an operation by its operationId, by one of its tags or by its method and path, e.g. "GET /users/*", where * matches any sequence of characters.
+	//
+	// +optional
+	Include []string `json:"include,omitempty"`
+
+	// Exclude removes operations from the selection, e.g. "DELETE *" or "admin". Entries have the format of Include.
+	//
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+
+	// Examples specifies the values of parameters by name, e.g. userId: "42". They take precedence over the examples of the document.
+	// Parameters without example get a sample value derived from their schema. Optional query, header and cookie parameters are only sent when they have a value here.
+	//
+	// +optional
+	Examples map[string]string `json:"examples,omitempty"`
+}
+
 // ReportSpec defines the desired report
 type ReportSpec struct {
 
@@ -213,17 +488,70 @@ type ReportSpec struct {
 	// +optional
 	OutputClaim string `json:"outputClaim,omitempty"`
 
-	// Specifies the type of storage to use for the output. Valid values are stdout, pvc, obc. Stdout is the default.
+	// Specifies the type of storage to use for the output. Valid values are stdout, pvc, obc. Defaulted to stdout.
 	//
 	// +optional
 	OutputType OutputTypeEnum `json:"outputType,omitempty"`
 
-	// Type defines the report type to generate. Valid values are text, json, hist, hdrplot. It defaults to "text".
+	// Type defines the report type to generate. Valid values are text, json, hist, hdrplot. Defaulted to text.
 	//
 	// +optional
 	Type ReportTypeEnum `json:"type,omitempty"`
 }
 
+// PodTemplate defines the settings merged into the pods generated for a vegeta resource
+type PodTemplate struct {
+	// Specifies labels added to the pods. They cannot override the labels set by the operator.
+	//
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Specifies annotations added to the pods. The annotations with the vegeta.testing.io/ prefix are reserved to the operator and skipped.
+	//
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Specifies a selector which must match the labels of a node for the pods to be scheduled on it.
+	//
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Specifies the tolerations of the pods, e.g. for the taints of a dedicated node pool.
+	//
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Specifies the scheduling constraints of the pods.
+	//
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// Specifies the priority class of the pods.
+	//
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Specifies the scheduler dispatching the pods. If not specified the pods are dispatched by the default scheduler.
+	//
+	// +optional
+	SchedulerName string `json:"schedulerName,omitempty"`
+
+	// Specifies the service account the pods run with. If not specified the default service account of the namespace is used.
+	//
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Specifies the secrets used to pull the image, e.g. from a private registry.
+	//
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Specifies the security attributes of the pods.
+	//
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+}
+
 // VegetaSpec defines the desired state of Vegeta
 type VegetaSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
@@ -234,12 +562,25 @@ type VegetaSpec struct {
 	// +required
 	Attack *AttackSpec `json:"attack"`
 
-	// Specifies the number of pods running the attack. The attack as specified above will be run by each pod. This brings an additional level of parallelism and scalability to what workers provide.
+	// Specifies the number of pods running the attack. The attack as specified above will be run by each pod. This brings an additional level of parallelism and scalability to what workers provide. Defaulted to 1.
 	//
 	// +kubebuilder:validation:Minimum=1
 	Replicas uint32 `json:"replicas,omitempty"`
 
-	// Specifies the report parameters.
+	// Specifies whether the rate, workers and max workers of the attack apply to each replica (perReplica) or to all the replicas together (total). Valid values are perReplica, total. Defaulted to perReplica.
+	// With total they are divided across the replicas, the first replicas taking the remainders, e.g. a rate of 1000/1s with 3 replicas gives 334/1s, 333/1s and 333/1s. Each replica gets at least one worker.
+	//
+	// +optional
+	RateMode RateModeEnum `json:"rateMode,omitempty"`
+
+	// Specifies how the attack pods are spread when there are several replicas so that they don't saturate the network of a single node. Valid values are node, zone, none.
+	// Without a value the pods are spread across nodes on a best effort basis: they still get scheduled when there are less nodes than replicas.
+	// A requested spread is enforced: pods that cannot be placed on a node or in a zone without another attack pod stay pending until one becomes available.
+	//
+	// +optional
+	Spread SpreadEnum `json:"spread,omitempty"`
+
+	// Specifies the report parameters. Defaulted to a text report written to stdout.
 	//
 	// +optional
 	Report *ReportSpec `json:"report,omitempty"`
@@ -252,6 +593,57 @@ type VegetaSpec struct {
 	//
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Specifies the scheduling and runtime settings merged into the attack and report pods, e.g. to pin them to a dedicated node pool or to pull the image from a private registry.
+	//
+	// +optional
+	PodTemplate *PodTemplate `json:"podTemplate,omitempty"`
+
+	// Specifies that the attack is to be stopped. The attack pods stop sending requests and flush the results collected so far, from which the report is generated.
+	// The processing then ends in the aborted phase. The annotation vegeta.testing.io/abort=true has the same effect.
+	//
+	// +optional
+	Abort bool `json:"abort,omitempty"`
+
+	// Specifies when the attack is to stop on its own because the target degrades, e.g. for tests against shared or production-like environments.
+	// The processing then ends in the aborted phase with the report of the results collected so far and the window that tripped the breaker is recorded in the status.
+	//
+	// +optional
+	CircuitBreaker *CircuitBreakerSpec `json:"circuitBreaker,omitempty"`
+
+	// Identifies the run of the attack. Setting a new value once the current run has terminated starts a new run with fresh pods, which can use a modified specification.
+	// The results of each run are stored with their own prefix and the summary of the previous runs is kept in the history of the status.
+	//
+	// +optional
+	RunID string `json:"runID,omitempty"`
+
+	// Specifies the number of summaries of previous runs kept in the history. Defaulted to 5.
+	//
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	HistoryLimit *int32 `json:"historyLimit,omitempty"`
+
+	// Specifies the number of seconds after which the pods of a finished run are deleted. The results are kept in the status. Defaulted to the value configured for the operator, if any, otherwise the pods are kept.
+	//
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+
+	// Specifies that the Vegeta resource itself, and not only its pods, is deleted once the time to live after the run has finished has expired.
+	//
+	// +optional
+	DeleteAfterFinished bool `json:"deleteAfterFinished,omitempty"`
+
+	// Specifies assertions evaluated against the results once the report has been generated. The processing fails if one of them is breached, which is reflected in the ThresholdsMet condition.
+	// Assertions have the format "<metric> <operator> <value>" with the operators <, <=, >, >= and ==, e.g.:
+	// "p99 < 250ms" for the latencies min, mean, p50, p90, p95, p99 and max,
+	// "success >= 99.9%" for the ratio of successful requests,
+	// "status 5xx < 0.1%" for the ratio of responses with a status code or class,
+	// "throughput >= 0.95 * rate" for the requests per second, where rate is the requested rate across all the replicas (the measured one when stages are specified or the rate is unlimited),
+	// "requests >= 1000" for the number of requests.
+	//
+	// +optional
+	Thresholds []string `json:"thresholds,omitempty"`
 }
 
 // VegetaStatus defines the observed state of Vegeta
@@ -259,8 +651,6 @@ type VegetaStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// TODO: Ideally this would reflect the result of the test execution.
-
 	// Active contains the names of currently running pods.
 	// +optional
 	Active []string `json:"active,omitempty"`
@@ -273,8 +663,307 @@ type VegetaStatus struct {
 	// +optional
 	Succeeded []string `json:"succeeded,omitempty"`
 
-	// Phase of the processing of the Vegeta request. Possible values are: pending (no pod started), running (not all pods have terminated yet and no pod has failed), failed (one of the pod has failed), succeeded (all pods have successfully terminated but report has not been generated yet), completed (all pods have successfully terminated and report has been generated)
+	// Phase of the processing of the Vegeta request. Possible values are: pending (no pod started), running (not all pods have terminated yet and no pod has failed), failed (one of the pod has failed), succeeded (all pods have successfully terminated but report has not been generated yet), completed (all pods have successfully terminated and report has been generated), aborted (the attack has been aborted and the report of the partial results has been generated)
 	Phase PhaseEnum `json:"phase,omitempty"`
+
+	// RunID is the ID of the run the status reflects.
+	// +optional
+	RunID string `json:"runID,omitempty"`
+
+	// StartTime is the time the pods of the run have been created at.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// History contains the summaries of the previous runs, the most recent first.
+	// +optional
+	History []RunSummary `json:"history,omitempty"`
+
+	// AbortedAt is the time the abort of the attack has been requested at.
+	// +optional
+	AbortedAt *metav1.Time `json:"abortedAt,omitempty"`
+
+	// CircuitBreaker records the window that tripped the circuit breaker, which stopped the attack.
+	// +optional
+	CircuitBreaker *CircuitBreakerTrip `json:"circuitBreaker,omitempty"`
+
+	// ReplicaRates contains the rate of each replica when the rate is divided across the replicas (rateMode total), in the order of the replicas.
+	// +optional
+	ReplicaRates []string `json:"replicaRates,omitempty"`
+
+	// ResolvedTarget is the target resolved from spec.attack.targetRef for the current run, including the http verb.
+	// +optional
+	ResolvedTarget string `json:"resolvedTarget,omitempty"`
+
+	// TargetCASecret is the name of the secret containing the CA discovered for the resolved target, if any.
+	// +optional
+	TargetCASecret string `json:"targetCASecret,omitempty"`
+
+	// OpenAPIOperations are the operations of the document referenced by spec.attack.openAPI the targets of the current run have been derived from, as method and path, e.g. GET /users/{id}.
+	// +optional
+	OpenAPIOperations []string `json:"openAPIOperations,omitempty"`
+
+	// TargetMix is the traffic mix requested by the weights of the targets and the mix achieved by the attack, once the attack pods have terminated.
+	// +optional
+	TargetMix []TargetMixResults `json:"targetMix,omitempty"`
+
+	// Endpoints are the endpoints of the Service attacked directly when spec.attack.targetRef.perEndpoint is set.
+	// Their results get recorded once the attack pods have terminated.
+	// +optional
+	Endpoints []EndpointResults `json:"endpoints,omitempty"`
+
+	// StartAt is the time the attack pods start the attack at when there are several replicas. It is set once all the attack pods are running so that the attack windows of the replicas are aligned.
+	// +optional
+	StartAt *metav1.Time `json:"startAt,omitempty"`
+
+	// Placement contains the node and zone each attack pod has been scheduled on.
+	// +optional
+	Placement []ReplicaPlacement `json:"placement,omitempty"`
+
+	// LoadProfileDrift is the largest delay of the start of a step of spec.attack.stages behind the schedule of the load profile, across the attack pods.
+	// It gets recorded once the attack pods have terminated. See Stage for the origin of the drift.
+	// +optional
+	LoadProfileDrift *metav1.Duration `json:"loadProfileDrift,omitempty"`
+
+	// Results contains the metrics of the attack as computed by vegeta report once the processing has completed.
+	// +optional
+	Results *AttackResults `json:"results,omitempty"`
+
+	// Conditions represent the latest available observations of the processing of the Vegeta request.
+	// Known condition types are TargetResolved, PodsScheduled, AttackRunning, AttackSucceeded, ReportGenerated, ThresholdsMet, TargetMixHonoured, Ready, Complete, Aborted and Failed.
+	//
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// CircuitBreakerSpec defines when the attack stops on its own.
+// Each attack pod evaluates its results once per second over a sliding window. When one of the maximums is exceeded the attack pod stops sending requests and the attack of the other pods gets aborted.
+type CircuitBreakerSpec struct {
+	// Specifies the duration of the sliding window the results are evaluated over. Defaulted to 10s.
+	//
+	// +kubebuilder:validation:Format=duration
+	// +optional
+	Window string `json:"window,omitempty"`
+
+	// Specifies the ratio of failed requests in the window above which the attack stops, as a percentage, e.g. "5%", or as a number between 0 and 1, e.g. "0.05".
+	//
+	// +optional
+	MaxErrorRatio string `json:"maxErrorRatio,omitempty"`
+
+	// Specifies the 99th percentile of the latencies in the window above which the attack stops, e.g. "500ms".
+	//
+	// +kubebuilder:validation:Format=duration
+	// +optional
+	MaxP99 string `json:"maxP99,omitempty"`
+
+	// Specifies the minimal number of requests in the window for it to be evaluated, so that a few slow or failed requests at a low rate don't stop the attack. Defaulted to 10.
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinRequests uint32 `json:"minRequests,omitempty"`
+}
+
+// CircuitBreakerTrip records the window that tripped the circuit breaker
+type CircuitBreakerTrip struct {
+	// Pod is the name of the attack pod whose results tripped the breaker.
+	Pod string `json:"pod"`
+
+	// Reason is ErrorRatioExceeded or P99Exceeded.
+	Reason string `json:"reason"`
+
+	// Message is a human readable description of the trip.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// WindowStart is the start of the window that tripped the breaker.
+	WindowStart metav1.Time `json:"windowStart"`
+
+	// WindowEnd is the end of the window that tripped the breaker.
+	WindowEnd metav1.Time `json:"windowEnd"`
+
+	// Requests is the number of requests in the window.
+	Requests uint64 `json:"requests"`
+
+	// ErrorRatio is the ratio of failed requests in the window as a percentage.
+	ErrorRatio string `json:"errorRatio"`
+
+	// P99 is the 99th percentile of the latencies in the window.
+	P99 metav1.Duration `json:"p99"`
+}
+
+// RunSummary records the outcome of a previous run
+type RunSummary struct {
+	// RunID is the ID of the run.
+	// +optional
+	RunID string `json:"runID,omitempty"`
+
+	// Phase is the phase the run ended in.
+	Phase PhaseEnum `json:"phase"`
+
+	// Reason is the reason of the outcome of the run, if any.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is a human readable description of the outcome of the run.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// StartTime is the time the pods of the run have been created at.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time the run has ended at.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// ResultPrefix is the prefix of the result and report files of the run when they are stored in a persistent volume or a bucket.
+	// +optional
+	ResultPrefix string `json:"resultPrefix,omitempty"`
+
+	// Results contains the metrics of the run.
+	// +optional
+	Results *AttackResults `json:"results,omitempty"`
+}
+
+// ReplicaPlacement records where an attack pod has been scheduled
+type ReplicaPlacement struct {
+	// Pod is the name of the attack pod.
+	Pod string `json:"pod"`
+
+	// Node is the name of the node the pod has been scheduled on.
+	Node string `json:"node"`
+
+	// Zone is the value of the topology.kubernetes.io/zone label of the node, if any.
+	// +optional
+	Zone string `json:"zone,omitempty"`
+}
+
+// AttackResults contains the metrics extracted from the json report of an attack.
+// When the report is generated by each of the attack pods (stdout output with several replicas) the results of the pods are merged: counters are summed up, the mean latency is weighted by the number of requests and the percentiles are the highest of the values reported by the pods, which makes them an upper bound.
+type AttackResults struct {
+	// Requests is the total number of requests issued.
+	Requests uint64 `json:"requests"`
+
+	// Rate is the rate of sent requests per second.
+	// +optional
+	Rate string `json:"rate,omitempty"`
+
+	// Throughput is the rate of successful requests per second.
+	// +optional
+	Throughput string `json:"throughput,omitempty"`
+
+	// Success is the ratio of requests whose responses were not errors and had status codes between 200 and 400.
+	// +optional
+	Success string `json:"success,omitempty"`
+
+	// Duration is the duration of the attack.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Wait is the extra time waiting for responses from the targets.
+	// +optional
+	Wait *metav1.Duration `json:"wait,omitempty"`
+
+	// Latencies contains the latency statistics of the requests.
+	// +optional
+	Latencies *LatencyResults `json:"latencies,omitempty"`
+
+	// BytesIn is the total number of bytes received with the response bodies.
+	// +optional
+	BytesIn uint64 `json:"bytesIn,omitempty"`
+
+	// BytesOut is the total number of bytes sent with the request bodies.
+	// +optional
+	BytesOut uint64 `json:"bytesOut,omitempty"`
+
+	// StatusCodes contains the number of responses per status code. Code 0 is used for requests that did not get any response.
+	// +optional
+	StatusCodes map[string]uint64 `json:"statusCodes,omitempty"`
+
+	// Errors contains the first distinct errors returned by the targets.
+	// +optional
+	Errors []string `json:"errors,omitempty"`
+}
+
+// LatencyResults contains the latency statistics of an attack.
+type LatencyResults struct {
+	// Min is the minimum latency of all requests.
+	// +optional
+	Min *metav1.Duration `json:"min,omitempty"`
+
+	// Mean is the mean latency of all requests.
+	// +optional
+	Mean *metav1.Duration `json:"mean,omitempty"`
+
+	// P50 is the 50th percentile of the request latencies.
+	// +optional
+	P50 *metav1.Duration `json:"p50,omitempty"`
+
+	// P90 is the 90th percentile of the request latencies.
+	// +optional
+	P90 *metav1.Duration `json:"p90,omitempty"`
+
+	// P95 is the 95th percentile of the request latencies.
+	// +optional
+	P95 *metav1.Duration `json:"p95,omitempty"`
+
+	// P99 is the 99th percentile of the request latencies.
+	// +optional
+	P99 *metav1.Duration `json:"p99,omitempty"`
+
+	// Max is the maximum latency of all requests.
+	// +optional
+	Max *metav1.Duration `json:"max,omitempty"`
+}
+
+// TargetMixResults records the share of the requests of a target in a weighted traffic mix
+type TargetMixResults struct {
+	// Target is the http verb and the url of the target. Targets with the same verb and url are accounted together.
+	Target string `json:"target"`
+
+	// Weight of the target, summed up over the targets with the same verb and url.
+	Weight uint32 `json:"weight"`
+
+	// Expected is the share of the requests expected from the weights.
+	Expected string `json:"expected"`
+
+	// Requests is the number of requests issued to the target.
+	// +optional
+	Requests uint64 `json:"requests,omitempty"`
+
+	// Achieved is the share of the requests issued to the target.
+	// +optional
+	Achieved string `json:"achieved,omitempty"`
+}
+
+// EndpointResults records an endpoint attacked directly and the results of the requests it received
+type EndpointResults struct {
+	// Address of the endpoint, ip:port.
+	Address string `json:"address"`
+
+	// Pod is the name of the pod backing the endpoint, if any.
+	// +optional
+	Pod string `json:"pod,omitempty"`
+
+	// Requests is the number of requests issued to the endpoint.
+	// +optional
+	Requests uint64 `json:"requests,omitempty"`
+
+	// Success is the ratio of the requests to the endpoint that succeeded.
+	// +optional
+	Success string `json:"success,omitempty"`
+
+	// Latencies are the latencies of the requests to the endpoint.
+	// +optional
+	Latencies *LatencyResults `json:"latencies,omitempty"`
+
+	// Partial is true when some attack pods have not reported results for the endpoint, as only the slowest endpoints of each pod fit into its termination message.
+	// The results then only cover the requests of the pods that reported them.
+	// +optional
+	Partial bool `json:"partial,omitempty"`
 }
 
 // Vegeta is the Schema for the vegeta API
@@ -329,6 +1018,132 @@ func (e OutputTypeEnum) String() string {
 	}
 }
 
+// StageShapeEnum is an enumeration of the possible shapes of a stage
+// +kubebuilder:validation:Enum=constant;linear;sine
+type StageShapeEnum string
+
+const (
+	// ConstantShape keeps the rate constant during the stage
+	ConstantShape StageShapeEnum = "constant"
+	// LinearShape ramps the rate linearly from Rate to TargetRate
+	LinearShape StageShapeEnum = "linear"
+	// SineShape makes the rate oscillate around Rate
+	SineShape StageShapeEnum = "sine"
+)
+
+func (e StageShapeEnum) String() string {
+	switch e {
+	case ConstantShape:
+		return "constant"
+	case LinearShape:
+		return "linear"
+	case SineShape:
+		return "sine"
+	default:
+		return ""
+	}
+}
+
+// RateModeEnum is an enumeration of the possible ways the rate applies to the replicas
+// +kubebuilder:validation:Enum=perReplica;total
+type RateModeEnum string
+
+const (
+	// PerReplicaRate means that each replica runs the attack with the specified rate
+	PerReplicaRate RateModeEnum = "perReplica"
+	// TotalRate means that the specified rate is divided across the replicas
+	TotalRate RateModeEnum = "total"
+)
+
+func (e RateModeEnum) String() string {
+	switch e {
+	case PerReplicaRate:
+		return "perReplica"
+	case TotalRate:
+		return "total"
+	default:
+		return ""
+	}
+}
+
+// SpreadEnum is an enumeration of the possible placement policies of the attack pods
+// +kubebuilder:validation:Enum=node;zone;none
+type SpreadEnum string
+
+const (
+	// NodeSpread spreads the attack pods across nodes
+	NodeSpread SpreadEnum = "node"
+	// ZoneSpread spreads the attack pods across zones
+	ZoneSpread SpreadEnum = "zone"
+	// NoSpread leaves the placement of the attack pods to the scheduler
+	NoSpread SpreadEnum = "none"
+)
+
+func (e SpreadEnum) String() string {
+	switch e {
+	case NodeSpread:
+		return "node"
+	case ZoneSpread:
+		return "zone"
+	case NoSpread:
+		return "none"
+	default:
+		return ""
+	}
+}
+
+// TargetRefKindEnum is an enumeration of the kinds of resources a target can be resolved from
+// +kubebuilder:validation:Enum=Service;Route;Ingress;HTTPRoute
+type TargetRefKindEnum string
+
+const (
+	// ServiceKind references a Service, reached through its cluster DNS name
+	ServiceKind TargetRefKindEnum = "Service"
+	// RouteKind references an OpenShift Route
+	RouteKind TargetRefKindEnum = "Route"
+	// IngressKind references a networking.k8s.io/v1 Ingress
+	IngressKind TargetRefKindEnum = "Ingress"
+	// HTTPRouteKind references a Gateway API HTTPRoute, reached through the listener of its parent Gateway
+	HTTPRouteKind TargetRefKindEnum = "HTTPRoute"
+)
+
+func (e TargetRefKindEnum) String() string {
+	switch e {
+	case ServiceKind:
+		return "Service"
+	case RouteKind:
+		return "Route"
+	case IngressKind:
+		return "Ingress"
+	case HTTPRouteKind:
+		return "HTTPRoute"
+	default:
+		return ""
+	}
+}
+
+// DatasetFormatEnum is an enumeration of the formats of a dataset feeding generated targets
+// +kubebuilder:validation:Enum=csv;json
+type DatasetFormatEnum string
+
+const (
+	// CSVDataset is a dataset in the csv format, whose first line names the fields
+	CSVDataset DatasetFormatEnum = "csv"
+	// JSONDataset is a dataset made of json objects
+	JSONDataset DatasetFormatEnum = "json"
+)
+
+func (e DatasetFormatEnum) String() string {
+	switch e {
+	case CSVDataset:
+		return "csv"
+	case JSONDataset:
+		return "json"
+	default:
+		return ""
+	}
+}
+
 // PhaseEnum is an enumaration of possible phases for  the vegeta resource
 type PhaseEnum string
 
@@ -343,6 +1158,8 @@ const (
 	FailedPhase PhaseEnum = "failed"
 	// CompletedPhase means that all pods have successfully terminated and report has been generated
 	CompletedPhase PhaseEnum = "completed"
+	// AbortedPhase means that the attack has been aborted and the report of the partial results has been generated
+	AbortedPhase PhaseEnum = "aborted"
 )
 
 func (e PhaseEnum) String() string {
@@ -357,11 +1174,100 @@ func (e PhaseEnum) String() string {
 		return "failed"
 	case CompletedPhase:
 		return "completed"
+	case AbortedPhase:
+		return "aborted"
 	default:
 		return ""
 	}
 }
 
+// IsTerminated returns true for the phases in which the processing of a run has ended
+func (e PhaseEnum) IsTerminated() bool {
+	return e == CompletedPhase || e == FailedPhase || e == AbortedPhase
+}
+
+// Condition types reported in the status of the vegeta resource
+const (
+	// PodsScheduledCondition is true when all the attack pods have been scheduled
+	PodsScheduledCondition = "PodsScheduled"
+	// AttackRunningCondition is true while attack pods are running
+	AttackRunningCondition = "AttackRunning"
+	// AttackSucceededCondition is true when all the attack pods have successfully terminated and false as soon as one of them has failed
+	AttackSucceededCondition = "AttackSucceeded"
+	// ReportGeneratedCondition is true when the report has been generated
+	ReportGeneratedCondition = "ReportGenerated"
+	// ReadyCondition is true when the processing has completed and the results are available
+	ReadyCondition = "Ready"
+	// CompleteCondition is set to true when the processing has completed
+	CompleteCondition = "Complete"
+	// FailedCondition is set to true when the processing has failed
+	FailedCondition = "Failed"
+	// AbortedCondition is set to true when the attack has been aborted
+	AbortedCondition = "Aborted"
+	// ThresholdsMetCondition is true when the results satisfy all the thresholds and false when one of them is breached
+	ThresholdsMetCondition = "ThresholdsMet"
+	// TargetResolvedCondition is true when the target referenced by spec.attack.targetRef has been resolved or the targets have been derived from spec.attack.openAPI
+	TargetResolvedCondition = "TargetResolved"
+	// TargetMixHonouredCondition is true when the share of the requests of each weighted target matches its weight
+	TargetMixHonouredCondition = "TargetMixHonoured"
+)
+
+// Reasons of the conditions reported in the status of the vegeta resource
+const (
+	// PendingReason means that no attack pod has started
+	PendingReason = "Pending"
+	// RunningReason means that the attack is in progress
+	RunningReason = "Running"
+	// GeneratingReportReason means that the attack has terminated and the report is being generated
+	GeneratingReportReason = "GeneratingReport"
+	// CompletedReason means that the processing has completed
+	CompletedReason = "Completed"
+	// AbortedReason means that the attack has been aborted on demand
+	AbortedReason = "Aborted"
+	// CircuitBreakerTrippedReason means that the attack has been aborted because the circuit breaker tripped
+	CircuitBreakerTrippedReason = "CircuitBreakerTripped"
+	// PodsPendingReason means that not all attack pods have been created or scheduled
+	PodsPendingReason = "PodsPending"
+	// UnschedulableReason means that an attack pod cannot be scheduled
+	UnschedulableReason = "Unschedulable"
+	// AllPodsScheduledReason means that all attack pods have been scheduled
+	AllPodsScheduledReason = "AllPodsScheduled"
+	// AttackPodsRunningReason means that attack pods are running
+	AttackPodsRunningReason = "AttackPodsRunning"
+	// AttackPodsPendingReason means that no attack pod is running yet
+	AttackPodsPendingReason = "AttackPodsPending"
+	// AttackFinishedReason means that all attack pods have terminated
+	AttackFinishedReason = "AttackFinished"
+	// AttackInProgressReason means that the outcome of the attack is not known yet
+	AttackInProgressReason = "AttackInProgress"
+	// AllPodsSucceededReason means that all attack pods have successfully terminated
+	AllPodsSucceededReason = "AllPodsSucceeded"
+	// AttackPodFailedReason means that an attack pod has failed
+	AttackPodFailedReason = "AttackPodFailed"
+	// ReportGeneratedByAttackPodsReason means that the report has been generated by the attack pods
+	ReportGeneratedByAttackPodsReason = "ReportGeneratedByAttackPods"
+	// ReportPodRunningReason means that the report pod has been created and has not terminated yet
+	ReportPodRunningReason = "ReportPodRunning"
+	// ReportPodSucceededReason means that the report has been generated by the report pod
+	ReportPodSucceededReason = "ReportPodSucceeded"
+	// ReportPodFailedReason means that the report pod has failed
+	ReportPodFailedReason = "ReportPodFailed"
+	// AllThresholdsMetReason means that the results satisfy all the thresholds
+	AllThresholdsMetReason = "AllThresholdsMet"
+	// ThresholdsBreachedReason means that the results don't satisfy at least one of the thresholds
+	ThresholdsBreachedReason = "ThresholdsBreached"
+	// ResultsUnavailableReason means that the results could not be retrieved to evaluate the thresholds
+	ResultsUnavailableReason = "ResultsUnavailable"
+	// TargetResolvedReason means that the target reference has been resolved
+	TargetResolvedReason = "TargetResolved"
+	// TargetNotResolvedReason means that the target reference could not be resolved
+	TargetNotResolvedReason = "TargetNotResolved"
+	// TargetMixHonouredReason means that the share of the requests of each weighted target matches its weight
+	TargetMixHonouredReason = "TargetMixHonoured"
+	// TargetMixDeviatesReason means that the share of the requests of a weighted target deviates from its weight
+	TargetMixDeviatesReason = "TargetMixDeviates"
+)
+
 // ReportTypeEnum is an enumeration of possible types of reports
 // +kubebuilder:validation:Enum=text;json;hist;hdrplot
 type ReportTypeEnum string
@@ -0,0 +1,797 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var vegetalog = logf.Log.WithName("vegeta-resource")
+
+// MaxTargetWeight is the maximum weight of a target in a weighted traffic mix
+const MaxTargetWeight = 1000
+
+// generatorFuncs declares the functions available to the templates of generated targets, so that the templates can be parsed.
+// They are implemented by the generator app of the vegeta image.
+var generatorFuncs = template.FuncMap{
+	"uuid":    func() string { return "" },
+	"randInt": func(min, max int) int { return min },
+}
+
+// SetupWebhookWithManager registers the webhooks for Vegeta resources with the manager.
+func (r *Vegeta) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// Defaults of the vegeta binary, which are materialised in the stored resource
+const (
+	defaultRate        = "50/1s"
+	defaultWorkers     = 10
+	defaultConnections = 10000
+	defaultRedirects   = 10
+	defaultTimeout     = "30s"
+	defaultStep        = "10s"
+)
+
+// AbortAnnotation requests the abort of the attack when set to true on the vegeta resource
+const AbortAnnotation = "vegeta.testing.io/abort"
+
+// DefaultHistoryLimit is the default number of summaries of previous runs kept in the status
+const DefaultHistoryLimit = 5
+
+// Defaults of the circuit breaker
+const (
+	defaultBreakerWindow      = "10s"
+	defaultBreakerMinRequests = 10
+)
+
+// +kubebuilder:webhook:path=/mutate-vegeta-testing-io-v1alpha1-vegeta,mutating=true,failurePolicy=fail,sideEffects=None,groups=vegeta.testing.io,resources=vegeta,verbs=create;update,versions=v1alpha1,name=mvegeta.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Defaulter = &Vegeta{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type.
+// The values implicitly used by vegeta are set so that the stored resource shows the effective configuration of the attack.
+func (r *Vegeta) Default() {
+	vegetalog.V(1).Info("default", "name", r.Name)
+	if r.Spec.Replicas == 0 {
+		r.Spec.Replicas = 1
+	}
+	// Spread is not defaulted: without an explicit value the attack pods are spread across nodes on a best effort basis
+	if r.Spec.RateMode == "" {
+		r.Spec.RateMode = PerReplicaRate
+	}
+	if r.Spec.HistoryLimit == nil {
+		limit := int32(DefaultHistoryLimit)
+		r.Spec.HistoryLimit = &limit
+	}
+	if r.Spec.Attack != nil {
+		r.Spec.Attack.Default()
+	}
+	if r.Spec.Report == nil {
+		r.Spec.Report = &ReportSpec{}
+	}
+	r.Spec.Report.Default()
+	if r.Spec.CircuitBreaker != nil {
+		r.Spec.CircuitBreaker.Default()
+	}
+}
+
+// Default sets the window and the minimal number of requests of the circuit breaker.
+func (b *CircuitBreakerSpec) Default() {
+	if b.Window == "" {
+		b.Window = defaultBreakerWindow
+	}
+	if b.MinRequests == 0 {
+		b.MinRequests = defaultBreakerMinRequests
+	}
+}
+
+// Default sets the attack parameters that have not been specified to the values vegeta would use.
+// MaxWorkers is left unset as vegeta does not limit the number of workers by default.
+func (a *AttackSpec) Default() {
+	if a.Rate == "" && len(a.Stages) == 0 {
+		a.Rate = defaultRate
+	}
+	for i := range a.Stages {
+		a.Stages[i].Default()
+	}
+	if a.Workers == 0 {
+		a.Workers = defaultWorkers
+		if a.MaxWorkers != 0 && a.MaxWorkers < a.Workers {
+			a.Workers = a.MaxWorkers
+		}
+	}
+	if a.Connections == 0 {
+		a.Connections = defaultConnections
+	}
+	if a.KeepAlive == nil {
+		keepAlive := true
+		a.KeepAlive = &keepAlive
+	}
+	if a.Redirects == nil {
+		redirects := int32(defaultRedirects)
+		a.Redirects = &redirects
+	}
+	if a.Timeout == "" {
+		a.Timeout = defaultTimeout
+	}
+	if a.Format == "" {
+		if len(a.Targets) > 0 || a.TargetGenerator != nil || a.OpenAPI != nil {
+			a.Format = JSONFormat
+		} else {
+			a.Format = HTTPFormat
+		}
+	}
+	for i := range a.Targets {
+		if a.Targets[i].Method == "" {
+			a.Targets[i].Method = http.MethodGet
+		}
+	}
+	if a.TargetRef != nil && a.TargetRef.Method == "" {
+		a.TargetRef.Method = http.MethodGet
+	}
+	if a.TargetGenerator != nil {
+		// Generated targets are streamed without end unless the dataset is used once
+		a.Lazy = true
+		a.TargetGenerator.Default()
+	}
+}
+
+// Default sets the method of the generated requests and the format of their dataset.
+func (g *TargetGenerator) Default() {
+	if g.Method == "" {
+		g.Method = http.MethodGet
+	}
+	if g.Dataset != nil && g.Dataset.Format == "" {
+		g.Dataset.Format = CSVDataset
+		switch path.Ext(g.Dataset.fileName()) {
+		case ".json", ".jsonl", ".ndjson":
+			g.Dataset.Format = JSONDataset
+		}
+	}
+}
+
+// fileName returns the name of the key or of the file containing the dataset
+func (d *DatasetSource) fileName() string {
+	switch {
+	case d.ConfigMapKeyRef != nil:
+		return d.ConfigMapKeyRef.Key
+	case d.PersistentVolumeClaim != nil:
+		return d.PersistentVolumeClaim.Path
+	default:
+		return ""
+	}
+}
+
+// Default sets the shape of the stage and the duration of the steps approximating linear and sine shapes.
+func (s *Stage) Default() {
+	if s.Shape == "" {
+		s.Shape = ConstantShape
+	}
+	if s.StepDuration == "" && s.Shape != ConstantShape {
+		s.StepDuration = defaultStep
+	}
+}
+
+// Default sets the report parameters that have not been specified to the values used by the operator and vegeta.
+func (rep *ReportSpec) Default() {
+	if rep.OutputType == "" {
+		rep.OutputType = StdoutOutput
+	}
+	if rep.Type == "" {
+		rep.Type = TextReport
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-vegeta-testing-io-v1alpha1-vegeta,mutating=false,failurePolicy=fail,sideEffects=None,groups=vegeta.testing.io,resources=vegeta,verbs=create;update,versions=v1alpha1,name=vvegeta.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &Vegeta{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Vegeta) ValidateCreate() error {
+	vegetalog.V(1).Info("validate create", "name", r.Name)
+	return r.toInvalid(r.validateSpec())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+// Attack, replicas and report cannot be changed anymore once the pods have been created.
+func (r *Vegeta) ValidateUpdate(old runtime.Object) error {
+	vegetalog.V(1).Info("validate update", "name", r.Name)
+	allErrs := r.validateSpec()
+	oldVegeta, ok := old.(*Vegeta)
+	if !ok {
+		return fmt.Errorf("Expected a Vegeta resource but got a %T", old)
+	}
+	specPath := field.NewPath("spec")
+	switch {
+	case r.Spec.RunID != oldVegeta.Spec.RunID && oldVegeta.Status.Phase != "":
+		// A new run can use a modified specification
+		if !oldVegeta.Status.Phase.IsTerminated() {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("runID"), "a new run can only be started once the current one has terminated"))
+		}
+		if r.Spec.Abort || r.Annotations[AbortAnnotation] == "true" {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("runID"), "the abort request needs to be removed to start a new run"))
+		}
+	case oldVegeta.Status.Phase != "":
+		// Resources stored before the defaulting webhook was in place don't show the defaults
+		newVegeta, oldVegeta := r.DeepCopy(), oldVegeta.DeepCopy()
+		newVegeta.Default()
+		oldVegeta.Default()
+		if !equality.Semantic.DeepEqual(newVegeta.Spec.Attack, oldVegeta.Spec.Attack) {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("attack"), "the attack cannot be modified once the pods have been created"))
+		}
+		if newVegeta.Spec.Replicas != oldVegeta.Spec.Replicas {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("replicas"), "the number of replicas cannot be modified once the pods have been created"))
+		}
+		if newVegeta.Spec.RateMode != oldVegeta.Spec.RateMode {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("rateMode"), "the rate mode cannot be modified once the pods have been created"))
+		}
+		if newVegeta.Spec.Spread != oldVegeta.Spec.Spread {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("spread"), "the placement policy cannot be modified once the pods have been created"))
+		}
+		if !equality.Semantic.DeepEqual(newVegeta.Spec.Report, oldVegeta.Spec.Report) {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("report"), "the report cannot be modified once the pods have been created"))
+		}
+		if !equality.Semantic.DeepEqual(newVegeta.Spec.PodTemplate, oldVegeta.Spec.PodTemplate) {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("podTemplate"), "the pod template cannot be modified once the pods have been created"))
+		}
+		if !equality.Semantic.DeepEqual(newVegeta.Spec.CircuitBreaker, oldVegeta.Spec.CircuitBreaker) {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("circuitBreaker"), "the circuit breaker cannot be modified once the pods have been created"))
+		}
+	}
+	return r.toInvalid(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Vegeta) ValidateDelete() error {
+	// Nothing to validate on deletion
+	return nil
+}
+
+// toInvalid converts a list of field errors into an invalid error for the vegeta resource
+func (r *Vegeta) toInvalid(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "Vegeta"}, r.Name, allErrs)
+}
+
+// validateSpec checks the cross-field rules and the formats that vegeta would otherwise only reject at runtime
+func (r *Vegeta) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if r.Spec.Attack == nil {
+		return append(allErrs, field.Required(specPath.Child("attack"), "the attack parameters must be specified"))
+	}
+	allErrs = append(allErrs, validateAttack(r.Spec.Attack, specPath.Child("attack"))...)
+	if r.Spec.RateMode == TotalRate && r.Spec.Attack.MaxWorkers != 0 && r.Spec.Attack.MaxWorkers < uint64(r.Spec.Replicas) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("attack").Child("maxWorkers"), r.Spec.Attack.MaxWorkers, "maxWorkers cannot be lower than the number of replicas when it is divided across them"))
+	}
+	if r.Spec.Report != nil {
+		allErrs = append(allErrs, validateReport(r.Spec.Report, specPath.Child("report"))...)
+	}
+	if r.Spec.RunID != "" {
+		for _, msg := range validation.IsValidLabelValue(r.Spec.RunID) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("runID"), r.Spec.RunID, msg))
+		}
+	}
+	if r.Spec.CircuitBreaker != nil {
+		allErrs = append(allErrs, validateCircuitBreaker(r.Spec.CircuitBreaker, specPath.Child("circuitBreaker"))...)
+	}
+	for i, expr := range r.Spec.Thresholds {
+		if _, err := ParseThreshold(expr); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("thresholds").Index(i), expr, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+func validateAttack(a *AttackSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	sources := 0
+	for _, set := range []bool{a.Target != "", a.TargetsConfigMap != "", len(a.Targets) > 0, a.TargetRef != nil, a.TargetGenerator != nil, a.OpenAPI != nil} {
+		if set {
+			sources++
+		}
+	}
+	switch {
+	case sources > 1:
+		allErrs = append(allErrs, field.Invalid(path.Child("target"), a.Target, "target, targetsConfigMap, targets, targetRef, targetGenerator and openAPI are mutually exclusive"))
+	case sources == 0:
+		allErrs = append(allErrs, field.Required(path.Child("target"), "one of target, targetsConfigMap, targets, targetRef, targetGenerator or openAPI must be specified"))
+	}
+	if a.WeightedTargets && a.TargetsConfigMap == "" {
+		allErrs = append(allErrs, field.Invalid(path.Child("weightedTargets"), a.WeightedTargets, "weightedTargets requires targetsConfigMap, inline targets carry their own weight"))
+	}
+	if a.Format == JSONFormat && a.TargetsConfigMap == "" && len(a.Targets) == 0 && a.TargetGenerator == nil && a.OpenAPI == nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("format"), a.Format, "the json format requires targetsConfigMap, targets, targetGenerator or openAPI"))
+	}
+	if a.Format == HTTPFormat && len(a.Targets) > 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("format"), a.Format, "inline targets are rendered in the json format"))
+	}
+	if a.Format == HTTPFormat && a.TargetGenerator != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("format"), a.Format, "generated targets are in the json format"))
+	}
+	if a.Format == HTTPFormat && a.OpenAPI != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("format"), a.Format, "the targets derived from an OpenAPI document are rendered in the json format"))
+	}
+	for i := range a.Targets {
+		allErrs = append(allErrs, validateTarget(&a.Targets[i], path.Child("targets").Index(i))...)
+	}
+	allErrs = append(allErrs, validateTargetMix(a.Targets, path.Child("targets"))...)
+	if a.TargetRef != nil {
+		allErrs = append(allErrs, validateTargetRef(a.TargetRef, path.Child("targetRef"))...)
+	}
+	if a.TargetGenerator != nil {
+		allErrs = append(allErrs, validateTargetGenerator(a.TargetGenerator, path.Child("targetGenerator"))...)
+	}
+	if a.OpenAPI != nil {
+		allErrs = append(allErrs, validateOpenAPI(a.OpenAPI, path.Child("openAPI"))...)
+	}
+	if a.ClientCertSecret != "" && a.KeySecret != "" {
+		allErrs = append(allErrs, field.Invalid(path.Child("keySecret"), a.KeySecret, "keySecret and clientCertSecret are mutually exclusive, the private key is taken from the tls.key of clientCertSecret"))
+	}
+	if a.BodyConfigMap != "" && a.TargetsConfigMap != "" && !a.WeightedTargets {
+		// Both config maps would be mounted under /opt/config/ and the attack pods could not be created
+		allErrs = append(allErrs, field.Invalid(path.Child("bodyConfigMap"), a.BodyConfigMap, "bodyConfigMap and targetsConfigMap are mutually exclusive as both are mounted under /opt/config/, the bodies can be added to targetsConfigMap instead"))
+	}
+	if a.RootCertsFile != "" && a.RootCertsConfigMap == "" {
+		allErrs = append(allErrs, field.Invalid(path.Child("rootCertsFile"), a.RootCertsFile, "rootCertsFile requires rootCertsConfigMap"))
+	}
+
+	for name, value := range map[string]string{"duration": a.Duration, "timeout": a.Timeout} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child(name), value, err.Error()))
+		}
+	}
+
+	if a.Rate != "" {
+		freq, _, err := ParseRate(a.Rate)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("rate"), a.Rate, err.Error()))
+		} else if freq == 0 && a.MaxWorkers == 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("rate"), a.Rate, "a rate of 0 or infinity requires maxWorkers to be set"))
+		}
+	}
+
+	if len(a.Stages) > 0 {
+		if a.Rate != "" {
+			allErrs = append(allErrs, field.Invalid(path.Child("rate"), a.Rate, "rate and stages are mutually exclusive"))
+		}
+		if a.Duration != "" {
+			allErrs = append(allErrs, field.Invalid(path.Child("duration"), a.Duration, "duration and stages are mutually exclusive"))
+		}
+	}
+	for i := range a.Stages {
+		allErrs = append(allErrs, validateStage(&a.Stages[i], path.Child("stages").Index(i))...)
+	}
+
+	if a.MaxWorkers != 0 && a.Workers > a.MaxWorkers {
+		allErrs = append(allErrs, field.Invalid(path.Child("workers"), a.Workers, "workers cannot be greater than maxWorkers"))
+	}
+
+	if a.Redirects != nil && *a.Redirects < -1 {
+		allErrs = append(allErrs, field.Invalid(path.Child("redirects"), *a.Redirects, "redirects must be -1 or greater"))
+	}
+
+	for i, h := range a.Headers {
+		if err := validateHeader(h); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("headers").Index(i), h, err.Error()))
+		}
+	}
+	if a.ProxyHeader != "" {
+		if err := validateHeader(a.ProxyHeader); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("proxyHeader"), a.ProxyHeader, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+func validateCircuitBreaker(b *CircuitBreakerSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if b.MaxErrorRatio == "" && b.MaxP99 == "" {
+		allErrs = append(allErrs, field.Required(path, "at least one of maxErrorRatio or maxP99 must be specified"))
+	}
+	if b.Window != "" {
+		if d, err := time.ParseDuration(b.Window); err != nil || d <= 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("window"), b.Window, "the window must be a positive duration"))
+		}
+	}
+	if b.MaxErrorRatio != "" {
+		if r, err := ParseRatio(b.MaxErrorRatio); err != nil || r == 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("maxErrorRatio"), b.MaxErrorRatio, "the maximum error ratio must be a percentage, e.g. 5%, or a number greater than 0 and lower than or equal to 1"))
+		}
+	}
+	if b.MaxP99 != "" {
+		if d, err := time.ParseDuration(b.MaxP99); err != nil || d <= 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("maxP99"), b.MaxP99, "the maximum p99 must be a positive duration"))
+		}
+	}
+
+	return allErrs
+}
+
+func validateStage(s *Stage, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if d, err := time.ParseDuration(s.Duration); err != nil || d <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("duration"), s.Duration, "the duration of a stage must be positive"))
+	}
+	rate, err := RatePerSecond(s.Rate)
+	if err != nil || rate <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("rate"), s.Rate, "the rate of a stage must be positive and have the freq/duration format (i.e. 50/1s)"))
+	}
+
+	shape := s.Shape
+	if shape == "" {
+		shape = ConstantShape
+	}
+	if shape != LinearShape && s.TargetRate != "" {
+		allErrs = append(allErrs, field.Invalid(path.Child("targetRate"), s.TargetRate, "targetRate is only used with the linear shape"))
+	}
+	if shape != SineShape && s.Amplitude != "" {
+		allErrs = append(allErrs, field.Invalid(path.Child("amplitude"), s.Amplitude, "amplitude is only used with the sine shape"))
+	}
+	if shape != SineShape && s.Period != "" {
+		allErrs = append(allErrs, field.Invalid(path.Child("period"), s.Period, "period is only used with the sine shape"))
+	}
+	if shape == ConstantShape && s.StepDuration != "" {
+		allErrs = append(allErrs, field.Invalid(path.Child("stepDuration"), s.StepDuration, "stepDuration is only used with the linear and sine shapes"))
+	}
+
+	switch shape {
+	case LinearShape:
+		if _, err := RatePerSecond(s.TargetRate); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("targetRate"), s.TargetRate, "the linear shape requires a targetRate with the freq/duration format (i.e. 50/1s)"))
+		}
+	case SineShape:
+		amplitude, err := RatePerSecond(s.Amplitude)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("amplitude"), s.Amplitude, "the sine shape requires an amplitude with the freq/duration format (i.e. 50/1s)"))
+		} else if amplitude > rate {
+			allErrs = append(allErrs, field.Invalid(path.Child("amplitude"), s.Amplitude, "the amplitude cannot be greater than the rate"))
+		}
+		if d, err := time.ParseDuration(s.Period); err != nil || d <= 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("period"), s.Period, "the sine shape requires a positive period"))
+		}
+	}
+	if s.StepDuration != "" {
+		if d, err := time.ParseDuration(s.StepDuration); err != nil || d <= 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("stepDuration"), s.StepDuration, "the step duration must be positive"))
+		}
+	}
+
+	return allErrs
+}
+
+func validateTarget(t *AttackTarget, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if t.Method != "" && strings.IndexFunc(t.Method, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("method"), t.Method, "the method must be an uppercase HTTP method, e.g. GET"))
+	}
+	if u, err := url.ParseRequestURI(t.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		allErrs = append(allErrs, field.Invalid(path.Child("url"), t.URL, "the url must be absolute, e.g. https://kubernetes.default.svc.cluster.local:443/healthz"))
+	}
+	for i, h := range t.Headers {
+		if err := validateHeader(h); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("headers").Index(i), h, err.Error()))
+		}
+	}
+	if t.BodyFrom != nil {
+		bodyFromPath := path.Child("bodyFrom")
+		if t.Body != "" {
+			allErrs = append(allErrs, field.Invalid(bodyFromPath, "", "body and bodyFrom are mutually exclusive"))
+		}
+		if (t.BodyFrom.ConfigMapKeyRef == nil) == (t.BodyFrom.SecretKeyRef == nil) {
+			allErrs = append(allErrs, field.Invalid(bodyFromPath, "", "exactly one of configMapKeyRef or secretKeyRef must be specified"))
+		}
+	}
+	if t.Weight > MaxTargetWeight {
+		allErrs = append(allErrs, field.Invalid(path.Child("weight"), t.Weight, fmt.Sprintf("the weight must not exceed %d", MaxTargetWeight)))
+	}
+
+	return allErrs
+}
+
+// validateTargetMix checks that weighted targets with the same method and url are identical, as the breakdown of the results identifies the targets by their method and url
+func validateTargetMix(targets []AttackTarget, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	weighted := false
+	for i := range targets {
+		weighted = weighted || targets[i].Weight > 0
+	}
+	if !weighted {
+		return allErrs
+	}
+	first := map[string]int{}
+	for i := range targets {
+		t := targets[i].DeepCopy()
+		t.Weight = 0
+		if t.Method == "" {
+			t.Method = http.MethodGet
+		}
+		key := t.Method + " " + t.URL
+		j, ok := first[key]
+		if !ok {
+			first[key] = i
+			continue
+		}
+		other := targets[j].DeepCopy()
+		other.Weight = 0
+		if other.Method == "" {
+			other.Method = http.MethodGet
+		}
+		if !equality.Semantic.DeepEqual(t, other) {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), key, fmt.Sprintf("the target shares its method and url with target %d but not its headers or body, their requests could not be told apart in the traffic mix", j)))
+		}
+	}
+
+	return allErrs
+}
+
+func validateTargetRef(ref *TargetRef, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	switch ref.Kind {
+	case ServiceKind, RouteKind, IngressKind, HTTPRouteKind:
+	default:
+		allErrs = append(allErrs, field.NotSupported(path.Child("kind"), ref.Kind, []string{ServiceKind.String(), RouteKind.String(), IngressKind.String(), HTTPRouteKind.String()}))
+	}
+	if ref.Name == "" {
+		allErrs = append(allErrs, field.Required(path.Child("name"), "the name of the referenced resource must be specified"))
+	}
+	if ref.Kind != ServiceKind {
+		if ref.Port != "" {
+			allErrs = append(allErrs, field.Invalid(path.Child("port"), ref.Port, "the port can only be specified for a Service"))
+		}
+		if ref.Scheme != "" {
+			allErrs = append(allErrs, field.Invalid(path.Child("scheme"), ref.Scheme, "the scheme can only be specified for a Service"))
+		}
+		if ref.PerEndpoint {
+			allErrs = append(allErrs, field.Invalid(path.Child("perEndpoint"), ref.PerEndpoint, "the endpoints can only be attacked directly for a Service"))
+		}
+	}
+	if ref.Scheme != "" && ref.Scheme != "http" && ref.Scheme != "https" {
+		allErrs = append(allErrs, field.NotSupported(path.Child("scheme"), ref.Scheme, []string{"http", "https"}))
+	}
+	if ref.Path != "" && !strings.HasPrefix(ref.Path, "/") {
+		allErrs = append(allErrs, field.Invalid(path.Child("path"), ref.Path, "the path must start with /"))
+	}
+	if ref.Method != "" && strings.IndexFunc(ref.Method, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("method"), ref.Method, "the method must be an uppercase HTTP method, e.g. GET"))
+	}
+
+	return allErrs
+}
+
+func validateTargetGenerator(g *TargetGenerator, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if g.Method != "" && strings.IndexFunc(g.Method, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("method"), g.Method, "the method must be an uppercase HTTP method, e.g. GET"))
+	}
+	if !strings.HasPrefix(g.URL, "http://") && !strings.HasPrefix(g.URL, "https://") {
+		allErrs = append(allErrs, field.Invalid(path.Child("url"), g.URL, "the url must be absolute, e.g. https://api.example.com/users/{{.userId}}"))
+	}
+	if err := validateTemplate(g.URL); err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("url"), g.URL, err.Error()))
+	}
+	for i, h := range g.Headers {
+		if err := validateHeader(h); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("headers").Index(i), h, err.Error()))
+		} else if err := validateTemplate(h); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("headers").Index(i), h, err.Error()))
+		}
+	}
+	if err := validateTemplate(g.Body); err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("body"), g.Body, err.Error()))
+	}
+	if g.Dataset == nil {
+		if g.Once {
+			allErrs = append(allErrs, field.Invalid(path.Child("once"), g.Once, "once requires a dataset"))
+		}
+		return allErrs
+	}
+	datasetPath := path.Child("dataset")
+	if (g.Dataset.ConfigMapKeyRef == nil) == (g.Dataset.PersistentVolumeClaim == nil) {
+		allErrs = append(allErrs, field.Invalid(datasetPath, "", "exactly one of configMapKeyRef or persistentVolumeClaim must be specified"))
+	}
+	if claim := g.Dataset.PersistentVolumeClaim; claim != nil {
+		if claim.ClaimName == "" {
+			allErrs = append(allErrs, field.Required(datasetPath.Child("persistentVolumeClaim", "claimName"), "the name of the claim must be specified"))
+		}
+		if !isVolumePath(claim.Path) {
+			allErrs = append(allErrs, field.Invalid(datasetPath.Child("persistentVolumeClaim", "path"), claim.Path, "the path must be relative to the root of the volume and stay within it"))
+		}
+	}
+
+	return allErrs
+}
+
+func validateOpenAPI(o *OpenAPISource, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if (o.ConfigMapKeyRef == nil) == (o.URL == "") {
+		allErrs = append(allErrs, field.Invalid(path, "", "exactly one of configMapKeyRef or url must be specified"))
+	}
+	for name, value := range map[string]string{"url": o.URL, "server": o.Server} {
+		if value == "" {
+			continue
+		}
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(path.Child(name), value, "the url must be absolute with the http or https scheme"))
+		}
+	}
+	for name, patterns := range map[string][]string{"include": o.Include, "exclude": o.Exclude} {
+		for i, p := range patterns {
+			if strings.TrimSpace(p) == "" {
+				allErrs = append(allErrs, field.Invalid(path.Child(name).Index(i), p, "the entry must be an operationId, a tag or a method and a path, e.g. GET /users/*"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// isVolumePath returns true if the path is relative and stays within the volume it is resolved against
+func isVolumePath(p string) bool {
+	clean := path.Clean(p)
+	return p != "" && !path.IsAbs(clean) && clean != "." && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+// validateTemplate checks that a template of generated targets can be parsed
+func validateTemplate(text string) error {
+	if _, err := template.New("").Funcs(generatorFuncs).Parse(text); err != nil {
+		return fmt.Errorf("invalid template: %v", err)
+	}
+	return nil
+}
+
+func validateReport(rep *ReportSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	switch rep.OutputType {
+	case PvcOutput, ObcOutput:
+		if rep.OutputClaim == "" {
+			allErrs = append(allErrs, field.Required(path.Child("outputClaim"), fmt.Sprintf("outputClaim is required with the %s output type", rep.OutputType)))
+		}
+	default:
+		if rep.OutputClaim != "" {
+			allErrs = append(allErrs, field.Invalid(path.Child("outputClaim"), rep.OutputClaim, "outputClaim is only used with the pvc and obc output types"))
+		}
+	}
+
+	if rep.Every != "" {
+		if _, err := time.ParseDuration(rep.Every); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("every"), rep.Every, err.Error()))
+		}
+	}
+
+	if rep.Buckets != "" {
+		if err := validateBuckets(rep.Buckets); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("buckets"), rep.Buckets, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// ParseRate parses a rate the way vegeta does: freq/duration, e.g. 50/1s. The duration defaults to 1s and its leading 1 may be omitted, e.g. 50/s.
+// 0 and infinity mean that there is no rate limit. They are returned with a frequency of 0.
+func ParseRate(rate string) (int, time.Duration, error) {
+	if rate == "infinity" {
+		return 0, 0, nil
+	}
+	ps := strings.SplitN(rate, "/", 2)
+	if len(ps) == 1 {
+		ps = append(ps, "1s")
+	}
+	freq, err := strconv.Atoi(ps[0])
+	if err != nil || freq < 0 {
+		return 0, 0, fmt.Errorf("the rate %q doesn't match the freq/duration format (i.e. 50/1s)", rate)
+	}
+	if freq == 0 {
+		return 0, 0, nil
+	}
+	switch ps[1] {
+	case "ns", "us", "µs", "ms", "s", "m", "h":
+		ps[1] = "1" + ps[1]
+	}
+	per, err := time.ParseDuration(ps[1])
+	if err != nil || per <= 0 {
+		return 0, 0, fmt.Errorf("the rate %q doesn't have a valid duration (i.e. 50/1s)", rate)
+	}
+	return freq, per, nil
+}
+
+// RatePerSecond converts a rate with the freq/duration format into a number of requests per second.
+// 0 and infinity are returned as 0.
+func RatePerSecond(rate string) (float64, error) {
+	freq, per, err := ParseRate(rate)
+	if err != nil || freq == 0 {
+		return 0, err
+	}
+	return float64(freq) / per.Seconds(), nil
+}
+
+// validateHeader checks that a header has the Key: Value format expected by vegeta and is a valid HTTP header.
+// Line breaks are rejected in particular: the headers are rendered into targets in the http format, where they would inject further lines.
+func validateHeader(h string) error {
+	ps := strings.SplitN(h, ":", 2)
+	if len(ps) != 2 {
+		return fmt.Errorf("the header doesn't match the Key: Value format")
+	}
+	key := strings.TrimSpace(ps[0])
+	if key == "" {
+		return fmt.Errorf("the header has a missing key")
+	}
+	if !httpguts.ValidHeaderFieldName(key) {
+		return fmt.Errorf("the header key %q is not a valid HTTP header name", key)
+	}
+	if !httpguts.ValidHeaderFieldValue(strings.TrimSpace(ps[1])) {
+		return fmt.Errorf("the header value contains characters not allowed in HTTP headers, e.g. a line break")
+	}
+	return nil
+}
+
+// validateBuckets checks that the histogram buckets have the [0,1ms,10ms] format expected by vegeta and are sorted
+func validateBuckets(buckets string) error {
+	if len(buckets) < 2 || buckets[0] != '[' || buckets[len(buckets)-1] != ']' {
+		return fmt.Errorf("the buckets don't match the [0,1ms,10ms] format")
+	}
+	var previous time.Duration
+	for i, b := range strings.Split(buckets[1:len(buckets)-1], ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(b))
+		if err != nil {
+			return fmt.Errorf("the bucket %q is not a valid duration", strings.TrimSpace(b))
+		}
+		if i > 0 && d <= previous {
+			return fmt.Errorf("the buckets must be sorted in ascending order")
+		}
+		previous = d
+	}
+	return nil
+}
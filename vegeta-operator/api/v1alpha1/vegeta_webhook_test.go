@@ -0,0 +1,462 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Vegeta webhook", func() {
+	var vegeta *Vegeta
+
+	BeforeEach(func() {
+		vegeta = &Vegeta{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-vegeta",
+				Namespace: "test-vegeta",
+			},
+			Spec: VegetaSpec{
+				Attack: &AttackSpec{
+					Duration: "10s",
+					Rate:     "5/1s",
+					Target:   "GET https://kubernetes.default.svc.cluster.local:443/healthz",
+				},
+				Replicas: 1,
+			},
+		}
+	})
+
+	Context("When a Vegeta resource is defaulted", func() {
+		It("Should materialise the values used by vegeta", func() {
+			vegeta.Spec.Attack.Rate = ""
+			vegeta.Spec.Replicas = 0
+			vegeta.Default()
+			Expect(vegeta.Spec.Replicas).To(Equal(uint32(1)))
+			Expect(vegeta.Spec.Spread).To(BeEmpty())
+			Expect(vegeta.Spec.RateMode).To(Equal(PerReplicaRate))
+			Expect(vegeta.Spec.Attack.Rate).To(Equal("50/1s"))
+			Expect(vegeta.Spec.Attack.Workers).To(Equal(uint64(10)))
+			Expect(vegeta.Spec.Attack.Connections).To(Equal(uint32(10000)))
+			Expect(*vegeta.Spec.Attack.KeepAlive).To(BeTrue())
+			Expect(*vegeta.Spec.Attack.Redirects).To(Equal(int32(10)))
+			Expect(vegeta.Spec.Attack.Timeout).To(Equal("30s"))
+			Expect(vegeta.Spec.Attack.Format).To(Equal(HTTPFormat))
+			Expect(vegeta.Spec.Report).NotTo(BeNil())
+			Expect(vegeta.Spec.Report.OutputType).To(Equal(StdoutOutput))
+			Expect(vegeta.Spec.Report.Type).To(Equal(TextReport))
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+		})
+		It("Should keep the values that have been specified", func() {
+			keepAlive := false
+			redirects := int32(0)
+			vegeta.Spec.Attack.KeepAlive = &keepAlive
+			vegeta.Spec.Attack.Redirects = &redirects
+			vegeta.Spec.Attack.MaxWorkers = 4
+			vegeta.Default()
+			Expect(vegeta.Spec.Attack.Rate).To(Equal("5/1s"))
+			Expect(*vegeta.Spec.Attack.KeepAlive).To(BeFalse())
+			Expect(*vegeta.Spec.Attack.Redirects).To(Equal(int32(0)))
+			Expect(vegeta.Spec.Attack.Workers).To(Equal(uint64(4)))
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+		})
+		It("Should not consider the defaults as changes of resources created without them", func() {
+			vegeta.Status.Phase = RunningPhase
+			updated := vegeta.DeepCopy()
+			updated.Labels = map[string]string{"team": "perf"}
+			updated.Default()
+			Expect(updated.ValidateUpdate(vegeta)).To(Succeed())
+		})
+	})
+
+	Context("When a Vegeta resource is created", func() {
+		It("Should accept a valid specification", func() {
+			vegeta.Spec.Attack.Headers = []string{"Authorization: Bearer token"}
+			vegeta.Spec.Report = &ReportSpec{
+				OutputType:  PvcOutput,
+				OutputClaim: "claim",
+				Buckets:     "[0,1ms,10ms]",
+				Every:       "1s",
+			}
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+		})
+		It("Should reject target and targetsConfigMap together", func() {
+			vegeta.Spec.Attack.TargetsConfigMap = "targets"
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should accept inline targets and reject them together with target", func() {
+			vegeta.Spec.Attack.Targets = []AttackTarget{
+				{URL: "https://example.com/healthz"},
+				{Method: "POST", URL: "https://example.com/things", BodyFrom: &BodySource{
+					SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "body"}, Key: "body.json"},
+				}},
+			}
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+			vegeta.Spec.Attack.Target = ""
+			vegeta.Default()
+			Expect(vegeta.Spec.Attack.Format).To(Equal(JSONFormat))
+			Expect(vegeta.Spec.Attack.Targets[0].Method).To(Equal("GET"))
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+		})
+		It("Should reject invalid inline targets", func() {
+			vegeta.Spec.Attack.Target = ""
+			for _, target := range []AttackTarget{
+				{URL: "/relative"},
+				{Method: "get", URL: "https://example.com"},
+				{URL: "https://example.com", Headers: []string{"no-separator"}},
+				{URL: "https://example.com", Body: "body", BodyFrom: &BodySource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "body"}, Key: "body.json"},
+				}},
+				{URL: "https://example.com", BodyFrom: &BodySource{}},
+				{URL: "https://example.com", Weight: MaxTargetWeight + 1},
+			} {
+				vegeta.Spec.Attack.Targets = []AttackTarget{target}
+				Expect(vegeta.ValidateCreate()).NotTo(Succeed(), "target %v", target)
+			}
+		})
+		It("Should reject weighted targets sharing their method and url but not their headers or body", func() {
+			vegeta.Spec.Attack.Target = ""
+			vegeta.Spec.Attack.Targets = []AttackTarget{
+				{URL: "https://shop.example.com/items", Weight: 70},
+				{Method: "GET", URL: "https://shop.example.com/items", Weight: 30},
+			}
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+			vegeta.Spec.Attack.Targets[1].Headers = []string{"Accept: text/html"}
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+			// Without weights there is no traffic mix to verify
+			vegeta.Spec.Attack.Targets[0].Weight = 0
+			vegeta.Spec.Attack.Targets[1].Weight = 0
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+		})
+		It("Should only accept weighted targets with a targets config map", func() {
+			vegeta.Spec.Attack.WeightedTargets = true
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+			vegeta.Spec.Attack.Target = ""
+			vegeta.Spec.Attack.TargetsConfigMap = "targets"
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+		})
+		It("Should accept a target reference and reject it together with target", func() {
+			vegeta.Spec.Attack.TargetRef = &TargetRef{Kind: ServiceKind, Name: "api", Port: "https", Path: "/healthz"}
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+			vegeta.Spec.Attack.Target = ""
+			vegeta.Default()
+			Expect(vegeta.Spec.Attack.TargetRef.Method).To(Equal("GET"))
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+		})
+		It("Should reject invalid target references", func() {
+			vegeta.Spec.Attack.Target = ""
+			for _, ref := range []TargetRef{
+				{Kind: "Deployment", Name: "api"},
+				{Kind: ServiceKind},
+				{Kind: RouteKind, Name: "web", Port: "https"},
+				{Kind: ServiceKind, Name: "api", Scheme: "grpc"},
+				{Kind: IngressKind, Name: "web", Path: "api"},
+				{Kind: HTTPRouteKind, Name: "web", Method: "get"},
+				{Kind: IngressKind, Name: "web", PerEndpoint: true},
+			} {
+				vegeta.Spec.Attack.TargetRef = ref.DeepCopy()
+				Expect(vegeta.ValidateCreate()).NotTo(Succeed(), "target reference %v", ref)
+			}
+		})
+		It("Should default and accept a target generator and reject it together with target", func() {
+			vegeta.Spec.Attack.TargetGenerator = &TargetGenerator{
+				URL:     "https://api.example.com/users/{{.userId}}",
+				Headers: []string{"X-Request-Id: {{uuid}}"},
+				Body:    `{"quantity": {{randInt 1 10}}}`,
+				Dataset: &DatasetSource{PersistentVolumeClaim: &DatasetClaim{ClaimName: "datasets", Path: "shop/users.ndjson"}},
+			}
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+			vegeta.Spec.Attack.Target = ""
+			vegeta.Default()
+			Expect(vegeta.Spec.Attack.Format).To(Equal(JSONFormat))
+			Expect(vegeta.Spec.Attack.Lazy).To(BeTrue())
+			Expect(vegeta.Spec.Attack.TargetGenerator.Method).To(Equal("GET"))
+			Expect(vegeta.Spec.Attack.TargetGenerator.Dataset.Format).To(Equal(JSONDataset))
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+
+			vegeta.Spec.Attack.TargetGenerator.Dataset = &DatasetSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "users"}, Key: "users"}}
+			vegeta.Default()
+			Expect(vegeta.Spec.Attack.TargetGenerator.Dataset.Format).To(Equal(CSVDataset))
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+		})
+		It("Should reject invalid target generators", func() {
+			vegeta.Spec.Attack.Target = ""
+			users := &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "users"}, Key: "users.csv"}
+			for _, g := range []TargetGenerator{
+				{URL: "/users/{{.userId}}"},
+				{URL: "https://api.example.com/users/{{.userId"},
+				{URL: "https://api.example.com/users/{{unknown}}"},
+				{Method: "post", URL: "https://api.example.com/users"},
+				{URL: "https://api.example.com/users", Headers: []string{"{{uuid}}"}},
+				{URL: "https://api.example.com/users", Body: "{{randInt 1}"},
+				{URL: "https://api.example.com/users", Once: true},
+				{URL: "https://api.example.com/users", Dataset: &DatasetSource{}},
+				{URL: "https://api.example.com/users", Dataset: &DatasetSource{ConfigMapKeyRef: users, PersistentVolumeClaim: &DatasetClaim{ClaimName: "datasets", Path: "users.csv"}}},
+				{URL: "https://api.example.com/users", Dataset: &DatasetSource{PersistentVolumeClaim: &DatasetClaim{ClaimName: "datasets", Path: "/data/users.csv"}}},
+				{URL: "https://api.example.com/users", Dataset: &DatasetSource{PersistentVolumeClaim: &DatasetClaim{ClaimName: "datasets", Path: "data/../../users.csv"}}},
+			} {
+				vegeta.Spec.Attack.TargetGenerator = g.DeepCopy()
+				Expect(vegeta.ValidateCreate()).NotTo(Succeed(), "target generator %v", g)
+			}
+			vegeta.Spec.Attack.TargetGenerator = &TargetGenerator{URL: "https://api.example.com/users"}
+			vegeta.Spec.Attack.Format = HTTPFormat
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should default and accept an OpenAPI document and reject it together with target", func() {
+			vegeta.Spec.Attack.OpenAPI = &OpenAPISource{
+				URL:     "https://petstore.example.com/openapi.yaml",
+				Include: []string{"pets", "GET /pets/*"},
+				Exclude: []string{"deletePet"},
+			}
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+			vegeta.Spec.Attack.Target = ""
+			vegeta.Default()
+			Expect(vegeta.Spec.Attack.Format).To(Equal(JSONFormat))
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+			vegeta.Spec.Attack.Format = HTTPFormat
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject invalid OpenAPI sources", func() {
+			vegeta.Spec.Attack.Target = ""
+			petstore := &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "petstore"}, Key: "openapi.yaml"}
+			for _, o := range []OpenAPISource{
+				{},
+				{ConfigMapKeyRef: petstore, URL: "https://petstore.example.com/openapi.yaml"},
+				{URL: "/openapi.yaml"},
+				{URL: "ftp://petstore.example.com/openapi.yaml"},
+				{ConfigMapKeyRef: petstore, Server: "petstore.example.com"},
+				{ConfigMapKeyRef: petstore, Include: []string{" "}},
+				{ConfigMapKeyRef: petstore, Exclude: []string{""}},
+			} {
+				vegeta.Spec.Attack.OpenAPI = o.DeepCopy()
+				Expect(vegeta.ValidateCreate()).NotTo(Succeed(), "OpenAPI source %v", o)
+			}
+		})
+		It("Should reject a client certificate secret together with a key secret", func() {
+			vegeta.Spec.Attack.ClientCertSecret = "client-tls"
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+			vegeta.Spec.Attack.KeySecret = "client-key"
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject a body config map together with a targets config map", func() {
+			vegeta.Spec.Attack.Target = ""
+			vegeta.Spec.Attack.TargetsConfigMap = "targets"
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+			vegeta.Spec.Attack.BodyConfigMap = "body"
+			Expect(vegeta.ValidateCreate()).To(MatchError(ContainSubstring("bodyConfigMap and targetsConfigMap are mutually exclusive")))
+			// The weighted targets are rendered into a secret, the body config map is the only one mounted under /opt/config/
+			vegeta.Spec.Attack.WeightedTargets = true
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+		})
+		It("Should accept a load profile instead of rate and duration", func() {
+			vegeta.Spec.Attack.Stages = []Stage{
+				{Shape: LinearShape, Duration: "5m", Rate: "10/1s", TargetRate: "500/1s"},
+				{Duration: "10m", Rate: "500/1s"},
+				{Shape: SineShape, Duration: "10m", Rate: "250/1s", Amplitude: "250/1s", Period: "2m"},
+			}
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+			vegeta.Spec.Attack.Rate = ""
+			vegeta.Spec.Attack.Duration = ""
+			vegeta.Default()
+			Expect(vegeta.Spec.Attack.Rate).To(BeEmpty())
+			Expect(vegeta.Spec.Attack.Stages[0].StepDuration).To(Equal("10s"))
+			Expect(vegeta.Spec.Attack.Stages[1].Shape).To(Equal(ConstantShape))
+			Expect(vegeta.Spec.Attack.Stages[1].StepDuration).To(BeEmpty())
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+		})
+		It("Should reject invalid stages", func() {
+			vegeta.Spec.Attack.Rate = ""
+			vegeta.Spec.Attack.Duration = ""
+			for _, stage := range []Stage{
+				{Duration: "0s", Rate: "10/1s"},
+				{Duration: "1m", Rate: "0"},
+				{Duration: "1m", Rate: "10/1s", TargetRate: "20/1s"},
+				{Shape: LinearShape, Duration: "1m", Rate: "10/1s"},
+				{Shape: SineShape, Duration: "1m", Rate: "10/1s", Amplitude: "20/1s", Period: "1m"},
+				{Shape: SineShape, Duration: "1m", Rate: "10/1s", Amplitude: "5/1s"},
+				{Shape: LinearShape, Duration: "1m", Rate: "10/1s", TargetRate: "20/1s", StepDuration: "-1s"},
+			} {
+				vegeta.Spec.Attack.Stages = []Stage{stage}
+				Expect(vegeta.ValidateCreate()).NotTo(Succeed(), "stage %v", stage)
+			}
+		})
+		It("Should reject a missing target", func() {
+			vegeta.Spec.Attack.Target = ""
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject a pvc output without claim", func() {
+			vegeta.Spec.Report = &ReportSpec{OutputType: PvcOutput}
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject invalid rates and durations", func() {
+			for _, rate := range []string{"fast", "50/second", "-1/1s", "50/0s"} {
+				vegeta.Spec.Attack.Rate = rate
+				Expect(vegeta.ValidateCreate()).NotTo(Succeed(), "rate %s", rate)
+			}
+			vegeta.Spec.Attack.Rate = "50/s"
+			vegeta.Spec.Attack.Duration = "1d"
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should require at least one max worker per replica when they are divided across replicas", func() {
+			vegeta.Spec.Replicas = 3
+			vegeta.Spec.RateMode = TotalRate
+			vegeta.Spec.Attack.Workers = 2
+			vegeta.Spec.Attack.MaxWorkers = 2
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+			vegeta.Spec.Attack.MaxWorkers = 3
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+		})
+		It("Should require maxWorkers for an unlimited rate", func() {
+			vegeta.Spec.Attack.Rate = "0"
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+			vegeta.Spec.Attack.MaxWorkers = 10
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+		})
+		It("Should reject malformed headers and buckets", func() {
+			vegeta.Spec.Attack.Headers = []string{"no-separator"}
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+			// Line breaks would inject lines into the targets rendered in the http format
+			vegeta.Spec.Attack.Headers = []string{"X-Test: a\r\nPOST https://evil.example.com/"}
+			Expect(vegeta.ValidateCreate()).To(MatchError(ContainSubstring("e.g. a line break")))
+			vegeta.Spec.Attack.Headers = []string{"X-Test\nPOST: a"}
+			Expect(vegeta.ValidateCreate()).To(MatchError(ContainSubstring("is not a valid HTTP header name")))
+			vegeta.Spec.Attack.Targets = []AttackTarget{{URL: "https://shop.example.com/items", Headers: []string{"Accept: application/json\n\nGET https://evil.example.com/"}}}
+			vegeta.Spec.Attack.Headers = []string{"X-Test: a\tb"}
+			Expect(vegeta.ValidateCreate()).To(MatchError(ContainSubstring("spec.attack.targets[0].headers[0]")))
+			vegeta.Spec.Attack.Targets = nil
+			vegeta.Spec.Attack.Headers = nil
+			vegeta.Spec.Report = &ReportSpec{Buckets: "0,1ms"}
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+			vegeta.Spec.Report.Buckets = "[10ms,1ms]"
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should default and validate the circuit breaker", func() {
+			vegeta.Spec.CircuitBreaker = &CircuitBreakerSpec{}
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+			vegeta.Spec.CircuitBreaker.MaxErrorRatio = "5%"
+			vegeta.Spec.CircuitBreaker.MaxP99 = "500ms"
+			Expect(vegeta.ValidateCreate()).To(Succeed())
+			vegeta.Default()
+			Expect(vegeta.Spec.CircuitBreaker.Window).Should(Equal("10s"))
+			Expect(vegeta.Spec.CircuitBreaker.MinRequests).Should(Equal(uint32(10)))
+			for _, ratio := range []string{"0", "150%", "high"} {
+				vegeta.Spec.CircuitBreaker.MaxErrorRatio = ratio
+				Expect(vegeta.ValidateCreate()).NotTo(Succeed(), "ratio %s", ratio)
+			}
+			vegeta.Spec.CircuitBreaker.MaxErrorRatio = "0.05"
+			vegeta.Spec.CircuitBreaker.Window = "0s"
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+		})
+	})
+
+	Context("When a Vegeta resource is updated", func() {
+		It("Should accept changes before the pods have been created", func() {
+			updated := vegeta.DeepCopy()
+			updated.Spec.Replicas = 3
+			Expect(updated.ValidateUpdate(vegeta)).To(Succeed())
+		})
+		It("Should reject changes of attack, replicas, report, pod template and circuit breaker once the pods have been created", func() {
+			vegeta.Status.Phase = RunningPhase
+			updated := vegeta.DeepCopy()
+			updated.Spec.Replicas = 3
+			Expect(updated.ValidateUpdate(vegeta)).NotTo(Succeed())
+			updated = vegeta.DeepCopy()
+			updated.Spec.Attack.Rate = "10/1s"
+			Expect(updated.ValidateUpdate(vegeta)).NotTo(Succeed())
+			updated = vegeta.DeepCopy()
+			updated.Spec.Report = &ReportSpec{Type: JSONReport}
+			Expect(updated.ValidateUpdate(vegeta)).NotTo(Succeed())
+			updated = vegeta.DeepCopy()
+			updated.Spec.PodTemplate = &PodTemplate{PriorityClassName: "low"}
+			Expect(updated.ValidateUpdate(vegeta)).NotTo(Succeed())
+			updated = vegeta.DeepCopy()
+			updated.Spec.CircuitBreaker = &CircuitBreakerSpec{MaxErrorRatio: "5%"}
+			Expect(updated.ValidateUpdate(vegeta)).NotTo(Succeed())
+			updated = vegeta.DeepCopy()
+			updated.Labels = map[string]string{"team": "perf"}
+			Expect(updated.ValidateUpdate(vegeta)).To(Succeed())
+		})
+		It("Should accept a new run with a modified specification once the current run has terminated", func() {
+			vegeta.Status.Phase = RunningPhase
+			updated := vegeta.DeepCopy()
+			updated.Spec.RunID = "2"
+			updated.Spec.Attack.Rate = "10/1s"
+			Expect(updated.ValidateUpdate(vegeta)).NotTo(Succeed())
+			vegeta.Status.Phase = CompletedPhase
+			Expect(updated.ValidateUpdate(vegeta)).To(Succeed())
+			updated.Spec.Abort = true
+			Expect(updated.ValidateUpdate(vegeta)).NotTo(Succeed())
+			updated.Spec.Abort = false
+			updated.Spec.RunID = "not a label value"
+			Expect(updated.ValidateUpdate(vegeta)).NotTo(Succeed())
+		})
+		It("Should accept the abort of a running attack", func() {
+			vegeta.Status.Phase = RunningPhase
+			updated := vegeta.DeepCopy()
+			updated.Spec.Abort = true
+			Expect(updated.ValidateUpdate(vegeta)).To(Succeed())
+		})
+	})
+
+	Context("When thresholds are parsed", func() {
+		It("Should accept the supported assertions", func() {
+			t, err := ParseThreshold("p99 < 250ms")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*t).To(Equal(Threshold{Expression: "p99 < 250ms", Metric: P99Metric, Operator: "<", Value: 0.25}))
+			t, err = ParseThreshold("status 5xx < 0.1%")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.Status).To(Equal("5xx"))
+			Expect(t.Value).To(BeNumerically("~", 0.001))
+			t, err = ParseThreshold("throughput >= 0.95 * rate")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.OfRate).To(BeTrue())
+			Expect(t.Holds(95, 100)).To(BeTrue())
+			Expect(t.Holds(94, 100)).To(BeFalse())
+		})
+		It("Should reject invalid assertions", func() {
+			for _, expr := range []string{"p99 < fast", "p42 < 1s", "success >= 120%", "status 6xx < 1%", "status < 1%", "rate >= 0.5 * rate", "p99 =< 1s", "p99<1s"} {
+				_, err := ParseThreshold(expr)
+				Expect(err).To(HaveOccurred(), "threshold %s", expr)
+			}
+			vegeta.Spec.Thresholds = []string{"p99 < 250ms", "p99 < fast"}
+			Expect(vegeta.ValidateCreate()).NotTo(Succeed())
+		})
+	})
+
+	Context("When a rate is parsed", func() {
+		It("Should follow the vegeta format", func() {
+			freq, per, err := ParseRate("50/1s")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(freq).To(Equal(50))
+			Expect(per).To(Equal(time.Second))
+			freq, per, err = ParseRate("100/m")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(freq).To(Equal(100))
+			Expect(per).To(Equal(time.Minute))
+			freq, _, err = ParseRate("20")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(freq).To(Equal(20))
+			freq, _, err = ParseRate("infinity")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(freq).To(Equal(0))
+		})
+	})
+})
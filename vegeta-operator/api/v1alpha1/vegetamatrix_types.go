@@ -0,0 +1,161 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VegetaMatrixSpec defines the desired state of VegetaMatrix
+type VegetaMatrixSpec struct {
+	// Specifies the parameter values to combine. A Vegeta resource is run for each combination, one after the other.
+	Matrix MatrixParameters `json:"matrix"`
+
+	// Specifies the Vegeta resources to create. The rate and the target of the attack are set from the combination of the matrix.
+	VegetaTemplate VegetaTemplateSpec `json:"vegetaTemplate"`
+}
+
+// MatrixParameters lists the values of the parameters of a matrix. At least one of the lists must be specified.
+// The combinations are run target by target, with the rates in the order of the list for each target.
+type MatrixParameters struct {
+	// Specifies the rates of the attack, e.g. 100/1s, 200/1s, 400/1s. They cannot be used together with stages in the template.
+	//
+	// +optional
+	Rates []string `json:"rates,omitempty"`
+
+	// Specifies the targets of the attack including the http verb, e.g. "GET https://myapp:8080/api". They cannot be used together with inline targets or a targets config map in the template.
+	//
+	// +optional
+	Targets []string `json:"targets,omitempty"`
+}
+
+// VegetaMatrixStatus defines the observed state of VegetaMatrix
+type VegetaMatrixStatus struct {
+	// Phase of the matrix. Possible values are: pending (no run started), running, completed (all the runs have finished).
+	//
+	// +optional
+	Phase PhaseEnum `json:"phase,omitempty"`
+
+	// StartTime is the time the first run has been created at.
+	//
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time the last run has finished at.
+	//
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Runs contains the summaries of the runs that have started, in the order of the combinations.
+	//
+	// +optional
+	Runs []MatrixRun `json:"runs,omitempty"`
+
+	// ResultsConfigMap is the name of the config map containing the table of the results of the finished runs in the results.csv and results.json files.
+	//
+	// +optional
+	ResultsConfigMap string `json:"resultsConfigMap,omitempty"`
+
+	// Knees contains the estimated knee point of the capacity curve of each target when several rates are specified.
+	//
+	// +optional
+	Knees []MatrixKnee `json:"knees,omitempty"`
+
+	// Conditions represent the latest available observations of the matrix. Known condition types are Ready and Complete.
+	//
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// MatrixRun summarizes the run of a combination of the matrix
+type MatrixRun struct {
+	// Rate of the combination, if rates are specified.
+	//
+	// +optional
+	Rate string `json:"rate,omitempty"`
+
+	// Target of the combination, if targets are specified.
+	//
+	// +optional
+	Target string `json:"target,omitempty"`
+
+	// Vegeta is the name of the Vegeta resource running the combination.
+	Vegeta string `json:"vegeta"`
+
+	RunSummary `json:",inline"`
+}
+
+// MatrixKnee is the estimated knee point of the capacity curve of a target: the highest rate run before the target saturated.
+// A rate is considered saturated when its throughput is below 90% of the rate, its success ratio below 99%, its 99th percentile latency more than twice the one of the lowest rate or when it has not produced any result.
+type MatrixKnee struct {
+	// Target the knee has been estimated for, if targets are specified.
+	//
+	// +optional
+	Target string `json:"target,omitempty"`
+
+	// Rate is the highest rate sustained before the saturation. It is empty when the target was saturated at the lowest rate or when no saturation has been observed.
+	//
+	// +optional
+	Rate string `json:"rate,omitempty"`
+
+	// Throughput is the throughput at the knee rate.
+	//
+	// +optional
+	Throughput string `json:"throughput,omitempty"`
+
+	// P99 is the 99th percentile latency at the knee rate.
+	//
+	// +optional
+	P99 *metav1.Duration `json:"p99,omitempty"`
+
+	// Message describes how the knee has been determined.
+	//
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// VegetaMatrix is the Schema for the vegetamatrices API. It runs an attack for each combination of parameter values, one after the other, to measure the capacity curve of targets.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Results",type=string,JSONPath=`.status.resultsConfigMap`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type VegetaMatrix struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VegetaMatrixSpec   `json:"spec,omitempty"`
+	Status VegetaMatrixStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VegetaMatrixList contains a list of VegetaMatrix
+type VegetaMatrixList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VegetaMatrix `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VegetaMatrix{}, &VegetaMatrixList{})
+}
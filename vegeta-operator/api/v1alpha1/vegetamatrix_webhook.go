@@ -0,0 +1,168 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// MaxMatrixVegetaNameLength is the maximum length of the names of the Vegeta resources created by a VegetaMatrix: <matrix>-<index>.
+// They are used as label values, which are limited to 63 characters.
+const MaxMatrixVegetaNameLength = 63
+
+// MatrixCombination is a combination of the parameter values of a matrix. Empty values are not set.
+type MatrixCombination struct {
+	Rate   string
+	Target string
+}
+
+// Combinations returns the combinations of the parameters in the order they are run: target by target, rate by rate.
+func (p *MatrixParameters) Combinations() []MatrixCombination {
+	rates, targets := p.Rates, p.Targets
+	if len(rates) == 0 {
+		rates = []string{""}
+	}
+	if len(targets) == 0 {
+		targets = []string{""}
+	}
+	combinations := make([]MatrixCombination, 0, len(rates)*len(targets))
+	for _, target := range targets {
+		for _, rate := range rates {
+			combinations = append(combinations, MatrixCombination{Rate: rate, Target: target})
+		}
+	}
+	return combinations
+}
+
+// MatrixVegetaName returns the name of the Vegeta resource created by a matrix for the combination with the given index
+func MatrixVegetaName(matrix string, index int) string {
+	return matrix + "-" + strconv.Itoa(index)
+}
+
+// VegetaSpecFor returns the specification of the Vegeta resource of a combination: the template with the rate and the target of the combination
+func (r *VegetaMatrix) VegetaSpecFor(c MatrixCombination) VegetaSpec {
+	spec := *r.Spec.VegetaTemplate.Spec.DeepCopy()
+	if spec.Attack == nil {
+		spec.Attack = &AttackSpec{}
+	}
+	if c.Rate != "" {
+		spec.Attack.Rate = c.Rate
+	}
+	if c.Target != "" {
+		spec.Attack.Target = c.Target
+	}
+	return spec
+}
+
+// SetupWebhookWithManager registers the webhooks for VegetaMatrix resources with the manager.
+func (r *VegetaMatrix) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-vegeta-testing-io-v1alpha1-vegetamatrix,mutating=false,failurePolicy=fail,sideEffects=None,groups=vegeta.testing.io,resources=vegetamatrices,verbs=create;update,versions=v1alpha1,name=vvegetamatrix.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &VegetaMatrix{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *VegetaMatrix) ValidateCreate() error {
+	vegetalog.V(1).Info("validate create", "vegetamatrix", r.Name)
+	return r.toInvalid(r.validateVegetaMatrix())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+// The matrix cannot be modified once its first run has started.
+func (r *VegetaMatrix) ValidateUpdate(old runtime.Object) error {
+	vegetalog.V(1).Info("validate update", "vegetamatrix", r.Name)
+	allErrs := r.validateVegetaMatrix()
+	oldMatrix, ok := old.(*VegetaMatrix)
+	if !ok {
+		return fmt.Errorf("Expected a VegetaMatrix resource but got a %T", old)
+	}
+	if oldMatrix.Status.Phase != "" && !equality.Semantic.DeepEqual(r.Spec, oldMatrix.Spec) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "the matrix cannot be modified once it has started"))
+	}
+	return r.toInvalid(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *VegetaMatrix) ValidateDelete() error {
+	// Nothing to validate on deletion
+	return nil
+}
+
+// toInvalid converts a list of field errors into an invalid error for the matrix
+func (r *VegetaMatrix) toInvalid(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "VegetaMatrix"}, r.Name, allErrs)
+}
+
+func (r *VegetaMatrix) validateVegetaMatrix() field.ErrorList {
+	var allErrs field.ErrorList
+	matrixPath := field.NewPath("spec").Child("matrix")
+	params := &r.Spec.Matrix
+	if len(params.Rates) == 0 && len(params.Targets) == 0 {
+		return append(allErrs, field.Required(matrixPath, "at least one of rates or targets must be specified"))
+	}
+	rates := map[string]bool{}
+	for i, rate := range params.Rates {
+		if rates[rate] {
+			allErrs = append(allErrs, field.Duplicate(matrixPath.Child("rates").Index(i), rate))
+		}
+		rates[rate] = true
+	}
+	targets := map[string]bool{}
+	for i, target := range params.Targets {
+		if target == "" {
+			allErrs = append(allErrs, field.Required(matrixPath.Child("targets").Index(i), "the target cannot be empty"))
+		} else if targets[target] {
+			allErrs = append(allErrs, field.Duplicate(matrixPath.Child("targets").Index(i), target))
+		}
+		targets[target] = true
+	}
+	combinations := params.Combinations()
+	if name := MatrixVegetaName(r.Name, len(combinations)-1); len(name) > MaxMatrixVegetaNameLength {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("name"), r.Name, fmt.Sprintf("the name of the Vegeta resource %s must be no more than %d characters", name, MaxMatrixVegetaNameLength)))
+	}
+	// The combinations are defaulted and validated the way the created Vegeta resources will be.
+	// Only the errors of the first invalid combination are reported, the other ones would mostly repeat them.
+	for _, c := range combinations {
+		vegeta := &Vegeta{Spec: r.VegetaSpecFor(c)}
+		vegeta.Default()
+		errs := vegeta.validateSpec()
+		for _, err := range errs {
+			err.Field = "spec.vegetaTemplate." + err.Field
+			allErrs = append(allErrs, err)
+		}
+		if len(errs) > 0 {
+			break
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("VegetaMatrix webhook", func() {
+	var matrix *VegetaMatrix
+
+	BeforeEach(func() {
+		matrix = &VegetaMatrix{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-matrix",
+				Namespace: "test-vegeta",
+			},
+			Spec: VegetaMatrixSpec{
+				Matrix: MatrixParameters{
+					Rates:   []string{"100/1s", "200/1s"},
+					Targets: []string{"GET https://myapp:8080/a", "GET https://myapp:8080/b"},
+				},
+				VegetaTemplate: VegetaTemplateSpec{
+					Spec: VegetaSpec{
+						Attack: &AttackSpec{
+							Duration: "10s",
+						},
+					},
+				},
+			},
+		}
+	})
+
+	Context("When the combinations of a matrix are expanded", func() {
+		It("Should run the rates target by target", func() {
+			Expect(matrix.Spec.Matrix.Combinations()).To(Equal([]MatrixCombination{
+				{Rate: "100/1s", Target: "GET https://myapp:8080/a"},
+				{Rate: "200/1s", Target: "GET https://myapp:8080/a"},
+				{Rate: "100/1s", Target: "GET https://myapp:8080/b"},
+				{Rate: "200/1s", Target: "GET https://myapp:8080/b"},
+			}))
+		})
+		It("Should set the rate and the target of the template", func() {
+			matrix.Spec.Matrix.Targets = nil
+			matrix.Spec.VegetaTemplate.Spec.Attack.Target = "GET https://myapp:8080/c"
+			combinations := matrix.Spec.Matrix.Combinations()
+			Expect(combinations).To(HaveLen(2))
+			spec := matrix.VegetaSpecFor(combinations[1])
+			Expect(spec.Attack.Rate).To(Equal("200/1s"))
+			Expect(spec.Attack.Target).To(Equal("GET https://myapp:8080/c"))
+			Expect(spec.Attack.Duration).To(Equal("10s"))
+			Expect(matrix.Spec.VegetaTemplate.Spec.Attack.Rate).To(BeEmpty())
+		})
+	})
+
+	Context("When a VegetaMatrix resource is created", func() {
+		It("Should accept a valid specification", func() {
+			Expect(matrix.ValidateCreate()).To(Succeed())
+		})
+		It("Should require parameters", func() {
+			matrix.Spec.Matrix = MatrixParameters{}
+			Expect(matrix.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject duplicated values", func() {
+			matrix.Spec.Matrix.Rates = []string{"100/1s", "100/1s"}
+			Expect(matrix.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject an invalid rate", func() {
+			matrix.Spec.Matrix.Rates = []string{"100/1s", "fast"}
+			err := matrix.ValidateCreate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.vegetaTemplate.spec.attack.rate"))
+		})
+		It("Should reject targets together with a targets config map", func() {
+			matrix.Spec.VegetaTemplate.Spec.Attack.TargetsConfigMap = "targets"
+			Expect(matrix.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject names too long for the generated Vegeta resources", func() {
+			matrix.Name = strings.Repeat("a", MaxMatrixVegetaNameLength-1)
+			Expect(matrix.ValidateCreate()).NotTo(Succeed())
+		})
+	})
+
+	Context("When a VegetaMatrix resource is updated", func() {
+		It("Should only allow changes before the matrix has started", func() {
+			old := matrix.DeepCopy()
+			matrix.Spec.Matrix.Rates = append(matrix.Spec.Matrix.Rates, "400/1s")
+			Expect(matrix.ValidateUpdate(old)).To(Succeed())
+			old.Status.Phase = RunningPhase
+			Expect(matrix.ValidateUpdate(old)).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,138 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VegetaScheduleSpec defines the desired state of VegetaSchedule
+type VegetaScheduleSpec struct {
+	// Specifies the schedule of the attacks in Cron format, e.g. "0 2 * * *" for every night at 2am, see https://en.wikipedia.org/wiki/Cron.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// Specifies the deadline in seconds for starting an attack if it misses its scheduled time for any reason. Missed attacks are counted as failed ones.
+	//
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+
+	// Specifies how to treat concurrent executions of an attack. Valid values are:
+	// - "Allow" (default): allows attacks to run concurrently;
+	// - "Forbid": skips the next attack if the previous one hasn't finished yet;
+	// - "Replace": deletes the currently running attack and replaces it with a new one
+	//
+	// +optional
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	// Suspends subsequent attacks when set to true. It does not apply to already started attacks. Defaults to false.
+	//
+	// +optional
+	Suspend *bool `json:"suspend,omitempty"`
+
+	// Specifies the Vegeta resource that will be created for each attack.
+	VegetaTemplate VegetaTemplateSpec `json:"vegetaTemplate"`
+
+	// Specifies the number of completed Vegeta resources to keep. Defaults to 3.
+	//
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SuccessfulHistoryLimit *int32 `json:"successfulHistoryLimit,omitempty"`
+
+	// Specifies the number of failed Vegeta resources to keep. Defaults to 1.
+	//
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailedHistoryLimit *int32 `json:"failedHistoryLimit,omitempty"`
+}
+
+// VegetaTemplateSpec describes the Vegeta resource that will be created for each attack
+type VegetaTemplateSpec struct {
+	// Labels and annotations of the created Vegeta resources.
+	//
+	// +optional
+	Metadata VegetaTemplateMeta `json:"metadata,omitempty"`
+
+	// Specifies the attack.
+	Spec VegetaSpec `json:"spec"`
+}
+
+// VegetaTemplateMeta contains the labels and annotations to set on the created Vegeta resources
+type VegetaTemplateMeta struct {
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ConcurrencyPolicy describes how concurrent attacks of a schedule are handled
+// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent allows attacks to run concurrently
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+	// ForbidConcurrent forbids concurrent runs, skipping the next attack if the previous one hasn't finished yet
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+	// ReplaceConcurrent cancels the currently running attack and replaces it with a new one
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+)
+
+// VegetaScheduleStatus defines the observed state of VegetaSchedule
+type VegetaScheduleStatus struct {
+	// Active contains references to the Vegeta resources of the attacks that have not finished yet.
+	//
+	// +optional
+	Active []corev1.ObjectReference `json:"active,omitempty"`
+
+	// LastScheduleTime is the last time an attack was successfully scheduled.
+	//
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+}
+
+// VegetaSchedule is the Schema for the vegetaschedules API. It creates Vegeta resources on a recurring schedule.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`
+// +kubebuilder:printcolumn:name="Last Schedule",type=date,JSONPath=`.status.lastScheduleTime`
+type VegetaSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VegetaScheduleSpec   `json:"spec,omitempty"`
+	Status VegetaScheduleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VegetaScheduleList contains a list of VegetaSchedule
+type VegetaScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VegetaSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VegetaSchedule{}, &VegetaScheduleList{})
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	"github.com/robfig/cron/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// MaxScheduleNameLength is the maximum length of the name of a VegetaSchedule.
+// The names of the created Vegeta resources get a timestamp suffix and are used as label values, which are limited to 63 characters.
+const MaxScheduleNameLength = 52
+
+// Defaults of the history limits, also applied by the controller to resources that have not been defaulted by the webhook
+const (
+	DefaultSuccessfulHistoryLimit = 3
+	DefaultFailedHistoryLimit     = 1
+)
+
+// SetupWebhookWithManager registers the webhooks for VegetaSchedule resources with the manager.
+func (r *VegetaSchedule) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-vegeta-testing-io-v1alpha1-vegetaschedule,mutating=true,failurePolicy=fail,sideEffects=None,groups=vegeta.testing.io,resources=vegetaschedules,verbs=create;update,versions=v1alpha1,name=mvegetaschedule.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Defaulter = &VegetaSchedule{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *VegetaSchedule) Default() {
+	vegetalog.V(1).Info("default", "vegetaschedule", r.Name)
+	if r.Spec.ConcurrencyPolicy == "" {
+		r.Spec.ConcurrencyPolicy = AllowConcurrent
+	}
+	if r.Spec.Suspend == nil {
+		suspend := false
+		r.Spec.Suspend = &suspend
+	}
+	if r.Spec.SuccessfulHistoryLimit == nil {
+		limit := int32(DefaultSuccessfulHistoryLimit)
+		r.Spec.SuccessfulHistoryLimit = &limit
+	}
+	if r.Spec.FailedHistoryLimit == nil {
+		limit := int32(DefaultFailedHistoryLimit)
+		r.Spec.FailedHistoryLimit = &limit
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-vegeta-testing-io-v1alpha1-vegetaschedule,mutating=false,failurePolicy=fail,sideEffects=None,groups=vegeta.testing.io,resources=vegetaschedules,verbs=create;update,versions=v1alpha1,name=vvegetaschedule.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &VegetaSchedule{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *VegetaSchedule) ValidateCreate() error {
+	vegetalog.V(1).Info("validate create", "vegetaschedule", r.Name)
+	return r.validateVegetaSchedule()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+// Contrary to Vegeta resources schedules can be modified at any time. Changes apply to the next attacks.
+func (r *VegetaSchedule) ValidateUpdate(old runtime.Object) error {
+	vegetalog.V(1).Info("validate update", "vegetaschedule", r.Name)
+	return r.validateVegetaSchedule()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *VegetaSchedule) ValidateDelete() error {
+	// Nothing to validate on deletion
+	return nil
+}
+
+func (r *VegetaSchedule) validateVegetaSchedule() error {
+	var allErrs field.ErrorList
+	if len(r.Name) > MaxScheduleNameLength {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("name"), r.Name, fmt.Sprintf("must be no more than %d characters", MaxScheduleNameLength)))
+	}
+	specPath := field.NewPath("spec")
+	if _, err := cron.ParseStandard(r.Spec.Schedule); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("schedule"), r.Spec.Schedule, err.Error()))
+	}
+	// The template is defaulted and validated the way the created Vegeta resources will be
+	vegeta := &Vegeta{Spec: *r.Spec.VegetaTemplate.Spec.DeepCopy()}
+	vegeta.Default()
+	for _, err := range vegeta.validateSpec() {
+		err.Field = specPath.Child("vegetaTemplate").String() + "." + err.Field
+		allErrs = append(allErrs, err)
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "VegetaSchedule"}, r.Name, allErrs)
+}
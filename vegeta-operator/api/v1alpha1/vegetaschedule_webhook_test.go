@@ -0,0 +1,79 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("VegetaSchedule webhook", func() {
+	var schedule *VegetaSchedule
+
+	BeforeEach(func() {
+		schedule = &VegetaSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-schedule",
+				Namespace: "test-vegeta",
+			},
+			Spec: VegetaScheduleSpec{
+				Schedule: "*/30 * * * *",
+				VegetaTemplate: VegetaTemplateSpec{
+					Spec: VegetaSpec{
+						Attack: &AttackSpec{
+							Duration: "10s",
+							Target:   "GET https://kubernetes.default.svc.cluster.local:443/healthz",
+						},
+					},
+				},
+			},
+		}
+	})
+
+	Context("When a VegetaSchedule resource is defaulted", func() {
+		It("Should allow concurrent runs and keep a limited history", func() {
+			schedule.Default()
+			Expect(schedule.Spec.ConcurrencyPolicy).To(Equal(AllowConcurrent))
+			Expect(*schedule.Spec.Suspend).To(BeFalse())
+			Expect(*schedule.Spec.SuccessfulHistoryLimit).To(Equal(int32(3)))
+			Expect(*schedule.Spec.FailedHistoryLimit).To(Equal(int32(1)))
+		})
+	})
+
+	Context("When a VegetaSchedule resource is created", func() {
+		It("Should accept a valid specification", func() {
+			Expect(schedule.ValidateCreate()).To(Succeed())
+		})
+		It("Should reject an invalid cron expression", func() {
+			schedule.Spec.Schedule = "every 30 minutes"
+			Expect(schedule.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject names too long for the generated Vegeta resources", func() {
+			schedule.Name = strings.Repeat("a", MaxScheduleNameLength+1)
+			Expect(schedule.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject an invalid template", func() {
+			schedule.Spec.VegetaTemplate.Spec.Attack.TargetsConfigMap = "targets"
+			err := schedule.ValidateCreate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.vegetaTemplate.spec.attack"))
+		})
+	})
+})
@@ -0,0 +1,163 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VegetaSuiteSpec defines the desired state of VegetaSuite
+type VegetaSuiteSpec struct {
+	// Specifies the stages of the suite. A stage only starts once all the attacks of the previous stage have finished.
+	//
+	// +kubebuilder:validation:MinItems=1
+	Stages []SuiteStage `json:"stages"`
+
+	// Specifies that the suite stops when an attack of a stage fails or gets aborted. The next stages are then not run. Defaults to false, in which case the suite fails once all its stages have run.
+	//
+	// +optional
+	StopOnFailure bool `json:"stopOnFailure,omitempty"`
+}
+
+// SuiteStage defines a group of attacks run in parallel
+type SuiteStage struct {
+	// Name of the stage. It is used in the names of the created Vegeta resources: <suite>-<stage>-<attack>.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Specifies the time to wait after the previous stage has finished before starting this stage, e.g. "5m" to let the system under test recover.
+	//
+	// +kubebuilder:validation:Format=duration
+	// +optional
+	PauseBefore string `json:"pauseBefore,omitempty"`
+
+	// Specifies the attacks of the stage, which run in parallel.
+	//
+	// +kubebuilder:validation:MinItems=1
+	Attacks []SuiteAttack `json:"attacks"`
+}
+
+// SuiteAttack describes the Vegeta resource created for an attack of a stage
+type SuiteAttack struct {
+	// Name of the attack within the stage.
+	//
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	VegetaTemplateSpec `json:",inline"`
+}
+
+// VegetaSuiteStatus defines the observed state of VegetaSuite
+type VegetaSuiteStatus struct {
+	// Phase of the suite. Possible values are: pending (no stage started), running, completed (all the attacks have completed), failed (an attack has failed or has been aborted).
+	//
+	// +optional
+	Phase PhaseEnum `json:"phase,omitempty"`
+
+	// CurrentStage is the name of the stage that is running or waiting for its pause to end.
+	//
+	// +optional
+	CurrentStage string `json:"currentStage,omitempty"`
+
+	// StartTime is the time the first stage has started at.
+	//
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time the suite has finished at.
+	//
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Stages contains the outcome of the stages that have started, in the order of the specification.
+	//
+	// +optional
+	Stages []SuiteStageStatus `json:"stages,omitempty"`
+
+	// Conditions represent the latest available observations of the suite. Known condition types are Ready, Complete and Failed.
+	//
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// SuiteStageStatus records the outcome of a stage
+type SuiteStageStatus struct {
+	// Name of the stage.
+	Name string `json:"name"`
+
+	// Phase of the stage: running, completed or failed.
+	Phase PhaseEnum `json:"phase"`
+
+	// StartTime is the time the Vegeta resources of the stage have been created at.
+	//
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time the last attack of the stage has finished at.
+	//
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Attacks contains the summaries of the runs of the attacks of the stage.
+	//
+	// +optional
+	Attacks []SuiteAttackStatus `json:"attacks,omitempty"`
+}
+
+// SuiteAttackStatus summarizes the run of an attack of a stage
+type SuiteAttackStatus struct {
+	// Name of the attack within the stage.
+	Name string `json:"name"`
+
+	// Vegeta is the name of the Vegeta resource running the attack.
+	Vegeta string `json:"vegeta"`
+
+	RunSummary `json:",inline"`
+}
+
+// VegetaSuite is the Schema for the vegetasuites API. It runs Vegeta attacks in stages, one stage after the other, the attacks of a stage in parallel.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Stage",type=string,JSONPath=`.status.currentStage`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type VegetaSuite struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VegetaSuiteSpec   `json:"spec,omitempty"`
+	Status VegetaSuiteStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VegetaSuiteList contains a list of VegetaSuite
+type VegetaSuiteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VegetaSuite `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VegetaSuite{}, &VegetaSuiteList{})
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// MaxSuiteVegetaNameLength is the maximum length of the names of the Vegeta resources created by a VegetaSuite: <suite>-<stage>-<attack>.
+// They are used as label values, which are limited to 63 characters.
+const MaxSuiteVegetaNameLength = 63
+
+// SuiteVegetaName returns the name of the Vegeta resource created by a suite for an attack of a stage
+func SuiteVegetaName(suite, stage, attack string) string {
+	return suite + "-" + stage + "-" + attack
+}
+
+// SetupWebhookWithManager registers the webhooks for VegetaSuite resources with the manager.
+func (r *VegetaSuite) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-vegeta-testing-io-v1alpha1-vegetasuite,mutating=false,failurePolicy=fail,sideEffects=None,groups=vegeta.testing.io,resources=vegetasuites,verbs=create;update,versions=v1alpha1,name=vvegetasuite.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &VegetaSuite{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *VegetaSuite) ValidateCreate() error {
+	vegetalog.V(1).Info("validate create", "vegetasuite", r.Name)
+	return r.toInvalid(r.validateVegetaSuite())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+// The stages cannot be modified once the suite has started.
+func (r *VegetaSuite) ValidateUpdate(old runtime.Object) error {
+	vegetalog.V(1).Info("validate update", "vegetasuite", r.Name)
+	allErrs := r.validateVegetaSuite()
+	oldSuite, ok := old.(*VegetaSuite)
+	if !ok {
+		return fmt.Errorf("Expected a VegetaSuite resource but got a %T", old)
+	}
+	if oldSuite.Status.Phase != "" && !equality.Semantic.DeepEqual(r.Spec, oldSuite.Spec) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "the suite cannot be modified once it has started"))
+	}
+	return r.toInvalid(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *VegetaSuite) ValidateDelete() error {
+	// Nothing to validate on deletion
+	return nil
+}
+
+// toInvalid converts a list of field errors into an invalid error for the suite
+func (r *VegetaSuite) toInvalid(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "VegetaSuite"}, r.Name, allErrs)
+}
+
+func (r *VegetaSuite) validateVegetaSuite() field.ErrorList {
+	var allErrs field.ErrorList
+	stagesPath := field.NewPath("spec").Child("stages")
+	if len(r.Spec.Stages) == 0 {
+		allErrs = append(allErrs, field.Required(stagesPath, "at least one stage must be specified"))
+	}
+	stages := map[string]bool{}
+	for i, stage := range r.Spec.Stages {
+		stagePath := stagesPath.Index(i)
+		allErrs = append(allErrs, validateSuiteName(stage.Name, stagePath.Child("name"))...)
+		if stages[stage.Name] {
+			allErrs = append(allErrs, field.Duplicate(stagePath.Child("name"), stage.Name))
+		}
+		stages[stage.Name] = true
+		if stage.PauseBefore != "" {
+			if d, err := time.ParseDuration(stage.PauseBefore); err != nil || d < 0 {
+				allErrs = append(allErrs, field.Invalid(stagePath.Child("pauseBefore"), stage.PauseBefore, "the pause must be a duration, e.g. 5m"))
+			}
+		}
+		if len(stage.Attacks) == 0 {
+			allErrs = append(allErrs, field.Required(stagePath.Child("attacks"), "at least one attack must be specified"))
+		}
+		attacks := map[string]bool{}
+		for j, attack := range stage.Attacks {
+			attackPath := stagePath.Child("attacks").Index(j)
+			allErrs = append(allErrs, validateSuiteName(attack.Name, attackPath.Child("name"))...)
+			if attacks[attack.Name] {
+				allErrs = append(allErrs, field.Duplicate(attackPath.Child("name"), attack.Name))
+			}
+			attacks[attack.Name] = true
+			if name := SuiteVegetaName(r.Name, stage.Name, attack.Name); len(name) > MaxSuiteVegetaNameLength {
+				allErrs = append(allErrs, field.Invalid(attackPath.Child("name"), attack.Name, fmt.Sprintf("the name of the Vegeta resource %s must be no more than %d characters", name, MaxSuiteVegetaNameLength)))
+			}
+			// The attacks are defaulted and validated the way the created Vegeta resources will be
+			vegeta := &Vegeta{Spec: *attack.Spec.DeepCopy()}
+			vegeta.Default()
+			for _, err := range vegeta.validateSpec() {
+				err.Field = attackPath.String() + "." + err.Field
+				allErrs = append(allErrs, err)
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateSuiteName checks that the name of a stage or an attack can be part of the name of a Vegeta resource
+func validateSuiteName(name string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, msg := range validation.IsDNS1123Label(name) {
+		allErrs = append(allErrs, field.Invalid(path, name, msg))
+	}
+	return allErrs
+}
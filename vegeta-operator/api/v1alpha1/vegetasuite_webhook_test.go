@@ -0,0 +1,102 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("VegetaSuite webhook", func() {
+	var suite *VegetaSuite
+
+	BeforeEach(func() {
+		attack := func(name string) SuiteAttack {
+			return SuiteAttack{
+				Name: name,
+				VegetaTemplateSpec: VegetaTemplateSpec{
+					Spec: VegetaSpec{
+						Attack: &AttackSpec{
+							Duration: "10s",
+							Target:   "GET https://kubernetes.default.svc.cluster.local:443/healthz",
+						},
+					},
+				},
+			}
+		}
+		suite = &VegetaSuite{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-suite",
+				Namespace: "test-vegeta",
+			},
+			Spec: VegetaSuiteSpec{
+				Stages: []SuiteStage{
+					{Name: "warmup", Attacks: []SuiteAttack{attack("healthz")}},
+					{Name: "load", PauseBefore: "30s", Attacks: []SuiteAttack{attack("healthz"), attack("version")}},
+				},
+			},
+		}
+	})
+
+	Context("When a VegetaSuite resource is created", func() {
+		It("Should accept a valid specification", func() {
+			Expect(suite.ValidateCreate()).To(Succeed())
+		})
+		It("Should reject duplicated stage and attack names", func() {
+			suite.Spec.Stages[1].Name = "warmup"
+			Expect(suite.ValidateCreate()).NotTo(Succeed())
+			suite.Spec.Stages[1].Name = "load"
+			suite.Spec.Stages[1].Attacks[1].Name = "healthz"
+			Expect(suite.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject names that cannot be part of a resource name", func() {
+			suite.Spec.Stages[0].Name = "Warm_up"
+			Expect(suite.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject names too long for the generated Vegeta resources", func() {
+			suite.Spec.Stages[0].Attacks[0].Name = strings.Repeat("a", MaxSuiteVegetaNameLength)
+			Expect(suite.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject an invalid pause", func() {
+			suite.Spec.Stages[1].PauseBefore = "30"
+			Expect(suite.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject a stage without attacks", func() {
+			suite.Spec.Stages[1].Attacks = nil
+			Expect(suite.ValidateCreate()).NotTo(Succeed())
+		})
+		It("Should reject an invalid attack", func() {
+			suite.Spec.Stages[1].Attacks[1].Spec.Attack.TargetsConfigMap = "targets"
+			err := suite.ValidateCreate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.stages[1].attacks[1].spec.attack"))
+		})
+	})
+
+	Context("When a VegetaSuite resource is updated", func() {
+		It("Should only allow changes before the suite has started", func() {
+			old := suite.DeepCopy()
+			suite.Spec.StopOnFailure = true
+			Expect(suite.ValidateUpdate(old)).To(Succeed())
+			old.Status.Phase = RunningPhase
+			Expect(suite.ValidateUpdate(old)).NotTo(Succeed())
+		})
+	})
+})
@@ -21,46 +21,867 @@ limitations under the License.
 package v1alpha1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AttackResults) DeepCopyInto(out *AttackResults) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Wait != nil {
+		in, out := &in.Wait, &out.Wait
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Latencies != nil {
+		in, out := &in.Latencies, &out.Latencies
+		*out = new(LatencyResults)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StatusCodes != nil {
+		in, out := &in.StatusCodes, &out.StatusCodes
+		*out = make(map[string]uint64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Errors != nil {
+		in, out := &in.Errors, &out.Errors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AttackResults.
+func (in *AttackResults) DeepCopy() *AttackResults {
+	if in == nil {
+		return nil
+	}
+	out := new(AttackResults)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AttackSpec) DeepCopyInto(out *AttackSpec) {
 	*out = *in
-	if in.Headers != nil {
-		in, out := &in.Headers, &out.Headers
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KeepAlive != nil {
+		in, out := &in.KeepAlive, &out.KeepAlive
+		*out = new(bool)
+		**out = **in
+	}
+	if in.OpenAPI != nil {
+		in, out := &in.OpenAPI, &out.OpenAPI
+		*out = new(OpenAPISource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Redirects != nil {
+		in, out := &in.Redirects, &out.Redirects
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]Stage, len(*in))
+		copy(*out, *in)
+	}
+	if in.TargetGenerator != nil {
+		in, out := &in.TargetGenerator, &out.TargetGenerator
+		*out = new(TargetGenerator)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TargetRef != nil {
+		in, out := &in.TargetRef, &out.TargetRef
+		*out = new(TargetRef)
+		**out = **in
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]AttackTarget, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AttackSpec.
+func (in *AttackSpec) DeepCopy() *AttackSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AttackSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AttackTarget) DeepCopyInto(out *AttackTarget) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BodyFrom != nil {
+		in, out := &in.BodyFrom, &out.BodyFrom
+		*out = new(BodySource)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AttackTarget.
+func (in *AttackTarget) DeepCopy() *AttackTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(AttackTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BodySource) DeepCopyInto(out *BodySource) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BodySource.
+func (in *BodySource) DeepCopy() *BodySource {
+	if in == nil {
+		return nil
+	}
+	out := new(BodySource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CircuitBreakerSpec) DeepCopyInto(out *CircuitBreakerSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CircuitBreakerSpec.
+func (in *CircuitBreakerSpec) DeepCopy() *CircuitBreakerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CircuitBreakerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CircuitBreakerTrip) DeepCopyInto(out *CircuitBreakerTrip) {
+	*out = *in
+	in.WindowStart.DeepCopyInto(&out.WindowStart)
+	in.WindowEnd.DeepCopyInto(&out.WindowEnd)
+	out.P99 = in.P99
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CircuitBreakerTrip.
+func (in *CircuitBreakerTrip) DeepCopy() *CircuitBreakerTrip {
+	if in == nil {
+		return nil
+	}
+	out := new(CircuitBreakerTrip)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetClaim) DeepCopyInto(out *DatasetClaim) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetClaim.
+func (in *DatasetClaim) DeepCopy() *DatasetClaim {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetClaim)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetSource) DeepCopyInto(out *DatasetSource) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PersistentVolumeClaim != nil {
+		in, out := &in.PersistentVolumeClaim, &out.PersistentVolumeClaim
+		*out = new(DatasetClaim)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetSource.
+func (in *DatasetSource) DeepCopy() *DatasetSource {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointResults) DeepCopyInto(out *EndpointResults) {
+	*out = *in
+	if in.Latencies != nil {
+		in, out := &in.Latencies, &out.Latencies
+		*out = new(LatencyResults)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointResults.
+func (in *EndpointResults) DeepCopy() *EndpointResults {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointResults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LatencyResults) DeepCopyInto(out *LatencyResults) {
+	*out = *in
+	if in.Min != nil {
+		in, out := &in.Min, &out.Min
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Mean != nil {
+		in, out := &in.Mean, &out.Mean
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.P50 != nil {
+		in, out := &in.P50, &out.P50
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.P90 != nil {
+		in, out := &in.P90, &out.P90
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.P95 != nil {
+		in, out := &in.P95, &out.P95
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.P99 != nil {
+		in, out := &in.P99, &out.P99
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LatencyResults.
+func (in *LatencyResults) DeepCopy() *LatencyResults {
+	if in == nil {
+		return nil
+	}
+	out := new(LatencyResults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixCombination) DeepCopyInto(out *MatrixCombination) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixCombination.
+func (in *MatrixCombination) DeepCopy() *MatrixCombination {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixCombination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixKnee) DeepCopyInto(out *MatrixKnee) {
+	*out = *in
+	if in.P99 != nil {
+		in, out := &in.P99, &out.P99
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixKnee.
+func (in *MatrixKnee) DeepCopy() *MatrixKnee {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixKnee)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixParameters) DeepCopyInto(out *MatrixParameters) {
+	*out = *in
+	if in.Rates != nil {
+		in, out := &in.Rates, &out.Rates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixParameters.
+func (in *MatrixParameters) DeepCopy() *MatrixParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixRun) DeepCopyInto(out *MatrixRun) {
+	*out = *in
+	in.RunSummary.DeepCopyInto(&out.RunSummary)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixRun.
+func (in *MatrixRun) DeepCopy() *MatrixRun {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixRun)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OpenAPISource) DeepCopyInto(out *OpenAPISource) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Examples != nil {
+		in, out := &in.Examples, &out.Examples
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenAPISource.
+func (in *OpenAPISource) DeepCopy() *OpenAPISource {
+	if in == nil {
+		return nil
+	}
+	out := new(OpenAPISource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodTemplate) DeepCopyInto(out *PodTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTemplate.
+func (in *PodTemplate) DeepCopy() *PodTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(PodTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaPlacement) DeepCopyInto(out *ReplicaPlacement) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaPlacement.
+func (in *ReplicaPlacement) DeepCopy() *ReplicaPlacement {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaPlacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReportSpec) DeepCopyInto(out *ReportSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReportSpec.
+func (in *ReportSpec) DeepCopy() *ReportSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ReportSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunSummary) DeepCopyInto(out *RunSummary) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Results != nil {
+		in, out := &in.Results, &out.Results
+		*out = new(AttackResults)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunSummary.
+func (in *RunSummary) DeepCopy() *RunSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(RunSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Stage) DeepCopyInto(out *Stage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Stage.
+func (in *Stage) DeepCopy() *Stage {
+	if in == nil {
+		return nil
+	}
+	out := new(Stage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SuiteAttack) DeepCopyInto(out *SuiteAttack) {
+	*out = *in
+	in.VegetaTemplateSpec.DeepCopyInto(&out.VegetaTemplateSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SuiteAttack.
+func (in *SuiteAttack) DeepCopy() *SuiteAttack {
+	if in == nil {
+		return nil
+	}
+	out := new(SuiteAttack)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SuiteAttackStatus) DeepCopyInto(out *SuiteAttackStatus) {
+	*out = *in
+	in.RunSummary.DeepCopyInto(&out.RunSummary)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SuiteAttackStatus.
+func (in *SuiteAttackStatus) DeepCopy() *SuiteAttackStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SuiteAttackStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SuiteStage) DeepCopyInto(out *SuiteStage) {
+	*out = *in
+	if in.Attacks != nil {
+		in, out := &in.Attacks, &out.Attacks
+		*out = make([]SuiteAttack, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SuiteStage.
+func (in *SuiteStage) DeepCopy() *SuiteStage {
+	if in == nil {
+		return nil
+	}
+	out := new(SuiteStage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SuiteStageStatus) DeepCopyInto(out *SuiteStageStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Attacks != nil {
+		in, out := &in.Attacks, &out.Attacks
+		*out = make([]SuiteAttackStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SuiteStageStatus.
+func (in *SuiteStageStatus) DeepCopy() *SuiteStageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SuiteStageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetGenerator) DeepCopyInto(out *TargetGenerator) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Dataset != nil {
+		in, out := &in.Dataset, &out.Dataset
+		*out = new(DatasetSource)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetGenerator.
+func (in *TargetGenerator) DeepCopy() *TargetGenerator {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetGenerator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetMixResults) DeepCopyInto(out *TargetMixResults) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetMixResults.
+func (in *TargetMixResults) DeepCopy() *TargetMixResults {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetMixResults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetRef) DeepCopyInto(out *TargetRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetRef.
+func (in *TargetRef) DeepCopy() *TargetRef {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Vegeta) DeepCopyInto(out *Vegeta) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Vegeta.
+func (in *Vegeta) DeepCopy() *Vegeta {
+	if in == nil {
+		return nil
+	}
+	out := new(Vegeta)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Vegeta) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VegetaList) DeepCopyInto(out *VegetaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Vegeta, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaList.
+func (in *VegetaList) DeepCopy() *VegetaList {
+	if in == nil {
+		return nil
+	}
+	out := new(VegetaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VegetaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VegetaMatrix) DeepCopyInto(out *VegetaMatrix) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaMatrix.
+func (in *VegetaMatrix) DeepCopy() *VegetaMatrix {
+	if in == nil {
+		return nil
+	}
+	out := new(VegetaMatrix)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VegetaMatrix) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VegetaMatrixList) DeepCopyInto(out *VegetaMatrixList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VegetaMatrix, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AttackSpec.
-func (in *AttackSpec) DeepCopy() *AttackSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaMatrixList.
+func (in *VegetaMatrixList) DeepCopy() *VegetaMatrixList {
 	if in == nil {
 		return nil
 	}
-	out := new(AttackSpec)
+	out := new(VegetaMatrixList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VegetaMatrixList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ReportSpec) DeepCopyInto(out *ReportSpec) {
+func (in *VegetaMatrixSpec) DeepCopyInto(out *VegetaMatrixSpec) {
 	*out = *in
+	in.Matrix.DeepCopyInto(&out.Matrix)
+	in.VegetaTemplate.DeepCopyInto(&out.VegetaTemplate)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReportSpec.
-func (in *ReportSpec) DeepCopy() *ReportSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaMatrixSpec.
+func (in *VegetaMatrixSpec) DeepCopy() *VegetaMatrixSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ReportSpec)
+	out := new(VegetaMatrixSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Vegeta) DeepCopyInto(out *Vegeta) {
+func (in *VegetaMatrixStatus) DeepCopyInto(out *VegetaMatrixStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Runs != nil {
+		in, out := &in.Runs, &out.Runs
+		*out = make([]MatrixRun, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Knees != nil {
+		in, out := &in.Knees, &out.Knees
+		*out = make([]MatrixKnee, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaMatrixStatus.
+func (in *VegetaMatrixStatus) DeepCopy() *VegetaMatrixStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VegetaMatrixStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VegetaSchedule) DeepCopyInto(out *VegetaSchedule) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -68,18 +889,18 @@ func (in *Vegeta) DeepCopyInto(out *Vegeta) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Vegeta.
-func (in *Vegeta) DeepCopy() *Vegeta {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaSchedule.
+func (in *VegetaSchedule) DeepCopy() *VegetaSchedule {
 	if in == nil {
 		return nil
 	}
-	out := new(Vegeta)
+	out := new(VegetaSchedule)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Vegeta) DeepCopyObject() runtime.Object {
+func (in *VegetaSchedule) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -87,37 +908,97 @@ func (in *Vegeta) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VegetaList) DeepCopyInto(out *VegetaList) {
+func (in *VegetaScheduleList) DeepCopyInto(out *VegetaScheduleList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]Vegeta, len(*in))
+		*out = make([]VegetaSchedule, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaList.
-func (in *VegetaList) DeepCopy() *VegetaList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaScheduleList.
+func (in *VegetaScheduleList) DeepCopy() *VegetaScheduleList {
 	if in == nil {
 		return nil
 	}
-	out := new(VegetaList)
+	out := new(VegetaScheduleList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VegetaList) DeepCopyObject() runtime.Object {
+func (in *VegetaScheduleList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VegetaScheduleSpec) DeepCopyInto(out *VegetaScheduleSpec) {
+	*out = *in
+	if in.StartingDeadlineSeconds != nil {
+		in, out := &in.StartingDeadlineSeconds, &out.StartingDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Suspend != nil {
+		in, out := &in.Suspend, &out.Suspend
+		*out = new(bool)
+		**out = **in
+	}
+	in.VegetaTemplate.DeepCopyInto(&out.VegetaTemplate)
+	if in.SuccessfulHistoryLimit != nil {
+		in, out := &in.SuccessfulHistoryLimit, &out.SuccessfulHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailedHistoryLimit != nil {
+		in, out := &in.FailedHistoryLimit, &out.FailedHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaScheduleSpec.
+func (in *VegetaScheduleSpec) DeepCopy() *VegetaScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VegetaScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VegetaScheduleStatus) DeepCopyInto(out *VegetaScheduleStatus) {
+	*out = *in
+	if in.Active != nil {
+		in, out := &in.Active, &out.Active
+		*out = make([]v1.ObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaScheduleStatus.
+func (in *VegetaScheduleStatus) DeepCopy() *VegetaScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VegetaScheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VegetaSpec) DeepCopyInto(out *VegetaSpec) {
 	*out = *in
@@ -132,6 +1013,31 @@ func (in *VegetaSpec) DeepCopyInto(out *VegetaSpec) {
 		**out = **in
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(PodTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CircuitBreaker != nil {
+		in, out := &in.CircuitBreaker, &out.CircuitBreaker
+		*out = new(CircuitBreakerSpec)
+		**out = **in
+	}
+	if in.HistoryLimit != nil {
+		in, out := &in.HistoryLimit, &out.HistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TTLSecondsAfterFinished != nil {
+		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Thresholds != nil {
+		in, out := &in.Thresholds, &out.Thresholds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaSpec.
@@ -162,6 +1068,74 @@ func (in *VegetaStatus) DeepCopyInto(out *VegetaStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]RunSummary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AbortedAt != nil {
+		in, out := &in.AbortedAt, &out.AbortedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CircuitBreaker != nil {
+		in, out := &in.CircuitBreaker, &out.CircuitBreaker
+		*out = new(CircuitBreakerTrip)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReplicaRates != nil {
+		in, out := &in.ReplicaRates, &out.ReplicaRates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OpenAPIOperations != nil {
+		in, out := &in.OpenAPIOperations, &out.OpenAPIOperations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TargetMix != nil {
+		in, out := &in.TargetMix, &out.TargetMix
+		*out = make([]TargetMixResults, len(*in))
+		copy(*out, *in)
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]EndpointResults, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StartAt != nil {
+		in, out := &in.StartAt, &out.StartAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Placement != nil {
+		in, out := &in.Placement, &out.Placement
+		*out = make([]ReplicaPlacement, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoadProfileDrift != nil {
+		in, out := &in.LoadProfileDrift, &out.LoadProfileDrift
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Results != nil {
+		in, out := &in.Results, &out.Results
+		*out = new(AttackResults)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaStatus.
@@ -173,3 +1147,167 @@ func (in *VegetaStatus) DeepCopy() *VegetaStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VegetaSuite) DeepCopyInto(out *VegetaSuite) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaSuite.
+func (in *VegetaSuite) DeepCopy() *VegetaSuite {
+	if in == nil {
+		return nil
+	}
+	out := new(VegetaSuite)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VegetaSuite) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VegetaSuiteList) DeepCopyInto(out *VegetaSuiteList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VegetaSuite, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaSuiteList.
+func (in *VegetaSuiteList) DeepCopy() *VegetaSuiteList {
+	if in == nil {
+		return nil
+	}
+	out := new(VegetaSuiteList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VegetaSuiteList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VegetaSuiteSpec) DeepCopyInto(out *VegetaSuiteSpec) {
+	*out = *in
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]SuiteStage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaSuiteSpec.
+func (in *VegetaSuiteSpec) DeepCopy() *VegetaSuiteSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VegetaSuiteSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VegetaSuiteStatus) DeepCopyInto(out *VegetaSuiteStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]SuiteStageStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaSuiteStatus.
+func (in *VegetaSuiteStatus) DeepCopy() *VegetaSuiteStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VegetaSuiteStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VegetaTemplateMeta) DeepCopyInto(out *VegetaTemplateMeta) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaTemplateMeta.
+func (in *VegetaTemplateMeta) DeepCopy() *VegetaTemplateMeta {
+	if in == nil {
+		return nil
+	}
+	out := new(VegetaTemplateMeta)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VegetaTemplateSpec) DeepCopyInto(out *VegetaTemplateSpec) {
+	*out = *in
+	in.Metadata.DeepCopyInto(&out.Metadata)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VegetaTemplateSpec.
+func (in *VegetaTemplateSpec) DeepCopy() *VegetaTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VegetaTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// abortAnnotation requests the abort of the attack when set to true on the vegeta resource. It is propagated to the attack pods.
+	abortAnnotation = vegetav1alpha1.AbortAnnotation
+	// abortFile is the file of the downward API volume containing the abort annotation
+	abortFile = "abort"
+	// abortedMarker is created in the attack container once the abort has been requested so that no further attack gets started
+	abortedMarker = "/tmp/vegeta-aborted"
+)
+
+// isAbortRequested returns true if the abort of the attack has been requested through the specification or the annotation, or if the circuit breaker has tripped
+func isAbortRequested(v *vegetav1alpha1.Vegeta) bool {
+	return v.Spec.Abort || v.Annotations[abortAnnotation] == "true" || v.Status.CircuitBreaker != nil
+}
+
+// getAbortWatcherCmd generates the commands run in the background of the attack container, which interrupt the running vegeta attacks once the abort has been requested or the circuit breaker of the container has tripped.
+// On interrupt vegeta attack stops sending requests and flushes the results collected so far so that the report can still be generated.
+func getAbortWatcherCmd() string {
+	file := podInfoPath + abortFile
+	return "( until [ -s " + file + " ] || [ -e " + abortedMarker + " ]; do sleep 1; done; touch " + abortedMarker + "; " +
+		"while :; do for p in /proc/[0-9]*; do case \"$(tr '\\0' ' ' < \"$p/cmdline\" 2>/dev/null)\" in \"vegeta attack \"*) kill -INT \"${p#/proc/}\";; esac; done; sleep 1; done ) & "
+}
+
+// getUnlessAbortedCmd wraps commands so that they do not get run once the abort has been requested
+func getUnlessAbortedCmd(cmd string) string {
+	return "[ -e " + abortedMarker + " ] || { " + cmd + "; }"
+}
+
+// reconcileAbort records the abort request and propagates it to the attack pods that have not terminated yet.
+// Once the run has failed the attack pods still active, e.g. waiting at the start barrier for a replica that failed, get stopped the same way.
+// It returns true if the abort has been recorded in the status.
+func (r *VegetaReconciler) reconcileAbort(ctx context.Context, v *vegetav1alpha1.Vegeta, attackPods []*corev1.Pod) (bool, error) {
+	failed := v.Status.Phase == vegetav1alpha1.FailedPhase
+	if !isAbortRequested(v) && !failed {
+		return false, nil
+	}
+	changed := false
+	if isAbortRequested(v) && v.Status.AbortedAt == nil {
+		switch v.Status.Phase {
+		case "", vegetav1alpha1.PendingPhase, vegetav1alpha1.RunningPhase:
+			v.Status.AbortedAt = &metav1.Time{Time: time.Now()}
+			changed = true
+		default:
+			if !failed {
+				// The attack has already terminated
+				return false, nil
+			}
+		}
+	}
+	for _, pod := range attackPods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed || pod.Annotations[abortAnnotation] == "true" {
+			continue
+		}
+		patch := client.MergeFrom(pod.DeepCopy())
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[abortAnnotation] = "true"
+		if err := r.Patch(ctx, pod, patch); err != nil {
+			return changed, fmt.Errorf("Unable to request the abort of the attack of pod %s: %v", pod.Name, err)
+		}
+	}
+	return changed, nil
+}
+
+// setAborted sets the aborted phase with how long the attack lasted and, when the circuit breaker stopped the attack, why it tripped.
+// The duration of the attack as measured by vegeta is used when the results are available.
+func setAborted(v *vegetav1alpha1.Vegeta) {
+	msg := "The attack has been aborted"
+	reason := vegetav1alpha1.AbortedReason
+	if v.Status.CircuitBreaker != nil {
+		msg = "The attack has been stopped by the circuit breaker"
+		reason = vegetav1alpha1.CircuitBreakerTrippedReason
+	}
+	switch {
+	case v.Status.Results != nil && v.Status.Results.Duration != nil:
+		msg += " after " + v.Status.Results.Duration.Duration.Round(time.Millisecond).String()
+	case v.Status.StartAt != nil && v.Status.AbortedAt != nil:
+		if d := v.Status.AbortedAt.Sub(v.Status.StartAt.Time); d > 0 {
+			msg += " after " + d.Round(time.Second).String()
+		} else {
+			msg += " before it started"
+		}
+	}
+	if v.Status.CircuitBreaker != nil {
+		msg += ": " + v.Status.CircuitBreaker.Message
+	}
+	setPhase(v, vegetav1alpha1.AbortedPhase, reason, msg)
+}
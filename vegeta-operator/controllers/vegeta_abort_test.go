@@ -0,0 +1,125 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Vegeta abort", func() {
+	Context("When the attack command is generated", func() {
+		It("Should watch for the abort request and skip the attack once aborted", func() {
+			vegeta := newVegeta("abort")
+			s := runtime.NewScheme()
+			Expect(vegetav1alpha1.AddToScheme(s)).To(Succeed())
+			pod := (&VegetaReconciler{Scheme: s}).aPod4Attack(vegeta, 0)
+			cmd := pod.Spec.Containers[0].Args[1]
+			Expect(strings.HasPrefix(cmd, "( until [ -s /etc/podinfo/abort ] || [ -e /tmp/vegeta-aborted ]; do sleep 1; done; touch /tmp/vegeta-aborted; ")).Should(BeTrue())
+			Expect(cmd).Should(ContainSubstring(`"vegeta attack "*) kill -INT "${p#/proc/}";;`))
+			Expect(cmd).Should(HaveSuffix("[ -e /tmp/vegeta-aborted ] || { " + getAttackCmd(vegeta) + "; }" + getAttackRecordCmd(vegeta)))
+		})
+	})
+
+	Context("When the abort is requested", func() {
+		It("Should record it and propagate it to the running attack pods", func() {
+			vegeta := newVegeta("abort-running")
+			vegeta.Annotations = map[string]string{abortAnnotation: "true"}
+			vegeta.Status.Phase = vegetav1alpha1.RunningPhase
+			running := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "attack-1", Namespace: TestNs},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+			}
+			r := &VegetaReconciler{Client: fake.NewClientBuilder().WithObjects(running).Build()}
+			recorded, err := r.reconcileAbort(context.Background(), vegeta, []*corev1.Pod{running})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorded).Should(BeTrue())
+			Expect(vegeta.Status.AbortedAt).ToNot(BeNil())
+			pod := &corev1.Pod{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Namespace: TestNs, Name: "attack-1"}, pod)).To(Succeed())
+			Expect(pod.Annotations).Should(HaveKeyWithValue(abortAnnotation, "true"))
+
+			recorded, err = r.reconcileAbort(context.Background(), vegeta, []*corev1.Pod{pod})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorded).Should(BeFalse())
+		})
+		It("Should stop the attack pods still active once the run has failed", func() {
+			vegeta := newVegeta("abort-failed")
+			vegeta.Spec.Replicas = 2
+			vegeta.Status.Phase = vegetav1alpha1.FailedPhase
+			waiting := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "attack-2", Namespace: TestNs},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+			}
+			failed := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "attack-1", Namespace: TestNs},
+				Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+			}
+			r := &VegetaReconciler{Client: fake.NewClientBuilder().WithObjects(waiting, failed).Build()}
+			recorded, err := r.reconcileAbort(context.Background(), vegeta, []*corev1.Pod{failed, waiting})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorded).Should(BeFalse())
+			Expect(vegeta.Status.AbortedAt).Should(BeNil())
+			pod := &corev1.Pod{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Namespace: TestNs, Name: "attack-2"}, pod)).To(Succeed())
+			Expect(pod.Annotations).Should(HaveKeyWithValue(abortAnnotation, "true"))
+			pod = &corev1.Pod{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Namespace: TestNs, Name: "attack-1"}, pod)).To(Succeed())
+			Expect(pod.Annotations).ShouldNot(HaveKey(abortAnnotation))
+		})
+		It("Should ignore it once the attack has terminated", func() {
+			vegeta := newVegeta("abort-late")
+			vegeta.Spec.Abort = true
+			vegeta.Status.Phase = vegetav1alpha1.SucceededPhase
+			recorded, err := (&VegetaReconciler{}).reconcileAbort(context.Background(), vegeta, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorded).Should(BeFalse())
+			Expect(vegeta.Status.AbortedAt).Should(BeNil())
+		})
+	})
+
+	Context("When the report of an aborted attack has been generated", func() {
+		It("Should end in the aborted phase with the partial results", func() {
+			vegeta := newVegeta("aborted")
+			vegeta.Spec.Thresholds = []string{"p99 < 1ms"}
+			vegeta.Status.AbortedAt = &metav1.Time{}
+			pod := &corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  containerName,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: testReport}},
+			}}}}
+			Expect(completeWithResults(vegeta, []*corev1.Pod{pod})).To(Succeed())
+			Expect(vegeta.Status.Phase).Should(Equal(vegetav1alpha1.AbortedPhase))
+			Expect(vegeta.Status.Results).ToNot(BeNil())
+			aborted := meta.FindStatusCondition(vegeta.Status.Conditions, vegetav1alpha1.AbortedCondition)
+			Expect(aborted).ToNot(BeNil())
+			Expect(aborted.Status).Should(Equal(metav1.ConditionTrue))
+			Expect(aborted.Message).Should(Equal("The attack has been aborted after 10s"))
+			Expect(meta.FindStatusCondition(vegeta.Status.Conditions, vegetav1alpha1.ThresholdsMetCondition)).Should(BeNil())
+		})
+	})
+})
@@ -0,0 +1,94 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// breakdownRecordFile is where the breakdown of the attack container writes the results per target
+	breakdownRecordFile = "/tmp/vegeta-breakdown"
+	// breakdownMaxTargets is the maximum number of targets in the record of the breakdown. The recorder drops further ones if needed for the record to fit into the termination message.
+	breakdownMaxTargets = 10
+)
+
+// hasBreakdown returns true when the results of the attack are to be broken down per target: per endpoint of a Service or for verifying a weighted traffic mix
+func hasBreakdown(v *vegetav1alpha1.Vegeta) bool {
+	return isPerEndpoint(v) || hasTargetMix(v)
+}
+
+// getBreakdownCmd generates the command breaking the json results of the attack down per target.
+// The results are passed through unchanged.
+func getBreakdownCmd() string {
+	return shellJoin([]string{"breakdown", "-output", breakdownRecordFile, "-max", strconv.Itoa(breakdownMaxTargets)})
+}
+
+// breakdownRecord mirrors the record written by the breakdown: the total number of requests and the results of the slowest targets
+type breakdownRecord struct {
+	Requests uint64          `json:"requests"`
+	Targets  []targetMetrics `json:"targets"`
+}
+
+// targetMetrics mirrors the results of a target in the record of the breakdown, whose field names are the ones of the vegeta json report
+type targetMetrics struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	vegetaMetrics
+}
+
+// podBreakdown extracts the record of the breakdown from the termination message of a terminated attack pod.
+// It returns nil if the record is not available, for instance because the termination message got truncated.
+func podBreakdown(pod *corev1.Pod) *breakdownRecord {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name != containerName || cs.State.Terminated == nil {
+			continue
+		}
+		// The termination message is a sequence of json documents: the report, if generated by the attack pod, the record of the trip of the breaker and the breakdown
+		dec := json.NewDecoder(strings.NewReader(cs.State.Terminated.Message))
+		for {
+			var record struct {
+				Breakdown *breakdownRecord `json:"breakdown"`
+			}
+			if err := dec.Decode(&record); err != nil {
+				return nil
+			}
+			if record.Breakdown != nil {
+				return record.Breakdown
+			}
+		}
+	}
+	return nil
+}
+
+// attackTerminated returns true when all the attack pods have terminated
+func attackTerminated(attackPods []*corev1.Pod) bool {
+	if len(attackPods) == 0 {
+		return false
+	}
+	for _, pod := range attackPods {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			return false
+		}
+	}
+	return true
+}
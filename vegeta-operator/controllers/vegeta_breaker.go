@@ -0,0 +1,97 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// breakerTripFile is where the breaker of the attack container writes the record of the window that tripped it
+const breakerTripFile = "/tmp/vegeta-breaker-trip"
+
+// hasCircuitBreaker returns true when the results of the attack are to be evaluated by the circuit breaker
+func hasCircuitBreaker(v *vegetav1alpha1.Vegeta) bool {
+	return v.Spec.CircuitBreaker != nil
+}
+
+// getCircuitBreakerCmd generates the command evaluating the json results of the attack over a sliding window.
+// The results are passed through unchanged. When the breaker trips it creates the aborted marker, upon which the abort watcher interrupts the attack.
+func getCircuitBreakerCmd(v *vegetav1alpha1.Vegeta) string {
+	b := v.Spec.CircuitBreaker
+	args := []string{"breaker"}
+	if b.Window != "" {
+		args = append(args, "-window", b.Window)
+	}
+	if b.MaxErrorRatio != "" {
+		if ratio, err := vegetav1alpha1.ParseRatio(b.MaxErrorRatio); err == nil {
+			args = append(args, "-max-error-ratio", strconv.FormatFloat(ratio, 'f', -1, 64))
+		}
+	}
+	if b.MaxP99 != "" {
+		args = append(args, "-max-p99", b.MaxP99)
+	}
+	if b.MinRequests > 0 {
+		args = append(args, "-min-requests", strconv.FormatUint(uint64(b.MinRequests), 10))
+	}
+	args = append(args, "-trip-file", breakerTripFile, "-marker", abortedMarker)
+	return shellJoin(args)
+}
+
+// podTrip extracts the record of the trip of the circuit breaker from the termination message of a terminated attack pod.
+// It returns nil if the breaker of the pod has not tripped.
+func podTrip(pod *corev1.Pod) *vegetav1alpha1.CircuitBreakerTrip {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name != containerName || cs.State.Terminated == nil {
+			continue
+		}
+		// The termination message is a sequence of json documents: the report, if generated by the attack pod, and the record of the trip
+		dec := json.NewDecoder(strings.NewReader(cs.State.Terminated.Message))
+		for {
+			var record struct {
+				CircuitBreaker *vegetav1alpha1.CircuitBreakerTrip `json:"circuitBreaker"`
+			}
+			if err := dec.Decode(&record); err != nil {
+				return nil
+			}
+			if record.CircuitBreaker != nil {
+				record.CircuitBreaker.Pod = pod.Name
+				return record.CircuitBreaker
+			}
+		}
+	}
+	return nil
+}
+
+// recordCircuitBreakerTrip records the first trip of the circuit breaker of the attack pods in the status.
+// The abort of the attack of the other pods follows from it. It returns true if the trip has been recorded.
+func recordCircuitBreakerTrip(v *vegetav1alpha1.Vegeta, attackPods []*corev1.Pod) bool {
+	if !hasCircuitBreaker(v) || v.Status.CircuitBreaker != nil {
+		return false
+	}
+	for _, pod := range attackPods {
+		if trip := podTrip(pod); trip != nil {
+			v.Status.CircuitBreaker = trip
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// testTrip is a trip record as written by the breaker
+const testTrip = `{"circuitBreaker":{"reason":"ErrorRatioExceeded","message":"The error ratio 25.5% of the 51 requests of the last 5s is above 20%","windowStart":"2021-01-01T00:00:14.5Z","windowEnd":"2021-01-01T00:00:19.5Z","requests":51,"errorRatio":"25.5%","p99":"1.2ms"}}`
+
+func terminatedWith(name, message string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  containerName,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message}},
+		}}},
+	}
+}
+
+var _ = Describe("Vegeta circuit breaker", func() {
+	Context("When the attack command is generated", func() {
+		It("Should evaluate the json results on their way to the report", func() {
+			vegeta := newVegeta("breaker")
+			vegeta.Spec.CircuitBreaker = &vegetav1alpha1.CircuitBreakerSpec{Window: "5s", MaxErrorRatio: "5%", MaxP99: "500ms", MinRequests: 20}
+			cmd := getAttackCmd(vegeta)
+			Expect(cmd).Should(ContainSubstring(" | vegeta encode -to json | breaker -window 5s -max-error-ratio 0.05 -max-p99 500ms -min-requests 20 -trip-file /tmp/vegeta-breaker-trip -marker /tmp/vegeta-aborted | tee "))
+			Expect(cmd).Should(ContainSubstring("_res.json"))
+
+			s := runtime.NewScheme()
+			Expect(vegetav1alpha1.AddToScheme(s)).To(Succeed())
+			pod := (&VegetaReconciler{Scheme: s}).aPod4Attack(vegeta, 0)
+			Expect(pod.Spec.Containers[0].Args[1]).Should(HaveSuffix("; }; rc=$?; vegeta report -type json -output /tmp/vegeta-report.json /results/00010101000000-${HOSTNAME}_res.json; recorder -output /dev/termination-log -max-bytes 4096 -max-errors 10 -report /tmp/vegeta-report.json -trip /tmp/vegeta-breaker-trip; exit $rc"))
+		})
+		It("Should write the results of the stages through the breaker to the result file", func() {
+			vegeta := newVegeta("breaker-stages")
+			vegeta.Spec.Attack.Rate = ""
+			vegeta.Spec.Attack.Duration = ""
+			vegeta.Spec.Attack.Stages = []vegetav1alpha1.Stage{{Duration: "10s", Rate: "10/1s"}}
+			vegeta.Spec.Report = &vegetav1alpha1.ReportSpec{OutputType: vegetav1alpha1.PvcOutput, OutputClaim: "results"}
+			vegeta.Spec.CircuitBreaker = &vegetav1alpha1.CircuitBreakerSpec{MaxP99: "1s"}
+			cmd := getAttackCmd(vegeta)
+			Expect(strings.Count(cmd, "vegeta encode -to json")).Should(Equal(1))
+			Expect(cmd).Should(ContainSubstring("; } | breaker -max-p99 1s -trip-file /tmp/vegeta-breaker-trip -marker /tmp/vegeta-aborted > /results/"))
+		})
+		It("Should leave the attack unchanged without circuit breaker", func() {
+			vegeta := newVegeta("no-breaker")
+			Expect(getAttackCmd(vegeta)).ShouldNot(ContainSubstring("breaker"))
+			Expect(getAttackRecordCmd(vegeta)).ShouldNot(ContainSubstring("-trip"))
+		})
+	})
+
+	Context("When an attack pod has terminated", func() {
+		It("Should find the trip record after the report", func() {
+			pod := terminatedWith("attack-1", testReport+"\n"+testTrip+"\n")
+			trip := podTrip(pod)
+			Expect(trip).ToNot(BeNil())
+			Expect(trip.Pod).Should(Equal("attack-1"))
+			Expect(trip.Reason).Should(Equal("ErrorRatioExceeded"))
+			Expect(trip.Requests).Should(Equal(uint64(51)))
+			Expect(trip.P99.Duration).Should(Equal(1200 * time.Microsecond))
+			Expect(trip.WindowEnd.Sub(trip.WindowStart.Time)).Should(Equal(5 * time.Second))
+			m, err := podMetrics(pod)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.Requests).Should(BeNumerically(">", 0))
+		})
+		It("Should find the trip record alone when the results are stored", func() {
+			Expect(podTrip(terminatedWith("attack-1", testTrip))).ToNot(BeNil())
+			Expect(podTrip(terminatedWith("attack-1", testReport))).Should(BeNil())
+			Expect(podTrip(terminatedWith("attack-1", ""))).Should(BeNil())
+		})
+	})
+
+	Context("When the breaker of an attack pod has tripped", func() {
+		It("Should record the window and abort the attack", func() {
+			vegeta := newVegeta("tripped")
+			vegeta.Spec.CircuitBreaker = &vegetav1alpha1.CircuitBreakerSpec{MaxErrorRatio: "20%"}
+			running := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "attack-0"}, Status: corev1.PodStatus{Phase: corev1.PodRunning}}
+			pods := []*corev1.Pod{running, terminatedWith("attack-1", testTrip)}
+			Expect(recordCircuitBreakerTrip(vegeta, pods)).Should(BeTrue())
+			Expect(vegeta.Status.CircuitBreaker.Pod).Should(Equal("attack-1"))
+			Expect(isAbortRequested(vegeta)).Should(BeTrue())
+			Expect(recordCircuitBreakerTrip(vegeta, pods)).Should(BeFalse())
+
+			vegeta.Status.AbortedAt = &metav1.Time{}
+			Expect(completeWithResults(vegeta, []*corev1.Pod{terminatedWith("report", testReport)})).To(Succeed())
+			Expect(vegeta.Status.Phase).Should(Equal(vegetav1alpha1.AbortedPhase))
+			aborted := meta.FindStatusCondition(vegeta.Status.Conditions, vegetav1alpha1.AbortedCondition)
+			Expect(aborted).ToNot(BeNil())
+			Expect(aborted.Reason).Should(Equal(vegetav1alpha1.CircuitBreakerTrippedReason))
+			Expect(aborted.Message).Should(Equal("The attack has been stopped by the circuit breaker after 10s: The error ratio 25.5% of the 51 requests of the last 5s is above 20%"))
+		})
+		It("Should ignore trip records without circuit breaker", func() {
+			vegeta := newVegeta("not-tripped")
+			Expect(recordCircuitBreakerTrip(vegeta, []*corev1.Pod{terminatedWith("attack-1", testTrip)})).Should(BeFalse())
+		})
+	})
+})
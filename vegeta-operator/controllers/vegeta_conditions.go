@@ -0,0 +1,156 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// setCondition adds or updates a condition of the vegeta resource. The transition time only changes with the status of the condition.
+func setCondition(v *vegetav1alpha1.Vegeta, condType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&v.Status.Conditions, metav1.Condition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: v.Generation,
+	})
+}
+
+// setPhase sets the phase of the vegeta resource together with the matching Ready, Complete and Failed conditions.
+// Reason and message are used for the failed and aborted phases, other phases have predefined ones.
+func setPhase(v *vegetav1alpha1.Vegeta, phase vegetav1alpha1.PhaseEnum, reason, message string) {
+	v.Status.Phase = phase
+	switch phase {
+	case vegetav1alpha1.PendingPhase:
+		setCondition(v, vegetav1alpha1.ReadyCondition, metav1.ConditionFalse, vegetav1alpha1.PendingReason, "No attack pod has started yet")
+	case vegetav1alpha1.RunningPhase:
+		setCondition(v, vegetav1alpha1.ReadyCondition, metav1.ConditionFalse, vegetav1alpha1.RunningReason, "The attack is in progress")
+	case vegetav1alpha1.SucceededPhase:
+		setCondition(v, vegetav1alpha1.ReadyCondition, metav1.ConditionFalse, vegetav1alpha1.GeneratingReportReason, "The attack has succeeded, the report is being generated")
+	case vegetav1alpha1.FailedPhase:
+		setCondition(v, vegetav1alpha1.ReadyCondition, metav1.ConditionFalse, reason, message)
+		setCondition(v, vegetav1alpha1.FailedCondition, metav1.ConditionTrue, reason, message)
+	case vegetav1alpha1.CompletedPhase:
+		setCondition(v, vegetav1alpha1.ReadyCondition, metav1.ConditionTrue, vegetav1alpha1.CompletedReason, "The attack has succeeded and the report has been generated")
+		setCondition(v, vegetav1alpha1.CompleteCondition, metav1.ConditionTrue, vegetav1alpha1.CompletedReason, "The attack has succeeded and the report has been generated")
+	case vegetav1alpha1.AbortedPhase:
+		setCondition(v, vegetav1alpha1.ReadyCondition, metav1.ConditionTrue, reason, message)
+		setCondition(v, vegetav1alpha1.AbortedCondition, metav1.ConditionTrue, reason, message)
+	}
+}
+
+// setAttackPodConditions reflects the state of the attack pods into the PodsScheduled, AttackRunning and AttackSucceeded conditions
+func setAttackPodConditions(v *vegetav1alpha1.Vegeta, pods []*corev1.Pod) {
+	var scheduled, running, succeeded, failed uint32
+	var unschedulable, firstFailed *corev1.Pod
+	for _, pod := range pods {
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodScheduled {
+				if c.Status == corev1.ConditionTrue {
+					scheduled++
+				} else if c.Reason == corev1.PodReasonUnschedulable && unschedulable == nil {
+					unschedulable = pod
+				}
+			}
+		}
+		switch pod.Status.Phase {
+		case corev1.PodRunning:
+			running++
+		case corev1.PodSucceeded:
+			succeeded++
+		case corev1.PodFailed:
+			failed++
+			if firstFailed == nil {
+				firstFailed = pod
+			}
+		}
+	}
+
+	switch {
+	case scheduled >= v.Spec.Replicas:
+		setCondition(v, vegetav1alpha1.PodsScheduledCondition, metav1.ConditionTrue, vegetav1alpha1.AllPodsScheduledReason,
+			fmt.Sprintf("%d/%d attack pods scheduled", scheduled, v.Spec.Replicas))
+	case unschedulable != nil:
+		setCondition(v, vegetav1alpha1.PodsScheduledCondition, metav1.ConditionFalse, vegetav1alpha1.UnschedulableReason,
+			fmt.Sprintf("%d/%d attack pods scheduled, pod %s cannot be scheduled: %s", scheduled, v.Spec.Replicas, unschedulable.Name, podConditionMessage(unschedulable, corev1.PodScheduled)))
+	default:
+		setCondition(v, vegetav1alpha1.PodsScheduledCondition, metav1.ConditionFalse, vegetav1alpha1.PodsPendingReason,
+			fmt.Sprintf("%d/%d attack pods scheduled", scheduled, v.Spec.Replicas))
+	}
+
+	terminated := succeeded + failed
+	switch {
+	case running > 0:
+		setCondition(v, vegetav1alpha1.AttackRunningCondition, metav1.ConditionTrue, vegetav1alpha1.AttackPodsRunningReason,
+			fmt.Sprintf("%d/%d attack pods running", running, v.Spec.Replicas))
+	case terminated >= v.Spec.Replicas:
+		setCondition(v, vegetav1alpha1.AttackRunningCondition, metav1.ConditionFalse, vegetav1alpha1.AttackFinishedReason,
+			fmt.Sprintf("%d/%d attack pods terminated", terminated, v.Spec.Replicas))
+	default:
+		setCondition(v, vegetav1alpha1.AttackRunningCondition, metav1.ConditionFalse, vegetav1alpha1.AttackPodsPendingReason,
+			"No attack pod is running yet")
+	}
+
+	switch {
+	case firstFailed != nil:
+		setCondition(v, vegetav1alpha1.AttackSucceededCondition, metav1.ConditionFalse, vegetav1alpha1.AttackPodFailedReason, podFailureMessage(firstFailed))
+	case succeeded >= v.Spec.Replicas:
+		setCondition(v, vegetav1alpha1.AttackSucceededCondition, metav1.ConditionTrue, vegetav1alpha1.AllPodsSucceededReason,
+			fmt.Sprintf("%d/%d attack pods succeeded", succeeded, v.Spec.Replicas))
+	default:
+		setCondition(v, vegetav1alpha1.AttackSucceededCondition, metav1.ConditionUnknown, vegetav1alpha1.AttackInProgressReason,
+			fmt.Sprintf("%d/%d attack pods succeeded", succeeded, v.Spec.Replicas))
+	}
+}
+
+// podFailureMessage provides a human readable explanation of the failure of a pod
+func podFailureMessage(pod *corev1.Pod) string {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if t := cs.State.Terminated; t != nil && t.ExitCode != 0 {
+			msg := fmt.Sprintf("Pod %s failed: container %s terminated with exit code %d", pod.Name, cs.Name, t.ExitCode)
+			if t.Reason != "" {
+				msg += " (" + t.Reason + ")"
+			}
+			// Messages that are not records of the attack explain the failure, e.g. the timeout of the start barrier
+			if m := strings.TrimSpace(t.Message); m != "" && !strings.HasPrefix(m, "{") {
+				msg += ": " + m
+			}
+			return msg
+		}
+	}
+	if pod.Status.Message != "" {
+		return fmt.Sprintf("Pod %s failed: %s", pod.Name, pod.Status.Message)
+	}
+	return fmt.Sprintf("Pod %s failed", pod.Name)
+}
+
+// podConditionMessage returns the message of the pod condition of the given type
+func podConditionMessage(pod *corev1.Pod, condType corev1.PodConditionType) string {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == condType {
+			return c.Message
+		}
+	}
+	return ""
+}
@@ -30,6 +30,7 @@ import (
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -46,6 +47,10 @@ type VegetaReconciler struct {
 	Scheme *runtime.Scheme
 	Labels operator.Labels
 	Image  string
+	// APIReader reads resources that are not watched by the operator, e.g. the ones targets are resolved from. Defaulted to the uncached reader of the manager.
+	APIReader client.Reader
+	// TTLSecondsAfterFinished is the default time to live of the pods of finished runs. Nil means that they are kept.
+	TTLSecondsAfterFinished *int32
 }
 
 var (
@@ -56,6 +61,9 @@ var (
 // +kubebuilder:rbac:groups=vegeta.testing.io,resources=vegeta,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=vegeta.testing.io,resources=vegeta/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get
 // +kubebuilder:rbac:groups=vegeta.testing.io,resources=vegeta/finalizers,verbs=update
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -85,6 +93,16 @@ func (r *VegetaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, fmt.Errorf("Failed to get Vegeta resource: %v", err)
 	}
 
+	// A new run starts with a fresh status once the processing of the current one has terminated
+	if isNewRunRequested(vegeta) {
+		startNewRun(vegeta)
+		if err := r.Status().Update(ctx, vegeta); err != nil {
+			return ctrl.Result{}, fmt.Errorf("Unable to update Vegeta status for the new run: %v", err)
+		}
+		log.V(0).Info("New run started", "run", vegeta.Spec.RunID)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
 	statusChanged := false
 
 	// podOwnerKey field is added to the cached pod objects. This key references the owning controller and functions as the index.
@@ -95,33 +113,101 @@ func (r *VegetaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	if err := r.List(ctx, &childPods, client.InNamespace(req.Namespace), client.MatchingFields{podOwnerKey: req.Name}); err != nil {
 		return ctrl.Result{}, fmt.Errorf("List Vegeta's child pods: %v", err)
 	}
+	// Only the pods of the current run are considered, the ones of previous runs get deleted
+	if err := r.removePreviousRuns(ctx, vegeta, &childPods); err != nil {
+		return ctrl.Result{}, err
+	}
 	// But first give time to the pods to get created if the reconciliation loop has already been run
-	if uint32(len(childPods.Items)) < vegeta.Spec.Replicas {
+	if uint32(len(childPods.Items)) < vegeta.Spec.Replicas && !vegeta.Status.Phase.IsTerminated() {
 		time.Sleep(1 * time.Second)
 		// and try to get the list again
 		if err := r.List(ctx, &childPods, client.InNamespace(req.Namespace), client.MatchingFields{podOwnerKey: req.Name}); err != nil {
 			return ctrl.Result{}, fmt.Errorf("List Vegeta's child pods: %v", err)
 		}
+		if err := r.removePreviousRuns(ctx, vegeta, &childPods); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	// The pods of a finished run get deleted once their time to live has expired
+	expiry := r.ttlExpiry(vegeta)
+	if expiry != nil && !time.Now().Before(*expiry) {
+		if err := r.cleanupFinished(ctx, vegeta, &childPods); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	// Nothing to run if the attack has been aborted before the attack pods got created
+	if len(childPods.Items) == 0 && isAbortRequested(vegeta) {
+		if vegeta.Status.Phase == "" || vegeta.Status.Phase == vegetav1alpha1.PendingPhase {
+			vegeta.Status.RunID = currentRun(vegeta)
+			vegeta.Status.AbortedAt = &metav1.Time{Time: time.Now()}
+			setAborted(vegeta)
+			if err := r.Status().Update(ctx, vegeta); err != nil {
+				return ctrl.Result{}, fmt.Errorf("Unable to update Vegeta status: %v", err)
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+	// Only the replicas without attack pod get created, the pods are told apart by their replica label
+	missing := missingReplicas(vegeta, attackPodsOf(&childPods))
+	// The inline targets and the weighted targets of a config map need to be rendered before the attack pods mounting them get created
+	if len(missing) > 0 && !vegeta.Status.Phase.IsTerminated() && (len(vegeta.Spec.Attack.Targets) > 0 || isWeightedConfigMap(vegeta)) {
+		if err := r.reconcileTargets(ctx, vegeta); err != nil {
+			return ctrl.Result{}, err
+		}
 	}
-	for i := uint32(len(childPods.Items)); i < vegeta.Spec.Replicas; i++ {
-		go func() {
-			pod := r.aPod4Attack(vegeta)
-			if err = r.Create(ctx, pod); err != nil {
+	// The target reference is resolved once per run before the attack pods get created, so that each run follows the current address of the target
+	if len(missing) > 0 && !vegeta.Status.Phase.IsTerminated() && vegeta.Spec.Attack.TargetRef != nil {
+		changed, err := r.reconcileTargetRef(ctx, vegeta)
+		if err != nil {
+			if changed {
+				if uerr := r.Status().Update(ctx, vegeta); uerr != nil {
+					log.Error(uerr, "Unable to update Vegeta status")
+				}
+			}
+			return ctrl.Result{}, err
+		}
+	}
+	// The targets of an OpenAPI document are derived once per run, so that each run follows the current version of the document
+	if len(missing) > 0 && !vegeta.Status.Phase.IsTerminated() && vegeta.Spec.Attack.OpenAPI != nil {
+		changed, err := r.reconcileOpenAPI(ctx, vegeta)
+		if err != nil {
+			if changed {
+				if uerr := r.Status().Update(ctx, vegeta); uerr != nil {
+					log.Error(uerr, "Unable to update Vegeta status")
+				}
+			}
+			return ctrl.Result{}, err
+		}
+	}
+	// The start of the run is recorded before the attack pods get created. The webhook rejects the modifications of the specification once the phase is set
+	// and the update conflicts with a modification made since the resource has been read, so that the pods never run a specification that can still change.
+	if len(missing) > 0 && vegeta.Status.Phase == "" {
+		vegeta.Status.RunID = currentRun(vegeta)
+		vegeta.Status.StartTime = &metav1.Time{Time: time.Now()}
+		setPhase(vegeta, vegetav1alpha1.PendingPhase, "", "")
+		vegeta.Status.ReplicaRates = getReplicaRates(vegeta)
+		setAttackPodConditions(vegeta, attackPodsOf(&childPods))
+		if err := r.Status().Update(ctx, vegeta); err != nil {
+			return ctrl.Result{}, fmt.Errorf("Unable to record the start of the run in the Vegeta status: %v", err)
+		}
+	}
+	// Pods deleted after the run has finished don't get recreated
+	for _, i := range missing {
+		if vegeta.Status.Phase.IsTerminated() {
+			break
+		}
+		go func(replica uint32) {
+			pod := r.aPod4Attack(vegeta, replica)
+			if err := r.Create(ctx, pod); err != nil {
 				log.Error(err, "Failed to create new Pod", "Pod.Namespace", pod.Namespace, "Pod.Name", pod.Name)
 			}
 			log.V(0).Info("created", "pod", pod)
-		}()
+		}(i)
 		statusChanged = true
 	}
 	if statusChanged {
-		// attack pods created, return and requeue
-		if vegeta.Status.Phase == "" {
-			vegeta.Status.Phase = vegetav1alpha1.PendingPhase
-			if err := r.Status().Update(ctx, vegeta); err != nil {
-				return ctrl.Result{Requeue: true, RequeueAfter: 5 * time.Second}, fmt.Errorf("Unable to update Vegeta status: %v", err)
-			}
-		}
-		// status updated with pending on creation of attack pods, requeue and return
+		// attack pods created with the status already pending, requeue and return
 		return ctrl.Result{Requeue: true, RequeueAfter: 5 * time.Second}, nil
 	}
 
@@ -153,17 +239,59 @@ func (r *VegetaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	applyChanges(&activePods, &vegeta.Status.Active)
 	applyChanges(&successfulPods, &vegeta.Status.Succeeded)
 	applyChanges(&failedPods, &vegeta.Status.Failed)
+	conditions := make([]metav1.Condition, len(vegeta.Status.Conditions))
+	copy(conditions, vegeta.Status.Conditions)
+	attackPods := attackPodsOf(&childPods)
+	setAttackPodConditions(vegeta, attackPods)
+	if placement := r.placementOf(ctx, attackPods, vegeta.Status.Placement); !equality.Semantic.DeepEqual(placement, vegeta.Status.Placement) {
+		vegeta.Status.Placement = placement
+		statusChanged = true
+	}
+	startAtSet, err := r.reconcileStartBarrier(ctx, vegeta, attackPods)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if startAtSet {
+		statusChanged = true
+	}
+	if recordCircuitBreakerTrip(vegeta, attackPods) {
+		statusChanged = true
+	}
+	if recordEndpointResults(vegeta, attackPods) {
+		statusChanged = true
+	}
+	if recordTargetMix(vegeta, attackPods) {
+		statusChanged = true
+	}
+	if recordLoadProfileDrift(vegeta, attackPods) {
+		statusChanged = true
+	}
+	abortRecorded, err := r.reconcileAbort(ctx, vegeta, attackPods)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if abortRecorded {
+		statusChanged = true
+	}
+	if !equality.Semantic.DeepEqual(conditions, vegeta.Status.Conditions) {
+		statusChanged = true
+	}
 	log.V(1).Info("pod count", "active pods", len(vegeta.Status.Active), "successful pods", len(vegeta.Status.Succeeded), "failed pods", len(vegeta.Status.Failed))
 
 	// Update the vegeta status
 	if statusChanged {
-		if vegeta.Status.Phase != vegetav1alpha1.CompletedPhase && vegeta.Status.Phase != vegetav1alpha1.FailedPhase {
+		if !vegeta.Status.Phase.IsTerminated() {
 			if len(failedPods) > 0 {
-				vegeta.Status.Phase = vegetav1alpha1.FailedPhase
+				for _, pod := range attackPods {
+					if pod.Status.Phase == corev1.PodFailed {
+						setPhase(vegeta, vegetav1alpha1.FailedPhase, vegetav1alpha1.AttackPodFailedReason, podFailureMessage(pod))
+						break
+					}
+				}
 			} else if len(activePods) > 0 {
-				vegeta.Status.Phase = vegetav1alpha1.RunningPhase
+				setPhase(vegeta, vegetav1alpha1.RunningPhase, "", "")
 			} else {
-				vegeta.Status.Phase = vegetav1alpha1.SucceededPhase
+				setPhase(vegeta, vegetav1alpha1.SucceededPhase, "", "")
 			}
 		}
 		if err := r.Status().Update(ctx, vegeta); err != nil {
@@ -175,9 +303,12 @@ func (r *VegetaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 	// Attack pods have succeeded but report pod may need to be started
 	if vegeta.Status.Phase == vegetav1alpha1.SucceededPhase && uint32(len(childPods.Items)) < vegeta.Spec.Replicas+1 {
-		if vegeta.Spec.Report == nil || vegeta.Spec.Report.OutputType.String() == "" {
-			// Nothing to do the report was processed within the attack pod
-			vegeta.Status.Phase = vegetav1alpha1.CompletedPhase
+		if !isStoredOutput(vegeta) {
+			// Nothing to do the report was processed within the attack pod, which is the case for the stdout output defaulted by the webhook
+			setCondition(vegeta, vegetav1alpha1.ReportGeneratedCondition, metav1.ConditionTrue, vegetav1alpha1.ReportGeneratedByAttackPodsReason, "The report has been generated by the attack pods")
+			if err := completeWithResults(vegeta, attackPods); err != nil {
+				log.Error(err, "Unable to retrieve the results from the attack pods")
+			}
 			if err := r.Status().Update(ctx, vegeta); err != nil {
 				return ctrl.Result{}, fmt.Errorf("Unable to update Vegeta status to completion: %v", err)
 			}
@@ -191,24 +322,33 @@ func (r *VegetaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			return ctrl.Result{}, fmt.Errorf("Failed to create the pod to generate the report: %v", err)
 		}
 		log.V(0).Info("Report pod created", "pod", pod)
+		setCondition(vegeta, vegetav1alpha1.ReportGeneratedCondition, metav1.ConditionFalse, vegetav1alpha1.ReportPodRunningReason, "The report is being generated by pod "+pod.Name)
+		if err := r.Status().Update(ctx, vegeta); err != nil {
+			return ctrl.Result{}, fmt.Errorf("Unable to update Vegeta status: %v", err)
+		}
 		// Requeue for further processing
 		return ctrl.Result{Requeue: true, RequeueAfter: 2 * time.Second}, nil
 	}
 
 	// Checking the report pod
 	if vegeta.Status.Phase == vegetav1alpha1.SucceededPhase && uint32(len(childPods.Items)) == vegeta.Spec.Replicas+1 {
-		for _, pod := range childPods.Items {
+		for i, pod := range childPods.Items {
 			if pod.Labels["vegeta.testing.io/type"] == "report" {
 				switch pod.Status.Phase {
 				case corev1.PodFailed:
-					vegeta.Status.Phase = vegetav1alpha1.FailedPhase
+					msg := podFailureMessage(&childPods.Items[i])
+					setCondition(vegeta, vegetav1alpha1.ReportGeneratedCondition, metav1.ConditionFalse, vegetav1alpha1.ReportPodFailedReason, msg)
+					setPhase(vegeta, vegetav1alpha1.FailedPhase, vegetav1alpha1.ReportPodFailedReason, msg)
 					if err := r.Status().Update(ctx, vegeta); err != nil {
 						return ctrl.Result{}, fmt.Errorf("Unable to update Vegeta status: %v", err)
 					}
 					// status updated with failure of report pod, return, no need to requeue
 					return ctrl.Result{}, nil
 				case corev1.PodSucceeded:
-					vegeta.Status.Phase = vegetav1alpha1.CompletedPhase
+					setCondition(vegeta, vegetav1alpha1.ReportGeneratedCondition, metav1.ConditionTrue, vegetav1alpha1.ReportPodSucceededReason, "The report has been generated by pod "+pod.Name)
+					if err := completeWithResults(vegeta, []*corev1.Pod{&childPods.Items[i]}); err != nil {
+						log.Error(err, "Unable to retrieve the results from the report pod", "Pod.Name", pod.Name)
+					}
 					if err := r.Status().Update(ctx, vegeta); err != nil {
 						return ctrl.Result{}, fmt.Errorf("Unable to update Vegeta status: %v", err)
 					}
@@ -222,17 +362,31 @@ func (r *VegetaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		}
 	}
 
-	// TODO:
-	// It would make sense to implement a validating webhook to force most of the spec fields to be immutable
-	// It makes little sense to allow changing them after the pods have been created.
-	// https://book.kubebuilder.io/cronjob-tutorial/webhook-implementation.html
+	// Requeue for the cleanup of the finished run
+	if expiry != nil {
+		return ctrl.Result{RequeueAfter: time.Until(*expiry)}, nil
+	}
 
 	// Request successfully processed - no requeue
 	return ctrl.Result{}, nil
 }
 
+// attackPodsOf filters the attack pods out of the child pods of a vegeta resource
+func attackPodsOf(childPods *corev1.PodList) []*corev1.Pod {
+	var attackPods []*corev1.Pod
+	for i := range childPods.Items {
+		if childPods.Items[i].Labels["vegeta.testing.io/type"] == "attack" {
+			attackPods = append(attackPods, &childPods.Items[i])
+		}
+	}
+	return attackPods
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *VegetaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.APIReader == nil {
+		r.APIReader = mgr.GetAPIReader()
+	}
 	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Pod{}, podOwnerKey, func(rawObj client.Object) []string {
 		// grab the job object, extract the owner...
 		pod := rawObj.(*corev1.Pod)
@@ -28,6 +28,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -90,6 +91,58 @@ var _ = Describe("Vegeta controller", func() {
 			GinkgoWriter.Write([]byte(msg))
 			Expect(len(createdVegeta.Status.Active)).Should(Equal(0))
 			Expect(len(createdVegeta.Status.Succeeded)).Should(Equal(1))
+			Expect(meta.IsStatusConditionTrue(createdVegeta.Status.Conditions, v1alpha1.CompleteCondition)).Should(BeTrue())
+			Expect(meta.IsStatusConditionTrue(createdVegeta.Status.Conditions, v1alpha1.ReadyCondition)).Should(BeTrue())
+			Expect(meta.IsStatusConditionTrue(createdVegeta.Status.Conditions, v1alpha1.AttackSucceededCondition)).Should(BeTrue())
+			Expect(meta.IsStatusConditionTrue(createdVegeta.Status.Conditions, v1alpha1.ReportGeneratedCondition)).Should(BeTrue())
+			Expect(meta.IsStatusConditionFalse(createdVegeta.Status.Conditions, v1alpha1.AttackRunningCondition)).Should(BeTrue())
+		})
+	})
+	Context("When an attack pod writes its report into the termination message", func() {
+		It("Should publish the results in Vegeta.Status", func() {
+			By("Creation")
+			ctx := context.Background()
+			vegeta := newVegeta(VegetaName + "-results")
+			Expect(k8sClient.Create(ctx, vegeta)).Should(Succeed())
+			vLookupKey := types.NamespacedName{Name: vegeta.Name, Namespace: TestNs}
+			createdVegeta := &vegetav1alpha1.Vegeta{}
+
+			Eventually(func() v1alpha1.PhaseEnum {
+				_ = k8sClient.Get(ctx, vLookupKey, createdVegeta)
+				return createdVegeta.Status.Phase
+			}, timeout, interval).Should(Equal(v1alpha1.RunningPhase))
+
+			By("Completion with a report")
+			createdPod := &corev1.Pod{}
+			podLookupKey := types.NamespacedName{Name: createdVegeta.Status.Active[0], Namespace: TestNs}
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, podLookupKey, createdPod)
+				if err != nil {
+					return false
+				}
+				return true
+			}, timeout, interval).Should(BeTrue())
+
+			createdPod.Status.Phase = corev1.PodSucceeded
+			createdPod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+				Name: containerName,
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 0,
+						Message:  testReport,
+					},
+				},
+			}}
+			Expect(k8sClient.Status().Update(ctx, createdPod)).Should(Succeed())
+			Eventually(func() v1alpha1.PhaseEnum {
+				_ = k8sClient.Get(ctx, vLookupKey, createdVegeta)
+				return createdVegeta.Status.Phase
+			}, timeout, interval).Should(Equal(v1alpha1.CompletedPhase))
+			Expect(createdVegeta.Status.Results).ShouldNot(BeNil())
+			Expect(createdVegeta.Status.Results.Requests).Should(Equal(uint64(500)))
+			Expect(createdVegeta.Status.Results.Success).Should(Equal("0.9980"))
+			Expect(createdVegeta.Status.Results.Latencies.P99.Duration).Should(Equal(12 * time.Millisecond))
+			Expect(createdVegeta.Status.Results.StatusCodes).Should(HaveKeyWithValue("503", uint64(1)))
 		})
 	})
 	Context("When an attack is performed with a successful and an unsuccessful pod", func() {
@@ -145,6 +198,12 @@ var _ = Describe("Vegeta controller", func() {
 			Expect(len(createdVegeta.Status.Active)).Should(Equal(1))
 			Expect(len(createdVegeta.Status.Succeeded)).Should(Equal(0))
 			Expect(len(createdVegeta.Status.Failed)).Should(Equal(1))
+			failedCond := meta.FindStatusCondition(createdVegeta.Status.Conditions, v1alpha1.FailedCondition)
+			Expect(failedCond).ShouldNot(BeNil())
+			Expect(failedCond.Status).Should(Equal(metav1.ConditionTrue))
+			Expect(failedCond.Reason).Should(Equal(v1alpha1.AttackPodFailedReason))
+			Expect(meta.IsStatusConditionFalse(createdVegeta.Status.Conditions, v1alpha1.AttackSucceededCondition)).Should(BeTrue())
+			Expect(meta.IsStatusConditionFalse(createdVegeta.Status.Conditions, v1alpha1.ReadyCondition)).Should(BeTrue())
 
 			// A second successful pod should NOT impact the status
 			By("Success after Failure")
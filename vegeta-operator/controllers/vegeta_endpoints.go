@@ -0,0 +1,161 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var endpointSliceListGVK = schema.GroupVersionKind{Group: "discovery.k8s.io", Version: "v1", Kind: "EndpointSliceList"}
+
+// +kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=list
+
+// isPerEndpoint returns true when the endpoints of the referenced Service are attacked directly
+func isPerEndpoint(v *vegetav1alpha1.Vegeta) bool {
+	return v.Spec.Attack.TargetRef != nil && v.Spec.Attack.TargetRef.PerEndpoint
+}
+
+// resolveEndpoints lists the ready endpoints of a Service from its EndpointSlices, sorted by address.
+// The port of the endpoints is the one with the name of the selected port of the Service.
+func (r *VegetaReconciler) resolveEndpoints(ctx context.Context, namespace, service, portName string) ([]vegetav1alpha1.EndpointResults, error) {
+	slices := &unstructured.UnstructuredList{}
+	slices.SetGroupVersionKind(endpointSliceListGVK)
+	if err := r.APIReader.List(ctx, slices, client.InNamespace(namespace), client.MatchingLabels{"kubernetes.io/service-name": service}); err != nil {
+		return nil, fmt.Errorf("Unable to list the endpoint slices of Service %s: %v", service, err)
+	}
+	seen := map[string]bool{}
+	endpoints := []vegetav1alpha1.EndpointResults{}
+	for _, slice := range slices.Items {
+		port := ""
+		ports, _, _ := unstructured.NestedSlice(slice.Object, "ports")
+		for _, p := range ports {
+			p, _ := p.(map[string]interface{})
+			if name, _, _ := unstructured.NestedString(p, "name"); name == portName {
+				if number, found, _ := unstructured.NestedFieldNoCopy(p, "port"); found {
+					port = fmt.Sprint(number)
+				}
+				break
+			}
+		}
+		if port == "" {
+			continue
+		}
+		items, _, _ := unstructured.NestedSlice(slice.Object, "endpoints")
+		for _, item := range items {
+			item, _ := item.(map[string]interface{})
+			// An unknown readiness is to be interpreted as ready
+			if ready, found, _ := unstructured.NestedBool(item, "conditions", "ready"); found && !ready {
+				continue
+			}
+			addresses, _, _ := unstructured.NestedStringSlice(item, "addresses")
+			if len(addresses) == 0 {
+				continue
+			}
+			address := net.JoinHostPort(addresses[0], port)
+			if seen[address] {
+				continue
+			}
+			seen[address] = true
+			endpoint := vegetav1alpha1.EndpointResults{Address: address}
+			if kind, _, _ := unstructured.NestedString(item, "targetRef", "kind"); kind == "Pod" {
+				endpoint.Pod, _, _ = unstructured.NestedString(item, "targetRef", "name")
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("Service %s has no ready endpoint", service)
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Address < endpoints[j].Address })
+	return endpoints, nil
+}
+
+// renderEndpointTargets renders one target per endpoint in the vegeta json format.
+// The requests keep the Host header of the Service so that they are routed by the application as if they came through the Service.
+// Vegeta derives the TLS server name from the url though, hence the certificate of https endpoints is verified against their address.
+func renderEndpointTargets(target *resolvedTarget, method string, endpoints []vegetav1alpha1.EndpointResults) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range endpoints {
+		vt := &vegetaTarget{
+			Method: method,
+			URL:    target.scheme + "://" + e.Address + target.requestPath(),
+			Header: http.Header{"Host": []string{target.hostPort()}},
+		}
+		if vt.Method == "" {
+			vt.Method = http.MethodGet
+		}
+		// Encoding a target made of strings does not fail
+		_ = enc.Encode(vt)
+	}
+	return buf.Bytes()
+}
+
+// recordEndpointResults records the results per endpoint in the status once all the attack pods have terminated.
+// The results of the replicas are merged the same way as their reports. Endpoints that some pods have not reported results for are marked as partial.
+// It returns true if the results have been recorded.
+func recordEndpointResults(v *vegetav1alpha1.Vegeta, attackPods []*corev1.Pod) bool {
+	if !isPerEndpoint(v) || len(v.Status.Endpoints) == 0 || !attackTerminated(attackPods) {
+		return false
+	}
+	for _, e := range v.Status.Endpoints {
+		if e.Latencies != nil {
+			return false
+		}
+	}
+	metrics := map[string][]*vegetaMetrics{}
+	for _, pod := range attackPods {
+		record := podBreakdown(pod)
+		if record == nil {
+			continue
+		}
+		for i := range record.Targets {
+			u, err := url.Parse(record.Targets[i].URL)
+			if err != nil {
+				continue
+			}
+			metrics[u.Host] = append(metrics[u.Host], &record.Targets[i].vegetaMetrics)
+		}
+	}
+	recorded := false
+	for i := range v.Status.Endpoints {
+		e := &v.Status.Endpoints[i]
+		if len(metrics[e.Address]) == 0 {
+			continue
+		}
+		results := mergeMetrics(metrics[e.Address]).toResults()
+		e.Requests = results.Requests
+		e.Success = results.Success
+		e.Latencies = results.Latencies
+		e.Partial = len(metrics[e.Address]) < len(attackPods)
+		recorded = true
+	}
+	return recorded
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// testEndpoints is a record of the results per endpoint as written by the breakdown
+const testEndpoints = `{"breakdown":{"requests":200,"targets":[` +
+	`{"method":"GET","url":"https://10.0.0.2:8443/healthz","requests":100,"success":0.9,"latencies":{"total":5000000000,"mean":50000000,"50th":40000000,"90th":80000000,"95th":90000000,"99th":120000000,"max":200000000,"min":1000000}},` +
+	`{"method":"GET","url":"https://10.0.0.1:8443/healthz","requests":100,"success":1,"latencies":{"total":500000000,"mean":5000000,"50th":4000000,"90th":8000000,"95th":9000000,"99th":12000000,"max":20000000,"min":1000000}}]}}`
+
+var _ = Describe("Vegeta endpoints", func() {
+	var vegeta *vegetav1alpha1.Vegeta
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: TestNs},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "metrics", Port: 9090},
+			{Name: "https", Port: 443},
+		}},
+	}
+	endpoint := func(ip, pod string, ready bool) map[string]interface{} {
+		return map[string]interface{}{
+			"addresses":  []interface{}{ip},
+			"conditions": map[string]interface{}{"ready": ready},
+			"targetRef":  map[string]interface{}{"kind": "Pod", "name": pod},
+		}
+	}
+	newSlice := func(name string, endpoints ...interface{}) client.Object {
+		slice := newUnstructured("discovery.k8s.io/v1", "EndpointSlice", name, map[string]interface{}{
+			"addressType": "IPv4",
+			"ports": []interface{}{
+				map[string]interface{}{"name": "metrics", "port": int64(9090)},
+				map[string]interface{}{"name": "https", "port": int64(8443)},
+			},
+			"endpoints": endpoints,
+		})
+		slice.SetLabels(map[string]string{"kubernetes.io/service-name": "api"})
+		return slice
+	}
+
+	BeforeEach(func() {
+		vegeta = newVegeta("endpoints")
+		vegeta.UID = "endpoints-uid"
+		vegeta.Spec.Attack.Target = ""
+		vegeta.Spec.Attack.TargetRef = &vegetav1alpha1.TargetRef{Kind: vegetav1alpha1.ServiceKind, Name: "api", Port: "https", Path: "/healthz", Method: "GET", PerEndpoint: true}
+	})
+
+	Context("When the endpoints of a Service are attacked directly", func() {
+		It("Should render a target per ready endpoint keeping the Host header of the Service", func() {
+			r := newTargetRefReconciler(svc.DeepCopy(),
+				newSlice("api-a", endpoint("10.0.0.2", "api-2", true), endpoint("10.0.0.3", "api-3", false)),
+				newSlice("api-b", endpoint("10.0.0.1", "api-1", true)))
+			changed, err := r.reconcileTargetRef(context.Background(), vegeta)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			Expect(vegeta.Status.ResolvedTarget).To(Equal("GET https://api.test-vegeta.svc/healthz"))
+			Expect(vegeta.Status.Endpoints).To(Equal([]vegetav1alpha1.EndpointResults{
+				{Address: "10.0.0.1:8443", Pod: "api-1"},
+				{Address: "10.0.0.2:8443", Pod: "api-2"},
+			}))
+			Expect(meta.FindStatusCondition(vegeta.Status.Conditions, vegetav1alpha1.TargetResolvedCondition).Message).To(ContainSubstring("2 ready endpoints"))
+
+			secret := &corev1.Secret{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Name: "endpoints-targets", Namespace: TestNs}, secret)).To(Succeed())
+			Expect(string(secret.Data[targetsFile])).To(Equal(
+				`{"method":"GET","url":"https://10.0.0.1:8443/healthz","header":{"Host":["api.test-vegeta.svc"]}}` + "\n" +
+					`{"method":"GET","url":"https://10.0.0.2:8443/healthz","header":{"Host":["api.test-vegeta.svc"]}}` + "\n"))
+		})
+		It("Should fail when the Service has no ready endpoint", func() {
+			r := newTargetRefReconciler(svc.DeepCopy(), newSlice("api-a", endpoint("10.0.0.3", "api-3", false)))
+			_, err := r.reconcileTargetRef(context.Background(), vegeta)
+			Expect(err).To(HaveOccurred())
+			Expect(vegeta.Status.Endpoints).To(BeEmpty())
+			Expect(meta.IsStatusConditionFalse(vegeta.Status.Conditions, vegetav1alpha1.TargetResolvedCondition)).To(BeTrue())
+		})
+		It("Should break the json results down per endpoint", func() {
+			r := newTargetRefReconciler()
+			vegeta.Status.ResolvedTarget = "GET https://api.test-vegeta.svc/healthz"
+			cmd := strings.Join(r.aPod4Attack(vegeta, 0).Spec.Containers[0].Args, " ")
+			Expect(cmd).To(ContainSubstring("vegeta attack -targets " + targetsPath + targetsFile + " -format json"))
+			Expect(cmd).ToNot(ContainSubstring("printf"))
+			Expect(cmd).To(ContainSubstring(" | vegeta encode -to json | breakdown -output " + breakdownRecordFile + " -max 10 | tee "))
+			Expect(cmd).To(HaveSuffix("; }; rc=$?; vegeta report -type json -output " + jsonReportFile + " /results/00010101000000-${HOSTNAME}_res.json; recorder -output " + terminationLogPath + " -max-bytes 4096 -max-errors 10 -report " + jsonReportFile + " -breakdown " + breakdownRecordFile + "; exit $rc"))
+		})
+	})
+
+	Context("When the attack pods have terminated", func() {
+		It("Should record the results of the endpoints merged over the replicas", func() {
+			vegeta.Status.Endpoints = []vegetav1alpha1.EndpointResults{
+				{Address: "10.0.0.1:8443", Pod: "api-1"},
+				{Address: "10.0.0.2:8443", Pod: "api-2"},
+			}
+			pods := []*corev1.Pod{terminatedWith("a", testReport+"\n"+testEndpoints+"\n"), terminatedWith("b", testEndpoints+"\n")}
+			pods[0].Status.Phase = corev1.PodSucceeded
+			Expect(recordEndpointResults(vegeta, pods)).To(BeFalse())
+
+			pods[1].Status.Phase = corev1.PodSucceeded
+			Expect(recordEndpointResults(vegeta, pods)).To(BeTrue())
+			slow := vegeta.Status.Endpoints[1]
+			Expect(slow.Requests).To(Equal(uint64(200)))
+			Expect(slow.Success).To(Equal("0.9000"))
+			Expect(slow.Latencies.Mean.Duration).To(Equal(50 * time.Millisecond))
+			Expect(slow.Latencies.P99.Duration).To(Equal(120 * time.Millisecond))
+			Expect(vegeta.Status.Endpoints[0].Latencies.P99.Duration).To(Equal(12 * time.Millisecond))
+			Expect(slow.Partial).To(BeFalse())
+			Expect(vegeta.Status.Endpoints[0].Partial).To(BeFalse())
+
+			// The results are only recorded once
+			Expect(recordEndpointResults(vegeta, pods)).To(BeFalse())
+		})
+		It("Should mark the endpoints that some pods have not reported as partial", func() {
+			vegeta.Status.Endpoints = []vegetav1alpha1.EndpointResults{
+				{Address: "10.0.0.1:8443", Pod: "api-1"},
+				{Address: "10.0.0.2:8443", Pod: "api-2"},
+			}
+			// The record of the second pod only kept its slowest endpoint
+			slowOnly := `{"breakdown":{"requests":200,"targets":[` + testEndpoints[strings.Index(testEndpoints, `{"method"`):strings.Index(testEndpoints, `},{"method"`)+1] + `],"omitted":1}}`
+			pods := []*corev1.Pod{terminatedWith("a", testEndpoints+"\n"), terminatedWith("b", slowOnly+"\n")}
+			for _, pod := range pods {
+				pod.Status.Phase = corev1.PodSucceeded
+			}
+			Expect(recordEndpointResults(vegeta, pods)).To(BeTrue())
+			Expect(vegeta.Status.Endpoints[1].Requests).To(Equal(uint64(200)))
+			Expect(vegeta.Status.Endpoints[1].Partial).To(BeFalse())
+			Expect(vegeta.Status.Endpoints[0].Requests).To(Equal(uint64(100)))
+			Expect(vegeta.Status.Endpoints[0].Partial).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,93 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// datasetPath is the directory where the dataset feeding the generated targets is mounted
+	datasetPath = "/opt/dataset/"
+	// replicaEnv is the environment variable containing the index of the replica, with which the generator selects its rows of the dataset
+	replicaEnv = "VEGETA_REPLICA"
+)
+
+// hasTargetGenerator returns true when the targets of the attack are generated from a template
+func hasTargetGenerator(v *vegetav1alpha1.Vegeta) bool {
+	return v.Spec.Attack.TargetGenerator != nil
+}
+
+// getDatasetFile returns the path of the dataset in the attack pod, empty if the generated targets have no dataset
+func getDatasetFile(v *vegetav1alpha1.Vegeta) string {
+	g := v.Spec.Attack.TargetGenerator
+	if g == nil || g.Dataset == nil {
+		return ""
+	}
+	switch {
+	case g.Dataset.ConfigMapKeyRef != nil:
+		return datasetPath + g.Dataset.ConfigMapKeyRef.Key
+	case g.Dataset.PersistentVolumeClaim != nil:
+		return datasetPath + g.Dataset.PersistentVolumeClaim.Path
+	default:
+		return ""
+	}
+}
+
+// getGeneratorCmd generates the command writing the generated targets in the vegeta json format to the standard output for vegeta attack to read them lazily.
+// With several replicas each of them takes its share of the rows of the dataset, based on its index passed in the environment.
+func getGeneratorCmd(v *vegetav1alpha1.Vegeta) string {
+	g := v.Spec.Attack.TargetGenerator
+	method := g.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	args := []string{"generator", "-method", method, "-url", g.URL}
+	for _, h := range g.Headers {
+		args = append(args, "-header", h)
+	}
+	if g.Body != "" {
+		args = append(args, "-body", g.Body)
+	}
+	if file := getDatasetFile(v); file != "" {
+		args = append(args, "-dataset", file)
+		if g.Dataset.Format != "" {
+			args = append(args, "-format", g.Dataset.Format.String())
+		}
+		if g.Once {
+			args = append(args, "-once")
+		}
+		if v.Spec.Replicas > 1 {
+			args = append(args, "-replicas", strconv.FormatUint(uint64(v.Spec.Replicas), 10))
+			// The variable is expanded by the shell, hence not quoted with the other arguments
+			return shellJoin(args) + ` -replica "$` + replicaEnv + `"`
+		}
+	}
+	return shellJoin(args)
+}
+
+// getGeneratorEnv returns the environment of the attack pod of a replica needed by the generator
+func getGeneratorEnv(v *vegetav1alpha1.Vegeta, replica uint32) []corev1.EnvVar {
+	if !hasTargetGenerator(v) || getDatasetFile(v) == "" || v.Spec.Replicas < 2 {
+		return nil
+	}
+	return []corev1.EnvVar{{Name: replicaEnv, Value: strconv.FormatUint(uint64(replica), 10)}}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Vegeta generator", func() {
+	var vegeta *vegetav1alpha1.Vegeta
+
+	BeforeEach(func() {
+		vegeta = newVegeta("generator")
+		vegeta.Spec.Attack.Target = ""
+		vegeta.Spec.Attack.TargetGenerator = &vegetav1alpha1.TargetGenerator{
+			Method:  "POST",
+			URL:     "https://api.example.com/users/{{.userId}}/orders",
+			Headers: []string{"X-Request-Id: {{uuid}}"},
+			Body:    `{"quantity": {{randInt 1 10}}}`,
+			Dataset: &vegetav1alpha1.DatasetSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "users"}, Key: "users.csv"},
+				Format:          vegetav1alpha1.CSVDataset,
+			},
+		}
+	})
+
+	Context("When the targets are generated from a template", func() {
+		It("Should stream the generated targets into a lazy attack", func() {
+			cmd := getAttackCmd(vegeta)
+			Expect(cmd).To(HavePrefix("generator -method POST -url 'https://api.example.com/users/{{.userId}}/orders' -header 'X-Request-Id: {{uuid}}'" +
+				` -body '{"quantity": {{randInt 1 10}}}' -dataset /opt/dataset/users.csv -format csv | vegeta attack -format json `))
+			Expect(cmd).To(ContainSubstring(" -lazy "))
+			Expect(cmd).ToNot(ContainSubstring("printf"))
+			Expect(strings.Count(cmd, " -format json")).To(Equal(1))
+		})
+		It("Should mount the dataset of the config map", func() {
+			volumes, mounts := getAPVolumesAndMounts(vegeta)
+			var dataset *corev1.Volume
+			for i := range volumes {
+				if volumes[i].Name == "dataset" {
+					dataset = &volumes[i]
+				}
+			}
+			Expect(dataset).ToNot(BeNil())
+			Expect(dataset.ConfigMap.Name).To(Equal("users"))
+			Expect(dataset.ConfigMap.Items).To(Equal([]corev1.KeyToPath{{Key: "users.csv", Path: "users.csv"}}))
+			Expect(mounts).To(ContainElement(corev1.VolumeMount{Name: "dataset", MountPath: datasetPath, ReadOnly: true}))
+		})
+		It("Should mount the claim of the dataset read-only", func() {
+			vegeta.Spec.Attack.TargetGenerator.Dataset = &vegetav1alpha1.DatasetSource{
+				PersistentVolumeClaim: &vegetav1alpha1.DatasetClaim{ClaimName: "datasets", Path: "shop/users.jsonl"},
+			}
+			vegeta.Spec.Attack.TargetGenerator.Once = true
+			Expect(getAttackCmd(vegeta)).To(ContainSubstring(" -dataset /opt/dataset/shop/users.jsonl -once | vegeta attack "))
+			volumes, _ := getAPVolumesAndMounts(vegeta)
+			Expect(volumes).To(ContainElement(corev1.Volume{Name: "dataset", VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "datasets", ReadOnly: true},
+			}}))
+		})
+		It("Should divide the rows of the dataset across the replicas", func() {
+			vegeta.Spec.Replicas = 3
+			r := newTargetRefReconciler()
+			pod := r.aPod4Attack(vegeta, 2)
+			Expect(pod.Spec.Containers[0].Args[1]).To(ContainSubstring(` -format csv -replicas 3 -replica "$VEGETA_REPLICA" | vegeta attack `))
+			Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: replicaEnv, Value: "2"}))
+
+			vegeta.Spec.Attack.TargetGenerator.Dataset = nil
+			Expect(getAttackCmd(vegeta)).To(HavePrefix("generator -method POST -url 'https://api.example.com/users/{{.userId}}/orders' -header 'X-Request-Id: {{uuid}}'" +
+				` -body '{"quantity": {{randInt 1 10}}}' | vegeta attack `))
+			Expect(r.aPod4Attack(vegeta, 2).Spec.Containers[0].Env).To(BeEmpty())
+		})
+		It("Should restart the generator with every stage", func() {
+			vegeta.Spec.Attack.Rate = ""
+			vegeta.Spec.Attack.Duration = ""
+			vegeta.Spec.Attack.Stages = []vegetav1alpha1.Stage{{Duration: "30s", Rate: "10/1s"}, {Duration: "30s", Rate: "20/1s"}}
+			Expect(strings.Count(getAttackCmd(vegeta), "generator -method POST ")).To(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,646 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// openAPIMaxSize is the maximal size of an OpenAPI document downloaded by the operator
+	openAPIMaxSize = 10 << 20
+	// openAPIMaxDepth limits the nesting of the samples derived from schemas, which may be recursive
+	openAPIMaxDepth = 8
+	// openAPITimeout bounds the download of an OpenAPI document, during which a reconcile worker is blocked
+	openAPITimeout = 5 * time.Second
+)
+
+// openAPIMethods are the methods of the operations of a path item, in the order their targets are rendered
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// openAPIBlockedNets are the networks the operator does not download OpenAPI documents from: loopback, link-local, which includes the metadata endpoints of the cloud providers,
+// private and shared addresses. A url could otherwise make the operator reach services only exposed to it. Documents served inside the cluster are read from a config map instead.
+var openAPIBlockedNets = parseCIDRs("0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16", "::/128", "::1/128", "fc00::/7", "fe80::/10")
+
+// openAPIClient downloads the OpenAPI documents referenced by url. The address is checked when connecting, after the name resolution and for every redirect.
+// No proxy is used, as the address of the proxy rather than the one of the document would be checked.
+var openAPIClient = &http.Client{
+	Timeout: openAPITimeout,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: openAPITimeout, Control: checkOpenAPIAddress}).DialContext,
+		TLSHandshakeTimeout: openAPITimeout,
+	},
+}
+
+// parseCIDRs parses networks in the CIDR notation, it panics on an invalid one
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// checkOpenAPIAddress refuses to connect to an address of openAPIBlockedNets or to a multicast address
+func checkOpenAPIAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%s is not an ip address", host)
+	}
+	if ip.IsMulticast() {
+		return fmt.Errorf("the address %s is not allowed", ip)
+	}
+	for _, n := range openAPIBlockedNets {
+		if n.Contains(ip) {
+			return fmt.Errorf("the address %s is not allowed, documents served inside the cluster need to be provided in a config map", ip)
+		}
+	}
+	return nil
+}
+
+// isOpenAPI returns true when the targets are derived from an OpenAPI document
+func isOpenAPI(v *vegetav1alpha1.Vegeta) bool {
+	return v.Spec.Attack.OpenAPI != nil
+}
+
+// reconcileOpenAPI derives the targets from the OpenAPI document of the vegeta resource, once per run, and renders them into the secret mounted by the attack pods.
+// It returns true when the status has changed.
+func (r *VegetaReconciler) reconcileOpenAPI(ctx context.Context, v *vegetav1alpha1.Vegeta) (bool, error) {
+	if v.Status.OpenAPIOperations != nil {
+		return false, nil
+	}
+	src := v.Spec.Attack.OpenAPI
+	data, origin, err := r.loadOpenAPIDocument(ctx, v.Namespace, src)
+	var content []byte
+	var operations, skipped []string
+	if err == nil {
+		content, operations, skipped, err = deriveOpenAPITargets(data, src)
+	}
+	if err == nil {
+		err = r.writeTargetsSecret(ctx, v, content)
+	}
+	if err != nil {
+		setCondition(v, vegetav1alpha1.TargetResolvedCondition, metav1.ConditionFalse, vegetav1alpha1.TargetNotResolvedReason, err.Error())
+		return true, err
+	}
+	v.Status.OpenAPIOperations = operations
+	msg := fmt.Sprintf("%d targets derived from the OpenAPI document of %s", len(operations), origin)
+	if len(skipped) > 0 {
+		msg += fmt.Sprintf(", %d operations skipped: %s", len(skipped), strings.Join(skipped, ", "))
+	}
+	setCondition(v, vegetav1alpha1.TargetResolvedCondition, metav1.ConditionTrue, vegetav1alpha1.TargetResolvedReason, msg)
+	return true, nil
+}
+
+// loadOpenAPIDocument reads the OpenAPI document from its config map or downloads it from its url.
+// It also returns where the document comes from, for the status.
+func (r *VegetaReconciler) loadOpenAPIDocument(ctx context.Context, namespace string, src *vegetav1alpha1.OpenAPISource) ([]byte, string, error) {
+	if ref := src.ConfigMapKeyRef; ref != nil {
+		origin := "config map " + ref.Name
+		cm := &corev1.ConfigMap{}
+		// The config map is not watched by the operator, it is read through the uncached reader
+		if err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, cm); err != nil {
+			return nil, origin, fmt.Errorf("Failed to get config map %s: %v", ref.Name, err)
+		}
+		if data, ok := cm.Data[ref.Key]; ok {
+			return []byte(data), origin, nil
+		}
+		if data, ok := cm.BinaryData[ref.Key]; ok {
+			return data, origin, nil
+		}
+		return nil, origin, fmt.Errorf("Key %s not found in config map %s", ref.Key, ref.Name)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
+	if err != nil {
+		return nil, src.URL, fmt.Errorf("Invalid url of the OpenAPI document: %v", err)
+	}
+	req.Header.Set("Accept", "application/json, application/yaml;q=0.9, */*;q=0.8")
+	resp, err := openAPIClient.Do(req)
+	if err != nil {
+		return nil, src.URL, fmt.Errorf("Unable to download the OpenAPI document: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, src.URL, fmt.Errorf("Unable to download the OpenAPI document: %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(&io.LimitedReader{R: resp.Body, N: openAPIMaxSize + 1})
+	if err != nil {
+		return nil, src.URL, fmt.Errorf("Unable to download the OpenAPI document: %v", err)
+	}
+	if len(data) > openAPIMaxSize {
+		return nil, src.URL, fmt.Errorf("The OpenAPI document exceeds %d bytes", openAPIMaxSize)
+	}
+	return data, src.URL, nil
+}
+
+// openAPIDocument is an OpenAPI document decoded without schema, so that references can be resolved anywhere in it
+type openAPIDocument struct {
+	root     map[string]interface{}
+	examples map[string]string
+}
+
+// deriveOpenAPITargets renders a target in the vegeta json format for every selected operation of an OpenAPI 3 document, in the order of the paths.
+// It returns the rendered targets, the operations they have been derived from and the selected operations that have been skipped with the reason.
+func deriveOpenAPITargets(data []byte, src *vegetav1alpha1.OpenAPISource) ([]byte, []string, []string, error) {
+	// YAML documents are converted, JSON documents are kept as they are
+	data, err := yaml.ToJSON(data)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Unable to parse the OpenAPI document: %v", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Numbers are rendered as they are written in the document
+	dec.UseNumber()
+	doc := &openAPIDocument{examples: src.Examples}
+	if err := dec.Decode(&doc.root); err != nil {
+		return nil, nil, nil, fmt.Errorf("Unable to parse the OpenAPI document: %v", err)
+	}
+	if version, _ := doc.root["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, nil, nil, fmt.Errorf("Only OpenAPI 3 documents are supported")
+	}
+	server, err := doc.server(src)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	paths, _ := doc.root["paths"].(map[string]interface{})
+	keys := make([]string, 0, len(paths))
+	for p := range paths {
+		keys = append(keys, p)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// The query strings are kept readable
+	enc.SetEscapeHTML(false)
+	var operations, skipped []string
+	for _, p := range keys {
+		item, _ := doc.resolve(paths[p]).(map[string]interface{})
+		for _, m := range openAPIMethods {
+			op, ok := item[m].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			key := strings.ToUpper(m) + " " + p
+			if !isSelected(op, key, src) {
+				continue
+			}
+			t, err := doc.target(server, p, strings.ToUpper(m), item, op)
+			if err != nil {
+				skipped = append(skipped, key+" ("+err.Error()+")")
+				continue
+			}
+			// Encoding a target made of strings and bytes does not fail
+			_ = enc.Encode(t)
+			operations = append(operations, key)
+		}
+	}
+	if len(operations) == 0 {
+		if len(skipped) > 0 {
+			return nil, nil, nil, fmt.Errorf("No operation of the OpenAPI document can be attacked, skipped: %s", strings.Join(skipped, ", "))
+		}
+		return nil, nil, nil, fmt.Errorf("No operation of the OpenAPI document is selected")
+	}
+	return buf.Bytes(), operations, skipped, nil
+}
+
+// isSelected returns true if the operation is matched by an entry of Include, or Include is empty, and by no entry of Exclude
+func isSelected(op map[string]interface{}, key string, src *vegetav1alpha1.OpenAPISource) bool {
+	matches := func(patterns []string) bool {
+		id, _ := op["operationId"].(string)
+		tags, _ := op["tags"].([]interface{})
+		for _, p := range patterns {
+			if id != "" && p == id {
+				return true
+			}
+			for _, t := range tags {
+				if tag, _ := t.(string); tag != "" && p == tag {
+					return true
+				}
+			}
+			if matchOperation(p, key) {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(src.Include) == 0 || matches(src.Include)) && !matches(src.Exclude)
+}
+
+// matchOperation returns true if the method and path of an operation, e.g. GET /users/{id}, match the pattern, in which * matches any sequence of characters
+func matchOperation(pattern, key string) bool {
+	expr := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	matched, _ := regexp.MatchString("^"+expr+"$", key)
+	return matched
+}
+
+// server returns the base url of the requests: the one specified in the vegeta resource or the first server of the document with the defaults of its variables.
+// A relative server url is resolved against the url of the document.
+func (d *openAPIDocument) server(src *vegetav1alpha1.OpenAPISource) (string, error) {
+	server := src.Server
+	if server == "" {
+		// The default server of a document without servers is /
+		server = "/"
+		if servers, _ := d.root["servers"].([]interface{}); len(servers) > 0 {
+			s, _ := servers[0].(map[string]interface{})
+			if u, _ := s["url"].(string); u != "" {
+				server = u
+			}
+			variables, _ := s["variables"].(map[string]interface{})
+			for name, v := range variables {
+				variable, _ := v.(map[string]interface{})
+				server = strings.ReplaceAll(server, "{"+name+"}", fmt.Sprint(variable["default"]))
+			}
+		}
+		if src.URL != "" {
+			base, err := url.Parse(src.URL)
+			ref, rerr := url.Parse(server)
+			if err == nil && rerr == nil {
+				server = base.ResolveReference(ref).String()
+			}
+		}
+	}
+	if u, err := url.Parse(server); err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("The OpenAPI document has no absolute server url, spec.attack.openAPI.server needs to be specified")
+	}
+	return strings.TrimSuffix(server, "/"), nil
+}
+
+// target renders the target of an operation. The path parameters and the required query, header and cookie parameters get example or sample values.
+// An error is returned when the operation cannot be rendered, e.g. when a required request body has no example and no schema.
+func (d *openAPIDocument) target(server, p, method string, item, op map[string]interface{}) (*vegetaTarget, error) {
+	t := &vegetaTarget{Method: method}
+	query := url.Values{}
+	var cookies []string
+	for _, param := range d.parameters(item, op) {
+		name, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		required, _ := param["required"].(bool)
+		value, specified := d.parameterValue(param)
+		if in != "path" && !required && !specified {
+			continue
+		}
+		switch in {
+		case "path":
+			p = strings.ReplaceAll(p, "{"+name+"}", url.PathEscape(formatValue(value)))
+		case "query":
+			if values, ok := value.([]interface{}); ok {
+				for _, v := range values {
+					query.Add(name, formatValue(v))
+				}
+			} else {
+				query.Add(name, formatValue(value))
+			}
+		case "header":
+			if t.Header == nil {
+				t.Header = http.Header{}
+			}
+			t.Header.Set(name, formatValue(value))
+		case "cookie":
+			cookies = append(cookies, name+"="+formatValue(value))
+		}
+	}
+	if i := strings.Index(p, "{"); i >= 0 {
+		return nil, fmt.Errorf("the path parameter %s is not declared", strings.SplitN(p[i+1:], "}", 2)[0])
+	}
+	if len(cookies) > 0 {
+		if t.Header == nil {
+			t.Header = http.Header{}
+		}
+		t.Header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+	t.URL = server + p
+	if len(query) > 0 {
+		t.URL += "?" + query.Encode()
+	}
+
+	if rb, ok := d.resolve(op["requestBody"]).(map[string]interface{}); ok {
+		required, _ := rb["required"].(bool)
+		content, _ := rb["content"].(map[string]interface{})
+		mediaType, body, err := d.requestBody(content)
+		switch {
+		case err == nil:
+			t.Body = body
+			if !strings.Contains(mediaType, "*") {
+				if t.Header == nil {
+					t.Header = http.Header{}
+				}
+				t.Header.Set("Content-Type", mediaType)
+			}
+		case required:
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// parameters returns the parameters of an operation: the ones of the path item overridden by the ones of the operation with the same name and location
+func (d *openAPIDocument) parameters(item, op map[string]interface{}) []map[string]interface{} {
+	var params []map[string]interface{}
+	index := map[string]int{}
+	for _, owner := range []map[string]interface{}{item, op} {
+		list, _ := owner["parameters"].([]interface{})
+		for _, p := range list {
+			param, ok := d.resolve(p).(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := param["name"].(string)
+			in, _ := param["in"].(string)
+			key := in + "/" + name
+			if i, ok := index[key]; ok {
+				params[i] = param
+				continue
+			}
+			index[key] = len(params)
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// parameterValue returns the value of a parameter: the example specified in the vegeta resource, the example of the document or a sample derived from its schema.
+// It also returns whether the value has been specified in the vegeta resource.
+func (d *openAPIDocument) parameterValue(param map[string]interface{}) (interface{}, bool) {
+	name, _ := param["name"].(string)
+	if v, ok := d.examples[name]; ok {
+		return v, true
+	}
+	if v, ok := d.example(param); ok {
+		return v, false
+	}
+	schema := param["schema"]
+	if content, ok := param["content"].(map[string]interface{}); ok && schema == nil {
+		for _, mediaType := range sortedKeys(content) {
+			media, _ := content[mediaType].(map[string]interface{})
+			schema = media["schema"]
+			break
+		}
+	}
+	return d.sample(schema, 0), false
+}
+
+// requestBody renders the body of a request from the example of its media type or a sample derived from its schema.
+// A json media type is preferred. It returns the selected media type and the body.
+func (d *openAPIDocument) requestBody(content map[string]interface{}) (string, []byte, error) {
+	mediaTypes := sortedKeys(content)
+	sort.SliceStable(mediaTypes, func(i, j int) bool {
+		rank := func(m string) int {
+			switch {
+			case m == "application/json":
+				return 0
+			case strings.Contains(m, "json"):
+				return 1
+			default:
+				return 2
+			}
+		}
+		return rank(mediaTypes[i]) < rank(mediaTypes[j])
+	})
+	for _, mediaType := range mediaTypes {
+		media, _ := content[mediaType].(map[string]interface{})
+		value, ok := d.example(media)
+		if !ok {
+			value = d.sample(media["schema"], 0)
+		}
+		if value == nil {
+			continue
+		}
+		switch {
+		case strings.Contains(mediaType, "json"):
+			// Values decoded from json can be encoded again
+			body, _ := json.Marshal(value)
+			return mediaType, body, nil
+		case mediaType == "application/x-www-form-urlencoded":
+			if fields, ok := value.(map[string]interface{}); ok {
+				form := url.Values{}
+				for k, v := range fields {
+					form.Set(k, formatValue(v))
+				}
+				return mediaType, []byte(form.Encode()), nil
+			}
+		}
+		if s, ok := value.(string); ok {
+			return mediaType, []byte(s), nil
+		}
+	}
+	return "", nil, fmt.Errorf("no example of the request body")
+}
+
+// example returns the example of a parameter or a media type, either in the example field or the first of the examples field
+func (d *openAPIDocument) example(obj map[string]interface{}) (interface{}, bool) {
+	if v, ok := obj["example"]; ok {
+		return v, true
+	}
+	examples, _ := obj["examples"].(map[string]interface{})
+	for _, name := range sortedKeys(examples) {
+		if ex, ok := d.resolve(examples[name]).(map[string]interface{}); ok {
+			if v, ok := ex["value"]; ok {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// sample derives a value from a schema: its example, default or first enum value, otherwise a value of its type.
+// Objects get all their properties that are not read-only.
+func (d *openAPIDocument) sample(s interface{}, depth int) interface{} {
+	schema, _ := d.resolve(s).(map[string]interface{})
+	if schema == nil || depth > openAPIMaxDepth {
+		return nil
+	}
+	for _, k := range []string{"example", "default"} {
+		if v, ok := schema[k]; ok {
+			return v
+		}
+	}
+	if enum, _ := schema["enum"].([]interface{}); len(enum) > 0 {
+		return enum[0]
+	}
+	if all, _ := schema["allOf"].([]interface{}); len(all) > 0 {
+		if len(all) == 1 {
+			return d.sample(all[0], depth+1)
+		}
+		merged := map[string]interface{}{}
+		for _, sub := range all {
+			if fields, ok := d.sample(sub, depth+1).(map[string]interface{}); ok {
+				for k, v := range fields {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+	for _, k := range []string{"oneOf", "anyOf"} {
+		if alternatives, _ := schema[k].([]interface{}); len(alternatives) > 0 {
+			return d.sample(alternatives[0], depth+1)
+		}
+	}
+
+	typ, _ := schema["type"].(string)
+	if types, ok := schema["type"].([]interface{}); ok {
+		// OpenAPI 3.1 allows several types, e.g. string and null
+		for _, t := range types {
+			if t, _ := t.(string); t != "null" {
+				typ = t
+				break
+			}
+		}
+	}
+	if typ == "" {
+		if _, ok := schema["properties"]; ok {
+			typ = "object"
+		} else if _, ok := schema["items"]; ok {
+			typ = "array"
+		}
+	}
+	switch typ {
+	case "object":
+		obj := map[string]interface{}{}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, p := range properties {
+			if property, _ := d.resolve(p).(map[string]interface{}); property != nil {
+				if readOnly, _ := property["readOnly"].(bool); readOnly {
+					continue
+				}
+			}
+			if v := d.sample(p, depth+1); v != nil {
+				obj[name] = v
+			}
+		}
+		return obj
+	case "array":
+		if v := d.sample(schema["items"], depth+1); v != nil {
+			return []interface{}{v}
+		}
+		return []interface{}{}
+	case "integer", "number":
+		for _, k := range []string{"minimum", "maximum"} {
+			if v, ok := schema[k].(json.Number); ok {
+				return v
+			}
+		}
+		return json.Number("1")
+	case "boolean":
+		return true
+	case "string":
+		switch schema["format"] {
+		case "date":
+			return "2020-01-01"
+		case "date-time":
+			return "2020-01-01T00:00:00Z"
+		case "uuid":
+			return "00000000-0000-4000-8000-000000000000"
+		case "email":
+			return "user@example.com"
+		case "uri", "url":
+			return "https://example.com"
+		case "byte":
+			return "ZXhhbXBsZQ=="
+		}
+		return "example"
+	default:
+		return nil
+	}
+}
+
+// resolve follows the local references, e.g. #/components/schemas/User, until the referenced value. External references are not supported and resolve to nil.
+func (d *openAPIDocument) resolve(v interface{}) interface{} {
+	for i := 0; i < openAPIMaxDepth; i++ {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		ref, ok := obj["$ref"].(string)
+		if !ok {
+			return v
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil
+		}
+		var current interface{} = d.root
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			parent, _ := current.(map[string]interface{})
+			current = parent[token]
+		}
+		v = current
+	}
+	return nil
+}
+
+// formatValue formats the value of a parameter. Arrays are formatted with the simple style: comma separated values.
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case []interface{}:
+		values := make([]string, len(value))
+		for i := range value {
+			values[i] = formatValue(value[i])
+		}
+		return strings.Join(values, ",")
+	case map[string]interface{}:
+		encoded, _ := json.Marshal(value)
+		return string(encoded)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// sortedKeys returns the keys of a map in alphabetical order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const petstore = `openapi: 3.0.3
+info:
+  title: Petstore
+  version: 1.0.0
+servers:
+- url: https://{env}.petstore.example.com/v1/
+  variables:
+    env:
+      default: staging
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      tags: [pets]
+      parameters:
+      - name: limit
+        in: query
+        schema:
+          type: integer
+          maximum: 100
+      - name: status
+        in: query
+        required: true
+        schema:
+          type: string
+          enum: [available, sold]
+    post:
+      operationId: createPet
+      tags: [pets, admin]
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Pet'
+  /pets/{petId}:
+    parameters:
+    - $ref: '#/components/parameters/PetId'
+    get:
+      operationId: showPet
+      tags: [pets]
+      parameters:
+      - name: X-Tenant
+        in: header
+        required: true
+        example: shop
+    delete:
+      operationId: deletePet
+      tags: [admin]
+  /uploads:
+    put:
+      operationId: upload
+      requestBody:
+        required: true
+        content:
+          application/octet-stream: {}
+components:
+  parameters:
+    PetId:
+      name: petId
+      in: path
+      required: true
+      schema:
+        type: integer
+        example: 42
+  schemas:
+    Pet:
+      type: object
+      properties:
+        id:
+          type: integer
+          readOnly: true
+        name:
+          type: string
+          example: Rex
+        born:
+          type: string
+          format: date
+`
+
+var _ = Describe("Vegeta openapi", func() {
+	var vegeta *vegetav1alpha1.Vegeta
+
+	BeforeEach(func() {
+		vegeta = newVegeta("openapi")
+		vegeta.Spec.Attack.Target = ""
+		vegeta.Spec.Attack.Format = vegetav1alpha1.JSONFormat
+		vegeta.Spec.Attack.OpenAPI = &vegetav1alpha1.OpenAPISource{
+			ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "petstore"}, Key: "openapi.yaml"},
+		}
+	})
+
+	// renderedTargets returns the lines of the targets rendered into the secret
+	renderedTargets := func(r *VegetaReconciler) []string {
+		secret := &corev1.Secret{}
+		Expect(r.Get(context.Background(), types.NamespacedName{Name: "openapi-targets", Namespace: TestNs}, secret)).To(Succeed())
+		return strings.Split(strings.TrimSpace(string(secret.Data[targetsFile])), "\n")
+	}
+
+	Context("When the targets are derived from an OpenAPI document", func() {
+		It("Should render a target for every operation of a config map document", func() {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "petstore", Namespace: TestNs},
+				Data:       map[string]string{"openapi.yaml": petstore},
+			}
+			r := newTargetRefReconciler(cm)
+			changed, err := r.reconcileOpenAPI(context.Background(), vegeta)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			Expect(renderedTargets(r)).To(Equal([]string{
+				`{"method":"GET","url":"https://staging.petstore.example.com/v1/pets?status=available"}`,
+				`{"method":"POST","url":"https://staging.petstore.example.com/v1/pets","body":"eyJib3JuIjoiMjAyMC0wMS0wMSIsIm5hbWUiOiJSZXgifQ==","header":{"Content-Type":["application/json"]}}`,
+				`{"method":"GET","url":"https://staging.petstore.example.com/v1/pets/42","header":{"X-Tenant":["shop"]}}`,
+				`{"method":"DELETE","url":"https://staging.petstore.example.com/v1/pets/42"}`,
+			}))
+			Expect(vegeta.Status.OpenAPIOperations).To(Equal([]string{"GET /pets", "POST /pets", "GET /pets/{petId}", "DELETE /pets/{petId}"}))
+			cond := meta.FindStatusCondition(vegeta.Status.Conditions, vegetav1alpha1.TargetResolvedCondition)
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(cond.Message).To(ContainSubstring("4 targets derived from the OpenAPI document of config map petstore, 1 operations skipped: PUT /uploads"))
+			Expect(getAttackCmd(vegeta)).To(HavePrefix("vegeta attack -targets /opt/targets/targets.json -format json "))
+
+			// The document is only read once per run
+			changed, err = r.reconcileOpenAPI(context.Background(), vegeta)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeFalse())
+		})
+		It("Should select the operations and use the specified examples", func() {
+			vegeta.Spec.Attack.OpenAPI.Include = []string{"pets", "DELETE *"}
+			vegeta.Spec.Attack.OpenAPI.Exclude = []string{"createPet"}
+			vegeta.Spec.Attack.OpenAPI.Examples = map[string]string{"petId": "7", "limit": "10", "status": "sold"}
+			vegeta.Spec.Attack.OpenAPI.Server = "http://petstore.test.svc:8080/"
+			content, operations, skipped, err := deriveOpenAPITargets([]byte(petstore), vegeta.Spec.Attack.OpenAPI)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(skipped).To(BeEmpty())
+			Expect(operations).To(Equal([]string{"GET /pets", "GET /pets/{petId}", "DELETE /pets/{petId}"}))
+			Expect(strings.Split(strings.TrimSpace(string(content)), "\n")).To(Equal([]string{
+				`{"method":"GET","url":"http://petstore.test.svc:8080/pets?limit=10&status=sold"}`,
+				`{"method":"GET","url":"http://petstore.test.svc:8080/pets/7","header":{"X-Tenant":["shop"]}}`,
+				`{"method":"DELETE","url":"http://petstore.test.svc:8080/pets/7"}`,
+			}))
+
+			vegeta.Spec.Attack.OpenAPI.Include = []string{"PATCH *"}
+			_, _, _, err = deriveOpenAPITargets([]byte(petstore), vegeta.Spec.Attack.OpenAPI)
+			Expect(err).To(MatchError("No operation of the OpenAPI document is selected"))
+		})
+		It("Should download the document and resolve a relative server against its url", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != "/api/openapi.json" {
+					http.NotFound(w, req)
+					return
+				}
+				w.Write([]byte(`{"openapi":"3.1.0","servers":[{"url":"/api"}],"paths":{"/health":{"get":{}}}}`))
+			}))
+			defer server.Close()
+			// The test server listens on the loopback interface, which the client of the operator refuses to connect to
+			defer func(c *http.Client) { openAPIClient = c }(openAPIClient)
+			openAPIClient = server.Client()
+			vegeta.Spec.Attack.OpenAPI = &vegetav1alpha1.OpenAPISource{URL: server.URL + "/api/openapi.json"}
+			r := newTargetRefReconciler()
+			_, err := r.reconcileOpenAPI(context.Background(), vegeta)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(renderedTargets(r)).To(Equal([]string{`{"method":"GET","url":"` + server.URL + `/api/health"}`}))
+
+			vegeta.Status.OpenAPIOperations = nil
+			vegeta.Spec.Attack.OpenAPI.URL = server.URL + "/missing.json"
+			changed, err := r.reconcileOpenAPI(context.Background(), vegeta)
+			Expect(err).To(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			cond := meta.FindStatusCondition(vegeta.Status.Conditions, vegetav1alpha1.TargetResolvedCondition)
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(vegetav1alpha1.TargetNotResolvedReason))
+			Expect(cond.Message).To(ContainSubstring("404"))
+		})
+		It("Should not download a document from a loopback, link-local or private address", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte(`{"openapi":"3.1.0","servers":[{"url":"/api"}],"paths":{"/health":{"get":{}}}}`))
+			}))
+			defer server.Close()
+			vegeta.Spec.Attack.OpenAPI = &vegetav1alpha1.OpenAPISource{URL: server.URL + "/openapi.json"}
+			r := newTargetRefReconciler()
+			_, err := r.reconcileOpenAPI(context.Background(), vegeta)
+			Expect(err).To(MatchError(ContainSubstring("the address 127.0.0.1 is not allowed")))
+
+			for _, address := range []string{"169.254.169.254:80", "10.0.0.1:443", "[::ffff:192.168.0.1]:443", "[fe80::1]:80", "224.0.0.1:80"} {
+				Expect(checkOpenAPIAddress("tcp", address, nil)).To(HaveOccurred(), address)
+			}
+			Expect(checkOpenAPIAddress("tcp", "93.184.216.34:443", nil)).To(Succeed())
+		})
+		It("Should reject a document it cannot derive targets from", func() {
+			src := &vegetav1alpha1.OpenAPISource{}
+			_, _, _, err := deriveOpenAPITargets([]byte(`{"swagger":"2.0","paths":{}}`), src)
+			Expect(err).To(MatchError("Only OpenAPI 3 documents are supported"))
+			_, _, _, err = deriveOpenAPITargets([]byte("openapi: 3.0.0\npaths:\n  /health:\n    get: {}\n"), src)
+			Expect(err).To(MatchError(ContainSubstring("spec.attack.openAPI.server needs to be specified")))
+		})
+	})
+})
@@ -0,0 +1,91 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// getTopologySpreadConstraints generates the constraints spreading the attack pods of a vegeta resource across nodes or zones.
+// A requested spread is enforced with DoNotSchedule: the pods exceeding the number of nodes or zones, or only fitting on nodes with another attack pod,
+// e.g. because the others are tainted, stay pending. ScheduleAnyway is only used for the default spread across nodes, so that the pods get scheduled anyway.
+func getTopologySpreadConstraints(v *vegetav1alpha1.Vegeta) []corev1.TopologySpreadConstraint {
+	if v.Spec.Replicas < 2 {
+		return nil
+	}
+	var topologyKey string
+	whenUnsatisfiable := corev1.DoNotSchedule
+	switch v.Spec.Spread {
+	case "":
+		topologyKey = corev1.LabelHostname
+		whenUnsatisfiable = corev1.ScheduleAnyway
+	case vegetav1alpha1.NodeSpread:
+		topologyKey = corev1.LabelHostname
+	case vegetav1alpha1.ZoneSpread:
+		topologyKey = corev1.LabelZoneFailureDomainStable
+	default:
+		return nil
+	}
+	selector := map[string]string{
+		"app.kubernetes.io/instance": v.Name,
+		"vegeta.testing.io/type":     "attack",
+	}
+	// The pods of the previous runs, which are kept until they get cleaned up, must not skew the placement of the current one
+	if run := currentRun(v); run != "" {
+		selector[runLabel] = run
+	}
+	return []corev1.TopologySpreadConstraint{{
+		MaxSkew:           1,
+		TopologyKey:       topologyKey,
+		WhenUnsatisfiable: whenUnsatisfiable,
+		LabelSelector:     &metav1.LabelSelector{MatchLabels: selector},
+	}}
+}
+
+// placementOf records the node and zone the scheduled attack pods run on.
+// Nodes are only looked up for pods that are not in the previous placement yet. They are read directly from the API server
+// rather than through the cache of the manager, which would otherwise watch and keep every node of the cluster in memory.
+func (r *VegetaReconciler) placementOf(ctx context.Context, attackPods []*corev1.Pod, previous []vegetav1alpha1.ReplicaPlacement) []vegetav1alpha1.ReplicaPlacement {
+	known := make(map[string]vegetav1alpha1.ReplicaPlacement, len(previous))
+	for _, p := range previous {
+		known[p.Pod] = p
+	}
+	var placement []vegetav1alpha1.ReplicaPlacement
+	for _, pod := range attackPods {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		if p, ok := known[pod.Name]; ok && p.Node == pod.Spec.NodeName {
+			placement = append(placement, p)
+			continue
+		}
+		p := vegetav1alpha1.ReplicaPlacement{Pod: pod.Name, Node: pod.Spec.NodeName}
+		node := &corev1.Node{}
+		if err := r.APIReader.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node); err != nil {
+			r.Log.V(1).Info("Unable to get the node of the attack pod, the zone is not recorded", "pod", pod.Name, "node", pod.Spec.NodeName, "error", err.Error())
+		} else {
+			p.Zone = node.Labels[corev1.LabelZoneFailureDomainStable]
+		}
+		placement = append(placement, p)
+	}
+	return placement
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Vegeta placement", func() {
+	Context("When several replicas are requested", func() {
+		It("Should spread the attack pods across nodes by default", func() {
+			vegeta := newVegeta("spread")
+			vegeta.Spec.Replicas = 3
+			constraints := getTopologySpreadConstraints(vegeta)
+			Expect(constraints).Should(HaveLen(1))
+			Expect(constraints[0].TopologyKey).Should(Equal("kubernetes.io/hostname"))
+			Expect(constraints[0].MaxSkew).Should(Equal(int32(1)))
+			Expect(constraints[0].WhenUnsatisfiable).Should(Equal(corev1.ScheduleAnyway))
+			Expect(constraints[0].LabelSelector.MatchLabels).Should(Equal(map[string]string{
+				"app.kubernetes.io/instance": "spread",
+				"vegeta.testing.io/type":     "attack",
+			}))
+		})
+		It("Should only spread the attack pods of the current run", func() {
+			vegeta := newVegeta("spread-run")
+			vegeta.Spec.Replicas = 3
+			vegeta.Spec.RunID = "nightly"
+			Expect(getTopologySpreadConstraints(vegeta)[0].LabelSelector.MatchLabels).Should(Equal(map[string]string{
+				"app.kubernetes.io/instance": "spread-run",
+				"vegeta.testing.io/type":     "attack",
+				"vegeta.testing.io/run":      "nightly",
+			}))
+		})
+		It("Should spread the attack pods across zones when requested", func() {
+			vegeta := newVegeta("spread-zone")
+			vegeta.Spec.Replicas = 3
+			vegeta.Spec.Spread = vegetav1alpha1.ZoneSpread
+			constraints := getTopologySpreadConstraints(vegeta)
+			Expect(constraints).Should(HaveLen(1))
+			Expect(constraints[0].TopologyKey).Should(Equal("topology.kubernetes.io/zone"))
+			Expect(constraints[0].WhenUnsatisfiable).Should(Equal(corev1.DoNotSchedule))
+		})
+		It("Should enforce a spread across nodes when requested", func() {
+			vegeta := newVegeta("spread-node")
+			vegeta.Spec.Replicas = 3
+			vegeta.Spec.Spread = vegetav1alpha1.NodeSpread
+			constraints := getTopologySpreadConstraints(vegeta)
+			Expect(constraints).Should(HaveLen(1))
+			Expect(constraints[0].TopologyKey).Should(Equal("kubernetes.io/hostname"))
+			Expect(constraints[0].WhenUnsatisfiable).Should(Equal(corev1.DoNotSchedule))
+		})
+		It("Should leave the placement to the scheduler when spreading is disabled or with a single replica", func() {
+			vegeta := newVegeta("no-spread")
+			vegeta.Spec.Replicas = 3
+			vegeta.Spec.Spread = vegetav1alpha1.NoSpread
+			Expect(getTopologySpreadConstraints(vegeta)).Should(BeEmpty())
+			vegeta.Spec.Spread = vegetav1alpha1.NodeSpread
+			vegeta.Spec.Replicas = 1
+			Expect(getTopologySpreadConstraints(vegeta)).Should(BeEmpty())
+		})
+	})
+
+	Context("When the attack pods have been scheduled", func() {
+		It("Should record their node and zone", func() {
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+				Name:   "worker-1",
+				Labels: map[string]string{"topology.kubernetes.io/zone": "eu-west-1a"},
+			}}
+			c := fake.NewClientBuilder().WithObjects(node).Build()
+			r := &VegetaReconciler{Client: c, APIReader: c, Log: logr.Discard()}
+			pods := []*corev1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Name: "attack-1"}, Spec: corev1.PodSpec{NodeName: "worker-1"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "attack-2"}, Spec: corev1.PodSpec{NodeName: "worker-2"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "attack-3"}},
+			}
+			previous := []vegetav1alpha1.ReplicaPlacement{{Pod: "attack-2", Node: "worker-2", Zone: "eu-west-1b"}}
+			Expect(r.placementOf(context.Background(), pods, previous)).Should(Equal([]vegetav1alpha1.ReplicaPlacement{
+				{Pod: "attack-1", Node: "worker-1", Zone: "eu-west-1a"},
+				{Pod: "attack-2", Node: "worker-2", Zone: "eu-west-1b"},
+			}))
+		})
+	})
+})
@@ -31,12 +31,15 @@ const (
 	configPath      = "/opt/config/"
 	credentialsPath = "/opt/config/credentials/"
 	resultsPath     = "/results/"
+	// reservedAnnotationPrefix is the prefix of the annotations the operator sets on the pods, which cannot be set through the pod template
+	reservedAnnotationPrefix = "vegeta.testing.io/"
 )
 
-// aPod4Attack generates the definition of the attack pod
-func (r *VegetaReconciler) aPod4Attack(v *vegetav1alpha1.Vegeta) *corev1.Pod {
+// aPod4Attack generates the definition of the attack pod of a replica
+func (r *VegetaReconciler) aPod4Attack(v *vegetav1alpha1.Vegeta, replica uint32) *corev1.Pod {
 	immediate := int64(0)
-	volumes, mounts := getAPVolumesAndMounts(v)
+	veg := forReplica(v, replica)
+	volumes, mounts := getAPVolumesAndMounts(veg)
 	var image string
 	if vImg := strings.TrimSpace(v.Spec.Image); vImg != "" {
 		image = vImg
@@ -59,20 +62,26 @@ func (r *VegetaReconciler) aPod4Attack(v *vegetav1alpha1.Vegeta) *corev1.Pod {
 				ImagePullPolicy: "Always",
 				Name:            containerName,
 				Command:         []string{"/bin/sh"},
-				Args:            []string{"-c", getAttackCmd(v)},
+				Args:            []string{"-c", getAbortWatcherCmd() + getStartBarrierCmd(veg) + getUnlessAbortedCmd(getAttackCmd(veg)) + getAttackRecordCmd(veg)},
 				Resources:       v.Spec.Resources,
 				VolumeMounts:    mounts,
 				// TODO: I am not sure this needs to be made configurable. What is defined in the image should be just fine.
 				WorkingDir: resultsPath,
-				Env:        getAttackEnv(v),
+				Env:        append(getAttackEnv(v), getGeneratorEnv(v, replica)...),
 				EnvFrom:    getEnvFrom(v),
 			}},
 			RestartPolicy:                 "Never",
 			Volumes:                       volumes,
 			SecurityContext:               &corev1.PodSecurityContext{},
 			TerminationGracePeriodSeconds: &immediate,
+			TopologySpreadConstraints:     getTopologySpreadConstraints(v),
 		},
 	}
+	pod.Labels[replicaLabel] = strconv.FormatUint(uint64(replica), 10)
+	if run := currentRun(v); run != "" {
+		pod.Labels[runLabel] = run
+	}
+	applyPodTemplate(pod, v.Spec.PodTemplate)
 	// Set Vegeta instance as the owner and controller
 	ctrl.SetControllerReference(v, pod, r.Scheme)
 	return pod
@@ -118,19 +127,59 @@ func (r *VegetaReconciler) aPod4Report(v *vegetav1alpha1.Vegeta) *corev1.Pod {
 			TerminationGracePeriodSeconds: &immediate,
 		},
 	}
+	if run := currentRun(v); run != "" {
+		pod.Labels[runLabel] = run
+	}
+	applyPodTemplate(pod, v.Spec.PodTemplate)
 
 	// Set Vegeta instance as the owner and controller
 	ctrl.SetControllerReference(v, pod, r.Scheme)
 	return pod
 }
 
-// getAttackCmd assembles an attack command based on the parameters configured in the vegeta resource
+// applyPodTemplate merges the pod settings specified in the vegeta resource into a generated pod.
+// The labels set by the operator take precedence as they are used to select the pods. The annotations with the prefix of the operator are skipped
+// as they drive the attack, e.g. an abort request or the start time set on the pods.
+func applyPodTemplate(pod *corev1.Pod, t *vegetav1alpha1.PodTemplate) {
+	if t == nil {
+		return
+	}
+	for k, v := range t.Labels {
+		if _, ok := pod.Labels[k]; !ok {
+			pod.Labels[k] = v
+		}
+	}
+	if len(t.Annotations) > 0 {
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		for k, v := range t.Annotations {
+			if strings.HasPrefix(k, reservedAnnotationPrefix) {
+				continue
+			}
+			pod.Annotations[k] = v
+		}
+	}
+	pod.Spec.NodeSelector = t.NodeSelector
+	pod.Spec.Tolerations = t.Tolerations
+	pod.Spec.Affinity = t.Affinity
+	pod.Spec.PriorityClassName = t.PriorityClassName
+	pod.Spec.SchedulerName = t.SchedulerName
+	pod.Spec.ServiceAccountName = t.ServiceAccountName
+	pod.Spec.ImagePullSecrets = t.ImagePullSecrets
+	if t.SecurityContext != nil {
+		pod.Spec.SecurityContext = t.SecurityContext
+	}
+}
+
+// getAttackCmd assembles the shell script running the attack based on the parameters configured in the vegeta resource.
+// The values of the vegeta resource are only passed as quoted arguments so that they cannot get interpreted by the shell.
 func getAttackCmd(veg *vegetav1alpha1.Vegeta) string {
 
 	/*
 	   Mounts:
 	   // - BodyConfigMap body.txt Specifies a config map containing the body of every request unless overridden per attack target.
-	   // - CertSecret client.crt Specifies the secret containing the TLS client PEM encoded certificate file.
+	   // - ClientCertSecret tls.crt and tls.key Specifies the kubernetes.io/tls secret containing the TLS client PEM encoded certificate and private key.
 	   // - HeadersConfigMap headers.txt Specifies a config map containing request headers to be used in all targets defined
 	   // - KeySecret client.key Specifies the secret containing the PEM encoded TLS client certificate private key
 	   	// - TargetsConfigMap targets.json or targets.http (depending on format)
@@ -138,146 +187,230 @@ func getAttackCmd(veg *vegetav1alpha1.Vegeta) string {
 
 	var sb strings.Builder
 
-	if veg.Spec.Attack.TargetsConfigMap != "" {
-		sb.WriteString("vegeta attack -targets ")
-		sb.WriteString(configPath)
-		sb.WriteString("targets")
-		if veg.Spec.Attack.Format == vegetav1alpha1.JSONFormat {
-			sb.WriteString(".json ")
+	// The output of the stages is a stream of json results written to stdout, not an attack whose -output can be set
+	output := " -output "
+	if len(veg.Spec.Attack.Stages) > 0 {
+		sb.WriteString(getStagesCmd(veg))
+		output = " > "
+	} else {
+		sb.WriteString(getSingleAttackCmd(veg, getAttackArgs(veg)))
+	}
+
+	// The breaker and the breakdown per target process the json results on their way to the report or the result file
+	if (hasCircuitBreaker(veg) || hasBreakdown(veg)) && len(veg.Spec.Attack.Stages) == 0 {
+		sb.WriteString(" | vegeta encode -to json")
+	}
+	if hasCircuitBreaker(veg) {
+		sb.WriteString(" | ")
+		sb.WriteString(getCircuitBreakerCmd(veg))
+		output = " > "
+	}
+	if hasBreakdown(veg) {
+		sb.WriteString(" | ")
+		sb.WriteString(getBreakdownCmd())
+		output = " > "
+	}
+
+	// In case of results being sent to standard ouptut the report should be processed immediately. There is no way to process it afterwards. Otherwise the output gets stored for later processing.
+	if veg.Spec.Report == nil {
+		writeStdoutReportCmd(&sb, veg)
+	} else {
+		switch veg.Spec.Report.OutputType {
+		case vegetav1alpha1.PvcOutput:
+			sb.WriteString(output)
+			sb.WriteString(getResultFile(veg))
+		case vegetav1alpha1.ObcOutput:
+			// The result file gets uploaded to the S3 bucket once the exit status of the attack has been captured, see getAttackRecordCmd
+			sb.WriteString(output)
+			sb.WriteString(getResultFile(veg))
+		default:
+			writeStdoutReportCmd(&sb, veg)
+		}
+	}
+
+	return sb.String()
+}
+
+// getSingleAttackCmd generates the command running vegeta attack with the given arguments.
+// A target specified in the vegeta resource or the generated targets are written to the standard input of the command.
+func getSingleAttackCmd(veg *vegetav1alpha1.Vegeta, args []string) string {
+	if hasTargetGenerator(veg) {
+		return getGeneratorCmd(veg) + " | " + shellJoin(args)
+	}
+	if target := getTarget(veg); veg.Spec.Attack.TargetsConfigMap == "" && !hasRenderedTargets(veg) && target != "" {
+		return getTargetCmd(target) + " | " + shellJoin(args)
+	}
+	return shellJoin(args)
+}
+
+// getBodyProjections returns the projections of the bodies of the inline targets into the files referenced by the targets rendered in the http format
+func getBodyProjections(veg *vegetav1alpha1.Vegeta) []corev1.VolumeProjection {
+	projections := []corev1.VolumeProjection{}
+	for i, t := range veg.Spec.Attack.Targets {
+		file := getTargetBodyFile(i)
+		switch {
+		case t.BodyFrom != nil && t.BodyFrom.SecretKeyRef != nil:
+			ref := t.BodyFrom.SecretKeyRef
+			projections = append(projections, corev1.VolumeProjection{
+				Secret: &corev1.SecretProjection{
+					LocalObjectReference: ref.LocalObjectReference,
+					Items:                []corev1.KeyToPath{{Key: ref.Key, Path: file}},
+					Optional:             ref.Optional,
+				},
+			})
+		case t.BodyFrom != nil && t.BodyFrom.ConfigMapKeyRef != nil:
+			ref := t.BodyFrom.ConfigMapKeyRef
+			projections = append(projections, corev1.VolumeProjection{
+				ConfigMap: &corev1.ConfigMapProjection{
+					LocalObjectReference: ref.LocalObjectReference,
+					Items:                []corev1.KeyToPath{{Key: ref.Key, Path: file}},
+					Optional:             ref.Optional,
+				},
+			})
+		case t.Body != "":
+			projections = append(projections, corev1.VolumeProjection{
+				Secret: &corev1.SecretProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: getTargetsSecretName(veg)},
+					Items:                []corev1.KeyToPath{{Key: file, Path: file}},
+				},
+			})
+		}
+	}
+	return projections
+}
+
+// getTargetCmd generates the command writing the target to the standard output for vegeta attack to read it
+func getTargetCmd(target string) string {
+	return shellJoin([]string{"printf", "%s\\n", target})
+}
+
+// getAttackArgs generates the arguments of the vegeta attack command based on the parameters configured in the vegeta resource.
+// The output of the attack is not part of the arguments, see getAttackCmd.
+func getAttackArgs(veg *vegetav1alpha1.Vegeta) []string {
+	attack := veg.Spec.Attack
+	args := []string{"vegeta", "attack"}
+
+	switch {
+	case hasRenderedTargets(veg):
+		// Inline targets, the endpoints of a Service and the operations of an OpenAPI document are rendered in the json format, weighted targets of a config map in their own format
+		// and inline targets with a body in a secret in the http format
+		args = append(args, "-targets", targetsPath+getRenderedTargetsFile(veg), "-format", getRenderedTargetsFormat(veg).String())
+	case hasTargetGenerator(veg):
+		// Generated targets are read from the standard input in the json format
+		args = append(args, "-format", vegetav1alpha1.JSONFormat.String())
+	case attack.TargetsConfigMap != "":
+		if attack.Format == vegetav1alpha1.JSONFormat {
+			args = append(args, "-targets", configPath+"targets.json")
 		} else {
-			sb.WriteString(".http ")
+			args = append(args, "-targets", configPath+"targets.http")
 		}
-	} else if veg.Spec.Attack.Target != "" {
-		sb.WriteString("echo ")
-		sb.WriteString(veg.Spec.Attack.Target)
-		sb.WriteString(" | ")
-		sb.WriteString("vegeta attack")
 	}
 
-	if veg.Spec.Attack.BodyConfigMap != "" {
-		sb.WriteString(" -body ")
-		sb.WriteString(configPath)
-		sb.WriteString("body.txt")
+	if attack.BodyConfigMap != "" {
+		args = append(args, "-body", configPath+"body.txt")
 	}
 
-	if veg.Spec.Attack.Chunked {
-		sb.WriteString(" -chunked")
+	if attack.ClientCertSecret != "" {
+		args = append(args, "-cert", credentialsPath+corev1.TLSCertKey)
 	}
 
-	if veg.Spec.Attack.Connections > 0 {
-		sb.WriteString(" -connections ")
-		sb.WriteString(strconv.Itoa(int(veg.Spec.Attack.Connections)))
+	if attack.Chunked {
+		args = append(args, "-chunked")
 	}
 
-	if veg.Spec.Attack.Duration != "" {
-		sb.WriteString(" -duration ")
-		sb.WriteString(veg.Spec.Attack.Duration)
+	if attack.Connections > 0 {
+		args = append(args, "-connections", strconv.FormatUint(uint64(attack.Connections), 10))
 	}
 
-	if veg.Spec.Attack.Format != "" {
-		sb.WriteString(" -format ")
-		sb.WriteString(veg.Spec.Attack.Format.String())
+	if attack.Duration != "" {
+		args = append(args, "-duration", attack.Duration)
 	}
 
-	if veg.Spec.Attack.H2C {
-		sb.WriteString(" -h2c")
+	if attack.Format != "" && !hasRenderedTargets(veg) && !hasTargetGenerator(veg) {
+		args = append(args, "-format", attack.Format.String())
 	}
 
-	for _, h := range veg.Spec.Attack.Headers {
-		sb.WriteString(" -header \"")
-		sb.WriteString(h)
-		sb.WriteString("\"")
+	if attack.H2C {
+		args = append(args, "-h2c")
 	}
 
-	if veg.Spec.Attack.HTTP2 {
-		sb.WriteString(" -http2")
+	for _, h := range attack.Headers {
+		args = append(args, "-header", h)
 	}
 
-	if veg.Spec.Attack.Insecure {
-		sb.WriteString(" -insecure")
+	if attack.HTTP2 {
+		args = append(args, "-http2")
 	}
 
-	if veg.Spec.Attack.KeepAlive {
-		sb.WriteString(" -keepalive")
+	if attack.Insecure {
+		args = append(args, "-insecure")
 	}
 
-	if veg.Spec.Attack.KeySecret != "" {
-		sb.WriteString(" -key ")
-		sb.WriteString(credentialsPath)
-		sb.WriteString("client.key")
+	if attack.KeepAlive != nil {
+		args = append(args, "-keepalive="+strconv.FormatBool(*attack.KeepAlive))
 	}
 
-	if veg.Spec.Attack.Lazy {
-		sb.WriteString(" -lazy")
+	if attack.ClientCertSecret != "" {
+		args = append(args, "-key", credentialsPath+corev1.TLSPrivateKeyKey)
+	} else if attack.KeySecret != "" {
+		args = append(args, "-key", credentialsPath+"client.key")
 	}
 
-	if veg.Spec.Attack.MaxBody != 0 {
-		sb.WriteString(" -max-body ")
-		sb.WriteString(strconv.FormatUint(uint64(veg.Spec.Attack.MaxBody), 10))
+	// The generated targets never end unless the dataset is used once, they must not be read eagerly
+	if attack.Lazy || hasTargetGenerator(veg) {
+		args = append(args, "-lazy")
 	}
 
-	if veg.Spec.Attack.MaxWorkers != 0 {
-		sb.WriteString(" -max-workers ")
-		sb.WriteString(strconv.FormatUint(uint64(veg.Spec.Attack.MaxWorkers), 10))
+	if attack.MaxBody != 0 {
+		args = append(args, "-max-body", strconv.FormatUint(uint64(attack.MaxBody), 10))
 	}
 
-	if veg.Spec.Attack.Name != "" {
-		sb.WriteString(" -max-name ")
-		sb.WriteString(veg.Spec.Attack.Name)
+	if attack.MaxWorkers != 0 {
+		args = append(args, "-max-workers", strconv.FormatUint(attack.MaxWorkers, 10))
 	}
 
-	if veg.Spec.Attack.ProxyHeader != "" {
-		sb.WriteString(" -proxy-header ")
-		sb.WriteString(veg.Spec.Attack.ProxyHeader)
+	if attack.Name != "" {
+		args = append(args, "-name", attack.Name)
 	}
 
-	if veg.Spec.Attack.Rate != "" {
-		sb.WriteString(" -rate ")
-		sb.WriteString(veg.Spec.Attack.Rate)
+	if attack.ProxyHeader != "" {
+		args = append(args, "-proxy-header", attack.ProxyHeader)
 	}
 
-	if veg.Spec.Attack.Redirects != 0 {
-		sb.WriteString(" -redirects ")
-		sb.WriteString(strconv.Itoa(int(veg.Spec.Attack.Redirects)))
+	if attack.Rate != "" {
+		args = append(args, "-rate", attack.Rate)
 	}
 
-	sb.WriteString(" -root-certs /var/run/secrets/kubernetes.io/serviceaccount/ca.crt,/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt,/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem")
+	if attack.Redirects != nil {
+		args = append(args, "-redirects", strconv.Itoa(int(*attack.Redirects)))
+	}
 
-	if veg.Spec.Attack.Timeout != "" {
-		sb.WriteString(" -timeout ")
-		sb.WriteString(veg.Spec.Attack.Timeout)
+	rootCerts := "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt,/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt,/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem"
+	if veg.Status.TargetCASecret != "" {
+		rootCerts += "," + targetCAPath + targetCAFile
 	}
+	args = append(args, "-root-certs", rootCerts)
 
-	if veg.Spec.Attack.Workers > 0 {
-		sb.WriteString(" -workers ")
-		sb.WriteString(strconv.FormatUint(uint64(veg.Spec.Attack.Workers), 10))
+	if attack.Timeout != "" {
+		args = append(args, "-timeout", attack.Timeout)
 	}
 
-	// In case of results being sent to standard ouptut the report should be processed immediately. There is no way to process it afterwards. Otherwise the output gets stored for later processing.
-	if veg.Spec.Report == nil {
-		sb.WriteString(" | ")
-		sb.WriteString(getReportCmd(veg))
-	} else {
-		switch veg.Spec.Report.OutputType {
-		case vegetav1alpha1.PvcOutput:
-			sb.WriteString(" -output ")
-			sb.WriteString(resultsPath)
-			sb.WriteString(getResultFileName(veg))
-			sb.WriteString("_res.gob")
-		case vegetav1alpha1.ObcOutput:
-			sb.WriteString(" -output ")
-			sb.WriteString(resultsPath)
-			sb.WriteString(getResultFileName(veg))
-			sb.WriteString("_res.gob")
-			// Additional step to upload the result file to the S3 bucket
-			sb.WriteString("; s3 -command upload")
-		default:
-			sb.WriteString(" | ")
-			sb.WriteString(getReportCmd(veg))
-		}
+	if attack.Workers > 0 {
+		args = append(args, "-workers", strconv.FormatUint(attack.Workers, 10))
 	}
 
-	return sb.String()
+	return args
+}
+
+// writeStdoutReportCmd pipes the results of the attack into the report command.
+// The results are also kept in the pod so that a json report can be written into the termination message for the controller.
+func writeStdoutReportCmd(sb *strings.Builder, veg *vegetav1alpha1.Vegeta) {
+	resultFile := getResultFile(veg)
+	sb.WriteString(" | tee ")
+	sb.WriteString(resultFile)
+	sb.WriteString(" | ")
+	sb.WriteString(getReportCmd(veg))
+	// The json report is written once the exit status of the attack has been captured, see getAttackRecordCmd
 }
 
 // getReportCmd generates the report command  based on the parameters configured in the vegeta resource
@@ -290,48 +423,118 @@ func getReportCmd(veg *vegetav1alpha1.Vegeta) string {
 		upload = "; s3 -command upload"
 	}
 
-	sb.WriteString("vegeta report ")
+	sb.WriteString(shellJoin(getReportArgs(veg)))
 
-	if veg.Spec.Report != nil && veg.Spec.Report.Buckets != "" {
-		sb.WriteString(" -buckets ")
-		sb.WriteString(veg.Spec.Report.Buckets)
+	if isStoredOutput(veg) {
+		// TODO: I am only generating reports in binary format. I may need to encode them in one of the available formats: (gob | json | csv)
+		sb.WriteString(" -output ")
+		sb.WriteString(getResultFileName(veg))
+		sb.WriteString("_rep.gob")
+		inputs := resultsPath + getResultBaseName(veg) + "*_res.*"
+		sb.WriteString(" ")
+		sb.WriteString(inputs)
+		// The exit status of the report is the one of the pod, the consolidated json report is made available to the controller and the report gets uploaded afterwards
+		sb.WriteString("; rc=$?; ")
+		sb.WriteString(getJSONReportCmd(inputs))
+		sb.WriteString("; ")
+		sb.WriteString(getRecordCmd(true, false, false, false))
+		sb.WriteString(upload)
+		sb.WriteString("; exit $rc")
+		return sb.String()
 	}
+	sb.WriteString(upload)
+	return sb.String()
+}
 
-	if veg.Spec.Report != nil && veg.Spec.Report.Every != "" {
-		sb.WriteString(" -every ")
-		sb.WriteString(veg.Spec.Report.Every)
+// getReportArgs generates the arguments of the vegeta report command based on the parameters configured in the vegeta resource.
+// The output and the inputs of the report are not part of the arguments, see getReportCmd.
+func getReportArgs(veg *vegetav1alpha1.Vegeta) []string {
+	args := []string{"vegeta", "report"}
+	if veg.Spec.Report == nil {
+		return args
 	}
 
-	if veg.Spec.Report != nil && veg.Spec.Report.OutputType != vegetav1alpha1.StdoutOutput {
-		// TODO: I am only generating reports in binary format. I may need to encode them in one of the available formats: (gob | json | csv)
-		sb.WriteString(" -output ")
-		fileName := getResultFileName(veg) + "_rep.gob "
-		sb.WriteString(fileName)
+	if veg.Spec.Report.Buckets != "" {
+		args = append(args, "-buckets", veg.Spec.Report.Buckets)
 	}
 
-	if veg.Spec.Report != nil && veg.Spec.Report.Type.String() != "" {
-		sb.WriteString(" -type ")
-		sb.WriteString(veg.Spec.Report.Type.String())
+	if veg.Spec.Report.Every != "" {
+		args = append(args, "-every", veg.Spec.Report.Every)
 	}
 
-	if veg.Spec.Report != nil && veg.Spec.Report.OutputType != vegetav1alpha1.StdoutOutput {
-		sb.WriteString(resultsPath)
-		sb.WriteString(veg.ObjectMeta.GetCreationTimestamp().Format("20060102150405"))
-		sb.WriteString("-")
-		sb.WriteString(veg.Name)
-		sb.WriteString("*_res.*")
+	if veg.Spec.Report.Type.String() != "" {
+		args = append(args, "-type", veg.Spec.Report.Type.String())
+	}
+
+	return args
+}
+
+// shellJoin assembles the arguments of a command into a shell command line.
+// Arguments are quoted unless they only contain characters that have no special meaning for the shell.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes an argument so that the shell passes it verbatim to the command.
+// Within single quotes no character is interpreted by the shell. Single quotes in the argument are escaped outside of the quoted strings.
+func shellQuote(arg string) string {
+	if arg != "" && strings.IndexFunc(arg, isUnsafeShellChar) < 0 {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// isUnsafeShellChar returns true for characters that may have a special meaning for the shell
+func isUnsafeShellChar(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case strings.ContainsRune("@%+=:,./_-", r):
+		return false
+	default:
+		return true
 	}
-	sb.WriteString(upload)
-	return sb.String()
+}
+
+// isStoredOutput returns true if the results of the attack are stored for a report to be generated by a separate pod
+func isStoredOutput(veg *vegetav1alpha1.Vegeta) bool {
+	return veg.Spec.Report != nil && (veg.Spec.Report.OutputType == vegetav1alpha1.PvcOutput || veg.Spec.Report.OutputType == vegetav1alpha1.ObcOutput)
 }
 
 // getResultFileName generates the name of the result file (used for result and report)
 func getResultFileName(veg *vegetav1alpha1.Vegeta) string {
-	return veg.ObjectMeta.GetCreationTimestamp().Format("20060102150405") + "-${HOSTNAME}"
+	return getRunPrefix(veg) + "-${HOSTNAME}"
+}
+
+// getRunPrefix generates the prefix of the result and report files of the current run: the creation time of the vegeta resource followed by the ID of the run, if any,
+// and by the start time of the run. A run ID can be reused, e.g. A, B then A again, and the start time keeps the files of the runs apart.
+// It is recorded before the attack pods get created.
+func getRunPrefix(veg *vegetav1alpha1.Vegeta) string {
+	prefix := veg.ObjectMeta.GetCreationTimestamp().Format("20060102150405")
+	if run := currentRun(veg); run != "" {
+		prefix += "-" + run
+	}
+	if veg.Status.StartTime != nil {
+		prefix += "-" + veg.Status.StartTime.Format("20060102150405")
+	}
+	return prefix
+}
+
+// getResultFile generates the path of the file containing the results of the attack.
+// The results of load profiles are encoded in json so that the results of their stages can be concatenated.
+func getResultFile(veg *vegetav1alpha1.Vegeta) string {
+	if len(veg.Spec.Attack.Stages) > 0 || hasCircuitBreaker(veg) || hasBreakdown(veg) {
+		return resultsPath + getResultFileName(veg) + "_res.json"
+	}
+	return resultsPath + getResultFileName(veg) + "_res.gob"
 }
 
 func getResultBaseName(veg *vegetav1alpha1.Vegeta) string {
-	return veg.ObjectMeta.GetCreationTimestamp().Format("20060102150405") + "-" + veg.Name
+	return getRunPrefix(veg) + "-" + veg.Name
 }
 
 // getAPVolumesAndMounts generates the list of volumes and mounts for the attack pod
@@ -342,7 +545,13 @@ func getAPVolumesAndMounts(veg *vegetav1alpha1.Vegeta) ([]corev1.Volume, []corev
 	// Fields (all optionals):
 	// - BodyConfigMap body.txt Specifies a config map containing the body of every request unless overridden per attack target.
 	// - KeySecret client.key Specifies the secret containing the PEM encoded TLS client certificate private key
+	// - ClientCertSecret tls.crt and tls.key Specifies the kubernetes.io/tls secret containing the TLS client certificate and private key
 	// - TargetsConfigMap targets.json or targets.http (depending on format)
+	// - Targets, weighted targets of TargetsConfigMap or endpoints of the Service referenced by TargetRef or operations of the OpenAPI document rendered into a secret mounted RO under /opt/targets/
+	// - Bodies of the Targets, when one of them is in a secret, projected from the rendered targets secret and the referenced config maps and secrets RO under /opt/bodies/
+	// - CA discovered for the target resolved from TargetRef in a secret mounted RO under /opt/target-ca/
+	// - Dataset of the generated targets from a config map or a PVC mounted RO under /opt/dataset/
+	// - Start time of the attack and abort request exposed through the downward API under /etc/podinfo/
 	var ro int32 = 292
 	volumes := []corev1.Volume{}
 	mounts := []corev1.VolumeMount{}
@@ -465,7 +674,122 @@ func getAPVolumesAndMounts(veg *vegetav1alpha1.Vegeta) ([]corev1.Volume, []corev
 		)
 	}
 
-	if veg.Spec.Attack.TargetsConfigMap != "" {
+	if hasRenderedTargets(veg) {
+		volumes = append(volumes,
+			corev1.Volume{
+				Name: "inline-targets",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: getTargetsSecretName(veg),
+						Items: []corev1.KeyToPath{
+							{
+								Key:  getRenderedTargetsFile(veg),
+								Path: getRenderedTargetsFile(veg),
+							},
+						},
+						DefaultMode: &ro,
+					},
+				},
+			},
+		)
+
+		mounts = append(mounts,
+			corev1.VolumeMount{
+				Name:      "inline-targets",
+				MountPath: targetsPath,
+				ReadOnly:  true,
+			},
+		)
+	}
+
+	if hasSecretBody(veg) {
+		volumes = append(volumes,
+			corev1.Volume{
+				Name: "bodies",
+				VolumeSource: corev1.VolumeSource{
+					Projected: &corev1.ProjectedVolumeSource{
+						Sources:     getBodyProjections(veg),
+						DefaultMode: &ro,
+					},
+				},
+			},
+		)
+
+		mounts = append(mounts,
+			corev1.VolumeMount{
+				Name:      "bodies",
+				MountPath: bodiesPath,
+				ReadOnly:  true,
+			},
+		)
+	}
+
+	if veg.Status.TargetCASecret != "" {
+		volumes = append(volumes,
+			corev1.Volume{
+				Name: "target-ca",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: veg.Status.TargetCASecret,
+						Items: []corev1.KeyToPath{
+							{
+								Key:  targetCAFile,
+								Path: targetCAFile,
+							},
+						},
+						DefaultMode: &ro,
+					},
+				},
+			},
+		)
+
+		mounts = append(mounts,
+			corev1.VolumeMount{
+				Name:      "target-ca",
+				MountPath: targetCAPath,
+				ReadOnly:  true,
+			},
+		)
+	}
+
+	if veg.Spec.Attack.ClientCertSecret != "" {
+		volumes = append(volumes,
+			corev1.Volume{
+				Name: "client-cert",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: veg.Spec.Attack.ClientCertSecret,
+						Items: []corev1.KeyToPath{
+							{
+								Key:  corev1.TLSCertKey,
+								Path: corev1.TLSCertKey,
+							},
+							{
+								Key:  corev1.TLSPrivateKeyKey,
+								Path: corev1.TLSPrivateKeyKey,
+							},
+						},
+						DefaultMode: &ro,
+					},
+				},
+			},
+		)
+
+		mounts = append(mounts,
+			corev1.VolumeMount{
+				Name:      "client-cert",
+				MountPath: credentialsPath,
+				ReadOnly:  true,
+			},
+		)
+	}
+
+	volume, mount := getPodInfoVolume()
+	volumes = append(volumes, volume)
+	mounts = append(mounts, mount)
+
+	// Weighted targets of the config map are read from the rendered targets instead
+	if veg.Spec.Attack.TargetsConfigMap != "" && !veg.Spec.Attack.WeightedTargets {
 		var file string
 		if veg.Spec.Attack.Format == vegetav1alpha1.JSONFormat {
 			file = "targets.json"
@@ -501,6 +825,41 @@ func getAPVolumesAndMounts(veg *vegetav1alpha1.Vegeta) ([]corev1.Volume, []corev
 		)
 	}
 
+	if g := veg.Spec.Attack.TargetGenerator; g != nil && g.Dataset != nil {
+		var source corev1.VolumeSource
+		if ref := g.Dataset.ConfigMapKeyRef; ref != nil {
+			source.ConfigMap = &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: ref.LocalObjectReference,
+				Items: []corev1.KeyToPath{
+					{
+						Key:  ref.Key,
+						Path: ref.Key,
+					},
+				},
+				DefaultMode: &ro,
+			}
+		} else {
+			source.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: g.Dataset.PersistentVolumeClaim.ClaimName,
+				ReadOnly:  true,
+			}
+		}
+		volumes = append(volumes,
+			corev1.Volume{
+				Name:         "dataset",
+				VolumeSource: source,
+			},
+		)
+
+		mounts = append(mounts,
+			corev1.VolumeMount{
+				Name:      "dataset",
+				MountPath: datasetPath,
+				ReadOnly:  true,
+			},
+		)
+	}
+
 	return volumes, mounts
 }
 
@@ -579,7 +938,7 @@ func getAttackEnv(veg *vegetav1alpha1.Vegeta) []corev1.EnvVar {
 		env = append(env,
 			corev1.EnvVar{
 				Name:  "S3_UPLOAD_FILE",
-				Value: resultsPath + getResultFileName(veg) + "_res.gob",
+				Value: getResultFile(veg),
 			})
 	}
 	if veg.Spec.Attack.RootCertsConfigMap != "" {
@@ -0,0 +1,193 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// hostileInputs are values that would break the command or run arbitrary commands if they were interpreted by the shell
+var hostileInputs = []string{
+	`X-Evil: $(touch /tmp/pwned)`,
+	"X-Evil: `touch /tmp/pwned`",
+	`X-Evil: "; touch /tmp/pwned; echo "`,
+	`X-Evil: '; touch /tmp/pwned; echo '`,
+	`X-Evil: it's a \ backslash & ${HOME} | cat > /tmp/pwned`,
+	"X-Evil: multi\nline",
+	"",
+}
+
+// shellArgs runs the command line with the shell and returns the arguments it has been split into
+func shellArgs(cmdLine string) []string {
+	out, err := exec.Command("/bin/sh", "-c", "for a in "+cmdLine+"; do printf '%s\\0' \"$a\"; done").Output()
+	Expect(err).ToNot(HaveOccurred())
+	args := strings.Split(string(out), "\x00")
+	return args[:len(args)-1]
+}
+
+var _ = Describe("Vegeta pod", func() {
+	Context("When arguments are quoted for the shell", func() {
+		It("Should pass them verbatim to the command", func() {
+			Expect(shellArgs(shellJoin(hostileInputs))).Should(Equal(hostileInputs))
+		})
+		It("Should leave plain arguments unquoted", func() {
+			Expect(shellJoin([]string{"vegeta", "attack", "-rate", "50/1s", "-keepalive=false"})).Should(Equal("vegeta attack -rate 50/1s -keepalive=false"))
+		})
+	})
+
+	Context("When the attack command is generated", func() {
+		It("Should pass hostile headers, names and targets as single arguments", func() {
+			vegeta := newVegeta("hostile")
+			vegeta.Spec.Attack.Headers = hostileInputs
+			vegeta.Spec.Attack.ProxyHeader = hostileInputs[0]
+			vegeta.Spec.Attack.Name = hostileInputs[3]
+			vegeta.Spec.Attack.Target = "GET https://example.com/$(touch /tmp/pwned)?a='b'&c=`d`"
+			cmd := getAttackCmd(vegeta)
+			for _, h := range hostileInputs {
+				Expect(cmd).Should(ContainSubstring(" -header " + shellQuote(h) + " "))
+			}
+			Expect(cmd).Should(ContainSubstring(" -proxy-header " + shellQuote(hostileInputs[0]) + " "))
+			Expect(cmd).Should(ContainSubstring(" -name " + shellQuote(hostileInputs[3]) + " "))
+			Expect(strings.HasPrefix(cmd, getTargetCmd(vegeta.Spec.Attack.Target)+" | vegeta attack ")).Should(BeTrue())
+
+			out, err := exec.Command("/bin/sh", "-c", getTargetCmd(vegeta.Spec.Attack.Target)).Output()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(out)).Should(Equal(vegeta.Spec.Attack.Target + "\n"))
+		})
+		It("Should explicitly disable keep-alive and redirects", func() {
+			vegeta := newVegeta("keepalive")
+			keepAlive := false
+			redirects := int32(0)
+			vegeta.Spec.Attack.KeepAlive = &keepAlive
+			vegeta.Spec.Attack.Redirects = &redirects
+			args := getAttackArgs(vegeta)
+			Expect(args).Should(ContainElement("-keepalive=false"))
+			Expect(strings.Join(args, " ")).Should(ContainSubstring("-redirects 0"))
+		})
+	})
+
+	Context("When a client certificate secret is specified", func() {
+		It("Should mount the certificate and key of the kubernetes.io/tls secret", func() {
+			vegeta := newVegeta("mtls")
+			vegeta.Spec.Attack.ClientCertSecret = "client-tls"
+			cmd := getAttackCmd(vegeta)
+			Expect(cmd).Should(ContainSubstring(" -cert /opt/config/credentials/tls.crt "))
+			Expect(cmd).Should(ContainSubstring(" -key /opt/config/credentials/tls.key "))
+			volumes, mounts := getAPVolumesAndMounts(vegeta)
+			Expect(mounts).Should(ContainElement(corev1.VolumeMount{Name: "client-cert", MountPath: credentialsPath, ReadOnly: true}))
+			var secret *corev1.SecretVolumeSource
+			for _, v := range volumes {
+				if v.Name == "client-cert" {
+					secret = v.Secret
+				}
+			}
+			Expect(secret).ToNot(BeNil())
+			Expect(secret.SecretName).Should(Equal("client-tls"))
+			Expect(secret.Items).Should(ConsistOf(
+				corev1.KeyToPath{Key: "tls.crt", Path: "tls.crt"},
+				corev1.KeyToPath{Key: "tls.key", Path: "tls.key"}))
+		})
+	})
+
+	Context("When inline targets are specified", func() {
+		It("Should render them in the vegeta json format and mount them", func() {
+			vegeta := newVegeta("inline")
+			vegeta.Spec.Attack.Target = ""
+			vegeta.Spec.Attack.Targets = []vegetav1alpha1.AttackTarget{
+				{URL: "https://example.com/healthz"},
+				{
+					Method:  "POST",
+					URL:     "https://example.com/things",
+					Headers: []string{"Content-Type: application/json", "X-Quote: it's \"quoted\""},
+					Body:    `{"name": "$(whoami)"}`,
+				},
+			}
+			content, _, err := (&VegetaReconciler{}).renderTargets(context.Background(), vegeta)
+			Expect(err).ToNot(HaveOccurred())
+			lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+			Expect(lines).Should(HaveLen(2))
+			Expect(lines[0]).Should(Equal(`{"method":"GET","url":"https://example.com/healthz"}`))
+			target := &vegetaTarget{}
+			Expect(json.Unmarshal([]byte(lines[1]), target)).To(Succeed())
+			Expect(target.Method).Should(Equal("POST"))
+			Expect(string(target.Body)).Should(Equal(`{"name": "$(whoami)"}`))
+			Expect(target.Header.Get("X-Quote")).Should(Equal(`it's "quoted"`))
+
+			cmd := getAttackCmd(vegeta)
+			Expect(strings.HasPrefix(cmd, "vegeta attack -targets /opt/targets/targets.json -format json ")).Should(BeTrue())
+			_, mounts := getAPVolumesAndMounts(vegeta)
+			Expect(mounts).Should(ContainElement(corev1.VolumeMount{Name: "inline-targets", MountPath: targetsPath, ReadOnly: true}))
+		})
+	})
+
+	Context("When a pod template is specified", func() {
+		It("Should merge it into the attack and report pods", func() {
+			vegeta := newVegeta("podtemplate")
+			vegeta.Spec.PodTemplate = &vegetav1alpha1.PodTemplate{
+				Labels:       map[string]string{"team": "perf", "vegeta.testing.io/type": "other"},
+				Annotations:  map[string]string{"sidecar.istio.io/inject": "false", "vegeta.testing.io/start-at": "0", "vegeta.testing.io/abort": "true"},
+				NodeSelector: map[string]string{"node-role.kubernetes.io/loadgen": ""},
+				Tolerations: []corev1.Toleration{{
+					Key:      "dedicated",
+					Operator: corev1.TolerationOpEqual,
+					Value:    "loadgen",
+					Effect:   corev1.TaintEffectNoSchedule,
+				}},
+				PriorityClassName:  "low",
+				ServiceAccountName: "vegeta",
+				ImagePullSecrets:   []corev1.LocalObjectReference{{Name: "registry"}},
+			}
+			s := runtime.NewScheme()
+			Expect(vegetav1alpha1.AddToScheme(s)).To(Succeed())
+			r := &VegetaReconciler{Scheme: s}
+			for _, pod := range []*corev1.Pod{r.aPod4Attack(vegeta, 0), r.aPod4Report(vegeta)} {
+				Expect(pod.Labels).Should(HaveKeyWithValue("team", "perf"))
+				Expect(pod.Labels).Should(HaveKeyWithValue("vegeta.testing.io/type", Not(Equal("other"))))
+				Expect(pod.Annotations).Should(HaveKeyWithValue("sidecar.istio.io/inject", "false"))
+				Expect(pod.Annotations).ShouldNot(HaveKey(startAtAnnotation))
+				Expect(pod.Annotations).ShouldNot(HaveKey(abortAnnotation))
+				Expect(pod.Spec.NodeSelector).Should(Equal(vegeta.Spec.PodTemplate.NodeSelector))
+				Expect(pod.Spec.Tolerations).Should(Equal(vegeta.Spec.PodTemplate.Tolerations))
+				Expect(pod.Spec.PriorityClassName).Should(Equal("low"))
+				Expect(pod.Spec.ServiceAccountName).Should(Equal("vegeta"))
+				Expect(pod.Spec.ImagePullSecrets).Should(Equal(vegeta.Spec.PodTemplate.ImagePullSecrets))
+				Expect(pod.Spec.SecurityContext).ToNot(BeNil())
+			}
+		})
+	})
+
+	Context("When the report command is generated", func() {
+		It("Should quote the buckets", func() {
+			vegeta := newVegeta("buckets")
+			vegeta.Spec.Report = &vegetav1alpha1.ReportSpec{
+				Buckets: "[0,1ms,10ms]",
+				Type:    vegetav1alpha1.HistReport,
+			}
+			Expect(getReportCmd(vegeta)).Should(Equal("vegeta report -buckets '[0,1ms,10ms]' -type hist"))
+		})
+	})
+})
@@ -0,0 +1,115 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// replicaLabel is set on the attack pods with the index of their replica, so that only the replicas without pod get created
+const replicaLabel = "vegeta.testing.io/replica"
+
+// missingReplicas returns the indexes of the replicas that have no attack pod, lowest first.
+// Pods without replica label, e.g. created by a previous version of the operator, are accounted for the lowest indexes not taken.
+func missingReplicas(v *vegetav1alpha1.Vegeta, attackPods []*corev1.Pod) []uint32 {
+	taken := make(map[uint32]bool, len(attackPods))
+	unlabelled := 0
+	for _, pod := range attackPods {
+		replica, err := strconv.ParseUint(pod.Labels[replicaLabel], 10, 32)
+		if err != nil {
+			unlabelled++
+			continue
+		}
+		taken[uint32(replica)] = true
+	}
+	var missing []uint32
+	for i := uint32(0); i < v.Spec.Replicas; i++ {
+		if taken[i] {
+			continue
+		}
+		if unlabelled > 0 {
+			unlabelled--
+			continue
+		}
+		missing = append(missing, i)
+	}
+	return missing
+}
+
+// forReplica returns the vegeta resource with the attack parameters of a replica.
+// With the total rate mode the rates, workers and max workers are divided across the replicas, the first replicas taking the remainders.
+func forReplica(v *vegetav1alpha1.Vegeta, replica uint32) *vegetav1alpha1.Vegeta {
+	if v.Spec.RateMode != vegetav1alpha1.TotalRate || v.Spec.Replicas < 2 {
+		return v
+	}
+	veg := v.DeepCopy()
+	a := veg.Spec.Attack
+	a.Rate = splitRate(a.Rate, v.Spec.Replicas, replica)
+	a.Workers = splitCount(a.Workers, v.Spec.Replicas, replica)
+	a.MaxWorkers = splitCount(a.MaxWorkers, v.Spec.Replicas, replica)
+	for i := range a.Stages {
+		a.Stages[i].Rate = splitRate(a.Stages[i].Rate, v.Spec.Replicas, replica)
+		a.Stages[i].TargetRate = splitRate(a.Stages[i].TargetRate, v.Spec.Replicas, replica)
+		a.Stages[i].Amplitude = splitRate(a.Stages[i].Amplitude, v.Spec.Replicas, replica)
+	}
+	return veg
+}
+
+// getReplicaRates returns the rate of each replica when the rate is divided across them, nil otherwise
+func getReplicaRates(v *vegetav1alpha1.Vegeta) []string {
+	if v.Spec.RateMode != vegetav1alpha1.TotalRate || v.Spec.Replicas < 2 || v.Spec.Attack.Rate == "" {
+		return nil
+	}
+	rates := make([]string, v.Spec.Replicas)
+	for i := range rates {
+		rates[i] = splitRate(v.Spec.Attack.Rate, v.Spec.Replicas, uint32(i))
+	}
+	return rates
+}
+
+// splitRate returns the share of a replica of a rate with the freq/duration format.
+// When the frequency is lower than the number of replicas the duration is multiplied instead, e.g. 2/1s gives 2/3s with 3 replicas,
+// as a frequency of 0 would mean no rate limit. Rates without limit or that cannot be parsed are returned unchanged.
+func splitRate(rate string, replicas, replica uint32) string {
+	freq, per, err := vegetav1alpha1.ParseRate(rate)
+	if err != nil || freq == 0 {
+		return rate
+	}
+	if uint32(freq) < replicas {
+		return strconv.Itoa(freq) + "/" + (per * time.Duration(replicas)).String()
+	}
+	return strconv.FormatUint(splitCount(uint64(freq), replicas, replica), 10) + "/" + per.String()
+}
+
+// splitCount returns the share of a replica of a count, which is at least 1 unless the count is 0 (unset)
+func splitCount(n uint64, replicas, replica uint32) uint64 {
+	if n == 0 {
+		return 0
+	}
+	share := n / uint64(replicas)
+	if uint64(replica) < n%uint64(replicas) {
+		share++
+	}
+	if share == 0 {
+		share = 1
+	}
+	return share
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Vegeta replicas", func() {
+	Context("When the rate applies to each replica", func() {
+		It("Should run the attack as specified in every pod", func() {
+			vegeta := newVegeta("per-replica")
+			vegeta.Spec.Replicas = 3
+			vegeta.Spec.Attack.Rate = "1000/1s"
+			vegeta.Spec.RateMode = vegetav1alpha1.PerReplicaRate
+			Expect(forReplica(vegeta, 2)).Should(BeIdenticalTo(vegeta))
+			Expect(getReplicaRates(vegeta)).Should(BeNil())
+		})
+	})
+
+	Context("When some attack pods are missing", func() {
+		It("Should only create the replicas without pod", func() {
+			vegeta := newVegeta("missing")
+			vegeta.Spec.Replicas = 3
+			pod := newTargetRefReconciler().aPod4Attack(vegeta, 2)
+			Expect(pod.Labels).Should(HaveKeyWithValue(replicaLabel, "2"))
+			replica := func(label string) *corev1.Pod {
+				return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{replicaLabel: label}}}
+			}
+			Expect(missingReplicas(vegeta, nil)).Should(Equal([]uint32{0, 1, 2}))
+			// The first replica is not created again when another pod has disappeared from the list
+			Expect(missingReplicas(vegeta, []*corev1.Pod{replica("0"), replica("2")})).Should(Equal([]uint32{1}))
+			Expect(missingReplicas(vegeta, []*corev1.Pod{replica("0"), replica("1"), replica("2")})).Should(BeEmpty())
+			// Pods without label take the lowest free indexes
+			Expect(missingReplicas(vegeta, []*corev1.Pod{replica("1"), {}})).Should(Equal([]uint32{2}))
+		})
+	})
+
+	Context("When the rate is divided across the replicas", func() {
+		var vegeta *vegetav1alpha1.Vegeta
+
+		BeforeEach(func() {
+			vegeta = newVegeta("total")
+			vegeta.Spec.Replicas = 3
+			vegeta.Spec.RateMode = vegetav1alpha1.TotalRate
+			vegeta.Spec.Attack.Rate = "1000/1s"
+			vegeta.Spec.Attack.Workers = 10
+			vegeta.Spec.Attack.MaxWorkers = 20
+		})
+
+		It("Should give the remainders to the first replicas", func() {
+			Expect(getReplicaRates(vegeta)).Should(Equal([]string{"334/1s", "333/1s", "333/1s"}))
+			first, last := forReplica(vegeta, 0), forReplica(vegeta, 2)
+			Expect(first.Spec.Attack.Workers).Should(Equal(uint64(4)))
+			Expect(last.Spec.Attack.Workers).Should(Equal(uint64(3)))
+			Expect(first.Spec.Attack.MaxWorkers).Should(Equal(uint64(7)))
+			Expect(last.Spec.Attack.MaxWorkers).Should(Equal(uint64(6)))
+			Expect(getAttackArgs(last)).Should(ContainElement("333/1s"))
+			// The resource itself is left untouched
+			Expect(vegeta.Spec.Attack.Rate).Should(Equal("1000/1s"))
+		})
+		It("Should stretch the duration of rates lower than the number of replicas", func() {
+			vegeta.Spec.Attack.Rate = "2/1s"
+			Expect(getReplicaRates(vegeta)).Should(Equal([]string{"2/3s", "2/3s", "2/3s"}))
+		})
+		It("Should keep unlimited rates and at least one worker per replica", func() {
+			vegeta.Spec.Attack.Rate = "0"
+			vegeta.Spec.Attack.Workers = 2
+			Expect(getReplicaRates(vegeta)).Should(Equal([]string{"0", "0", "0"}))
+			Expect(forReplica(vegeta, 2).Spec.Attack.Workers).Should(Equal(uint64(1)))
+		})
+		It("Should divide the rates of the stages", func() {
+			vegeta.Spec.Attack.Rate = ""
+			vegeta.Spec.Attack.Stages = []vegetav1alpha1.Stage{{
+				Shape:      vegetav1alpha1.LinearShape,
+				Duration:   "1m",
+				Rate:       "30/1s",
+				TargetRate: "300/1s",
+			}}
+			stage := forReplica(vegeta, 1).Spec.Attack.Stages[0]
+			Expect(stage.Rate).Should(Equal("10/1s"))
+			Expect(stage.TargetRate).Should(Equal("100/1s"))
+			Expect(getReplicaRates(vegeta)).Should(BeNil())
+		})
+	})
+})
@@ -0,0 +1,275 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// terminationLogPath is where the json report gets written for the controller to pick it up from the pod status.
+	terminationLogPath = "/dev/termination-log"
+	// terminationLogMaxBytes is the limit of the termination message. The kubelet keeps the last 4096 bytes of larger messages, which would make the records unreadable.
+	terminationLogMaxBytes = 4096
+	// jsonReportFile is where the json report gets written before the recorder copies it into the termination message
+	jsonReportFile = "/tmp/vegeta-report.json"
+	// maxErrors is the maximum number of distinct errors kept in the status.
+	maxErrors = 10
+)
+
+// vegetaMetrics mirrors the json report generated by vegeta report -type json
+type vegetaMetrics struct {
+	Latencies struct {
+		Total time.Duration `json:"total"`
+		Mean  time.Duration `json:"mean"`
+		P50   time.Duration `json:"50th"`
+		P90   time.Duration `json:"90th"`
+		P95   time.Duration `json:"95th"`
+		P99   time.Duration `json:"99th"`
+		Max   time.Duration `json:"max"`
+		Min   time.Duration `json:"min"`
+	} `json:"latencies"`
+	BytesIn struct {
+		Total uint64 `json:"total"`
+	} `json:"bytes_in"`
+	BytesOut struct {
+		Total uint64 `json:"total"`
+	} `json:"bytes_out"`
+	Duration    time.Duration     `json:"duration"`
+	Wait        time.Duration     `json:"wait"`
+	Requests    uint64            `json:"requests"`
+	Rate        float64           `json:"rate"`
+	Throughput  float64           `json:"throughput"`
+	Success     float64           `json:"success"`
+	StatusCodes map[string]uint64 `json:"status_codes"`
+	Errors      []string          `json:"errors"`
+}
+
+// getJSONReportCmd generates the command writing the json report of the given result files for the recorder, see getRecordCmd
+func getJSONReportCmd(inputs string) string {
+	return "vegeta report -type json -output " + jsonReportFile + " " + inputs
+}
+
+// getRecordCmd generates the command writing the records read by the controller into the termination message of the container: the json report, the record of the trip of the circuit breaker,
+// the record of the load profile and the breakdown.
+// The recorder trims them so that they fit into the size limit of the termination message, the report taking precedence, and skips the ones that have not been written.
+func getRecordCmd(report, trip, profile, breakdown bool) string {
+	args := []string{"recorder", "-output", terminationLogPath, "-max-bytes", strconv.Itoa(terminationLogMaxBytes), "-max-errors", strconv.Itoa(maxErrors)}
+	if report {
+		args = append(args, "-report", jsonReportFile)
+	}
+	if trip {
+		args = append(args, "-trip", breakerTripFile)
+	}
+	if profile {
+		args = append(args, "-profile", loadProfileRecordFile)
+	}
+	if breakdown {
+		args = append(args, "-breakdown", breakdownRecordFile)
+	}
+	return shellJoin(args)
+}
+
+// getAttackRecordCmd generates the command recording the results of the attack container once the attack has terminated, empty if there is nothing to record.
+// The exit status of the attack is captured first and kept as the one of the container, then the json report is written, the results uploaded and the records written.
+func getAttackRecordCmd(v *vegetav1alpha1.Vegeta) string {
+	report, trip, profile, breakdown := !isStoredOutput(v), hasCircuitBreaker(v), hasLoadProfile(v), hasBreakdown(v)
+	upload := v.Spec.Report != nil && v.Spec.Report.OutputType == vegetav1alpha1.ObcOutput
+	if !report && !trip && !profile && !breakdown && !upload {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("; rc=$?")
+	if report {
+		sb.WriteString("; ")
+		sb.WriteString(getJSONReportCmd(getResultFile(v)))
+	}
+	if upload {
+		sb.WriteString("; s3 -command upload")
+	}
+	if report || trip || profile || breakdown {
+		sb.WriteString("; ")
+		sb.WriteString(getRecordCmd(report, trip, profile, breakdown))
+	}
+	sb.WriteString("; exit $rc")
+	return sb.String()
+}
+
+// parseMetrics decodes a json report generated by vegeta and compacted by the recorder. The record of the trip of the circuit breaker and the breakdown may follow the report.
+func parseMetrics(report string) (*vegetaMetrics, error) {
+	m := &vegetaMetrics{}
+	if err := json.NewDecoder(strings.NewReader(report)).Decode(m); err != nil {
+		return nil, fmt.Errorf("Unable to parse the vegeta report: %v", err)
+	}
+	return m, nil
+}
+
+// podMetrics extracts the metrics from the termination message of the vegeta container of a terminated pod
+func podMetrics(pod *corev1.Pod) (*vegetaMetrics, error) {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == containerName && cs.State.Terminated != nil {
+			return parseMetrics(cs.State.Terminated.Message)
+		}
+	}
+	return nil, fmt.Errorf("No terminated %s container in pod %s", containerName, pod.Name)
+}
+
+// metricsFromPods merges the metrics of the reports written by the provided pods
+func metricsFromPods(pods []*corev1.Pod) (*vegetaMetrics, error) {
+	metrics := []*vegetaMetrics{}
+	for _, pod := range pods {
+		m, err := podMetrics(pod)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("No report available")
+	}
+	return mergeMetrics(metrics), nil
+}
+
+// completeWithResults publishes the results of the reports written by the provided pods and evaluates the thresholds against them.
+// The phase is set to completed, or to failed if a threshold is breached or the results needed for evaluating the thresholds are not available.
+// When the attack has been aborted the phase is set to aborted and the thresholds are not evaluated against the partial results.
+// The returned error only reports that the results could not be retrieved.
+func completeWithResults(v *vegetav1alpha1.Vegeta, pods []*corev1.Pod) error {
+	m, err := metricsFromPods(pods)
+	if v.Status.AbortedAt != nil {
+		if err == nil {
+			v.Status.Results = m.toResults()
+		}
+		setAborted(v)
+		return err
+	}
+	if err != nil {
+		if len(v.Spec.Thresholds) > 0 {
+			msg := fmt.Sprintf("The thresholds could not be evaluated: %v", err)
+			setCondition(v, vegetav1alpha1.ThresholdsMetCondition, metav1.ConditionFalse, vegetav1alpha1.ResultsUnavailableReason, msg)
+			setPhase(v, vegetav1alpha1.FailedPhase, vegetav1alpha1.ResultsUnavailableReason, msg)
+		} else {
+			setPhase(v, vegetav1alpha1.CompletedPhase, "", "")
+		}
+		return err
+	}
+	v.Status.Results = m.toResults()
+	if len(v.Spec.Thresholds) == 0 {
+		setPhase(v, vegetav1alpha1.CompletedPhase, "", "")
+		return nil
+	}
+	if breaches := evaluateThresholds(m, v.Spec.Thresholds, requestedRate(v, m)); len(breaches) > 0 {
+		msg := "Thresholds breached: " + strings.Join(breaches, ", ")
+		setCondition(v, vegetav1alpha1.ThresholdsMetCondition, metav1.ConditionFalse, vegetav1alpha1.ThresholdsBreachedReason, msg)
+		setPhase(v, vegetav1alpha1.FailedPhase, vegetav1alpha1.ThresholdsBreachedReason, msg)
+		return nil
+	}
+	setCondition(v, vegetav1alpha1.ThresholdsMetCondition, metav1.ConditionTrue, vegetav1alpha1.AllThresholdsMetReason, fmt.Sprintf("The results satisfy the %d thresholds", len(v.Spec.Thresholds)))
+	setPhase(v, vegetav1alpha1.CompletedPhase, "", "")
+	return nil
+}
+
+// mergeMetrics consolidates the metrics of attacks run in parallel.
+// Counters and rates are summed up, the mean latency is weighted by the number of requests and percentiles are the highest values reported.
+func mergeMetrics(metrics []*vegetaMetrics) *vegetaMetrics {
+	if len(metrics) == 1 {
+		return metrics[0]
+	}
+	merged := &vegetaMetrics{StatusCodes: map[string]uint64{}}
+	var successes float64
+	seenErrors := map[string]bool{}
+	for i, m := range metrics {
+		merged.Requests += m.Requests
+		merged.Rate += m.Rate
+		merged.Throughput += m.Throughput
+		successes += m.Success * float64(m.Requests)
+		merged.BytesIn.Total += m.BytesIn.Total
+		merged.BytesOut.Total += m.BytesOut.Total
+		merged.Latencies.Total += m.Latencies.Total
+		if i == 0 || m.Latencies.Min < merged.Latencies.Min {
+			merged.Latencies.Min = m.Latencies.Min
+		}
+		merged.Latencies.P50 = maxDuration(merged.Latencies.P50, m.Latencies.P50)
+		merged.Latencies.P90 = maxDuration(merged.Latencies.P90, m.Latencies.P90)
+		merged.Latencies.P95 = maxDuration(merged.Latencies.P95, m.Latencies.P95)
+		merged.Latencies.P99 = maxDuration(merged.Latencies.P99, m.Latencies.P99)
+		merged.Latencies.Max = maxDuration(merged.Latencies.Max, m.Latencies.Max)
+		merged.Duration = maxDuration(merged.Duration, m.Duration)
+		merged.Wait = maxDuration(merged.Wait, m.Wait)
+		for code, count := range m.StatusCodes {
+			merged.StatusCodes[code] += count
+		}
+		for _, e := range m.Errors {
+			if !seenErrors[e] {
+				seenErrors[e] = true
+				merged.Errors = append(merged.Errors, e)
+			}
+		}
+	}
+	if merged.Requests > 0 {
+		merged.Success = successes / float64(merged.Requests)
+		merged.Latencies.Mean = merged.Latencies.Total / time.Duration(merged.Requests)
+	}
+	return merged
+}
+
+// toResults converts the vegeta metrics into their API representation
+func (m *vegetaMetrics) toResults() *vegetav1alpha1.AttackResults {
+	duration := func(d time.Duration) *metav1.Duration {
+		return &metav1.Duration{Duration: d}
+	}
+	results := &vegetav1alpha1.AttackResults{
+		Requests:   m.Requests,
+		Rate:       strconv.FormatFloat(m.Rate, 'f', 2, 64),
+		Throughput: strconv.FormatFloat(m.Throughput, 'f', 2, 64),
+		Success:    strconv.FormatFloat(m.Success, 'f', 4, 64),
+		Duration:   duration(m.Duration),
+		Wait:       duration(m.Wait),
+		Latencies: &vegetav1alpha1.LatencyResults{
+			Min:  duration(m.Latencies.Min),
+			Mean: duration(m.Latencies.Mean),
+			P50:  duration(m.Latencies.P50),
+			P90:  duration(m.Latencies.P90),
+			P95:  duration(m.Latencies.P95),
+			P99:  duration(m.Latencies.P99),
+			Max:  duration(m.Latencies.Max),
+		},
+		BytesIn:     m.BytesIn.Total,
+		BytesOut:    m.BytesOut.Total,
+		StatusCodes: m.StatusCodes,
+		Errors:      m.Errors,
+	}
+	if len(results.Errors) > maxErrors {
+		results.Errors = results.Errors[:maxErrors]
+	}
+	return results
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
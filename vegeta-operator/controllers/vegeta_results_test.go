@@ -0,0 +1,301 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+)
+
+// testReport is a report as generated by vegeta report -type json
+const testReport = `{"latencies":{"total":2500000000,"mean":5000000,"50th":4000000,"90th":8000000,"95th":10000000,"99th":12000000,"max":20000000,"min":1000000},
+"bytes_in":{"total":10000,"mean":20},"bytes_out":{"total":0,"mean":0},
+"earliest":"2021-03-01T10:00:00Z","latest":"2021-03-01T10:00:10Z","end":"2021-03-01T10:00:10.005Z",
+"duration":10000000000,"wait":5000000,"requests":500,"rate":50.0,"throughput":49.87,"success":0.998,
+"status_codes":{"200":499,"503":1},"errors":["503 Service Unavailable"]}`
+
+var _ = Describe("Vegeta results", func() {
+	Context("When a json report is parsed", func() {
+		It("Should extract the metrics", func() {
+			m, err := parseMetrics(testReport)
+			Expect(err).ToNot(HaveOccurred())
+			results := m.toResults()
+			Expect(results.Requests).Should(Equal(uint64(500)))
+			Expect(results.Rate).Should(Equal("50.00"))
+			Expect(results.Throughput).Should(Equal("49.87"))
+			Expect(results.Success).Should(Equal("0.9980"))
+			Expect(results.Duration.Duration).Should(Equal(10 * time.Second))
+			Expect(results.Latencies.Min.Duration).Should(Equal(time.Millisecond))
+			Expect(results.Latencies.P95.Duration).Should(Equal(10 * time.Millisecond))
+			Expect(results.BytesIn).Should(Equal(uint64(10000)))
+			Expect(results.StatusCodes).Should(HaveKeyWithValue("200", uint64(499)))
+			Expect(results.Errors).Should(ConsistOf("503 Service Unavailable"))
+		})
+		It("Should fail on a truncated report", func() {
+			_, err := parseMetrics(testReport[:100])
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("When the records are written into the termination message", func() {
+		It("Should pass them through the recorder, which makes them fit", func() {
+			vegeta := newVegeta("records")
+			cmd := getAttackCmd(vegeta)
+			Expect(cmd).To(HaveSuffix(" | tee /results/00010101000000-${HOSTNAME}_res.gob | vegeta report"))
+			// The exit status of the attack is captured before the json report gets written
+			Expect(getAttackRecordCmd(vegeta)).To(Equal("; rc=$?; vegeta report -type json -output /tmp/vegeta-report.json /results/00010101000000-${HOSTNAME}_res.gob; " +
+				"recorder -output /dev/termination-log -max-bytes 4096 -max-errors 10 -report /tmp/vegeta-report.json; exit $rc"))
+
+			// The report of stored results is written by the report pod
+			vegeta.Spec.Report = &vegetav1alpha1.ReportSpec{OutputType: vegetav1alpha1.PvcOutput, OutputClaim: "results"}
+			Expect(getAttackRecordCmd(vegeta)).To(BeEmpty())
+			Expect(getReportCmd(vegeta)).To(HaveSuffix(" /results/00010101000000-records*_res.*; rc=$?; vegeta report -type json -output /tmp/vegeta-report.json /results/00010101000000-records*_res.*; " +
+				"recorder -output /dev/termination-log -max-bytes 4096 -max-errors 10 -report /tmp/vegeta-report.json; exit $rc"))
+
+			// The results are uploaded to the bucket after the exit status has been captured
+			vegeta.Spec.Report = &vegetav1alpha1.ReportSpec{OutputType: vegetav1alpha1.ObcOutput, OutputClaim: "results"}
+			Expect(getAttackCmd(vegeta)).ToNot(ContainSubstring("s3"))
+			Expect(getAttackRecordCmd(vegeta)).To(Equal("; rc=$?; s3 -command upload; exit $rc"))
+			Expect(getReportCmd(vegeta)).To(HavePrefix("s3 -command download; vegeta report "))
+			Expect(getReportCmd(vegeta)).To(HaveSuffix(" -report /tmp/vegeta-report.json; s3 -command upload; exit $rc"))
+		})
+		It("Should keep the exit status of a failed report as the one of the report pod", func() {
+			if _, err := exec.LookPath("sh"); err != nil {
+				Skip("no shell available")
+			}
+			// Stubs of the commands of the image: vegeta report fails unless it writes the json report, the other commands succeed
+			bin, err := ioutil.TempDir("", "vegeta-bin")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(bin)
+			stubs := map[string]string{
+				"vegeta":   "#!/bin/sh\ncase \"$*\" in *'-type json'*) exit 0;; esac\nexit \"${VEGETA_RC:-0}\"\n",
+				"recorder": "#!/bin/sh\nexit 0\n",
+				"s3":       "#!/bin/sh\nexit 0\n",
+			}
+			for name, content := range stubs {
+				Expect(ioutil.WriteFile(filepath.Join(bin, name), []byte(content), 0755)).To(Succeed())
+			}
+			run := func(cmd, rc string) int {
+				c := exec.Command("sh", "-c", cmd)
+				c.Env = []string{"PATH=" + bin + ":/usr/bin:/bin", "VEGETA_RC=" + rc}
+				err := c.Run()
+				if exitErr, ok := err.(*exec.ExitError); ok {
+					return exitErr.ExitCode()
+				}
+				Expect(err).ToNot(HaveOccurred())
+				return 0
+			}
+			vegeta := newVegeta("failed-report")
+			vegeta.Spec.Report = &vegetav1alpha1.ReportSpec{OutputType: vegetav1alpha1.ObcOutput, OutputClaim: "results", Buckets: "[0,bad]"}
+			Expect(run(getReportCmd(vegeta), "1")).To(Equal(1))
+			Expect(run(getReportCmd(vegeta), "0")).To(Equal(0))
+			// The same goes for the report generated by the attack pods
+			vegeta.Spec.Report = nil
+			Expect(run("vegeta attack"+getAttackRecordCmd(vegeta), "2")).To(Equal(2))
+		})
+		It("Should fail the run when the report pod has failed", func() {
+			vegeta := newVegeta("failed-report")
+			vegeta.UID = "failed-report-uid"
+			vegeta.Spec.Report = &vegetav1alpha1.ReportSpec{OutputType: vegetav1alpha1.PvcOutput, OutputClaim: "results"}
+			vegeta.Status.Phase = vegetav1alpha1.SucceededPhase
+			owner := []metav1.OwnerReference{*metav1.NewControllerRef(vegeta, vegetav1alpha1.GroupVersion.WithKind("Vegeta"))}
+			attack := terminatedWith("failed-report-0", "")
+			attack.Namespace, attack.OwnerReferences = TestNs, owner
+			attack.Labels = map[string]string{"vegeta.testing.io/type": "attack", replicaLabel: "0"}
+			attack.Status.Phase = corev1.PodSucceeded
+			report := terminatedWith("failed-report-report", "")
+			report.Namespace, report.OwnerReferences = TestNs, owner
+			report.Labels = map[string]string{"vegeta.testing.io/type": "report"}
+			report.Status.Phase = corev1.PodFailed
+			report.Status.ContainerStatuses[0].State.Terminated.ExitCode = 1
+			r := newTargetRefReconciler(vegeta, attack, report)
+			r.Log = ctrl.Log.WithName("controllers").WithName("Vegeta")
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "failed-report", Namespace: TestNs}}
+			// The status first reflects the pods, then the report pod gets checked
+			for i := 0; i < 2; i++ {
+				_, err := r.Reconcile(context.Background(), req)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(r.Get(context.Background(), req.NamespacedName, vegeta)).To(Succeed())
+			Expect(vegeta.Status.Phase).To(Equal(vegetav1alpha1.FailedPhase))
+			cond := meta.FindStatusCondition(vegeta.Status.Conditions, vegetav1alpha1.ReportGeneratedCondition)
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(vegetav1alpha1.ReportPodFailedReason))
+		})
+	})
+
+	Context("When the reports of several pods are merged", func() {
+		It("Should sum the counters and keep the highest percentiles", func() {
+			m1, err := parseMetrics(testReport)
+			Expect(err).ToNot(HaveOccurred())
+			m2, err := parseMetrics(testReport)
+			Expect(err).ToNot(HaveOccurred())
+			m2.Success = 1
+			m2.Latencies.P99 = 30 * time.Millisecond
+			m2.Latencies.Min = 500 * time.Microsecond
+			m2.StatusCodes = map[string]uint64{"200": 500}
+			m2.Errors = nil
+			results := mergeMetrics([]*vegetaMetrics{m1, m2}).toResults()
+			Expect(results.Requests).Should(Equal(uint64(1000)))
+			Expect(results.Rate).Should(Equal("100.00"))
+			Expect(results.Success).Should(Equal("0.9990"))
+			Expect(results.Latencies.Mean.Duration).Should(Equal(5 * time.Millisecond))
+			Expect(results.Latencies.P99.Duration).Should(Equal(30 * time.Millisecond))
+			Expect(results.Latencies.Min.Duration).Should(Equal(500 * time.Microsecond))
+			Expect(results.StatusCodes).Should(HaveKeyWithValue("200", uint64(999)))
+			Expect(results.StatusCodes).Should(HaveKeyWithValue("503", uint64(1)))
+			Expect(results.Errors).Should(HaveLen(1))
+		})
+	})
+	Context("When the spec has been defaulted by the webhook", func() {
+		It("Should collect the results of the attack pods without a report pod", func() {
+			vegeta := newVegeta("defaulted")
+			vegeta.UID = "defaulted-uid"
+			vegeta.Spec.Thresholds = []string{"p99 < 250ms"}
+			// envtest does not run the webhooks, the defaults are applied here
+			vegeta.Default()
+			Expect(vegeta.Spec.Report.OutputType).To(Equal(vegetav1alpha1.StdoutOutput))
+			vegeta.Status.Phase = vegetav1alpha1.RunningPhase
+			pod := terminatedWith("defaulted-0", testReport)
+			pod.Namespace = TestNs
+			pod.Labels = map[string]string{"vegeta.testing.io/type": "attack"}
+			pod.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(vegeta, vegetav1alpha1.GroupVersion.WithKind("Vegeta"))}
+			pod.Status.Phase = corev1.PodSucceeded
+			r := newTargetRefReconciler(vegeta, pod)
+			r.Log = ctrl.Log.WithName("controllers").WithName("Vegeta")
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "defaulted", Namespace: TestNs}}
+			for i := 0; i < 3; i++ {
+				_, err := r.Reconcile(context.Background(), req)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(r.Get(context.Background(), req.NamespacedName, vegeta)).To(Succeed())
+			Expect(vegeta.Status.Phase).To(Equal(vegetav1alpha1.CompletedPhase))
+			Expect(vegeta.Status.Results).ToNot(BeNil())
+			Expect(vegeta.Status.Results.Requests).To(Equal(uint64(500)))
+			Expect(meta.IsStatusConditionTrue(vegeta.Status.Conditions, vegetav1alpha1.ThresholdsMetCondition)).To(BeTrue())
+			pods := &corev1.PodList{}
+			Expect(r.List(context.Background(), pods)).To(Succeed())
+			Expect(pods.Items).To(HaveLen(1))
+		})
+	})
+})
+
+var _ = Describe("Vegeta thresholds", func() {
+	var metrics *vegetaMetrics
+
+	BeforeEach(func() {
+		var err error
+		metrics, err = parseMetrics(testReport)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Context("When thresholds are evaluated", func() {
+		It("Should pass when the results satisfy them", func() {
+			Expect(evaluateThresholds(metrics, []string{
+				"p99 < 250ms",
+				"max <= 20ms",
+				"success >= 99.8%",
+				"status 5xx < 0.5%",
+				"status 200 >= 0.99",
+				"throughput >= 0.95 * rate",
+				"requests == 500",
+			}, 50)).Should(BeEmpty())
+		})
+		It("Should report the breached thresholds with the actual values", func() {
+			breaches := evaluateThresholds(metrics, []string{
+				"p99 < 10ms",
+				"success >= 99.9%",
+				"status 5xx < 0.1%",
+				"throughput >= 0.9999*rate",
+			}, 50)
+			Expect(breaches).Should(Equal([]string{
+				"p99 < 10ms (actual: 12ms)",
+				"success >= 99.9% (actual: 99.8%)",
+				"status 5xx < 0.1% (actual: 0.2%)",
+				"throughput >= 0.9999*rate (actual: 49.87)",
+			}))
+		})
+	})
+
+	Context("When the throughput is compared to the rate", func() {
+		It("Should use the requested rate rather than the measured one", func() {
+			vegeta := newVegeta("requested")
+			vegeta.Spec.Attack.Rate = "100/1s"
+			// The attack could not keep up with the requested rate, vegeta measures the rate of the requests actually sent
+			Expect(requestedRate(vegeta, metrics)).Should(Equal(100.0))
+			Expect(evaluateThresholds(metrics, []string{"throughput >= 0.95 * rate"}, requestedRate(vegeta, metrics))).Should(Equal([]string{
+				"throughput >= 0.95 * rate (actual: 49.87)",
+			}))
+
+			vegeta.Spec.Replicas = 3
+			Expect(requestedRate(vegeta, metrics)).Should(Equal(300.0))
+			vegeta.Spec.RateMode = vegetav1alpha1.TotalRate
+			vegeta.Status.ReplicaRates = getReplicaRates(vegeta)
+			Expect(vegeta.Status.ReplicaRates).Should(Equal([]string{"34/1s", "33/1s", "33/1s"}))
+			Expect(requestedRate(vegeta, metrics)).Should(Equal(100.0))
+		})
+		It("Should fall back to the measured rate when no constant rate has been requested", func() {
+			vegeta := newVegeta("measured")
+			vegeta.Spec.Attack.Rate = "0"
+			Expect(requestedRate(vegeta, metrics)).Should(Equal(50.0))
+			vegeta.Spec.Attack.Rate = ""
+			vegeta.Spec.Attack.Stages = []vegetav1alpha1.Stage{{Duration: "10s", Rate: "100/1s"}}
+			Expect(requestedRate(vegeta, metrics)).Should(Equal(50.0))
+		})
+	})
+
+	Context("When the processing completes", func() {
+		It("Should fail the vegeta resource if a threshold is breached", func() {
+			vegeta := newVegeta("thresholds")
+			vegeta.Spec.Thresholds = []string{"p99 < 10ms"}
+			pod := &corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  containerName,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: testReport}},
+			}}}}
+			Expect(completeWithResults(vegeta, []*corev1.Pod{pod})).To(Succeed())
+			Expect(vegeta.Status.Phase).Should(Equal(vegetav1alpha1.FailedPhase))
+			Expect(vegeta.Status.Results).ToNot(BeNil())
+			Expect(meta.IsStatusConditionFalse(vegeta.Status.Conditions, vegetav1alpha1.ThresholdsMetCondition)).Should(BeTrue())
+			Expect(meta.IsStatusConditionTrue(vegeta.Status.Conditions, vegetav1alpha1.FailedCondition)).Should(BeTrue())
+
+			vegeta.Spec.Thresholds = []string{"p99 < 250ms"}
+			vegeta.Status = vegetav1alpha1.VegetaStatus{}
+			Expect(completeWithResults(vegeta, []*corev1.Pod{pod})).To(Succeed())
+			Expect(vegeta.Status.Phase).Should(Equal(vegetav1alpha1.CompletedPhase))
+			Expect(meta.IsStatusConditionTrue(vegeta.Status.Conditions, vegetav1alpha1.ThresholdsMetCondition)).Should(BeTrue())
+		})
+		It("Should fail the vegeta resource if the results are not available for the thresholds", func() {
+			vegeta := newVegeta("noresults")
+			vegeta.Spec.Thresholds = []string{"p99 < 250ms"}
+			Expect(completeWithResults(vegeta, []*corev1.Pod{{}})).NotTo(Succeed())
+			Expect(vegeta.Status.Phase).Should(Equal(vegetav1alpha1.FailedPhase))
+		})
+	})
+})
@@ -0,0 +1,186 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// runLabel is set on the pods of a run with its ID, unless it is empty
+	runLabel = "vegeta.testing.io/run"
+	// vegetaDeletedReason means that a Vegeta resource created by a suite or a matrix has been deleted before its outcome could be recorded
+	vegetaDeletedReason = "VegetaDeleted"
+	// vegetaNotControlledReason means that the Vegeta resource a suite or a matrix was about to create already exists and belongs to something else
+	vegetaNotControlledReason = "VegetaNotControlled"
+)
+
+// currentRun returns the ID of the run the pods and the status of the vegeta resource belong to.
+// The run of the specification only becomes the current one once the processing of the previous run has terminated.
+func currentRun(v *vegetav1alpha1.Vegeta) string {
+	if v.Status.Phase == "" {
+		return v.Spec.RunID
+	}
+	return v.Status.RunID
+}
+
+// isNewRunRequested returns true when a new run has been specified and the processing of the current one has terminated
+func isNewRunRequested(v *vegetav1alpha1.Vegeta) bool {
+	return v.Spec.RunID != v.Status.RunID && v.Status.Phase.IsTerminated()
+}
+
+// startNewRun moves the summary of the terminated run into the history and resets the status for the new run
+func startNewRun(v *vegetav1alpha1.Vegeta) {
+	// Resources that have not been defaulted by the webhook get the same default
+	limit := vegetav1alpha1.DefaultHistoryLimit
+	if v.Spec.HistoryLimit != nil {
+		limit = int(*v.Spec.HistoryLimit)
+	}
+	history := append([]vegetav1alpha1.RunSummary{summaryOf(v)}, v.Status.History...)
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	if len(history) == 0 {
+		history = nil
+	}
+	v.Status = vegetav1alpha1.VegetaStatus{
+		RunID:   v.Spec.RunID,
+		History: history,
+	}
+}
+
+// summaryOf summarizes the outcome of the current run
+func summaryOf(v *vegetav1alpha1.Vegeta) vegetav1alpha1.RunSummary {
+	summary := vegetav1alpha1.RunSummary{
+		RunID:     v.Status.RunID,
+		Phase:     v.Status.Phase,
+		StartTime: v.Status.StartTime,
+		Results:   v.Status.Results,
+	}
+	condType := vegetav1alpha1.ReadyCondition
+	if v.Status.Phase == vegetav1alpha1.FailedPhase {
+		condType = vegetav1alpha1.FailedCondition
+	}
+	if c := meta.FindStatusCondition(v.Status.Conditions, condType); c != nil {
+		summary.Reason = c.Reason
+		summary.Message = c.Message
+	}
+	summary.CompletionTime = finishedAt(v)
+	if isStoredOutput(v) {
+		summary.ResultPrefix = getResultBaseName(v)
+	}
+	return summary
+}
+
+// childSummary summarizes the outcome of a Vegeta resource created by a suite or a matrix.
+// Resources that have not been processed yet are reported as pending, which records that they have been created.
+func childSummary(v *vegetav1alpha1.Vegeta) vegetav1alpha1.RunSummary {
+	summary := summaryOf(v)
+	if summary.Phase == "" {
+		summary.Phase = vegetav1alpha1.PendingPhase
+	}
+	return summary
+}
+
+// deletedSummary is the summary of a Vegeta resource that has been deleted before its outcome could be recorded, e.g. by a time to live of 0.
+// It is not created again, as it may have run already.
+func deletedSummary(name string, now time.Time) vegetav1alpha1.RunSummary {
+	return vegetav1alpha1.RunSummary{
+		Phase:          vegetav1alpha1.FailedPhase,
+		Reason:         vegetaDeletedReason,
+		Message:        fmt.Sprintf("The Vegeta resource %s has been deleted before its outcome could be recorded", name),
+		CompletionTime: &metav1.Time{Time: now},
+	}
+}
+
+// notControlledSummary is the summary of a Vegeta resource that could not be created as a resource with the same name and another owner already exists
+func notControlledSummary(name, ownerKind string, now time.Time) vegetav1alpha1.RunSummary {
+	return vegetav1alpha1.RunSummary{
+		Phase:          vegetav1alpha1.FailedPhase,
+		Reason:         vegetaNotControlledReason,
+		Message:        fmt.Sprintf("The Vegeta resource %s already exists and is not controlled by the %s", name, ownerKind),
+		CompletionTime: &metav1.Time{Time: now},
+	}
+}
+
+// createChild creates a Vegeta resource of a suite or a matrix. It returns false when a resource with the same name already exists and is not controlled by the owner.
+// The existing resource is read with the uncached reader as the cache may not have caught up with a previous creation yet.
+func createChild(ctx context.Context, c client.Client, reader client.Reader, owner metav1.Object, vegeta *vegetav1alpha1.Vegeta) (bool, error) {
+	err := c.Create(ctx, vegeta)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+	existing := &vegetav1alpha1.Vegeta{}
+	if err := reader.Get(ctx, client.ObjectKeyFromObject(vegeta), existing); err != nil {
+		return false, err
+	}
+	return metav1.IsControlledBy(existing, owner), nil
+}
+
+// addMissingChildren reads with the uncached reader the Vegeta resources recorded as created that are not in the cache yet, so that they are not taken for deleted ones.
+// Resources that are not controlled by the owner are left out.
+func addMissingChildren(ctx context.Context, reader client.Reader, owner metav1.Object, names []string, children map[string]*vegetav1alpha1.Vegeta) error {
+	for _, name := range names {
+		if _, ok := children[name]; ok {
+			continue
+		}
+		vegeta := &vegetav1alpha1.Vegeta{}
+		if err := reader.Get(ctx, client.ObjectKey{Namespace: owner.GetNamespace(), Name: name}, vegeta); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("Unable to get the Vegeta resource %s: %v", name, err)
+		}
+		if metav1.IsControlledBy(vegeta, owner) {
+			children[name] = vegeta
+		}
+	}
+	return nil
+}
+
+// removePreviousRuns deletes the pods of the previous runs and keeps the pods of the current run in the list
+func (r *VegetaReconciler) removePreviousRuns(ctx context.Context, v *vegetav1alpha1.Vegeta, childPods *corev1.PodList) error {
+	run := currentRun(v)
+	current := childPods.Items[:0]
+	for i := range childPods.Items {
+		pod := &childPods.Items[i]
+		if pod.Labels[runLabel] == run {
+			current = append(current, *pod)
+			continue
+		}
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if err := r.Delete(ctx, pod, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("Unable to delete pod %s of a previous run: %v", pod.Name, err)
+		}
+	}
+	childPods.Items = current
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Vegeta runs", func() {
+	Context("When a new run is specified", func() {
+		It("Should only start it once the current run has terminated", func() {
+			vegeta := newVegeta("rerun-running")
+			vegeta.Spec.RunID = "2"
+			vegeta.Status.RunID = "1"
+			vegeta.Status.Phase = vegetav1alpha1.RunningPhase
+			Expect(isNewRunRequested(vegeta)).Should(BeFalse())
+			Expect(currentRun(vegeta)).Should(Equal("1"))
+			vegeta.Status.Phase = vegetav1alpha1.CompletedPhase
+			Expect(isNewRunRequested(vegeta)).Should(BeTrue())
+		})
+		It("Should keep the summary of the previous runs up to the limit", func() {
+			vegeta := newVegeta("rerun")
+			limit := int32(2)
+			vegeta.Spec.HistoryLimit = &limit
+			vegeta.Spec.Report = &vegetav1alpha1.ReportSpec{OutputType: vegetav1alpha1.PvcOutput, OutputClaim: "results"}
+			vegeta.CreationTimestamp = metav1.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+			start := metav1.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)
+			for _, run := range []string{"1", "2", "3"} {
+				vegeta.Spec.RunID = run
+				vegeta.Status.StartTime = &start
+				vegeta.Status.Results = &vegetav1alpha1.AttackResults{Requests: 100}
+				setPhase(vegeta, vegetav1alpha1.CompletedPhase, "", "")
+				Expect(isNewRunRequested(vegeta)).Should(BeTrue())
+				startNewRun(vegeta)
+				Expect(vegeta.Status.RunID).Should(Equal(run))
+				Expect(vegeta.Status.Phase).Should(BeEmpty())
+				Expect(vegeta.Status.Results).Should(BeNil())
+				Expect(vegeta.Status.Conditions).Should(BeEmpty())
+			}
+			Expect(vegeta.Status.History).Should(HaveLen(2))
+			last := vegeta.Status.History[0]
+			Expect(last.RunID).Should(Equal("2"))
+			Expect(last.Phase).Should(Equal(vegetav1alpha1.CompletedPhase))
+			Expect(last.Reason).Should(Equal(vegetav1alpha1.CompletedReason))
+			Expect(last.CompletionTime).ToNot(BeNil())
+			Expect(last.StartTime).ToNot(BeNil())
+			Expect(last.Results.Requests).Should(Equal(uint64(100)))
+			Expect(last.ResultPrefix).Should(Equal("20210102030405-2-20210103000000-rerun"))
+			Expect(vegeta.Status.History[1].RunID).Should(Equal("1"))
+		})
+	})
+
+	Context("When the pods of a run are generated", func() {
+		It("Should label them and prefix their files with the run", func() {
+			vegeta := newVegeta("run-pods")
+			vegeta.Spec.RunID = "nightly"
+			vegeta.CreationTimestamp = metav1.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+			s := runtime.NewScheme()
+			Expect(vegetav1alpha1.AddToScheme(s)).To(Succeed())
+			r := &VegetaReconciler{Scheme: s}
+			Expect(r.aPod4Attack(vegeta, 0).Labels).Should(HaveKeyWithValue(runLabel, "nightly"))
+			Expect(r.aPod4Report(vegeta).Labels).Should(HaveKeyWithValue(runLabel, "nightly"))
+			Expect(getResultBaseName(vegeta)).Should(Equal("20210102030405-nightly-run-pods"))
+			Expect(getResultFile(vegeta)).Should(Equal("/results/20210102030405-nightly-${HOSTNAME}_res.gob"))
+
+			vegeta.Spec.RunID = ""
+			Expect(r.aPod4Attack(vegeta, 0).Labels).ShouldNot(HaveKey(runLabel))
+			Expect(getResultBaseName(vegeta)).Should(Equal("20210102030405-run-pods"))
+		})
+		It("Should not mix their files with those of an earlier run with the same ID", func() {
+			vegeta := newVegeta("run-reused")
+			vegeta.Spec.RunID = "a"
+			vegeta.Status.RunID = "a"
+			vegeta.Spec.Report = &vegetav1alpha1.ReportSpec{OutputType: vegetav1alpha1.PvcOutput, OutputClaim: "results"}
+			vegeta.CreationTimestamp = metav1.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+			first := metav1.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)
+			vegeta.Status.StartTime = &first
+			setPhase(vegeta, vegetav1alpha1.CompletedPhase, "", "")
+			startNewRun(vegeta)
+			Expect(vegeta.Status.History[0].ResultPrefix).Should(Equal("20210102030405-a-20210103000000-run-reused"))
+
+			// The run ID a gets reused after the run b
+			for _, run := range []string{"b", "a"} {
+				vegeta.Spec.RunID = run
+				setPhase(vegeta, vegetav1alpha1.CompletedPhase, "", "")
+				startNewRun(vegeta)
+			}
+			second := metav1.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+			vegeta.Status.StartTime = &second
+			setPhase(vegeta, vegetav1alpha1.PendingPhase, "", "")
+			Expect(getResultBaseName(vegeta)).Should(Equal("20210102030405-a-20210104000000-run-reused"))
+			Expect(getReportCmd(vegeta)).ShouldNot(ContainSubstring("20210103000000"))
+		})
+		It("Should delete the pods of the previous runs", func() {
+			vegeta := newVegeta("run-cleanup")
+			vegeta.Spec.RunID = "2"
+			previous := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "previous", Namespace: TestNs, Labels: map[string]string{runLabel: "1"}}}
+			current := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: TestNs, Labels: map[string]string{runLabel: "2"}}}
+			r := &VegetaReconciler{Client: fake.NewClientBuilder().WithObjects(previous, current).Build()}
+			childPods := &corev1.PodList{Items: []corev1.Pod{*previous, *current}}
+			Expect(r.removePreviousRuns(context.Background(), vegeta, childPods)).To(Succeed())
+			Expect(childPods.Items).Should(HaveLen(1))
+			Expect(childPods.Items[0].Name).Should(Equal("current"))
+			remaining := &corev1.PodList{}
+			Expect(r.List(context.Background(), remaining, client.InNamespace(TestNs))).To(Succeed())
+			Expect(remaining.Items).Should(HaveLen(1))
+			Expect(remaining.Items[0].Name).Should(Equal("current"))
+		})
+	})
+})
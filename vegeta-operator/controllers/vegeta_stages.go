@@ -0,0 +1,210 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultStepDuration is the duration of the steps approximating linear and sine stages if not specified
+	defaultStepDuration = 10 * time.Second
+	// loadProfileRecordFile is where the attack container writes the record of the load profile, with the drift of its steps behind their schedule
+	loadProfileRecordFile = "/tmp/vegeta-load-profile"
+)
+
+// stageStep is a constant rate step of a load profile. An empty rate means a pause without request.
+type stageStep struct {
+	rate     string
+	duration time.Duration
+}
+
+// hasLoadProfile returns true when the attack follows a load profile made of stages
+func hasLoadProfile(v *vegetav1alpha1.Vegeta) bool {
+	return len(v.Spec.Attack.Stages) > 0
+}
+
+// getStagesCmd generates the commands running the stages of the load profile one after the other.
+// The results of each step are encoded in json so that they can be concatenated into a single results stream.
+// Each step is run by its own vegeta attack, which waits for the responses to its requests, up to the timeout of the attack, before it exits.
+// The steps therefore run until their end in the schedule of the profile rather than for their duration, so that the time waiting for the responses
+// is taken from the next step instead of delaying the rest of the profile. Steps whose end has already passed are skipped.
+// The largest delay of the start of a step behind its schedule is recorded as the drift of the profile.
+// The remaining steps are skipped once the abort of the attack has been requested.
+func getStagesCmd(veg *vegetav1alpha1.Vegeta) string {
+	cmds := []string{"profile_start=$(date +%s%3N)", "profile_drift=0"}
+	var start time.Duration
+	for _, stage := range veg.Spec.Attack.Stages {
+		for _, step := range stageSteps(&stage) {
+			end := start + step.duration
+			var cmd string
+			if step.rate == "" {
+				cmd = "sleep \"$(printf '%d.%03d' $(( left / 1000 )) $(( left % 1000 )))\""
+			} else {
+				// The duration is appended to the command line of vegeta attack, the last command of the pipeline, as it is only known when the step starts
+				args := append(getAttackArgs(veg), "-rate", step.rate)
+				cmd = getSingleAttackCmd(veg, args) + " -duration \"${left}ms\" | vegeta encode -to json"
+			}
+			cmds = append(cmds, getUnlessAbortedCmd(getStepScheduleCmd(start, end)+cmd+"; fi"))
+			start = end
+		}
+	}
+	cmds = append(cmds, "profile_rc=$?", getLoadProfileRecordCmd(), "(exit $profile_rc)")
+	return "{ " + strings.Join(cmds, "; ") + "; }"
+}
+
+// getStepScheduleCmd generates the command measuring the delay of a step behind its start in the schedule of the profile, which gets recorded as the drift
+// if it is the largest one so far. It opens the condition checking that the end of the step is still ahead, which is to be closed by the caller,
+// and sets the time left until then in milliseconds.
+func getStepScheduleCmd(start, end time.Duration) string {
+	s, e := strconv.FormatInt(start.Milliseconds(), 10), strconv.FormatInt(end.Milliseconds(), 10)
+	return "now=$(( $(date +%s%3N) - profile_start )); " +
+		"if [ $(( now - " + s + " )) -gt \"$profile_drift\" ]; then profile_drift=$(( now - " + s + " )); fi; " +
+		"if [ \"$now\" -lt " + e + " ]; then left=$(( " + e + " - now )); "
+}
+
+// getLoadProfileRecordCmd generates the command writing the record of the load profile for the recorder, see getRecordCmd
+func getLoadProfileRecordCmd() string {
+	return "printf '{\"loadProfile\":{\"drift\":\"%dms\"}}\\n' \"$profile_drift\" > " + loadProfileRecordFile
+}
+
+// stageSteps splits a stage into constant rate steps.
+// Linear and sine stages are split into steps of StepDuration, whose rate is the one of the shape in the middle of the step.
+// The specification is expected to have been validated by the admission webhook: steps are skipped for values that cannot be parsed.
+func stageSteps(stage *vegetav1alpha1.Stage) []stageStep {
+	duration, err := time.ParseDuration(stage.Duration)
+	if err != nil || duration <= 0 {
+		return nil
+	}
+	if stage.Shape == "" || stage.Shape == vegetav1alpha1.ConstantShape {
+		return []stageStep{{rate: stage.Rate, duration: duration}}
+	}
+
+	stepDuration := defaultStepDuration
+	if d, err := time.ParseDuration(stage.StepDuration); err == nil && d > 0 {
+		stepDuration = d
+	}
+	rate, err := vegetav1alpha1.RatePerSecond(stage.Rate)
+	if err != nil {
+		return nil
+	}
+
+	var rateAt func(t time.Duration) float64
+	switch stage.Shape {
+	case vegetav1alpha1.LinearShape:
+		target, err := vegetav1alpha1.RatePerSecond(stage.TargetRate)
+		if err != nil {
+			return nil
+		}
+		rateAt = func(t time.Duration) float64 {
+			return rate + (target-rate)*t.Seconds()/duration.Seconds()
+		}
+	case vegetav1alpha1.SineShape:
+		amplitude, err := vegetav1alpha1.RatePerSecond(stage.Amplitude)
+		if err != nil {
+			return nil
+		}
+		period, err := time.ParseDuration(stage.Period)
+		if err != nil || period <= 0 {
+			return nil
+		}
+		rateAt = func(t time.Duration) float64 {
+			return rate + amplitude*math.Sin(2*math.Pi*t.Seconds()/period.Seconds())
+		}
+	default:
+		return nil
+	}
+
+	var steps []stageStep
+	for start := time.Duration(0); start < duration; start += stepDuration {
+		d := stepDuration
+		if start+d > duration {
+			d = duration - start
+		}
+		steps = append(steps, stageStep{rate: formatRate(rateAt(start + d/2)), duration: d})
+	}
+	return steps
+}
+
+// formatRate formats a number of requests per second with the freq/duration format of vegeta.
+// Requests per minute are used for a better precision with low rates. An empty string is returned if no request is to be sent.
+func formatRate(perSecond float64) string {
+	perMinute := int64(math.Round(perSecond * 60))
+	if perMinute <= 0 {
+		return ""
+	}
+	if perMinute%60 == 0 {
+		return strconv.FormatInt(perMinute/60, 10) + "/1s"
+	}
+	return strconv.FormatInt(perMinute, 10) + "/1m"
+}
+
+// podLoadProfileDrift extracts the drift of the load profile from the termination message of a terminated attack pod, nil if it has not been recorded
+func podLoadProfileDrift(pod *corev1.Pod) *time.Duration {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name != containerName || cs.State.Terminated == nil {
+			continue
+		}
+		// The termination message is a sequence of json documents, see getRecordCmd
+		dec := json.NewDecoder(strings.NewReader(cs.State.Terminated.Message))
+		for {
+			var record struct {
+				LoadProfile *struct {
+					Drift string `json:"drift"`
+				} `json:"loadProfile"`
+			}
+			if err := dec.Decode(&record); err != nil {
+				return nil
+			}
+			if record.LoadProfile != nil {
+				drift, err := time.ParseDuration(record.LoadProfile.Drift)
+				if err != nil {
+					return nil
+				}
+				return &drift
+			}
+		}
+	}
+	return nil
+}
+
+// recordLoadProfileDrift records the largest drift of the load profile of the attack pods in the status once they have all terminated.
+// It returns true if the drift has been recorded.
+func recordLoadProfileDrift(v *vegetav1alpha1.Vegeta, attackPods []*corev1.Pod) bool {
+	if !hasLoadProfile(v) || v.Status.LoadProfileDrift != nil || !attackTerminated(attackPods) {
+		return false
+	}
+	var drift *time.Duration
+	for _, pod := range attackPods {
+		if d := podLoadProfileDrift(pod); d != nil && (drift == nil || *d > *drift) {
+			drift = d
+		}
+	}
+	if drift == nil {
+		return false
+	}
+	v.Status.LoadProfileDrift = &metav1.Duration{Duration: *drift}
+	return true
+}
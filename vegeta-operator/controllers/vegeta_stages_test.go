@@ -0,0 +1,166 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Vegeta stages", func() {
+	Context("When a stage is split into steps", func() {
+		It("Should keep a constant stage as a single step", func() {
+			steps := stageSteps(&vegetav1alpha1.Stage{Duration: "10m", Rate: "500/1s"})
+			Expect(steps).Should(Equal([]stageStep{{rate: "500/1s", duration: 10 * time.Minute}}))
+		})
+		It("Should approximate a linear ramp", func() {
+			steps := stageSteps(&vegetav1alpha1.Stage{
+				Shape:        vegetav1alpha1.LinearShape,
+				Duration:     "5m",
+				Rate:         "10/1s",
+				TargetRate:   "510/1s",
+				StepDuration: "1m",
+			})
+			Expect(steps).Should(Equal([]stageStep{
+				{rate: "60/1s", duration: time.Minute},
+				{rate: "160/1s", duration: time.Minute},
+				{rate: "260/1s", duration: time.Minute},
+				{rate: "360/1s", duration: time.Minute},
+				{rate: "460/1s", duration: time.Minute},
+			}))
+		})
+		It("Should shorten the last step to the duration of the stage", func() {
+			steps := stageSteps(&vegetav1alpha1.Stage{
+				Shape:      vegetav1alpha1.LinearShape,
+				Duration:   "25s",
+				Rate:       "1/1s",
+				TargetRate: "1/1s",
+			})
+			Expect(steps).Should(HaveLen(3))
+			Expect(steps[2]).Should(Equal(stageStep{rate: "1/1s", duration: 5 * time.Second}))
+		})
+		It("Should approximate a sine wave and pause when no request is to be sent", func() {
+			steps := stageSteps(&vegetav1alpha1.Stage{
+				Shape:        vegetav1alpha1.SineShape,
+				Duration:     "4m",
+				Rate:         "10/1s",
+				Amplitude:    "10/1s",
+				Period:       "4m",
+				StepDuration: "2m",
+			})
+			Expect(steps).Should(Equal([]stageStep{
+				{rate: "20/1s", duration: 2 * time.Minute},
+				{rate: "", duration: 2 * time.Minute},
+			}))
+		})
+		It("Should use requests per minute for low rates", func() {
+			Expect(formatRate(0.5)).Should(Equal("30/1m"))
+			Expect(formatRate(2)).Should(Equal("2/1s"))
+			Expect(formatRate(0.001)).Should(Equal(""))
+		})
+	})
+
+	Context("When the attack command of a load profile is generated", func() {
+		It("Should run the steps one after the other into a single json results stream", func() {
+			vegeta := newVegeta("stages")
+			vegeta.Spec.Attack.Duration = ""
+			vegeta.Spec.Attack.Rate = ""
+			vegeta.Spec.Attack.Stages = []vegetav1alpha1.Stage{
+				{Duration: "1m", Rate: "10/1s"},
+				{Shape: vegetav1alpha1.SineShape, Duration: "2m", Rate: "5/1s", Amplitude: "5/1s", Period: "2m", StepDuration: "1m"},
+			}
+			vegeta.Spec.Report = &vegetav1alpha1.ReportSpec{OutputType: vegetav1alpha1.PvcOutput, OutputClaim: "claim"}
+			cmd := getAttackCmd(vegeta)
+			Expect(strings.Count(cmd, " | vegeta encode -to json")).Should(Equal(2))
+			// The steps run until their end in the schedule of the profile
+			Expect(cmd).Should(HavePrefix("{ profile_start=$(date +%s%3N); profile_drift=0; [ -e /tmp/vegeta-aborted ] || { now=$(( $(date +%s%3N) - profile_start )); "))
+			Expect(cmd).Should(ContainSubstring("if [ \"$now\" -lt 60000 ]; then left=$(( 60000 - now )); " + getTargetCmd(vegeta.Spec.Attack.Target) + " | vegeta attack "))
+			Expect(cmd).Should(ContainSubstring(" -rate 10/1s -duration \"${left}ms\" | vegeta encode -to json; fi; }; "))
+			Expect(cmd).Should(ContainSubstring("if [ \"$now\" -lt 180000 ]; then left=$(( 180000 - now )); sleep \"$(printf '%d.%03d' $(( left / 1000 )) $(( left % 1000 )))\"; fi; }; "))
+			Expect(cmd).Should(ContainSubstring("; profile_rc=$?; printf '{\"loadProfile\":{\"drift\":\"%dms\"}}\\n' \"$profile_drift\" > /tmp/vegeta-load-profile; (exit $profile_rc); }"))
+			Expect(cmd).Should(HaveSuffix("; } > " + getResultFile(vegeta)))
+			Expect(getAttackRecordCmd(vegeta)).Should(ContainSubstring(" -profile /tmp/vegeta-load-profile"))
+			Expect(getResultFile(vegeta)).Should(HaveSuffix("_res.json"))
+		})
+		It("Should take the time waiting for the responses of a step from the next one and record the drift", func() {
+			if _, err := exec.LookPath("sh"); err != nil {
+				Skip("no shell available")
+			}
+			if out, err := exec.Command("date", "+%s%3N").Output(); err != nil || len(strings.TrimSpace(string(out))) != 13 {
+				Skip("no date command with milliseconds available")
+			}
+			dir, err := ioutil.TempDir("", "vegeta-stages")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			// The stub of vegeta attack records its duration and waits for the responses longer than the step
+			stub := "#!/bin/sh\ncase \"$1\" in attack) for a in \"$@\"; do d=\"$a\"; done; echo \"$d\" >> " + filepath.Join(dir, "durations") + "; sleep 1.5;; *) cat;; esac\n"
+			Expect(ioutil.WriteFile(filepath.Join(dir, "vegeta"), []byte(stub), 0755)).To(Succeed())
+
+			vegeta := newVegeta("stages-drift")
+			vegeta.Spec.Attack.Stages = []vegetav1alpha1.Stage{{Duration: "1s", Rate: "10/1s"}, {Duration: "1500ms", Rate: "20/1s"}}
+			cmd := strings.NewReplacer(loadProfileRecordFile, filepath.Join(dir, "profile"), abortedMarker, filepath.Join(dir, "aborted")).Replace(getStagesCmd(vegeta))
+			c := exec.Command("sh", "-c", cmd)
+			c.Env = []string{"PATH=" + dir + ":/usr/bin:/bin"}
+			out, err := c.CombinedOutput()
+			Expect(err).ToNot(HaveOccurred(), string(out))
+
+			durations, err := ioutil.ReadFile(filepath.Join(dir, "durations"))
+			Expect(err).ToNot(HaveOccurred())
+			steps := strings.Fields(string(durations))
+			Expect(steps).Should(HaveLen(2))
+			// The second step starts about 500ms late and ends on schedule
+			second, err := time.ParseDuration(steps[1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(second).Should(BeNumerically("<", 1500*time.Millisecond))
+			record, err := ioutil.ReadFile(filepath.Join(dir, "profile"))
+			Expect(err).ToNot(HaveOccurred())
+			pod := terminatedWith("stages-drift-0", string(record))
+			pod.Status.Phase = corev1.PodSucceeded
+			Expect(recordLoadProfileDrift(vegeta, []*corev1.Pod{pod})).Should(BeTrue())
+			Expect(vegeta.Status.LoadProfileDrift.Duration).Should(BeNumerically(">=", 400*time.Millisecond))
+			Expect(vegeta.Status.LoadProfileDrift.Duration).Should(BeNumerically("<", 1500*time.Millisecond))
+			Expect(second + vegeta.Status.LoadProfileDrift.Duration).Should(BeNumerically("~", 1500*time.Millisecond, 50*time.Millisecond))
+		})
+	})
+
+	Context("When the attack pods have terminated", func() {
+		It("Should record the largest drift of their load profiles", func() {
+			vegeta := newVegeta("drift")
+			vegeta.Spec.Attack.Stages = []vegetav1alpha1.Stage{{Duration: "1m", Rate: "10/1s"}}
+			first := terminatedWith("drift-0", testReport+`{"loadProfile":{"drift":"250ms"}}`+"\n")
+			second := terminatedWith("drift-1", testReport+`{"loadProfile":{"drift":"1200ms"}}`+"\n")
+			running := terminatedWith("drift-1", "")
+			running.Status.Phase = corev1.PodRunning
+			first.Status.Phase, second.Status.Phase = corev1.PodSucceeded, corev1.PodSucceeded
+			Expect(recordLoadProfileDrift(vegeta, []*corev1.Pod{first, running})).Should(BeFalse())
+			Expect(recordLoadProfileDrift(vegeta, []*corev1.Pod{first, second})).Should(BeTrue())
+			Expect(vegeta.Status.LoadProfileDrift.Duration).Should(Equal(1200 * time.Millisecond))
+			// The drift is only recorded once
+			Expect(recordLoadProfileDrift(vegeta, []*corev1.Pod{first, second})).Should(BeFalse())
+		})
+	})
+})
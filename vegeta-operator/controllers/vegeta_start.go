@@ -0,0 +1,131 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// startAtAnnotation is set by the controller on the attack pods with the unix time the attack is to start at
+	startAtAnnotation = "vegeta.testing.io/start-at"
+	// podInfoPath is where the downward API volume exposing the start time and the abort request is mounted
+	podInfoPath = "/etc/podinfo/"
+	// startAtFile is the file of the downward API volume containing the start time
+	startAtFile = "start-at"
+	// kubeletSyncPeriod is the default period at which the kubelet syncs its pods and refreshes their downward API volumes
+	kubeletSyncPeriod = time.Minute
+	// startDelay leaves time to the kubelets to propagate the start time to the pods before the attack starts: the annotation may only reach
+	// the downward API volume on the next sync of the pod, with some more delay for the caches of the kubelet
+	startDelay = kubeletSyncPeriod + 30*time.Second
+	// startBarrierTimeout is how long the attack pods wait for the start time before giving up, for instance when another replica could not be scheduled
+	startBarrierTimeout = 10 * time.Minute
+)
+
+// hasStartBarrier returns true when the attack pods are to wait for each other before starting the attack
+func hasStartBarrier(v *vegetav1alpha1.Vegeta) bool {
+	return v.Spec.Replicas > 1
+}
+
+// getStartBarrierCmd generates the commands waiting for the start time to be set by the controller and reached, unless the attack gets aborted in the meantime.
+// The start time is read from the downward API volume, which gets updated when the annotation is set on the pod.
+// The container fails if the start time has not been set before the timeout, so that the pods of a run that cannot start don't wait forever.
+func getStartBarrierCmd(v *vegetav1alpha1.Vegeta) string {
+	if !hasStartBarrier(v) {
+		return ""
+	}
+	file := podInfoPath + startAtFile
+	timeout := strconv.Itoa(int(startBarrierTimeout.Seconds()))
+	return "deadline=$(( $(date +%s) + " + timeout + " )); " +
+		"until [ -s " + file + " ] || [ -e " + abortedMarker + " ]; do " +
+		"if [ \"$(date +%s)\" -ge \"$deadline\" ]; then echo 'The start time of the attack has not been set within " + startBarrierTimeout.String() + "' > " + terminationLogPath + "; exit 1; fi; " +
+		"sleep 1; done; " +
+		getUnlessAbortedCmd("delay=$(( $(cat "+file+") - $(date +%s) )); if [ \"$delay\" -gt 0 ]; then sleep \"$delay\"; fi") + "; "
+}
+
+// getPodInfoVolume generates the downward API volume exposing the start time and the abort request to the attack container
+func getPodInfoVolume() (corev1.Volume, corev1.VolumeMount) {
+	annotationFile := func(path, annotation string) corev1.DownwardAPIVolumeFile {
+		return corev1.DownwardAPIVolumeFile{
+			Path: path,
+			FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "metadata.annotations['" + annotation + "']",
+			},
+		}
+	}
+	volume := corev1.Volume{
+		Name: "podinfo",
+		VolumeSource: corev1.VolumeSource{
+			DownwardAPI: &corev1.DownwardAPIVolumeSource{
+				Items: []corev1.DownwardAPIVolumeFile{
+					annotationFile(startAtFile, startAtAnnotation),
+					annotationFile(abortFile, abortAnnotation),
+				},
+			},
+		},
+	}
+	mount := corev1.VolumeMount{
+		Name:      "podinfo",
+		MountPath: podInfoPath,
+		ReadOnly:  true,
+	}
+	return volume, mount
+}
+
+// reconcileStartBarrier sets the common start time once all the attack pods are running.
+// It returns true if the start time has been set in the status. The start time is only propagated to the pods once it has been persisted,
+// so that pods cannot get different start times.
+func (r *VegetaReconciler) reconcileStartBarrier(ctx context.Context, v *vegetav1alpha1.Vegeta, attackPods []*corev1.Pod) (bool, error) {
+	if !hasStartBarrier(v) {
+		return false, nil
+	}
+	if v.Status.StartAt == nil {
+		if uint32(len(attackPods)) < v.Spec.Replicas {
+			return false, nil
+		}
+		for _, pod := range attackPods {
+			if pod.Status.Phase != corev1.PodRunning {
+				return false, nil
+			}
+		}
+		v.Status.StartAt = &metav1.Time{Time: time.Now().Add(startDelay).Truncate(time.Second)}
+		return true, nil
+	}
+	startAt := strconv.FormatInt(v.Status.StartAt.Unix(), 10)
+	for _, pod := range attackPods {
+		if pod.Status.Phase != corev1.PodRunning || pod.Annotations[startAtAnnotation] == startAt {
+			continue
+		}
+		patch := client.MergeFrom(pod.DeepCopy())
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[startAtAnnotation] = startAt
+		if err := r.Patch(ctx, pod, patch); err != nil {
+			return false, fmt.Errorf("Unable to set the start time on pod %s: %v", pod.Name, err)
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// conflictingClient fails the status updates as if the resource had been modified since it was read
+type conflictingClient struct {
+	client.Client
+}
+
+func (c conflictingClient) Status() client.StatusWriter {
+	return conflictingStatusWriter{c.Client.Status()}
+}
+
+type conflictingStatusWriter struct {
+	client.StatusWriter
+}
+
+func (w conflictingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return errors.NewConflict(vegetav1alpha1.GroupVersion.WithResource("vegeta").GroupResource(), obj.GetName(), fmt.Errorf("the object has been modified"))
+}
+
+var _ = Describe("Vegeta start barrier", func() {
+	newAttackPod := func(name string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: TestNs},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+
+	Context("When a single replica is requested", func() {
+		It("Should start the attack right away", func() {
+			vegeta := newVegeta("single")
+			Expect(getStartBarrierCmd(vegeta)).Should(BeEmpty())
+		})
+	})
+
+	Context("When several replicas are requested", func() {
+		It("Should make the attack pods wait for the start time", func() {
+			vegeta := newVegeta("barrier")
+			vegeta.Spec.Replicas = 2
+			cmd := getStartBarrierCmd(vegeta)
+			Expect(strings.HasPrefix(cmd, "deadline=$(( $(date +%s) + 600 )); until [ -s /etc/podinfo/start-at ] || [ -e /tmp/vegeta-aborted ]; do ")).Should(BeTrue())
+			// The pods give up when the start time is not set in time
+			Expect(cmd).Should(ContainSubstring(`if [ "$(date +%s)" -ge "$deadline" ]; then echo 'The start time of the attack has not been set within 10m0s' > /dev/termination-log; exit 1; fi; sleep 1; done; `))
+			timedOut := newAttackPod("attack-1", corev1.PodFailed)
+			timedOut.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: containerName, State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				ExitCode: 1, Reason: "Error", Message: "The start time of the attack has not been set within 10m0s\n",
+			}}}}
+			Expect(podFailureMessage(timedOut)).Should(Equal("Pod attack-1 failed: container vegeta terminated with exit code 1 (Error): The start time of the attack has not been set within 10m0s"))
+			volumes, mounts := getAPVolumesAndMounts(vegeta)
+			Expect(mounts).Should(ContainElement(corev1.VolumeMount{Name: "podinfo", MountPath: podInfoPath, ReadOnly: true}))
+			var downwardAPI *corev1.DownwardAPIVolumeSource
+			for _, v := range volumes {
+				if v.Name == "podinfo" {
+					downwardAPI = v.DownwardAPI
+				}
+			}
+			Expect(downwardAPI).ToNot(BeNil())
+			Expect(downwardAPI.Items[0].FieldRef.FieldPath).Should(Equal("metadata.annotations['vegeta.testing.io/start-at']"))
+		})
+
+		It("Should wait for the start time unless it has passed", func() {
+			if _, err := exec.LookPath("sh"); err != nil {
+				Skip("no shell available")
+			}
+			vegeta := newVegeta("barrier-wait")
+			vegeta.Spec.Replicas = 2
+			dir, err := ioutil.TempDir("", "podinfo")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			// The paths of the pod are relocated into the temporary directory
+			cmd := strings.NewReplacer(podInfoPath, dir+"/", abortedMarker, filepath.Join(dir, "aborted")).Replace(getStartBarrierCmd(vegeta))
+			run := func(startAt time.Time) time.Duration {
+				Expect(ioutil.WriteFile(filepath.Join(dir, startAtFile), []byte(strconv.FormatInt(startAt.Unix(), 10)), 0644)).To(Succeed())
+				begin := time.Now()
+				out, err := exec.Command("sh", "-c", cmd+"echo started").CombinedOutput()
+				Expect(err).ToNot(HaveOccurred(), string(out))
+				Expect(string(out)).To(Equal("started\n"))
+				return time.Since(begin)
+			}
+			// A start time read after it has passed does not delay the attack
+			Expect(run(time.Now().Add(-startDelay))).To(BeNumerically("<", time.Second))
+			Expect(run(time.Now().Add(2 * time.Second))).To(BeNumerically(">=", time.Second))
+		})
+
+		It("Should only set the start time once all the attack pods are running", func() {
+			vegeta := newVegeta("barrier-running")
+			vegeta.Spec.Replicas = 2
+			running, pending := newAttackPod("attack-1", corev1.PodRunning), newAttackPod("attack-2", corev1.PodPending)
+			r := &VegetaReconciler{Client: fake.NewClientBuilder().WithObjects(running, pending).Build()}
+			set, err := r.reconcileStartBarrier(context.Background(), vegeta, []*corev1.Pod{running})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(set).Should(BeFalse())
+			set, err = r.reconcileStartBarrier(context.Background(), vegeta, []*corev1.Pod{running, pending})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(set).Should(BeFalse())
+			Expect(vegeta.Status.StartAt).Should(BeNil())
+
+			pending.Status.Phase = corev1.PodRunning
+			set, err = r.reconcileStartBarrier(context.Background(), vegeta, []*corev1.Pod{running, pending})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(set).Should(BeTrue())
+			Expect(vegeta.Status.StartAt).ToNot(BeNil())
+			Expect(running.Annotations).ShouldNot(HaveKey(startAtAnnotation))
+
+			// The start time gets propagated to the pods once it has been persisted
+			set, err = r.reconcileStartBarrier(context.Background(), vegeta, []*corev1.Pod{running, pending})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(set).Should(BeFalse())
+			startAt := strconv.FormatInt(vegeta.Status.StartAt.Unix(), 10)
+			for _, name := range []string{"attack-1", "attack-2"} {
+				pod := &corev1.Pod{}
+				Expect(r.Get(context.Background(), types.NamespacedName{Namespace: TestNs, Name: name}, pod)).To(Succeed())
+				Expect(pod.Annotations).Should(HaveKeyWithValue(startAtAnnotation, startAt))
+			}
+		})
+	})
+})
+
+var _ = Describe("Vegeta start of a run", func() {
+	var vegeta *vegetav1alpha1.Vegeta
+	var req ctrl.Request
+
+	BeforeEach(func() {
+		vegeta = newVegeta("starting")
+		vegeta.UID = "starting-uid"
+		req = ctrl.Request{NamespacedName: types.NamespacedName{Name: "starting", Namespace: TestNs}}
+	})
+
+	Context("When the attack pods get created", func() {
+		It("Should record the start of the run before", func() {
+			r := newTargetRefReconciler(vegeta)
+			r.Log = ctrl.Log.WithName("controllers").WithName("Vegeta")
+			_, err := r.Reconcile(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+			updated := &vegetav1alpha1.Vegeta{}
+			Expect(r.Get(context.Background(), req.NamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Phase).To(Equal(vegetav1alpha1.PendingPhase))
+			Expect(updated.Status.StartTime).ToNot(BeNil())
+			Eventually(func() int {
+				pods := &corev1.PodList{}
+				Expect(r.List(context.Background(), pods, client.InNamespace(TestNs))).To(Succeed())
+				return len(pods.Items)
+			}).Should(Equal(1))
+		})
+		It("Should not create them when the start of the run cannot be recorded", func() {
+			r := newTargetRefReconciler(vegeta)
+			r.Log = ctrl.Log.WithName("controllers").WithName("Vegeta")
+			r.Client = conflictingClient{r.Client}
+			_, err := r.Reconcile(context.Background(), req)
+			Expect(err).To(MatchError(ContainSubstring("Unable to record the start of the run")))
+			Consistently(func() int {
+				pods := &corev1.PodList{}
+				Expect(r.List(context.Background(), pods, client.InNamespace(TestNs))).To(Succeed())
+				return len(pods.Items)
+			}, "200ms").Should(BeZero())
+		})
+	})
+})
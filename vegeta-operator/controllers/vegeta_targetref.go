@@ -0,0 +1,397 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// targetCAPath is where the secret containing the CA of the resolved target is mounted
+	targetCAPath = "/opt/target-ca/"
+	// targetCAFile is the key of the CA of the resolved target in the secret
+	targetCAFile = "ca.crt"
+)
+
+var (
+	routeGVK     = schema.GroupVersionKind{Group: "route.openshift.io", Version: "v1", Kind: "Route"}
+	httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+	gatewayGVK   = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "Gateway"}
+)
+
+// +kubebuilder:rbac:groups=core,resources=services,verbs=get
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get
+// +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes;gateways,verbs=get
+
+// resolvedTarget is the outcome of the resolution of a target reference
+type resolvedTarget struct {
+	scheme string
+	host   string
+	// port is left empty when it is the default one of the scheme
+	port string
+	// portName is the name of the port of a Service, which selects the port of its endpoints
+	portName string
+	path     string
+	// ca is the PEM encoded CA of the certificate of the target, when it has been discovered
+	ca []byte
+}
+
+// url formats the URL of the resolved target
+func (t *resolvedTarget) url() string {
+	return t.scheme + "://" + t.hostPort() + t.requestPath()
+}
+
+// hostPort formats the host of the resolved target as it appears in its URL and in the Host header: with the port unless it is the default one of the scheme
+func (t *resolvedTarget) hostPort() string {
+	if t.port != "" && !(t.scheme == "http" && t.port == "80") && !(t.scheme == "https" && t.port == "443") {
+		return net.JoinHostPort(t.host, t.port)
+	}
+	return t.host
+}
+
+// requestPath returns the path of the requests, / by default
+func (t *resolvedTarget) requestPath() string {
+	if t.path == "" {
+		return "/"
+	}
+	return t.path
+}
+
+// getTarget returns the target of the attack specified in the vegeta resource or resolved from its target reference
+func getTarget(v *vegetav1alpha1.Vegeta) string {
+	if v.Spec.Attack.TargetRef != nil {
+		return v.Status.ResolvedTarget
+	}
+	return v.Spec.Attack.Target
+}
+
+// getTargetCASecretName generates the name of the secret containing the CA of the resolved target
+func getTargetCASecretName(v *vegetav1alpha1.Vegeta) string {
+	return v.Name + "-target-ca"
+}
+
+// reconcileTargetRef resolves the target reference of the vegeta resource into its status, once per run, and renders the discovered CA into a secret mounted by the attack pods.
+// It returns true when the status has changed.
+func (r *VegetaReconciler) reconcileTargetRef(ctx context.Context, v *vegetav1alpha1.Vegeta) (bool, error) {
+	if v.Status.ResolvedTarget != "" {
+		return false, nil
+	}
+	ref := v.Spec.Attack.TargetRef
+	target, err := r.resolveTargetRef(ctx, v.Namespace, ref)
+	if err != nil {
+		setCondition(v, vegetav1alpha1.TargetResolvedCondition, metav1.ConditionFalse, vegetav1alpha1.TargetNotResolvedReason, err.Error())
+		return true, err
+	}
+	if len(target.ca) > 0 {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      getTargetCASecretName(v),
+				Namespace: v.Namespace,
+			},
+		}
+		_, err = ctrl.CreateOrUpdate(ctx, r.Client, secret, func() error {
+			if err := ensureControlledBy(secret, v); err != nil {
+				return err
+			}
+			secret.Labels = r.Labels.Merge(map[string]string{
+				"app.kubernetes.io/name":       "vegeta",
+				"app.kubernetes.io/instance":   v.Name,
+				"app.kubernetes.io/managed-by": "vegeta-operator"})
+			secret.Data = map[string][]byte{targetCAFile: target.ca}
+			// Set Vegeta instance as the owner and controller
+			return ctrl.SetControllerReference(v, secret, r.Scheme)
+		})
+		if err != nil {
+			return false, fmt.Errorf("Unable to create or update the target CA secret %s: %v", secret.Name, err)
+		}
+		v.Status.TargetCASecret = secret.Name
+	}
+	if ref.PerEndpoint {
+		endpoints, err := r.resolveEndpoints(ctx, v.Namespace, ref.Name, target.portName)
+		if err == nil {
+			err = r.writeTargetsSecret(ctx, v, renderEndpointTargets(target, ref.Method, endpoints))
+		}
+		if err != nil {
+			setCondition(v, vegetav1alpha1.TargetResolvedCondition, metav1.ConditionFalse, vegetav1alpha1.TargetNotResolvedReason, err.Error())
+			return true, err
+		}
+		v.Status.Endpoints = endpoints
+	}
+	v.Status.ResolvedTarget = ref.Method + " " + target.url()
+	msg := fmt.Sprintf("%s %s resolved to %s", ref.Kind, ref.Name, v.Status.ResolvedTarget)
+	if ref.PerEndpoint {
+		msg += fmt.Sprintf(", attacked through its %d ready endpoints", len(v.Status.Endpoints))
+	}
+	setCondition(v, vegetav1alpha1.TargetResolvedCondition, metav1.ConditionTrue, vegetav1alpha1.TargetResolvedReason, msg)
+	return true, nil
+}
+
+// resolveTargetRef discovers the address of the referenced resource. The API server is read directly so that the operator does not watch these kinds, some of which may not be installed.
+func (r *VegetaReconciler) resolveTargetRef(ctx context.Context, namespace string, ref *vegetav1alpha1.TargetRef) (*resolvedTarget, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	var target *resolvedTarget
+	var err error
+	switch ref.Kind {
+	case vegetav1alpha1.ServiceKind:
+		svc := &corev1.Service{}
+		if err = r.APIReader.Get(ctx, key, svc); err == nil {
+			target, err = resolveService(svc, ref)
+		}
+	case vegetav1alpha1.RouteKind:
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(routeGVK)
+		if err = r.APIReader.Get(ctx, key, route); err == nil {
+			target, err = resolveRoute(route)
+		}
+	case vegetav1alpha1.IngressKind:
+		ing := &networkingv1.Ingress{}
+		if err = r.APIReader.Get(ctx, key, ing); err == nil {
+			target, err = r.resolveIngress(ctx, ing)
+		}
+	case vegetav1alpha1.HTTPRouteKind:
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(httpRouteGVK)
+		if err = r.APIReader.Get(ctx, key, route); err == nil {
+			target, err = r.resolveHTTPRoute(ctx, route)
+		}
+	default:
+		err = fmt.Errorf("unsupported kind")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to resolve the target from %s %s: %v", ref.Kind, ref.Name, err)
+	}
+	if ref.Path != "" {
+		target.path = ref.Path
+	}
+	return target, nil
+}
+
+// resolveService resolves a Service into its cluster DNS name and the selected port.
+// The CA of OpenShift service serving certificates is already trusted by the attack pods.
+func resolveService(svc *corev1.Service, ref *vegetav1alpha1.TargetRef) (*resolvedTarget, error) {
+	if len(svc.Spec.Ports) == 0 {
+		return nil, fmt.Errorf("the service has no port")
+	}
+	port := &svc.Spec.Ports[0]
+	if ref.Port != "" {
+		port = nil
+		for i := range svc.Spec.Ports {
+			p := &svc.Spec.Ports[i]
+			if p.Name == ref.Port || strconv.Itoa(int(p.Port)) == ref.Port {
+				port = p
+				break
+			}
+		}
+		if port == nil {
+			return nil, fmt.Errorf("the service has no port %s", ref.Port)
+		}
+	}
+	scheme := ref.Scheme
+	if scheme == "" {
+		scheme = "http"
+		appProtocol := ""
+		if port.AppProtocol != nil {
+			appProtocol = strings.ToLower(*port.AppProtocol)
+		}
+		if appProtocol == "https" || port.Name == "https" || strings.HasPrefix(port.Name, "https-") || port.Port == 443 || port.Port == 8443 {
+			scheme = "https"
+		}
+	}
+	return &resolvedTarget{
+		scheme:   scheme,
+		host:     svc.Name + "." + svc.Namespace + ".svc",
+		port:     strconv.Itoa(int(port.Port)),
+		portName: port.Name,
+	}, nil
+}
+
+// resolveRoute resolves an OpenShift Route into its host. The CA is taken from the route when it has its own certificate.
+func resolveRoute(route *unstructured.Unstructured) (*resolvedTarget, error) {
+	host, _, _ := unstructured.NestedString(route.Object, "spec", "host")
+	if host == "" {
+		ingresses, _, _ := unstructured.NestedSlice(route.Object, "status", "ingress")
+		if len(ingresses) > 0 {
+			if ingress, ok := ingresses[0].(map[string]interface{}); ok {
+				host, _, _ = unstructured.NestedString(ingress, "host")
+			}
+		}
+	}
+	if host == "" {
+		return nil, fmt.Errorf("the route has no host yet")
+	}
+	target := &resolvedTarget{scheme: "http", host: host}
+	target.path, _, _ = unstructured.NestedString(route.Object, "spec", "path")
+	if tls, found, _ := unstructured.NestedMap(route.Object, "spec", "tls"); found && tls != nil {
+		target.scheme = "https"
+		if ca, _, _ := unstructured.NestedString(tls, "caCertificate"); ca != "" {
+			target.ca = []byte(ca)
+		}
+	}
+	return target, nil
+}
+
+// resolveIngress resolves an Ingress into the host of its first rule. The CA is taken from the ca.crt key of its TLS secret, as provided by cert-manager.
+func (r *VegetaReconciler) resolveIngress(ctx context.Context, ing *networkingv1.Ingress) (*resolvedTarget, error) {
+	var rule *networkingv1.IngressRule
+	for i := range ing.Spec.Rules {
+		if ing.Spec.Rules[i].Host != "" {
+			rule = &ing.Spec.Rules[i]
+			break
+		}
+	}
+	if rule == nil {
+		return nil, fmt.Errorf("the ingress has no rule with a host")
+	}
+	target := &resolvedTarget{scheme: "http", host: rule.Host}
+	if rule.HTTP != nil && len(rule.HTTP.Paths) > 0 {
+		target.path = rule.HTTP.Paths[0].Path
+	}
+	for _, tls := range ing.Spec.TLS {
+		if len(tls.Hosts) > 0 && !containsString(tls.Hosts, rule.Host) {
+			continue
+		}
+		target.scheme = "https"
+		if tls.SecretName != "" {
+			ca, err := r.secretCA(ctx, ing.Namespace, tls.SecretName)
+			if err != nil {
+				return nil, err
+			}
+			target.ca = ca
+		}
+		break
+	}
+	return target, nil
+}
+
+// resolveHTTPRoute resolves a Gateway API HTTPRoute into its first hostname and the listener of its parent Gateway.
+// The CA is taken from the ca.crt key of the secret of the listener certificate.
+func (r *VegetaReconciler) resolveHTTPRoute(ctx context.Context, route *unstructured.Unstructured) (*resolvedTarget, error) {
+	parents, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	if len(parents) == 0 {
+		return nil, fmt.Errorf("the HTTPRoute has no parent gateway")
+	}
+	parent, _ := parents[0].(map[string]interface{})
+	gwName, _, _ := unstructured.NestedString(parent, "name")
+	gwNamespace, _, _ := unstructured.NestedString(parent, "namespace")
+	if gwNamespace == "" {
+		gwNamespace = route.GetNamespace()
+	}
+	sectionName, _, _ := unstructured.NestedString(parent, "sectionName")
+	gw := &unstructured.Unstructured{}
+	gw.SetGroupVersionKind(gatewayGVK)
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: gwNamespace, Name: gwName}, gw); err != nil {
+		return nil, fmt.Errorf("unable to get the gateway %s/%s: %v", gwNamespace, gwName, err)
+	}
+
+	var listener map[string]interface{}
+	listeners, _, _ := unstructured.NestedSlice(gw.Object, "spec", "listeners")
+	for _, l := range listeners {
+		l, _ := l.(map[string]interface{})
+		name, _, _ := unstructured.NestedString(l, "name")
+		protocol, _, _ := unstructured.NestedString(l, "protocol")
+		if (sectionName != "" && name == sectionName) || (sectionName == "" && (protocol == "HTTP" || protocol == "HTTPS")) {
+			listener = l
+			break
+		}
+	}
+	if listener == nil {
+		return nil, fmt.Errorf("the gateway %s/%s has no matching HTTP or HTTPS listener", gwNamespace, gwName)
+	}
+
+	target := &resolvedTarget{scheme: "http"}
+	if protocol, _, _ := unstructured.NestedString(listener, "protocol"); protocol == "HTTPS" {
+		target.scheme = "https"
+	}
+	if port, found, _ := unstructured.NestedFieldNoCopy(listener, "port"); found {
+		target.port = fmt.Sprint(port)
+	}
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	if len(hostnames) > 0 && !strings.HasPrefix(hostnames[0], "*") {
+		target.host = hostnames[0]
+	} else if hostname, _, _ := unstructured.NestedString(listener, "hostname"); hostname != "" && !strings.HasPrefix(hostname, "*") {
+		target.host = hostname
+	} else {
+		addresses, _, _ := unstructured.NestedSlice(gw.Object, "status", "addresses")
+		if len(addresses) > 0 {
+			address, _ := addresses[0].(map[string]interface{})
+			target.host, _, _ = unstructured.NestedString(address, "value")
+		}
+	}
+	if target.host == "" {
+		return nil, fmt.Errorf("neither the HTTPRoute nor the gateway %s/%s has a hostname or an address", gwNamespace, gwName)
+	}
+	if rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules"); len(rules) > 0 {
+		rule, _ := rules[0].(map[string]interface{})
+		if matches, _, _ := unstructured.NestedSlice(rule, "matches"); len(matches) > 0 {
+			match, _ := matches[0].(map[string]interface{})
+			target.path, _, _ = unstructured.NestedString(match, "path", "value")
+		}
+	}
+	if target.scheme == "https" {
+		refs, _, _ := unstructured.NestedSlice(listener, "tls", "certificateRefs")
+		if len(refs) > 0 {
+			ref, _ := refs[0].(map[string]interface{})
+			kind, _, _ := unstructured.NestedString(ref, "kind")
+			name, _, _ := unstructured.NestedString(ref, "name")
+			namespace, _, _ := unstructured.NestedString(ref, "namespace")
+			if namespace == "" {
+				namespace = gwNamespace
+			}
+			if (kind == "" || kind == "Secret") && name != "" {
+				ca, err := r.secretCA(ctx, namespace, name)
+				if err != nil {
+					return nil, err
+				}
+				target.ca = ca
+			}
+		}
+	}
+	return target, nil
+}
+
+// secretCA returns the ca.crt key of a TLS secret, which is empty when the secret does not provide it
+func (r *VegetaReconciler) secretCA(ctx context.Context, namespace, name string) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+		return nil, fmt.Errorf("unable to get the TLS secret %s/%s: %v", namespace, name, err)
+	}
+	return secret.Data[targetCAFile], nil
+}
+
+// containsString returns true if the list contains the string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testCA = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+
+// newTargetRefReconciler returns a reconciler reading the given objects
+func newTargetRefReconciler(objs ...client.Object) *VegetaReconciler {
+	s := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+	Expect(vegetav1alpha1.AddToScheme(s)).To(Succeed())
+	// The fake client needs to know the kinds that are listed as unstructured objects
+	s.AddKnownTypeWithName(endpointSliceListGVK.GroupVersion().WithKind("EndpointSlice"), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(endpointSliceListGVK, &unstructured.UnstructuredList{})
+	c := fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build()
+	return &VegetaReconciler{Client: c, APIReader: c, Scheme: s}
+}
+
+// newUnstructured returns an object of a kind that is not part of the scheme of the operator
+func newUnstructured(apiVersion, kind, name string, content map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: content}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetName(name)
+	u.SetNamespace(TestNs)
+	return u
+}
+
+var _ = Describe("Vegeta target reference", func() {
+	var vegeta *vegetav1alpha1.Vegeta
+
+	BeforeEach(func() {
+		vegeta = newVegeta("targetref")
+		vegeta.UID = "targetref-uid"
+		vegeta.Spec.Attack.Target = ""
+		vegeta.Spec.Attack.TargetRef = &vegetav1alpha1.TargetRef{Method: "GET"}
+	})
+
+	resolve := func(r *VegetaReconciler) string {
+		target, err := r.resolveTargetRef(context.Background(), TestNs, vegeta.Spec.Attack.TargetRef)
+		Expect(err).ToNot(HaveOccurred())
+		return target.url()
+	}
+
+	Context("When a Service is referenced", func() {
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: TestNs},
+			Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+				{Name: "http", Port: 80},
+				{Name: "https-api", Port: 9443},
+			}},
+		}
+		It("Should use the cluster DNS name and the first port", func() {
+			vegeta.Spec.Attack.TargetRef.Kind = vegetav1alpha1.ServiceKind
+			vegeta.Spec.Attack.TargetRef.Name = "api"
+			Expect(resolve(newTargetRefReconciler(svc.DeepCopy()))).To(Equal("http://api.test-vegeta.svc/"))
+		})
+		It("Should select the port by name and derive the scheme from it", func() {
+			vegeta.Spec.Attack.TargetRef.Kind = vegetav1alpha1.ServiceKind
+			vegeta.Spec.Attack.TargetRef.Name = "api"
+			vegeta.Spec.Attack.TargetRef.Port = "https-api"
+			vegeta.Spec.Attack.TargetRef.Path = "/healthz"
+			Expect(resolve(newTargetRefReconciler(svc.DeepCopy()))).To(Equal("https://api.test-vegeta.svc:9443/healthz"))
+		})
+		It("Should fail on an unknown port", func() {
+			vegeta.Spec.Attack.TargetRef.Kind = vegetav1alpha1.ServiceKind
+			vegeta.Spec.Attack.TargetRef.Name = "api"
+			vegeta.Spec.Attack.TargetRef.Port = "grpc"
+			_, err := newTargetRefReconciler(svc.DeepCopy()).resolveTargetRef(context.Background(), TestNs, vegeta.Spec.Attack.TargetRef)
+			Expect(err).To(MatchError(ContainSubstring("no port grpc")))
+		})
+	})
+
+	Context("When a Route is referenced", func() {
+		It("Should use its host and the CA of its certificate", func() {
+			route := newUnstructured("route.openshift.io/v1", "Route", "web", map[string]interface{}{
+				"spec": map[string]interface{}{
+					"host": "web.apps.example.com",
+					"path": "/shop",
+					"tls":  map[string]interface{}{"termination": "edge", "caCertificate": testCA},
+				},
+			})
+			vegeta.Spec.Attack.TargetRef.Kind = vegetav1alpha1.RouteKind
+			vegeta.Spec.Attack.TargetRef.Name = "web"
+			target, err := newTargetRefReconciler(route).resolveTargetRef(context.Background(), TestNs, vegeta.Spec.Attack.TargetRef)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(target.url()).To(Equal("https://web.apps.example.com/shop"))
+			Expect(string(target.ca)).To(Equal(testCA))
+		})
+	})
+
+	Context("When an Ingress is referenced", func() {
+		It("Should use the host of its first rule and the CA of its TLS secret", func() {
+			ing := &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: TestNs},
+				Spec: networkingv1.IngressSpec{
+					TLS: []networkingv1.IngressTLS{{Hosts: []string{"web.example.com"}, SecretName: "web-tls"}},
+					Rules: []networkingv1.IngressRule{{
+						Host: "web.example.com",
+						IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{{Path: "/api"}},
+						}},
+					}},
+				},
+			}
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "web-tls", Namespace: TestNs},
+				Data:       map[string][]byte{"ca.crt": []byte(testCA)},
+			}
+			vegeta.Spec.Attack.TargetRef.Kind = vegetav1alpha1.IngressKind
+			vegeta.Spec.Attack.TargetRef.Name = "web"
+			target, err := newTargetRefReconciler(ing, secret).resolveTargetRef(context.Background(), TestNs, vegeta.Spec.Attack.TargetRef)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(target.url()).To(Equal("https://web.example.com/api"))
+			Expect(string(target.ca)).To(Equal(testCA))
+		})
+	})
+
+	Context("When an HTTPRoute is referenced", func() {
+		It("Should go through the listener of its gateway", func() {
+			route := newUnstructured("gateway.networking.k8s.io/v1", "HTTPRoute", "web", map[string]interface{}{
+				"spec": map[string]interface{}{
+					"parentRefs": []interface{}{map[string]interface{}{"name": "gw", "sectionName": "https"}},
+					"hostnames":  []interface{}{"web.example.com"},
+					"rules": []interface{}{map[string]interface{}{
+						"matches": []interface{}{map[string]interface{}{"path": map[string]interface{}{"type": "PathPrefix", "value": "/v1"}}},
+					}},
+				},
+			})
+			gw := newUnstructured("gateway.networking.k8s.io/v1", "Gateway", "gw", map[string]interface{}{
+				"spec": map[string]interface{}{
+					"listeners": []interface{}{
+						map[string]interface{}{"name": "http", "protocol": "HTTP", "port": int64(80)},
+						map[string]interface{}{"name": "https", "protocol": "HTTPS", "port": int64(8443),
+							"tls": map[string]interface{}{"certificateRefs": []interface{}{map[string]interface{}{"name": "gw-tls"}}}},
+					},
+				},
+			})
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "gw-tls", Namespace: TestNs},
+				Data:       map[string][]byte{"ca.crt": []byte(testCA)},
+			}
+			vegeta.Spec.Attack.TargetRef.Kind = vegetav1alpha1.HTTPRouteKind
+			vegeta.Spec.Attack.TargetRef.Name = "web"
+			target, err := newTargetRefReconciler(route, gw, secret).resolveTargetRef(context.Background(), TestNs, vegeta.Spec.Attack.TargetRef)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(target.url()).To(Equal("https://web.example.com:8443/v1"))
+			Expect(string(target.ca)).To(Equal(testCA))
+		})
+	})
+
+	Context("When the target reference is reconciled", func() {
+		It("Should record the resolved target and mount the discovered CA", func() {
+			route := newUnstructured("route.openshift.io/v1", "Route", "web", map[string]interface{}{
+				"spec": map[string]interface{}{
+					"host": "web.apps.example.com",
+					"tls":  map[string]interface{}{"termination": "reencrypt", "caCertificate": testCA},
+				},
+			})
+			vegeta.Spec.Attack.TargetRef.Kind = vegetav1alpha1.RouteKind
+			vegeta.Spec.Attack.TargetRef.Name = "web"
+			r := newTargetRefReconciler(route)
+			changed, err := r.reconcileTargetRef(context.Background(), vegeta)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			Expect(vegeta.Status.ResolvedTarget).To(Equal("GET https://web.apps.example.com/"))
+			Expect(vegeta.Status.TargetCASecret).To(Equal("targetref-target-ca"))
+			Expect(meta.IsStatusConditionTrue(vegeta.Status.Conditions, vegetav1alpha1.TargetResolvedCondition)).To(BeTrue())
+			secret := &corev1.Secret{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Name: "targetref-target-ca", Namespace: TestNs}, secret)).To(Succeed())
+			Expect(string(secret.Data[targetCAFile])).To(Equal(testCA))
+
+			// The target is only resolved once per run
+			changed, err = r.reconcileTargetRef(context.Background(), vegeta)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeFalse())
+
+			pod := r.aPod4Attack(vegeta, 0)
+			cmd := strings.Join(pod.Spec.Containers[0].Args, " ")
+			Expect(cmd).To(ContainSubstring("'GET https://web.apps.example.com/'"))
+			Expect(cmd).To(ContainSubstring(targetCAPath + targetCAFile))
+			var volumes []string
+			for _, v := range pod.Spec.Volumes {
+				volumes = append(volumes, v.Name)
+			}
+			Expect(volumes).To(ContainElement("target-ca"))
+		})
+		It("Should not take over a secret with the name of the CA secret it has not created", func() {
+			route := newUnstructured("route.openshift.io/v1", "Route", "web", map[string]interface{}{
+				"spec": map[string]interface{}{
+					"host": "web.apps.example.com",
+					"tls":  map[string]interface{}{"termination": "reencrypt", "caCertificate": testCA},
+				},
+			})
+			vegeta.Spec.Attack.TargetRef.Kind = vegetav1alpha1.RouteKind
+			vegeta.Spec.Attack.TargetRef.Name = "web"
+			existing := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "targetref-target-ca", Namespace: TestNs, ResourceVersion: "1"},
+				Data:       map[string][]byte{"ca.crt": []byte("another CA")},
+			}
+			r := newTargetRefReconciler(route, existing)
+			_, err := r.reconcileTargetRef(context.Background(), vegeta)
+			Expect(err).To(MatchError(ContainSubstring("it already exists and is not controlled by targetref")))
+			Expect(vegeta.Status.TargetCASecret).To(BeEmpty())
+			secret := &corev1.Secret{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Name: "targetref-target-ca", Namespace: TestNs}, secret)).To(Succeed())
+			Expect(secret.Data).To(Equal(existing.Data))
+		})
+		It("Should report a target that cannot be resolved", func() {
+			vegeta.Spec.Attack.TargetRef.Kind = vegetav1alpha1.ServiceKind
+			vegeta.Spec.Attack.TargetRef.Name = "missing"
+			changed, err := newTargetRefReconciler().reconcileTargetRef(context.Background(), vegeta)
+			Expect(err).To(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			Expect(vegeta.Status.ResolvedTarget).To(BeEmpty())
+			cond := meta.FindStatusCondition(vegeta.Status.Conditions, vegetav1alpha1.TargetResolvedCondition)
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(vegetav1alpha1.TargetNotResolvedReason))
+		})
+	})
+})
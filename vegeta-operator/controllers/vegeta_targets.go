@@ -0,0 +1,274 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// targetsPath is where the secret containing the rendered inline targets is mounted
+	targetsPath = "/opt/targets/"
+	// targetsFile is the key of the rendered inline targets in the secret
+	targetsFile = "targets.json"
+	// bodiesPath is where the bodies of the inline targets are mounted when the targets are rendered in the http format
+	bodiesPath = "/opt/bodies/"
+)
+
+// vegetaTarget mirrors a target in the vegeta json format. The body gets base64 encoded by the json encoder.
+type vegetaTarget struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Body   []byte      `json:"body,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+}
+
+// getTargetsSecretName generates the name of the secret containing the rendered inline targets
+func getTargetsSecretName(v *vegetav1alpha1.Vegeta) string {
+	return v.Name + "-targets"
+}
+
+// hasRenderedTargets returns true when the attack pods read the targets rendered by the operator: the inline targets, the weighted targets of a config map, the endpoints of a Service or the operations of an OpenAPI document
+func hasRenderedTargets(v *vegetav1alpha1.Vegeta) bool {
+	return len(v.Spec.Attack.Targets) > 0 || isWeightedConfigMap(v) || isPerEndpoint(v) || isOpenAPI(v)
+}
+
+// hasSecretBody returns true when the body of an inline target is read from a secret.
+// The secret is mounted by the attack pods rather than read by the operator, which would otherwise copy any secret it can read for whoever can create a Vegeta resource.
+// The targets are then rendered in the http format, which references the bodies as files.
+func hasSecretBody(v *vegetav1alpha1.Vegeta) bool {
+	for _, t := range v.Spec.Attack.Targets {
+		if t.BodyFrom != nil && t.BodyFrom.SecretKeyRef != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTargetBody returns true when the target has a body, inline or in a config map or a secret
+func hasTargetBody(t *vegetav1alpha1.AttackTarget) bool {
+	return t.Body != "" || t.BodyFrom != nil
+}
+
+// getTargetBodyFile returns the name of the file containing the body of the inline target with the given index when the targets are rendered in the http format
+func getTargetBodyFile(i int) string {
+	return fmt.Sprintf("target-%d.body", i)
+}
+
+// reconcileTargets renders the targets inlined in the vegeta resource or the weighted targets of its config map into a secret mounted by the attack pods.
+// A secret is used as headers and bodies may contain credentials. The traffic mix requested by the weights is recorded in the status once per run.
+func (r *VegetaReconciler) reconcileTargets(ctx context.Context, v *vegetav1alpha1.Vegeta) error {
+	var content []byte
+	var mix []vegetav1alpha1.TargetMixResults
+	var err error
+	if isWeightedConfigMap(v) {
+		content, mix, err = r.renderWeightedTargets(ctx, v)
+	} else {
+		content, mix, err = r.renderTargets(ctx, v)
+	}
+	if err != nil {
+		return err
+	}
+	if hasTargetMix(v) && v.Status.TargetMix == nil {
+		v.Status.TargetMix = mix
+	}
+	return r.writeTargetsSecret(ctx, v, content)
+}
+
+// writeTargetsSecret writes the rendered targets into the secret mounted by the attack pods.
+// The secret is read through the uncached reader so that the operator does not cache, hence list and watch, all the secrets of the cluster.
+func (r *VegetaReconciler) writeTargetsSecret(ctx context.Context, v *vegetav1alpha1.Vegeta, content []byte) error {
+	secret := &corev1.Secret{}
+	err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: v.Namespace, Name: getTargetsSecretName(v)}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("Unable to get the targets secret %s: %v", getTargetsSecretName(v), err)
+	}
+	if errors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      getTargetsSecretName(v),
+				Namespace: v.Namespace,
+			},
+		}
+	}
+	existing := secret.DeepCopy()
+	if err := r.mutateTargetsSecret(secret, v, content); err != nil {
+		return fmt.Errorf("Unable to create or update the targets secret %s: %v", secret.Name, err)
+	}
+	switch {
+	case secret.ResourceVersion == "":
+		err = r.Create(ctx, secret)
+	case !equality.Semantic.DeepEqual(existing, secret):
+		err = r.Update(ctx, secret)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to create or update the targets secret %s: %v", secret.Name, err)
+	}
+	return nil
+}
+
+// mutateTargetsSecret sets the rendered targets, the labels and the owner of the targets secret
+func (r *VegetaReconciler) mutateTargetsSecret(secret *corev1.Secret, v *vegetav1alpha1.Vegeta, content []byte) error {
+	if err := ensureControlledBy(secret, v); err != nil {
+		return err
+	}
+	secret.Labels = r.Labels.Merge(map[string]string{
+		"app.kubernetes.io/name":       "vegeta",
+		"app.kubernetes.io/instance":   v.Name,
+		"app.kubernetes.io/managed-by": "vegeta-operator"})
+	secret.Data = map[string][]byte{getRenderedTargetsFile(v): content}
+	if hasSecretBody(v) {
+		// The inline bodies are mounted as files next to the bodies of the config maps and secrets
+		for i, t := range v.Spec.Attack.Targets {
+			if t.Body != "" {
+				secret.Data[getTargetBodyFile(i)] = []byte(t.Body)
+			}
+		}
+	}
+	// Set Vegeta instance as the owner and controller
+	return ctrl.SetControllerReference(v, secret, r.Scheme)
+}
+
+// ensureControlledBy fails when an existing object is not controlled by the owner, so that the operator does not take over objects it has not created,
+// e.g. a secret of the user that happens to have the name of a generated one
+func ensureControlledBy(obj, owner metav1.Object) error {
+	if obj.GetResourceVersion() != "" && !metav1.IsControlledBy(obj, owner) {
+		return fmt.Errorf("it already exists and is not controlled by %s", owner.GetName())
+	}
+	return nil
+}
+
+// renderTargets renders the targets inlined in the vegeta resource in the vegeta json format: one json object per line, repeated according to their weights.
+// When the body of a target is read from a secret the targets are rendered in the http format instead, with their bodies referenced as files.
+// It also returns the traffic mix requested by the weights.
+func (r *VegetaReconciler) renderTargets(ctx context.Context, v *vegetav1alpha1.Vegeta) ([]byte, []vegetav1alpha1.TargetMixResults, error) {
+	format := getRenderedTargetsFormat(v)
+	targets := make([]weightedTarget, 0, len(v.Spec.Attack.Targets))
+	for i, t := range v.Spec.Attack.Targets {
+		var vt *vegetaTarget
+		var rendered []byte
+		if format == vegetav1alpha1.HTTPFormat {
+			vt = toVegetaTarget(&t, nil)
+			bodyFile := ""
+			if hasTargetBody(&t) {
+				bodyFile = bodiesPath + getTargetBodyFile(i)
+			}
+			rendered = renderHTTPTarget(vt, bodyFile)
+		} else {
+			body, err := r.getTargetBody(ctx, v.Namespace, &t)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Unable to get the body of target %d: %v", i, err)
+			}
+			vt = toVegetaTarget(&t, body)
+			rendered, err = json.Marshal(vt)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Unable to render target %d: %v", i, err)
+			}
+		}
+		targets = append(targets, weightedTarget{key: vt.Method + " " + vt.URL, weight: t.Weight, rendered: rendered})
+	}
+	if hasTargetMix(v) {
+		if err := checkDistinctTargets(targets); err != nil {
+			return nil, nil, fmt.Errorf("Invalid targets: %v", err)
+		}
+	}
+	content, err := expandTargets(targets, format)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to expand the targets: %v", err)
+	}
+	return content, targetMixOf(targets), nil
+}
+
+// renderHTTPTarget renders a target in the vegeta http format: the request line, the headers and the reference to the file containing the body if any
+func renderHTTPTarget(vt *vegetaTarget, bodyFile string) []byte {
+	lines := []string{vt.Method + " " + vt.URL}
+	keys := make([]string, 0, len(vt.Header))
+	for k := range vt.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, value := range vt.Header[k] {
+			lines = append(lines, k+": "+value)
+		}
+	}
+	if bodyFile != "" {
+		lines = append(lines, "@"+bodyFile)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// toVegetaTarget converts a target of the vegeta resource into the vegeta json format
+func toVegetaTarget(t *vegetav1alpha1.AttackTarget, body []byte) *vegetaTarget {
+	vt := &vegetaTarget{
+		Method: t.Method,
+		URL:    t.URL,
+		Body:   body,
+	}
+	if vt.Method == "" {
+		vt.Method = http.MethodGet
+	}
+	for _, h := range t.Headers {
+		ps := strings.SplitN(h, ":", 2)
+		if len(ps) != 2 {
+			continue
+		}
+		if vt.Header == nil {
+			vt.Header = http.Header{}
+		}
+		vt.Header.Add(strings.TrimSpace(ps[0]), strings.TrimSpace(ps[1]))
+	}
+	return vt
+}
+
+// getTargetBody returns the body of the target, which may be specified inline or in a config map.
+// A body in a secret is never read by the operator, see hasSecretBody.
+func (r *VegetaReconciler) getTargetBody(ctx context.Context, namespace string, t *vegetav1alpha1.AttackTarget) ([]byte, error) {
+	switch {
+	case t.BodyFrom != nil && t.BodyFrom.ConfigMapKeyRef != nil:
+		ref := t.BodyFrom.ConfigMapKeyRef
+		cm := &corev1.ConfigMap{}
+		// The config map is not watched by the operator, it is read through the uncached reader
+		if err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, cm); err != nil {
+			return nil, fmt.Errorf("Failed to get config map %s: %v", ref.Name, err)
+		}
+		if data, ok := cm.Data[ref.Key]; ok {
+			return []byte(data), nil
+		}
+		if data, ok := cm.BinaryData[ref.Key]; ok {
+			return data, nil
+		}
+		return nil, fmt.Errorf("Key %s not found in config map %s", ref.Key, ref.Name)
+	case t.Body != "":
+		return []byte(t.Body), nil
+	default:
+		return nil, nil
+	}
+}
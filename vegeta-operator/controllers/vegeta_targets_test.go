@@ -0,0 +1,121 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Vegeta targets", func() {
+	var vegeta *vegetav1alpha1.Vegeta
+
+	BeforeEach(func() {
+		vegeta = newVegeta("inline")
+		vegeta.UID = "inline-uid"
+		vegeta.Spec.Attack.Target = ""
+		vegeta.Spec.Attack.Targets = []vegetav1alpha1.AttackTarget{{URL: "https://shop.example.com/items"}}
+	})
+
+	Context("When the targets are rendered", func() {
+		It("Should write them into a secret controlled by the vegeta resource", func() {
+			r := newTargetRefReconciler()
+			Expect(r.reconcileTargets(context.Background(), vegeta)).To(Succeed())
+			secret := &corev1.Secret{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Name: "inline-targets", Namespace: TestNs}, secret)).To(Succeed())
+			Expect(metav1.IsControlledBy(secret, vegeta)).To(BeTrue())
+
+			// The secret gets updated with the targets of the next runs
+			vegeta.Spec.Attack.Targets[0].URL = "https://shop.example.com/cart"
+			Expect(r.reconcileTargets(context.Background(), vegeta)).To(Succeed())
+			secret = &corev1.Secret{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Name: "inline-targets", Namespace: TestNs}, secret)).To(Succeed())
+			Expect(string(secret.Data[targetsFile])).To(ContainSubstring("/cart"))
+		})
+		It("Should not take over a secret with the same name it has not created", func() {
+			existing := &corev1.Secret{
+				// Objects read from the API server always have a resource version, unlike the initial objects of the fake client
+				ObjectMeta: metav1.ObjectMeta{Name: "inline-targets", Namespace: TestNs, ResourceVersion: "1"},
+				Data:       map[string][]byte{"token": []byte("s3cr3t")},
+			}
+			r := newTargetRefReconciler(existing)
+			err := r.reconcileTargets(context.Background(), vegeta)
+			Expect(err).To(MatchError("Unable to create or update the targets secret inline-targets: it already exists and is not controlled by inline"))
+			secret := &corev1.Secret{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Name: "inline-targets", Namespace: TestNs}, secret)).To(Succeed())
+			Expect(secret.Data).To(Equal(existing.Data))
+			Expect(metav1.GetControllerOf(secret)).To(BeNil())
+		})
+		It("Should mount a body in a secret into the attack pods rather than read it", func() {
+			vegeta.Spec.Attack.Targets = []vegetav1alpha1.AttackTarget{
+				{Method: "POST", URL: "https://shop.example.com/orders", Headers: []string{"Content-Type: application/json"}, BodyFrom: &vegetav1alpha1.BodySource{
+					SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "order"}, Key: "order.json"},
+				}},
+				{Method: "PUT", URL: "https://shop.example.com/cart", Body: "{}"},
+				{URL: "https://shop.example.com/items"},
+			}
+			// The referenced secret does not need to be readable by the operator
+			r := newTargetRefReconciler()
+			Expect(r.reconcileTargets(context.Background(), vegeta)).To(Succeed())
+			secret := &corev1.Secret{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Name: "inline-targets", Namespace: TestNs}, secret)).To(Succeed())
+			Expect(secret.Data).To(Equal(map[string][]byte{
+				httpTargetsFile: []byte("POST https://shop.example.com/orders\nContent-Type: application/json\n@/opt/bodies/target-0.body\n\n" +
+					"PUT https://shop.example.com/cart\n@/opt/bodies/target-1.body\n\n" +
+					"GET https://shop.example.com/items\n\n"),
+				"target-1.body": []byte("{}"),
+			}))
+			Expect(getAttackCmd(vegeta)).To(HavePrefix("vegeta attack -targets /opt/targets/targets.http -format http "))
+
+			volumes, mounts := getAPVolumesAndMounts(vegeta)
+			Expect(mounts).To(ContainElement(corev1.VolumeMount{Name: "bodies", MountPath: bodiesPath, ReadOnly: true}))
+			var bodies *corev1.ProjectedVolumeSource
+			for _, v := range volumes {
+				if v.Name == "bodies" {
+					bodies = v.Projected
+				}
+			}
+			Expect(bodies).ToNot(BeNil())
+			Expect(bodies.Sources).To(HaveLen(2))
+			Expect(bodies.Sources[0].Secret.Name).To(Equal("order"))
+			Expect(bodies.Sources[0].Secret.Items).To(Equal([]corev1.KeyToPath{{Key: "order.json", Path: "target-0.body"}}))
+			Expect(bodies.Sources[1].Secret.Name).To(Equal("inline-targets"))
+			Expect(bodies.Sources[1].Secret.Items).To(Equal([]corev1.KeyToPath{{Key: "target-1.body", Path: "target-1.body"}}))
+		})
+		It("Should not render them once the run has terminated", func() {
+			vegeta.Status.Phase = vegetav1alpha1.CompletedPhase
+			vegeta.Status.RunID = vegeta.Spec.RunID
+			r := newTargetRefReconciler(vegeta)
+			r.Log = ctrl.Log.WithName("controllers").WithName("Vegeta")
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "inline", Namespace: TestNs}}
+			_, err := r.Reconcile(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+			secret := &corev1.Secret{}
+			err = r.Get(context.Background(), types.NamespacedName{Name: "inline-targets", Namespace: TestNs}, secret)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,118 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+)
+
+// evaluateThresholds checks the metrics against the thresholds and returns a description of the breached ones.
+// Assertions relative to the rate, e.g. throughput >= 0.95 * rate, are evaluated against the given requested rate.
+func evaluateThresholds(m *vegetaMetrics, thresholds []string, rate float64) []string {
+	var breaches []string
+	for _, expr := range thresholds {
+		t, err := vegetav1alpha1.ParseThreshold(expr)
+		if err != nil {
+			// Thresholds are validated by the admission webhook
+			breaches = append(breaches, fmt.Sprintf("%s (%v)", expr, err))
+			continue
+		}
+		actual := metricValue(m, t)
+		if !t.Holds(actual, rate) {
+			breaches = append(breaches, fmt.Sprintf("%s (actual: %s)", expr, t.FormatValue(actual)))
+		}
+	}
+	return breaches
+}
+
+// requestedRate returns the number of requests per second requested across all the replicas: the sum of the rates of the replicas with the total rate mode, the rate times the number of replicas otherwise.
+// The rate measured by vegeta is returned when no constant rate has been requested (stages or no rate limit), as there is nothing else to compare to.
+func requestedRate(v *vegetav1alpha1.Vegeta, m *vegetaMetrics) float64 {
+	if len(v.Status.ReplicaRates) > 0 {
+		var total float64
+		for _, r := range v.Status.ReplicaRates {
+			rate, _ := vegetav1alpha1.RatePerSecond(r)
+			total += rate
+		}
+		if total > 0 {
+			return total
+		}
+		return m.Rate
+	}
+	if v.Spec.Attack == nil {
+		return m.Rate
+	}
+	rate, _ := vegetav1alpha1.RatePerSecond(v.Spec.Attack.Rate)
+	if rate == 0 || len(v.Spec.Attack.Stages) > 0 {
+		return m.Rate
+	}
+	if v.Spec.RateMode == vegetav1alpha1.TotalRate || v.Spec.Replicas < 2 {
+		return rate
+	}
+	return rate * float64(v.Spec.Replicas)
+}
+
+// metricValue returns the value of the metric of the threshold in the unit used by the threshold
+func metricValue(m *vegetaMetrics, t *vegetav1alpha1.Threshold) float64 {
+	switch t.Metric {
+	case vegetav1alpha1.MinMetric:
+		return m.Latencies.Min.Seconds()
+	case vegetav1alpha1.MeanMetric:
+		return m.Latencies.Mean.Seconds()
+	case vegetav1alpha1.P50Metric:
+		return m.Latencies.P50.Seconds()
+	case vegetav1alpha1.P90Metric:
+		return m.Latencies.P90.Seconds()
+	case vegetav1alpha1.P95Metric:
+		return m.Latencies.P95.Seconds()
+	case vegetav1alpha1.P99Metric:
+		return m.Latencies.P99.Seconds()
+	case vegetav1alpha1.MaxMetric:
+		return m.Latencies.Max.Seconds()
+	case vegetav1alpha1.SuccessMetric:
+		return m.Success
+	case vegetav1alpha1.StatusMetric:
+		if m.Requests == 0 {
+			return 0
+		}
+		var count uint64
+		for code, c := range m.StatusCodes {
+			if matchStatus(code, t.Status) {
+				count += c
+			}
+		}
+		return float64(count) / float64(m.Requests)
+	case vegetav1alpha1.ThroughputMetric:
+		return m.Throughput
+	case vegetav1alpha1.RateMetric:
+		return m.Rate
+	case vegetav1alpha1.RequestsMetric:
+		return float64(m.Requests)
+	default:
+		return 0
+	}
+}
+
+// matchStatus returns true if the status code matches the code or class (e.g. 5xx) of a threshold
+func matchStatus(code, status string) bool {
+	if len(status) == 3 && status[1:] == "xx" {
+		return len(code) == 3 && code[0] == status[0]
+	}
+	return code == status
+}
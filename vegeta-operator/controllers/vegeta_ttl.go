@@ -0,0 +1,88 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// finishedAt returns the time the processing of the current run has terminated at, which is when the condition reflecting its outcome has last changed.
+// It returns nil while the processing is in progress.
+func finishedAt(v *vegetav1alpha1.Vegeta) *metav1.Time {
+	if !v.Status.Phase.IsTerminated() {
+		return nil
+	}
+	condType := vegetav1alpha1.ReadyCondition
+	if v.Status.Phase == vegetav1alpha1.FailedPhase {
+		condType = vegetav1alpha1.FailedCondition
+	}
+	c := meta.FindStatusCondition(v.Status.Conditions, condType)
+	if c == nil {
+		return nil
+	}
+	finished := c.LastTransitionTime
+	return &finished
+}
+
+// ttlOf returns the time to live of the finished pods of the vegeta resource, which defaults to the one configured for the operator. Nil means that they are kept.
+func (r *VegetaReconciler) ttlOf(v *vegetav1alpha1.Vegeta) *int32 {
+	if v.Spec.TTLSecondsAfterFinished != nil {
+		return v.Spec.TTLSecondsAfterFinished
+	}
+	return r.TTLSecondsAfterFinished
+}
+
+// ttlExpiry returns the time the pods of the finished run, and optionally the vegeta resource, are to be deleted at. It returns nil if there is nothing to delete.
+func (r *VegetaReconciler) ttlExpiry(v *vegetav1alpha1.Vegeta) *time.Time {
+	ttl := r.ttlOf(v)
+	finished := finishedAt(v)
+	if ttl == nil || finished == nil {
+		return nil
+	}
+	expiry := finished.Add(time.Duration(*ttl) * time.Second)
+	return &expiry
+}
+
+// cleanupFinished deletes the pods of the finished run or, when requested, the vegeta resource, whose pods get garbage collected.
+// The results are already persisted in the status at this point.
+func (r *VegetaReconciler) cleanupFinished(ctx context.Context, v *vegetav1alpha1.Vegeta, childPods *corev1.PodList) error {
+	if v.Spec.DeleteAfterFinished {
+		if err := r.Delete(ctx, v, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("Unable to delete the finished Vegeta resource: %v", err)
+		}
+		return nil
+	}
+	for i := range childPods.Items {
+		pod := &childPods.Items[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if err := r.Delete(ctx, pod, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("Unable to delete finished pod %s: %v", pod.Name, err)
+		}
+	}
+	return nil
+}
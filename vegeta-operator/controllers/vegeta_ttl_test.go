@@ -0,0 +1,93 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Vegeta ttl", func() {
+	finished := func(name string, at time.Time) *vegetav1alpha1.Vegeta {
+		vegeta := newVegeta(name)
+		setPhase(vegeta, vegetav1alpha1.CompletedPhase, "", "")
+		meta.FindStatusCondition(vegeta.Status.Conditions, vegetav1alpha1.ReadyCondition).LastTransitionTime = metav1.NewTime(at)
+		return vegeta
+	}
+	finishTime := metav1.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC).Time
+
+	Context("When the time to live is computed", func() {
+		It("Should prefer the one of the vegeta resource to the one of the operator", func() {
+			ttl, operatorTTL := int32(60), int32(3600)
+			vegeta := finished("ttl", finishTime)
+			r := &VegetaReconciler{}
+			Expect(r.ttlExpiry(vegeta)).Should(BeNil())
+			r.TTLSecondsAfterFinished = &operatorTTL
+			Expect(*r.ttlExpiry(vegeta)).Should(Equal(finishTime.Add(time.Hour)))
+			vegeta.Spec.TTLSecondsAfterFinished = &ttl
+			Expect(*r.ttlExpiry(vegeta)).Should(Equal(finishTime.Add(time.Minute)))
+		})
+		It("Should not expire while the run is in progress", func() {
+			ttl := int32(0)
+			vegeta := newVegeta("ttl-running")
+			vegeta.Spec.TTLSecondsAfterFinished = &ttl
+			setPhase(vegeta, vegetav1alpha1.RunningPhase, "", "")
+			Expect((&VegetaReconciler{}).ttlExpiry(vegeta)).Should(BeNil())
+		})
+	})
+
+	Context("When the time to live has expired", func() {
+		newReconciler := func(objs ...client.Object) *VegetaReconciler {
+			s := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+			Expect(vegetav1alpha1.AddToScheme(s)).To(Succeed())
+			return &VegetaReconciler{Client: fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build(), Scheme: s}
+		}
+
+		It("Should delete the pods and keep the vegeta resource", func() {
+			vegeta := finished("ttl-pods", finishTime)
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "attack-1", Namespace: TestNs}}
+			r := newReconciler(vegeta, pod)
+			Expect(r.cleanupFinished(context.Background(), vegeta, &corev1.PodList{Items: []corev1.Pod{*pod}})).To(Succeed())
+			err := r.Get(context.Background(), types.NamespacedName{Namespace: TestNs, Name: "attack-1"}, &corev1.Pod{})
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+			Expect(r.Get(context.Background(), types.NamespacedName{Namespace: TestNs, Name: "ttl-pods"}, &vegetav1alpha1.Vegeta{})).To(Succeed())
+		})
+		It("Should delete the vegeta resource when requested", func() {
+			vegeta := finished("ttl-vegeta", finishTime)
+			vegeta.Spec.DeleteAfterFinished = true
+			r := newReconciler(vegeta)
+			Expect(r.cleanupFinished(context.Background(), vegeta, &corev1.PodList{})).To(Succeed())
+			err := r.Get(context.Background(), types.NamespacedName{Namespace: TestNs, Name: "ttl-vegeta"}, &vegetav1alpha1.Vegeta{})
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+})
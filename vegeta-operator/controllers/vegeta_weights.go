@@ -0,0 +1,373 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// httpTargetsFile is the key of the rendered targets in the secret when they are in the http format
+	httpTargetsFile = "targets.http"
+	// targetMixTolerance is the maximum difference between the achieved and the expected share of the requests of a target for the mix to be honoured
+	targetMixTolerance = 0.01
+	// maxRenderedTargetsSize is the maximum size of the rendered targets. The size of a secret is limited to 1 MiB, some room is left for its metadata.
+	maxRenderedTargetsSize = 1000 * 1000
+)
+
+// weightLine matches the line carrying the weight of a target in the http format
+var weightLine = regexp.MustCompile(`(?i)^#\s*weight\s*:\s*(\S+)$`)
+
+// weightedTarget is a target rendered in the format of the targets file with its weight in the traffic mix
+type weightedTarget struct {
+	// key identifies the target in the breakdown of the results: its http verb and its url
+	key string
+	// weight of the target, 0 stands for 1
+	weight uint32
+	// rendered is the target without the trailing new line
+	rendered []byte
+}
+
+// isWeightedConfigMap returns true when the targets of the config map of the vegeta resource carry weights
+func isWeightedConfigMap(v *vegetav1alpha1.Vegeta) bool {
+	return v.Spec.Attack.TargetsConfigMap != "" && v.Spec.Attack.WeightedTargets
+}
+
+// hasTargetMix returns true when a traffic mix is requested by weighted targets, so that the achieved mix gets verified
+func hasTargetMix(v *vegetav1alpha1.Vegeta) bool {
+	if isWeightedConfigMap(v) {
+		return true
+	}
+	for _, t := range v.Spec.Attack.Targets {
+		if t.Weight > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// getRenderedTargetsFormat returns the format of the targets rendered by the operator: json, unless weighted targets of a config map are in the http format
+// or the body of an inline target is read from a secret
+func getRenderedTargetsFormat(v *vegetav1alpha1.Vegeta) vegetav1alpha1.TargetFormatEnum {
+	if (isWeightedConfigMap(v) && v.Spec.Attack.Format != vegetav1alpha1.JSONFormat) || hasSecretBody(v) {
+		return vegetav1alpha1.HTTPFormat
+	}
+	return vegetav1alpha1.JSONFormat
+}
+
+// getRenderedTargetsFile returns the key of the rendered targets in the secret
+func getRenderedTargetsFile(v *vegetav1alpha1.Vegeta) string {
+	if getRenderedTargetsFormat(v) == vegetav1alpha1.HTTPFormat {
+		return httpTargetsFile
+	}
+	return targetsFile
+}
+
+// renderWeightedTargets expands the weighted targets of the config map of the vegeta resource, keeping their format.
+// It also returns the traffic mix requested by the weights.
+func (r *VegetaReconciler) renderWeightedTargets(ctx context.Context, v *vegetav1alpha1.Vegeta) ([]byte, []vegetav1alpha1.TargetMixResults, error) {
+	name := v.Spec.Attack.TargetsConfigMap
+	format := getRenderedTargetsFormat(v)
+	key := "targets." + format.String()
+	cm := &corev1.ConfigMap{}
+	// The config map is not watched by the operator, it is read through the uncached reader
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: v.Namespace, Name: name}, cm); err != nil {
+		return nil, nil, fmt.Errorf("Failed to get config map %s: %v", name, err)
+	}
+	data, ok := cm.Data[key]
+	if !ok {
+		return nil, nil, fmt.Errorf("Key %s not found in config map %s", key, name)
+	}
+	var targets []weightedTarget
+	var err error
+	if format == vegetav1alpha1.JSONFormat {
+		targets, err = parseWeightedJSONTargets(data)
+	} else {
+		targets, err = parseWeightedHTTPTargets(data)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to parse the targets of config map %s: %v", name, err)
+	}
+	if len(targets) == 0 {
+		return nil, nil, fmt.Errorf("No target found in config map %s", name)
+	}
+	if err := checkDistinctTargets(targets); err != nil {
+		return nil, nil, fmt.Errorf("Invalid targets in config map %s: %v", name, err)
+	}
+	content, err := expandTargets(targets, format)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to expand the targets of config map %s: %v", name, err)
+	}
+	return content, targetMixOf(targets), nil
+}
+
+// parseWeightedJSONTargets parses targets in the vegeta json format, one per line, with an optional weight field.
+// The weight field is removed from the rendered targets.
+func parseWeightedJSONTargets(data string) ([]weightedTarget, error) {
+	var targets []weightedTarget
+	for _, line := range strings.Split(data, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		i := len(targets)
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			return nil, fmt.Errorf("target %d: %v", i, err)
+		}
+		t := weightedTarget{}
+		if raw, ok := fields["weight"]; ok {
+			if err := json.Unmarshal(raw, &t.weight); err != nil || t.weight < 1 || t.weight > vegetav1alpha1.MaxTargetWeight {
+				return nil, fmt.Errorf("target %d: the weight must be an integer between 1 and %d", i, vegetav1alpha1.MaxTargetWeight)
+			}
+			delete(fields, "weight")
+		}
+		var method, url string
+		if raw, ok := fields["method"]; ok {
+			_ = json.Unmarshal(raw, &method)
+		}
+		if raw, ok := fields["url"]; ok {
+			_ = json.Unmarshal(raw, &url)
+		}
+		if method == "" {
+			// Set explicitly so that the targets only differing by the default method are identical
+			method = http.MethodGet
+			fields["method"], _ = json.Marshal(method)
+		}
+		t.key = method + " " + url
+		// Re-encoding raw json values does not fail
+		t.rendered, _ = json.Marshal(fields)
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// parseWeightedHTTPTargets parses targets in the vegeta http format, separated by blank lines, with an optional "# weight: N" line.
+// The weight line is removed from the rendered targets.
+func parseWeightedHTTPTargets(data string) ([]weightedTarget, error) {
+	var targets []weightedTarget
+	var lines []string
+	t := weightedTarget{}
+	flush := func() {
+		if t.key != "" {
+			t.rendered = []byte(strings.Join(lines, "\n"))
+			targets = append(targets, t)
+		}
+		lines = nil
+		t = weightedTarget{}
+	}
+	for _, line := range strings.Split(data, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+			continue
+		case weightLine.MatchString(trimmed):
+			w, err := strconv.ParseUint(weightLine.FindStringSubmatch(trimmed)[1], 10, 32)
+			if err != nil || w < 1 || w > vegetav1alpha1.MaxTargetWeight {
+				return nil, fmt.Errorf("target %d: the weight must be an integer between 1 and %d", len(targets), vegetav1alpha1.MaxTargetWeight)
+			}
+			t.weight = uint32(w)
+			continue
+		case t.key == "" && !strings.HasPrefix(trimmed, "#"):
+			// The request line comes first, possibly after comments
+			t.key = strings.Join(strings.Fields(trimmed), " ")
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return targets, nil
+}
+
+// expandTargets renders the targets in the given format, each target being repeated in proportion to its weight.
+// As vegeta goes through the targets in a round robin fashion the requested mix is honoured at any rate.
+// It fails when the expanded targets would not fit into the secret they are mounted from.
+func expandTargets(targets []weightedTarget, format vegetav1alpha1.TargetFormatEnum) ([]byte, error) {
+	terminator := "\n"
+	if format == vegetav1alpha1.HTTPFormat {
+		// Targets in the http format are separated by blank lines
+		terminator = "\n\n"
+	}
+	weights := make([]uint32, len(targets))
+	for i, t := range targets {
+		weights[i] = t.weight
+	}
+	sequence := interleave(weights)
+	size := 0
+	for _, i := range sequence {
+		size += len(targets[i].rendered) + len(terminator)
+	}
+	if size > maxRenderedTargetsSize {
+		return nil, fmt.Errorf("the %d targets repeated according to their weights take %d bytes, more than the %d bytes a secret can hold: reduce the weights, e.g. by rounding them to multiples of 10, or the number of targets", len(targets), size, maxRenderedTargetsSize)
+	}
+	var buf bytes.Buffer
+	buf.Grow(size)
+	for _, i := range sequence {
+		buf.Write(targets[i].rendered)
+		buf.WriteString(terminator)
+	}
+	return buf.Bytes(), nil
+}
+
+// checkDistinctTargets verifies that weighted targets with the same http verb and url are identical.
+// The breakdown of the results identifies the targets by their verb and url only, the requests of targets differing by their headers or body could not be told apart.
+func checkDistinctTargets(targets []weightedTarget) error {
+	first := map[string]int{}
+	for i, t := range targets {
+		j, ok := first[t.key]
+		if !ok {
+			first[t.key] = i
+			continue
+		}
+		if !bytes.Equal(t.rendered, targets[j].rendered) {
+			return fmt.Errorf("targets %d and %d share the method and url %s but differ by their headers or body, their requests could not be told apart in the traffic mix", j, i, t.key)
+		}
+	}
+	return nil
+}
+
+// interleave returns the sequence of the indexes of the targets in which each target appears in proportion to its weight.
+// The weights are reduced by their greatest common divisor to keep the sequence short and the occurrences of the targets get spread
+// with a smooth weighted round robin, so that the mix is already honoured over a few requests.
+func interleave(weights []uint32) []int {
+	var divisor, total uint32
+	for i := range weights {
+		if weights[i] == 0 {
+			weights[i] = 1
+		}
+		divisor = gcd(divisor, weights[i])
+	}
+	for i := range weights {
+		weights[i] /= divisor
+		total += weights[i]
+	}
+	sequence := make([]int, 0, total)
+	current := make([]int64, len(weights))
+	for n := uint32(0); n < total; n++ {
+		selected := 0
+		for i, w := range weights {
+			current[i] += int64(w)
+			if current[i] > current[selected] {
+				selected = i
+			}
+		}
+		current[selected] -= int64(total)
+		sequence = append(sequence, selected)
+	}
+	return sequence
+}
+
+func gcd(a, b uint32) uint32 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// targetMixOf computes the traffic mix requested by the weights of the targets. Targets with the same http verb and url are accounted together.
+func targetMixOf(targets []weightedTarget) []vegetav1alpha1.TargetMixResults {
+	var mix []vegetav1alpha1.TargetMixResults
+	index := map[string]int{}
+	var total uint32
+	for _, t := range targets {
+		w := t.weight
+		if w == 0 {
+			w = 1
+		}
+		total += w
+		if i, ok := index[t.key]; ok {
+			mix[i].Weight += w
+			continue
+		}
+		index[t.key] = len(mix)
+		mix = append(mix, vegetav1alpha1.TargetMixResults{Target: t.key, Weight: w})
+	}
+	for i := range mix {
+		mix[i].Expected = formatShare(float64(mix[i].Weight) / float64(total))
+	}
+	return mix
+}
+
+// recordTargetMix records the mix achieved by the attack in the status once all the attack pods have terminated and verifies it against the requested mix.
+// The breakdown only reports the slowest targets of each pod: targets missing from it are only accounted with no request when all of them could have been reported.
+// It returns true if the achieved mix has been recorded.
+func recordTargetMix(v *vegetav1alpha1.Vegeta, attackPods []*corev1.Pod) bool {
+	if !hasTargetMix(v) || len(v.Status.TargetMix) == 0 || !attackTerminated(attackPods) {
+		return false
+	}
+	for _, m := range v.Status.TargetMix {
+		if m.Achieved != "" {
+			return false
+		}
+	}
+	var total uint64
+	requests := map[string]uint64{}
+	for _, pod := range attackPods {
+		record := podBreakdown(pod)
+		if record == nil {
+			continue
+		}
+		total += record.Requests
+		for _, t := range record.Targets {
+			requests[t.Method+" "+t.URL] += t.Requests
+		}
+	}
+	if total == 0 {
+		return false
+	}
+	complete := len(v.Status.TargetMix) <= breakdownMaxTargets
+	var deviations []string
+	verified := 0
+	for i := range v.Status.TargetMix {
+		m := &v.Status.TargetMix[i]
+		n, ok := requests[m.Target]
+		if !ok && !complete {
+			continue
+		}
+		m.Requests = n
+		achieved := float64(n) / float64(total)
+		m.Achieved = formatShare(achieved)
+		verified++
+		if expected, err := strconv.ParseFloat(m.Expected, 64); err == nil && math.Abs(achieved-expected) > targetMixTolerance {
+			deviations = append(deviations, fmt.Sprintf("%s got %s of the requests instead of %s", m.Target, m.Achieved, m.Expected))
+		}
+	}
+	if len(deviations) > 0 {
+		setCondition(v, vegetav1alpha1.TargetMixHonouredCondition, metav1.ConditionFalse, vegetav1alpha1.TargetMixDeviatesReason, strings.Join(deviations, ", "))
+	} else {
+		setCondition(v, vegetav1alpha1.TargetMixHonouredCondition, metav1.ConditionTrue, vegetav1alpha1.TargetMixHonouredReason,
+			fmt.Sprintf("The share of the requests of the %d verified targets matches their weights", verified))
+	}
+	return true
+}
+
+// formatShare formats a share of the requests the same way as the success ratio of the results
+func formatShare(share float64) string {
+	return strconv.FormatFloat(share, 'f', 4, 64)
+}
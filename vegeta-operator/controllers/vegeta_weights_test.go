@@ -0,0 +1,196 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// mixRecord returns a record of the breakdown with the given number of requests for the reads, searches and writes
+func mixRecord(reads, searches, writes int) string {
+	target := `{"method":"%s","url":"https://shop.example.com/%s","requests":%d,"success":1,"latencies":{}}`
+	return fmt.Sprintf(`{"breakdown":{"requests":%d,"targets":[`+target+","+target+","+target+`]}}`,
+		reads+searches+writes, "GET", "items", reads, "POST", "search", searches, "PUT", "items", writes)
+}
+
+var _ = Describe("Vegeta weights", func() {
+	var vegeta *vegetav1alpha1.Vegeta
+
+	BeforeEach(func() {
+		vegeta = newVegeta("weights")
+		vegeta.UID = "weights-uid"
+		vegeta.Spec.Attack.Target = ""
+	})
+
+	Context("When targets are weighted", func() {
+		It("Should spread the targets in proportion to their reduced weights", func() {
+			sequence := interleave([]uint32{70, 25, 5})
+			Expect(sequence).To(HaveLen(20))
+			counts := map[int]int{}
+			for i, t := range sequence {
+				counts[t]++
+				// The reads never come more than 3 times in a row
+				if i >= 3 && t == 0 {
+					Expect(sequence[i-3 : i]).ToNot(Equal([]int{0, 0, 0}))
+				}
+			}
+			Expect(counts).To(Equal(map[int]int{0: 14, 1: 5, 2: 1}))
+			Expect(interleave([]uint32{0, 0})).To(Equal([]int{0, 1}))
+		})
+		It("Should expand the inline targets and record the requested mix", func() {
+			vegeta.Spec.Attack.Targets = []vegetav1alpha1.AttackTarget{
+				{URL: "https://shop.example.com/items", Weight: 3},
+				{Method: "POST", URL: "https://shop.example.com/search"},
+			}
+			r := newTargetRefReconciler()
+			Expect(r.reconcileTargets(context.Background(), vegeta)).To(Succeed())
+			secret := &corev1.Secret{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Name: "weights-targets", Namespace: TestNs}, secret)).To(Succeed())
+			Expect(strings.Split(strings.TrimSpace(string(secret.Data[targetsFile])), "\n")).To(Equal([]string{
+				`{"method":"GET","url":"https://shop.example.com/items"}`,
+				`{"method":"GET","url":"https://shop.example.com/items"}`,
+				`{"method":"POST","url":"https://shop.example.com/search"}`,
+				`{"method":"GET","url":"https://shop.example.com/items"}`,
+			}))
+			Expect(vegeta.Status.TargetMix).To(Equal([]vegetav1alpha1.TargetMixResults{
+				{Target: "GET https://shop.example.com/items", Weight: 3, Expected: "0.7500"},
+				{Target: "POST https://shop.example.com/search", Weight: 1, Expected: "0.2500"},
+			}))
+			Expect(getAttackCmd(vegeta)).To(ContainSubstring(" | vegeta encode -to json | breakdown "))
+		})
+		It("Should expand the weighted json targets of a config map", func() {
+			vegeta.Spec.Attack.TargetsConfigMap = "mix"
+			vegeta.Spec.Attack.Format = vegetav1alpha1.JSONFormat
+			vegeta.Spec.Attack.WeightedTargets = true
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "mix", Namespace: TestNs},
+				Data: map[string]string{"targets.json": `{"method":"GET","url":"https://shop.example.com/items","weight":70}` + "\n" +
+					`{"method":"POST","url":"https://shop.example.com/search","body":"e30=","weight":25}` + "\n\n" +
+					`{"method":"PUT","url":"https://shop.example.com/items","weight":5}` + "\n"},
+			}
+			r := newTargetRefReconciler(cm)
+			Expect(r.reconcileTargets(context.Background(), vegeta)).To(Succeed())
+			secret := &corev1.Secret{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Name: "weights-targets", Namespace: TestNs}, secret)).To(Succeed())
+			lines := strings.Split(strings.TrimSpace(string(secret.Data[targetsFile])), "\n")
+			Expect(lines).To(HaveLen(20))
+			Expect(lines).To(ContainElement(`{"body":"e30=","method":"POST","url":"https://shop.example.com/search"}`))
+			Expect(string(secret.Data[targetsFile])).ToNot(ContainSubstring("weight"))
+			Expect(vegeta.Status.TargetMix).To(HaveLen(3))
+			Expect(vegeta.Status.TargetMix[2]).To(Equal(vegetav1alpha1.TargetMixResults{Target: "PUT https://shop.example.com/items", Weight: 5, Expected: "0.0500"}))
+
+			Expect(getAttackCmd(vegeta)).To(HavePrefix("vegeta attack -targets /opt/targets/targets.json -format json "))
+			volumes, _ := getAPVolumesAndMounts(vegeta)
+			var names []string
+			for _, v := range volumes {
+				names = append(names, v.Name)
+			}
+			Expect(names).To(ContainElement("inline-targets"))
+			Expect(names).ToNot(ContainElement("targets"))
+		})
+		It("Should expand the weighted http targets of a config map in the http format", func() {
+			vegeta.Spec.Attack.TargetsConfigMap = "mix"
+			vegeta.Spec.Attack.WeightedTargets = true
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "mix", Namespace: TestNs},
+				Data: map[string]string{"targets.http": "# weight: 2\nGET https://shop.example.com/items\nAccept: application/json\n\n" +
+					"# the searches\nPOST https://shop.example.com/search\n@/opt/config/body.txt\n"},
+			}
+			r := newTargetRefReconciler(cm)
+			Expect(r.reconcileTargets(context.Background(), vegeta)).To(Succeed())
+			secret := &corev1.Secret{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Name: "weights-targets", Namespace: TestNs}, secret)).To(Succeed())
+			Expect(string(secret.Data[httpTargetsFile])).To(Equal(
+				"GET https://shop.example.com/items\nAccept: application/json\n\n" +
+					"# the searches\nPOST https://shop.example.com/search\n@/opt/config/body.txt\n\n" +
+					"GET https://shop.example.com/items\nAccept: application/json\n\n"))
+			Expect(vegeta.Status.TargetMix[1].Target).To(Equal("POST https://shop.example.com/search"))
+			Expect(getAttackCmd(vegeta)).To(HavePrefix("vegeta attack -targets /opt/targets/targets.http -format http "))
+		})
+		It("Should reject targets that would not fit into the secret once expanded", func() {
+			targets := []weightedTarget{
+				{key: "GET https://shop.example.com/items", weight: 999, rendered: []byte(`{"method":"GET","url":"https://shop.example.com/items","body":"` + strings.Repeat("x", 2000) + `"}`)},
+				{key: "GET https://shop.example.com/cart", weight: 1000, rendered: []byte(`{"method":"GET","url":"https://shop.example.com/cart"}`)},
+			}
+			_, err := expandTargets(targets, vegetav1alpha1.JSONFormat)
+			Expect(err).To(MatchError(ContainSubstring("more than the 1000000 bytes a secret can hold")))
+			targets[0].weight = 1
+			content, err := expandTargets(targets, vegetav1alpha1.JSONFormat)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(content)).To(BeNumerically("<", maxRenderedTargetsSize))
+		})
+		It("Should reject targets of a config map sharing their method and url but not their headers or body", func() {
+			targets, err := parseWeightedHTTPTargets("# weight: 2\nGET https://shop.example.com/items\nAccept: application/json\n\n" +
+				"# weight: 1\nGET https://shop.example.com/items\nAccept: text/html\n")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(checkDistinctTargets(targets)).To(MatchError(ContainSubstring("targets 0 and 1 share the method and url GET https://shop.example.com/items")))
+			targets, err = parseWeightedJSONTargets(`{"method":"GET","url":"https://shop.example.com/items","weight":2}` + "\n" + `{"url":"https://shop.example.com/items","weight":1}`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(checkDistinctTargets(targets)).To(Succeed())
+		})
+		It("Should reject an invalid weight", func() {
+			_, err := parseWeightedHTTPTargets("# weight: 0\nGET https://shop.example.com/items\n")
+			Expect(err).To(HaveOccurred())
+			_, err = parseWeightedJSONTargets(`{"method":"GET","url":"https://shop.example.com/items","weight":"heavy"}`)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("When the attack pods have terminated", func() {
+		BeforeEach(func() {
+			vegeta.Spec.Attack.Targets = []vegetav1alpha1.AttackTarget{
+				{URL: "https://shop.example.com/items", Weight: 70},
+				{Method: "POST", URL: "https://shop.example.com/search", Weight: 25},
+				{Method: "PUT", URL: "https://shop.example.com/items", Weight: 5},
+			}
+			_, mix, err := (&VegetaReconciler{}).renderTargets(context.Background(), vegeta)
+			Expect(err).ToNot(HaveOccurred())
+			vegeta.Status.TargetMix = mix
+		})
+		It("Should verify the achieved mix over the replicas", func() {
+			pods := []*corev1.Pod{terminatedWith("a", testReport+"\n"+mixRecord(700, 250, 50)+"\n"), terminatedWith("b", mixRecord(140, 50, 10)+"\n")}
+			pods[0].Status.Phase = corev1.PodSucceeded
+			pods[1].Status.Phase = corev1.PodSucceeded
+			Expect(recordTargetMix(vegeta, pods)).To(BeTrue())
+			Expect(vegeta.Status.TargetMix[0].Requests).To(Equal(uint64(840)))
+			Expect(vegeta.Status.TargetMix[0].Achieved).To(Equal("0.7000"))
+			Expect(meta.IsStatusConditionTrue(vegeta.Status.Conditions, vegetav1alpha1.TargetMixHonouredCondition)).To(BeTrue())
+			// The achieved mix is only recorded once
+			Expect(recordTargetMix(vegeta, pods)).To(BeFalse())
+		})
+		It("Should report a target whose share deviates from its weight", func() {
+			pods := []*corev1.Pod{terminatedWith("a", mixRecord(800, 200, 0)+"\n")}
+			pods[0].Status.Phase = corev1.PodFailed
+			Expect(recordTargetMix(vegeta, pods)).To(BeTrue())
+			cond := meta.FindStatusCondition(vegeta.Status.Conditions, vegetav1alpha1.TargetMixHonouredCondition)
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(vegetav1alpha1.TargetMixDeviatesReason))
+			Expect(cond.Message).To(ContainSubstring("GET https://shop.example.com/items got 0.8000 of the requests instead of 0.7000"))
+		})
+	})
+})
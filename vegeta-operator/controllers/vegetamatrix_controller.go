@@ -0,0 +1,282 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	"github.com/fgiloux/vegeta-operator/operator"
+)
+
+// matrixLabel records on the created Vegeta resources the name of the matrix that created them
+const matrixLabel = "vegeta.testing.io/matrix"
+
+// matrixOwnerKey indexes the Vegeta resources by the name of the matrix owning them
+var matrixOwnerKey = ".metadata.matrixController"
+
+// VegetaMatrixReconciler reconciles a VegetaMatrix object
+type VegetaMatrixReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	Labels operator.Labels
+	Clock
+	// APIReader reads the Vegeta resources recorded as created that are not in the cache yet. Defaulted to the uncached reader of the manager.
+	APIReader client.Reader
+}
+
+// +kubebuilder:rbac:groups=vegeta.testing.io,resources=vegetamatrices,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vegeta.testing.io,resources=vegetamatrices/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vegeta.testing.io,resources=vegetamatrices/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
+
+// Reconcile runs the combinations of the matrix one after the other, tabulates their results into a config map and estimates the knee of the capacity curves.
+func (r *VegetaMatrixReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("vegetamatrix", req.NamespacedName)
+	log.V(1).Info("Starting reconciliation")
+
+	matrix := &vegetav1alpha1.VegetaMatrix{}
+	if err := r.Get(ctx, req.NamespacedName, matrix); err != nil {
+		if errors.IsNotFound(err) {
+			// Owned Vegeta resources and results config map are automatically garbage collected
+			log.V(1).Info("VegetaMatrix resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("Failed to get VegetaMatrix resource: %v", err)
+	}
+	if matrix.Status.Phase.IsTerminated() {
+		return ctrl.Result{}, nil
+	}
+
+	var children vegetav1alpha1.VegetaList
+	if err := r.List(ctx, &children, client.InNamespace(req.Namespace), client.MatchingFields{matrixOwnerKey: req.Name}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("List VegetaMatrix's child Vegeta resources: %v", err)
+	}
+	byName := make(map[string]*vegetav1alpha1.Vegeta, len(children.Items))
+	for i := range children.Items {
+		// The index matches the name of the owner only, e.g. a previous matrix with the same name
+		if metav1.IsControlledBy(&children.Items[i], matrix) {
+			byName[children.Items[i].Name] = &children.Items[i]
+		}
+	}
+	if err := addMissingChildren(ctx, r.APIReader, matrix, createdMatrixChildren(matrix), byName); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status := matrix.Status.DeepCopy()
+	vegeta, err := r.advanceMatrix(matrix, byName, r.Now())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	requeue := false
+	if vegeta != nil {
+		created, err := createChild(ctx, r.Client, r.APIReader, matrix, vegeta)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("Unable to create the Vegeta resource %s of the matrix: %v", vegeta.Name, err)
+		}
+		if created {
+			log.V(0).Info("Created Vegeta resource", "vegeta", vegeta.Name)
+		} else {
+			// The run fails rather than reporting the outcome of a resource the matrix does not control
+			log.V(0).Info("Vegeta resource not controlled by the matrix", "vegeta", vegeta.Name)
+			for i := range matrix.Status.Runs {
+				if matrix.Status.Runs[i].Vegeta == vegeta.Name {
+					matrix.Status.Runs[i].RunSummary = notControlledSummary(vegeta.Name, "matrix", r.Now())
+				}
+			}
+			requeue = true
+		}
+	}
+	if finishedRuns(matrix.Status.Runs) > finishedRuns(status.Runs) {
+		if err := r.reconcileResultsConfigMap(ctx, matrix); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if !equality.Semantic.DeepEqual(status, &matrix.Status) {
+		if err := r.Status().Update(ctx, matrix); err != nil {
+			return ctrl.Result{}, fmt.Errorf("Unable to update VegetaMatrix status: %v", err)
+		}
+	}
+	return ctrl.Result{Requeue: requeue}, nil
+}
+
+// advanceMatrix updates the status of the matrix with the outcome of the current run and moves on to the next combination once it has finished.
+// It returns the Vegeta resource to create for the current combination, if it has not been created yet.
+// Vegeta resources recorded as created are never created again: the run fails if they have been deleted before their outcome could be recorded.
+func (r *VegetaMatrixReconciler) advanceMatrix(matrix *vegetav1alpha1.VegetaMatrix, children map[string]*vegetav1alpha1.Vegeta, now time.Time) (*vegetav1alpha1.Vegeta, error) {
+	status := &matrix.Status
+	if status.Phase == "" {
+		status.StartTime = &metav1.Time{Time: now}
+		setMatrixPhase(matrix, vegetav1alpha1.PendingPhase, vegetav1alpha1.PendingReason, "No run has started yet")
+	}
+	combinations := matrix.Spec.Matrix.Combinations()
+	for i, c := range combinations {
+		if i < len(status.Runs) && status.Runs[i].Phase.IsTerminated() {
+			continue
+		}
+		name := vegetav1alpha1.MatrixVegetaName(matrix.Name, i)
+		if i >= len(status.Runs) {
+			status.Runs = append(status.Runs, vegetav1alpha1.MatrixRun{Rate: c.Rate, Target: c.Target, Vegeta: name})
+			setMatrixPhase(matrix, vegetav1alpha1.RunningPhase, vegetav1alpha1.RunningReason, fmt.Sprintf("Run %d/%d is in progress", i+1, len(combinations)))
+		}
+		vegeta, ok := children[name]
+		if !ok && status.Runs[i].Phase != "" {
+			status.Runs[i].RunSummary = deletedSummary(name, now)
+			continue
+		}
+		if !ok {
+			vegeta, err := r.constructVegetaForMatrix(matrix, i, c)
+			if err != nil {
+				return nil, err
+			}
+			status.Runs[i].Phase = vegetav1alpha1.PendingPhase
+			return vegeta, nil
+		}
+		status.Runs[i].RunSummary = childSummary(vegeta)
+		if !vegeta.Status.Phase.IsTerminated() {
+			return nil, nil
+		}
+	}
+
+	status.CompletionTime = &metav1.Time{Time: now}
+	status.Knees = estimateKnees(matrix)
+	message := fmt.Sprintf("The %d runs have finished", len(combinations))
+	if n := len(combinations) - completedRuns(status.Runs); n > 0 {
+		message += fmt.Sprintf(", %d of them without completing", n)
+	}
+	setMatrixPhase(matrix, vegetav1alpha1.CompletedPhase, vegetav1alpha1.CompletedReason, message)
+	return nil, nil
+}
+
+// createdMatrixChildren returns the names of the Vegeta resources recorded as created whose outcome has not been recorded yet
+func createdMatrixChildren(matrix *vegetav1alpha1.VegetaMatrix) []string {
+	var names []string
+	for _, run := range matrix.Status.Runs {
+		if run.Phase != "" && !run.Phase.IsTerminated() {
+			names = append(names, run.Vegeta)
+		}
+	}
+	return names
+}
+
+// finishedRuns returns the number of runs that have finished
+func finishedRuns(runs []vegetav1alpha1.MatrixRun) int {
+	n := 0
+	for i := range runs {
+		if runs[i].Phase.IsTerminated() {
+			n++
+		}
+	}
+	return n
+}
+
+// completedRuns returns the number of runs that have completed
+func completedRuns(runs []vegetav1alpha1.MatrixRun) int {
+	n := 0
+	for i := range runs {
+		if runs[i].Phase == vegetav1alpha1.CompletedPhase {
+			n++
+		}
+	}
+	return n
+}
+
+// constructVegetaForMatrix generates the Vegeta resource of a combination of the matrix
+func (r *VegetaMatrixReconciler) constructVegetaForMatrix(matrix *vegetav1alpha1.VegetaMatrix, index int, c vegetav1alpha1.MatrixCombination) (*vegetav1alpha1.Vegeta, error) {
+	vegeta := &vegetav1alpha1.Vegeta{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        vegetav1alpha1.MatrixVegetaName(matrix.Name, index),
+			Namespace:   matrix.Namespace,
+			Labels:      r.Labels.Merge(map[string]string{matrixLabel: matrix.Name}),
+			Annotations: map[string]string{},
+		},
+		Spec: matrix.VegetaSpecFor(c),
+	}
+	for k, v := range matrix.Spec.VegetaTemplate.Metadata.Labels {
+		if _, ok := vegeta.Labels[k]; !ok {
+			vegeta.Labels[k] = v
+		}
+	}
+	for k, v := range matrix.Spec.VegetaTemplate.Metadata.Annotations {
+		vegeta.Annotations[k] = v
+	}
+	if err := ctrl.SetControllerReference(matrix, vegeta, r.Scheme); err != nil {
+		return nil, fmt.Errorf("Unable to construct the Vegeta resource of run %d: %v", index, err)
+	}
+	return vegeta, nil
+}
+
+// setMatrixPhase sets the phase of the matrix together with the matching Ready and Complete conditions
+func setMatrixPhase(matrix *vegetav1alpha1.VegetaMatrix, phase vegetav1alpha1.PhaseEnum, reason, message string) {
+	matrix.Status.Phase = phase
+	set := func(condType string, status metav1.ConditionStatus) {
+		meta.SetStatusCondition(&matrix.Status.Conditions, metav1.Condition{
+			Type:               condType,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: matrix.Generation,
+		})
+	}
+	if phase == vegetav1alpha1.CompletedPhase {
+		set(vegetav1alpha1.ReadyCondition, metav1.ConditionTrue)
+		set(vegetav1alpha1.CompleteCondition, metav1.ConditionTrue)
+		return
+	}
+	set(vegetav1alpha1.ReadyCondition, metav1.ConditionFalse)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VegetaMatrixReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Clock == nil {
+		r.Clock = RealClock{}
+	}
+	if r.APIReader == nil {
+		r.APIReader = mgr.GetAPIReader()
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &vegetav1alpha1.Vegeta{}, matrixOwnerKey, func(rawObj client.Object) []string {
+		vegeta := rawObj.(*vegetav1alpha1.Vegeta)
+		owner := metav1.GetControllerOf(vegeta)
+		if owner == nil {
+			return nil
+		}
+		if owner.APIVersion != apiGVStr || owner.Kind != "VegetaMatrix" {
+			return nil
+		}
+		return []string{owner.Name}
+	}); err != nil {
+		return fmt.Errorf("SetupWithManager: %v", err)
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vegetav1alpha1.VegetaMatrix{}).
+		Owns(&vegetav1alpha1.Vegeta{}).
+		Owns(&corev1.ConfigMap{}).
+		Complete(r)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newVegetaMatrix(name string, rates ...string) *vegetav1alpha1.VegetaMatrix {
+	template := newVegeta("template").Spec
+	template.Attack.Rate = ""
+	return &vegetav1alpha1.VegetaMatrix{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: TestNs,
+			UID:       "matrix-uid",
+		},
+		Spec: vegetav1alpha1.VegetaMatrixSpec{
+			Matrix: vegetav1alpha1.MatrixParameters{Rates: rates},
+			VegetaTemplate: vegetav1alpha1.VegetaTemplateSpec{
+				Metadata: vegetav1alpha1.VegetaTemplateMeta{Labels: map[string]string{"test": "capacity"}},
+				Spec:     template,
+			},
+		},
+	}
+}
+
+// measuredVegeta returns a completed Vegeta resource of a matrix run with the given throughput and p99 latency
+func measuredVegeta(name, throughput string, p99 time.Duration) *vegetav1alpha1.Vegeta {
+	v := newVegeta(name)
+	v.Status.Phase = vegetav1alpha1.CompletedPhase
+	v.Status.Results = &vegetav1alpha1.AttackResults{
+		Requests:   1000,
+		Throughput: throughput,
+		Success:    "1.0000",
+		Latencies:  &vegetav1alpha1.LatencyResults{P99: &metav1.Duration{Duration: p99}},
+	}
+	return v
+}
+
+var _ = Describe("Vegeta matrix", func() {
+	start := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	var r *VegetaMatrixReconciler
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(vegetav1alpha1.AddToScheme(s)).To(Succeed())
+		r = &VegetaMatrixReconciler{Scheme: s}
+	})
+
+	Context("When the matrix is reconciled", func() {
+		It("Should run the combinations one after the other", func() {
+			matrix := newVegetaMatrix("capacity", "100/1s", "200/1s")
+			vegeta, err := r.advanceMatrix(matrix, nil, start)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(vegeta.Name).To(Equal("capacity-0"))
+			Expect(vegeta.Spec.Attack.Rate).To(Equal("100/1s"))
+			Expect(vegeta.Labels).To(HaveKeyWithValue(matrixLabel, "capacity"))
+			Expect(vegeta.Labels).To(HaveKeyWithValue("test", "capacity"))
+			Expect(metav1.GetControllerOf(vegeta).Name).To(Equal("capacity"))
+			Expect(matrix.Status.Phase).To(Equal(vegetav1alpha1.RunningPhase))
+			Expect(matrix.Status.Runs).To(HaveLen(1))
+
+			running := newVegeta("capacity-0")
+			running.Status.Phase = vegetav1alpha1.RunningPhase
+			vegeta, err = r.advanceMatrix(matrix, map[string]*vegetav1alpha1.Vegeta{running.Name: running}, start)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(vegeta).To(BeNil())
+			Expect(matrix.Status.Runs).To(HaveLen(1))
+
+			first := measuredVegeta("capacity-0", "100", 10*time.Millisecond)
+			children := map[string]*vegetav1alpha1.Vegeta{first.Name: first}
+			vegeta, err = r.advanceMatrix(matrix, children, start.Add(time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(vegeta.Name).To(Equal("capacity-1"))
+			Expect(vegeta.Spec.Attack.Rate).To(Equal("200/1s"))
+			Expect(matrix.Status.Runs).To(HaveLen(2))
+			Expect(matrix.Status.Runs[0].Phase).To(Equal(vegetav1alpha1.CompletedPhase))
+
+			// The first Vegeta resource may have been deleted after it has finished
+			second := measuredVegeta("capacity-1", "200", 12*time.Millisecond)
+			vegeta, err = r.advanceMatrix(matrix, map[string]*vegetav1alpha1.Vegeta{second.Name: second}, start.Add(2*time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(vegeta).To(BeNil())
+			Expect(matrix.Status.Phase).To(Equal(vegetav1alpha1.CompletedPhase))
+			Expect(matrix.Status.CompletionTime.Time).To(Equal(start.Add(2 * time.Minute)))
+			Expect(matrix.Status.Runs[0].Results.Throughput).To(Equal("100"))
+			Expect(matrix.Status.Knees).To(HaveLen(1))
+			Expect(meta.IsStatusConditionTrue(matrix.Status.Conditions, vegetav1alpha1.CompleteCondition)).To(BeTrue())
+		})
+		It("Should fail a run whose Vegeta resource has been deleted before it was recorded rather than run it again", func() {
+			matrix := newVegetaMatrix("capacity", "100/1s", "200/1s")
+			vegeta, err := r.advanceMatrix(matrix, nil, start)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(vegeta.Name).To(Equal("capacity-0"))
+			Expect(matrix.Status.Runs[0].Phase).To(Equal(vegetav1alpha1.PendingPhase))
+
+			vegeta, err = r.advanceMatrix(matrix, nil, start.Add(time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(vegeta.Name).To(Equal("capacity-1"))
+			Expect(matrix.Status.Runs[0].Phase).To(Equal(vegetav1alpha1.FailedPhase))
+			Expect(matrix.Status.Runs[0].Reason).To(Equal(vegetaDeletedReason))
+		})
+	})
+
+	Context("When a Vegeta resource with the name of a run already exists", func() {
+		It("Should fail the run if the matrix does not control it", func() {
+			matrix := newVegetaMatrix("capacity", "100/1s")
+			foreign := newVegeta("capacity-0")
+			foreign.Status.Phase = vegetav1alpha1.CompletedPhase
+			s := r.Scheme
+			Expect(corev1.AddToScheme(s)).To(Succeed())
+			c := fake.NewClientBuilder().WithScheme(s).WithObjects(matrix, foreign).Build()
+			r = &VegetaMatrixReconciler{Client: c, APIReader: c, Scheme: s, Log: ctrl.Log.WithName("controllers").WithName("VegetaMatrix"), Clock: RealClock{}}
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: matrix.Name, Namespace: TestNs}}
+			result, err := r.Reconcile(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Requeue).To(BeTrue())
+
+			updated := &vegetav1alpha1.VegetaMatrix{}
+			Expect(c.Get(context.Background(), req.NamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Runs[0].Reason).To(Equal(vegetaNotControlledReason))
+			Expect(updated.Status.Runs[0].Phase).To(Equal(vegetav1alpha1.FailedPhase))
+			// The foreign resource is left untouched
+			existing := &vegetav1alpha1.Vegeta{}
+			Expect(c.Get(context.Background(), types.NamespacedName{Name: "capacity-0", Namespace: TestNs}, existing)).To(Succeed())
+			Expect(metav1.GetControllerOf(existing)).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,235 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// resultsCSVFile is the key of the csv table of the results of a matrix in its config map
+	resultsCSVFile = "results.csv"
+	// resultsJSONFile is the key of the json table of the results of a matrix in its config map
+	resultsJSONFile = "results.json"
+
+	// kneeMinThroughputRatio is the ratio of the requested rate under which the throughput is considered saturated
+	kneeMinThroughputRatio = 0.9
+	// kneeMinSuccess is the success ratio under which a rate is considered saturated
+	kneeMinSuccess = 0.99
+	// kneeMaxP99Factor is the factor of the p99 latency of the lowest rate above which a rate is considered saturated
+	kneeMaxP99Factor = 2
+)
+
+// matrixRow is a row of the table of the results of a matrix. Latencies are in milliseconds to make them easy to plot.
+type matrixRow struct {
+	Target     string  `json:"target,omitempty"`
+	Rate       string  `json:"rate,omitempty"`
+	Vegeta     string  `json:"vegeta"`
+	Phase      string  `json:"phase"`
+	Requests   uint64  `json:"requests"`
+	Throughput float64 `json:"throughput"`
+	Success    float64 `json:"success"`
+	MeanMs     float64 `json:"meanMs"`
+	P50Ms      float64 `json:"p50Ms"`
+	P90Ms      float64 `json:"p90Ms"`
+	P95Ms      float64 `json:"p95Ms"`
+	P99Ms      float64 `json:"p99Ms"`
+	MaxMs      float64 `json:"maxMs"`
+}
+
+// getResultsConfigMapName generates the name of the config map containing the table of the results of a matrix
+func getResultsConfigMapName(matrix *vegetav1alpha1.VegetaMatrix) string {
+	return matrix.Name + "-results"
+}
+
+// reconcileResultsConfigMap writes the table of the results of the finished runs of the matrix into a config map, in csv and json formats
+func (r *VegetaMatrixReconciler) reconcileResultsConfigMap(ctx context.Context, matrix *vegetav1alpha1.VegetaMatrix) error {
+	rows := matrixRows(matrix.Status.Runs)
+	csvTable, err := formatCSV(rows)
+	if err != nil {
+		return fmt.Errorf("Unable to format the results of the matrix as csv: %v", err)
+	}
+	jsonTable, err := json.MarshalIndent(rows, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Unable to format the results of the matrix as json: %v", err)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getResultsConfigMapName(matrix),
+			Namespace: matrix.Namespace,
+		},
+	}
+	_, err = ctrl.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		if err := ensureControlledBy(cm, matrix); err != nil {
+			return err
+		}
+		cm.Labels = r.Labels.Merge(map[string]string{matrixLabel: matrix.Name})
+		cm.Data = map[string]string{
+			resultsCSVFile:  csvTable,
+			resultsJSONFile: string(jsonTable),
+		}
+		return ctrl.SetControllerReference(matrix, cm, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to create or update the results config map %s: %v", cm.Name, err)
+	}
+	matrix.Status.ResultsConfigMap = cm.Name
+	return nil
+}
+
+// matrixRows converts the finished runs into rows of the results table
+func matrixRows(runs []vegetav1alpha1.MatrixRun) []matrixRow {
+	rows := []matrixRow{}
+	for i := range runs {
+		run := &runs[i]
+		if !run.Phase.IsTerminated() {
+			continue
+		}
+		row := matrixRow{Target: run.Target, Rate: run.Rate, Vegeta: run.Vegeta, Phase: run.Phase.String()}
+		if res := run.Results; res != nil {
+			row.Requests = res.Requests
+			row.Throughput, _ = strconv.ParseFloat(res.Throughput, 64)
+			row.Success, _ = strconv.ParseFloat(res.Success, 64)
+			if l := res.Latencies; l != nil {
+				row.MeanMs = milliseconds(l.Mean)
+				row.P50Ms = milliseconds(l.P50)
+				row.P90Ms = milliseconds(l.P90)
+				row.P95Ms = milliseconds(l.P95)
+				row.P99Ms = milliseconds(l.P99)
+				row.MaxMs = milliseconds(l.Max)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// formatCSV formats the rows of the results table as csv with a header line
+func formatCSV(rows []matrixRow) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"target", "rate", "vegeta", "phase", "requests", "throughput", "success", "meanMs", "p50Ms", "p90Ms", "p95Ms", "p99Ms", "maxMs"})
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, row := range rows {
+		w.Write([]string{row.Target, row.Rate, row.Vegeta, row.Phase, strconv.FormatUint(row.Requests, 10),
+			f(row.Throughput), f(row.Success), f(row.MeanMs), f(row.P50Ms), f(row.P90Ms), f(row.P95Ms), f(row.P99Ms), f(row.MaxMs)})
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
+
+// milliseconds converts a duration into milliseconds, rounded to the microsecond
+func milliseconds(d *metav1.Duration) float64 {
+	if d == nil {
+		return 0
+	}
+	return math.Round(float64(d.Duration)/float64(time.Microsecond)) / 1000
+}
+
+// estimateKnees estimates for each target the knee of its capacity curve: the highest rate run before the first saturated one.
+// Knees are only estimated when the matrix has several rates.
+func estimateKnees(matrix *vegetav1alpha1.VegetaMatrix) []vegetav1alpha1.MatrixKnee {
+	if len(matrix.Spec.Matrix.Rates) < 2 {
+		return nil
+	}
+	var knees []vegetav1alpha1.MatrixKnee
+	var targets []string
+	byTarget := map[string][]*vegetav1alpha1.MatrixRun{}
+	for i := range matrix.Status.Runs {
+		run := &matrix.Status.Runs[i]
+		if _, ok := byTarget[run.Target]; !ok {
+			targets = append(targets, run.Target)
+		}
+		byTarget[run.Target] = append(byTarget[run.Target], run)
+	}
+	for _, target := range targets {
+		knee := estimateKnee(byTarget[target])
+		knee.Target = target
+		knees = append(knees, knee)
+	}
+	return knees
+}
+
+// estimateKnee estimates the knee of the capacity curve of a target from its runs
+func estimateKnee(runs []*vegetav1alpha1.MatrixRun) vegetav1alpha1.MatrixKnee {
+	perSecond := func(run *vegetav1alpha1.MatrixRun) float64 {
+		rate, _ := vegetav1alpha1.RatePerSecond(run.Rate)
+		if rate == 0 {
+			// No rate limit, sent as fast as possible
+			return math.Inf(1)
+		}
+		return rate
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return perSecond(runs[i]) < perSecond(runs[j]) })
+
+	var baseline time.Duration
+	var previous *vegetav1alpha1.MatrixRun
+	for _, run := range runs {
+		reason := saturation(run, perSecond(run), baseline)
+		if reason != "" {
+			if previous == nil {
+				return vegetav1alpha1.MatrixKnee{Message: fmt.Sprintf("Already saturated at the lowest rate %s: %s", run.Rate, reason)}
+			}
+			return vegetav1alpha1.MatrixKnee{
+				Rate:       previous.Rate,
+				Throughput: previous.Results.Throughput,
+				P99:        previous.Results.Latencies.P99,
+				Message:    fmt.Sprintf("Saturated at rate %s: %s", run.Rate, reason),
+			}
+		}
+		if previous == nil {
+			baseline = run.Results.Latencies.P99.Duration
+		}
+		previous = run
+	}
+	if previous == nil {
+		return vegetav1alpha1.MatrixKnee{Message: "No run has finished"}
+	}
+	return vegetav1alpha1.MatrixKnee{Message: fmt.Sprintf("No saturation observed up to rate %s", previous.Rate)}
+}
+
+// saturation returns why a run is considered saturated, or an empty string if it is not
+func saturation(run *vegetav1alpha1.MatrixRun, rate float64, baseline time.Duration) string {
+	res := run.Results
+	if res == nil || res.Latencies == nil || res.Latencies.P99 == nil {
+		return fmt.Sprintf("run %s has not produced any result", run.Vegeta)
+	}
+	throughput, _ := strconv.ParseFloat(res.Throughput, 64)
+	if !math.IsInf(rate, 1) && throughput < kneeMinThroughputRatio*rate {
+		return fmt.Sprintf("the throughput %s is below %g%% of the rate", res.Throughput, kneeMinThroughputRatio*100)
+	}
+	if success, _ := strconv.ParseFloat(res.Success, 64); success < kneeMinSuccess {
+		return fmt.Sprintf("the success ratio %s is below %g", res.Success, kneeMinSuccess)
+	}
+	if baseline > 0 && res.Latencies.P99.Duration > kneeMaxP99Factor*baseline {
+		return fmt.Sprintf("the p99 latency %s is more than %d times the %s of the lowest rate", res.Latencies.P99.Duration, kneeMaxP99Factor, baseline)
+	}
+	return ""
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// matrixRun returns a finished run of a matrix with the given rate, throughput and p99 latency
+func matrixRun(target, rate, throughput string, p99 time.Duration) vegetav1alpha1.MatrixRun {
+	v := measuredVegeta("run", throughput, p99)
+	return vegetav1alpha1.MatrixRun{Target: target, Rate: rate, Vegeta: "run-" + rate, RunSummary: summaryOf(v)}
+}
+
+var _ = Describe("Vegeta matrix results", func() {
+	var matrix *vegetav1alpha1.VegetaMatrix
+
+	BeforeEach(func() {
+		matrix = newVegetaMatrix("capacity", "100/1s", "200/1s", "400/1s", "800/1s")
+	})
+
+	Context("When the knee of the capacity curve is estimated", func() {
+		It("Should stop before the rate whose throughput falls behind", func() {
+			matrix.Status.Runs = []vegetav1alpha1.MatrixRun{
+				matrixRun("", "100/1s", "100.00", 10*time.Millisecond),
+				matrixRun("", "200/1s", "199.80", 11*time.Millisecond),
+				matrixRun("", "400/1s", "310.00", 15*time.Millisecond),
+				matrixRun("", "800/1s", "305.00", 900*time.Millisecond),
+			}
+			knees := estimateKnees(matrix)
+			Expect(knees).To(HaveLen(1))
+			Expect(knees[0].Rate).To(Equal("200/1s"))
+			Expect(knees[0].Throughput).To(Equal("199.80"))
+			Expect(knees[0].P99.Duration).To(Equal(11 * time.Millisecond))
+			Expect(knees[0].Message).To(ContainSubstring("Saturated at rate 400/1s: the throughput 310.00"))
+		})
+		It("Should detect the latency growth and estimate each target separately", func() {
+			matrix.Spec.Matrix.Rates = []string{"200/1s", "100/1s"}
+			matrix.Status.Runs = []vegetav1alpha1.MatrixRun{
+				matrixRun("GET http://a", "200/1s", "200.00", 50*time.Millisecond),
+				matrixRun("GET http://a", "100/1s", "100.00", 10*time.Millisecond),
+				matrixRun("GET http://b", "200/1s", "200.00", 12*time.Millisecond),
+				matrixRun("GET http://b", "100/1s", "100.00", 10*time.Millisecond),
+			}
+			knees := estimateKnees(matrix)
+			Expect(knees).To(HaveLen(2))
+			Expect(knees[0].Target).To(Equal("GET http://a"))
+			Expect(knees[0].Rate).To(Equal("100/1s"))
+			Expect(knees[0].Message).To(ContainSubstring("p99 latency 50ms"))
+			Expect(knees[1].Target).To(Equal("GET http://b"))
+			Expect(knees[1].Rate).To(BeEmpty())
+			Expect(knees[1].Message).To(Equal("No saturation observed up to rate 200/1s"))
+			// The order of the runs in the status is kept
+			Expect(matrix.Status.Runs[0].Rate).To(Equal("200/1s"))
+		})
+		It("Should report a target saturated at the lowest rate", func() {
+			failed := matrixRun("", "100/1s", "", 0)
+			failed.Phase = vegetav1alpha1.FailedPhase
+			failed.Results = nil
+			matrix.Status.Runs = []vegetav1alpha1.MatrixRun{failed}
+			knees := estimateKnees(matrix)
+			Expect(knees[0].Rate).To(BeEmpty())
+			Expect(knees[0].Message).To(ContainSubstring("Already saturated at the lowest rate 100/1s: run run-100/1s has not produced any result"))
+		})
+	})
+
+	Context("When the results of the matrix are tabulated", func() {
+		It("Should write the finished runs into a config map in csv and json", func() {
+			matrix.Status.Runs = []vegetav1alpha1.MatrixRun{
+				matrixRun("", "100/1s", "100.00", 10500*time.Microsecond),
+				{Rate: "200/1s", Vegeta: "capacity-1", RunSummary: vegetav1alpha1.RunSummary{Phase: vegetav1alpha1.RunningPhase}},
+			}
+			s := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+			Expect(vegetav1alpha1.AddToScheme(s)).To(Succeed())
+			r := &VegetaMatrixReconciler{Client: fake.NewClientBuilder().WithScheme(s).Build(), Scheme: s}
+			Expect(r.reconcileResultsConfigMap(context.Background(), matrix)).To(Succeed())
+			Expect(matrix.Status.ResultsConfigMap).To(Equal("capacity-results"))
+
+			cm := &corev1.ConfigMap{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Name: "capacity-results", Namespace: TestNs}, cm)).To(Succeed())
+			Expect(metav1.GetControllerOf(cm).Name).To(Equal("capacity"))
+			lines := strings.Split(strings.TrimSpace(cm.Data[resultsCSVFile]), "\n")
+			Expect(lines).To(Equal([]string{
+				"target,rate,vegeta,phase,requests,throughput,success,meanMs,p50Ms,p90Ms,p95Ms,p99Ms,maxMs",
+				",100/1s,run-100/1s,completed,1000,100,1,0,0,0,0,10.5,0",
+			}))
+			var rows []matrixRow
+			Expect(json.Unmarshal([]byte(cm.Data[resultsJSONFile]), &rows)).To(Succeed())
+			Expect(rows).To(HaveLen(1))
+			Expect(rows[0].P99Ms).To(Equal(10.5))
+		})
+		It("Should not take over a config map with the same name it has not created", func() {
+			matrix.Status.Runs = []vegetav1alpha1.MatrixRun{matrixRun("", "100/1s", "100.00", 10500*time.Microsecond)}
+			existing := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "capacity-results", Namespace: TestNs, ResourceVersion: "1"},
+				Data:       map[string]string{"owner": "someone else"},
+			}
+			s := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+			Expect(vegetav1alpha1.AddToScheme(s)).To(Succeed())
+			r := &VegetaMatrixReconciler{Client: fake.NewClientBuilder().WithScheme(s).WithObjects(existing).Build(), Scheme: s}
+			Expect(r.reconcileResultsConfigMap(context.Background(), matrix)).To(MatchError(ContainSubstring("it already exists and is not controlled by capacity")))
+			Expect(matrix.Status.ResultsConfigMap).To(BeEmpty())
+
+			cm := &corev1.ConfigMap{}
+			Expect(r.Get(context.Background(), types.NamespacedName{Name: "capacity-results", Namespace: TestNs}, cm)).To(Succeed())
+			Expect(cm.Data).To(Equal(existing.Data))
+		})
+	})
+})
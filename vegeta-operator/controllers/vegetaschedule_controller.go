@@ -0,0 +1,314 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ref "k8s.io/client-go/tools/reference"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	"github.com/fgiloux/vegeta-operator/operator"
+)
+
+const (
+	// scheduledTimeAnnotation records on the created Vegeta resources the time they have been scheduled for
+	scheduledTimeAnnotation = "vegeta.testing.io/scheduled-at"
+	// scheduleLabel records on the created Vegeta resources the name of the schedule that created them
+	scheduleLabel = "vegeta.testing.io/schedule"
+	// maxMissedSchedules is the number of missed schedules above which the schedule is considered as broken, e.g. because of clock skew
+	maxMissedSchedules = 100
+)
+
+var vegetaOwnerKey = ".metadata.controller"
+
+// Clock knows how to get the current time. It can be used to fake out timing for testing.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is the Clock returning the actual time
+type RealClock struct{}
+
+// Now returns the current local time
+func (RealClock) Now() time.Time { return time.Now() }
+
+// VegetaScheduleReconciler reconciles a VegetaSchedule object
+type VegetaScheduleReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	Labels operator.Labels
+	Clock
+}
+
+// +kubebuilder:rbac:groups=vegeta.testing.io,resources=vegetaschedules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vegeta.testing.io,resources=vegetaschedules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vegeta.testing.io,resources=vegetaschedules/finalizers,verbs=update
+
+// Reconcile creates Vegeta resources according to the schedule, keeps track of the running ones and garbage collects the old ones.
+func (r *VegetaScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("vegetaschedule", req.NamespacedName)
+	log.V(1).Info("Starting reconciliation")
+
+	schedule := &vegetav1alpha1.VegetaSchedule{}
+	if err := r.Get(ctx, req.NamespacedName, schedule); err != nil {
+		if errors.IsNotFound(err) {
+			// Owned Vegeta resources are automatically garbage collected
+			log.V(1).Info("VegetaSchedule resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("Failed to get VegetaSchedule resource: %v", err)
+	}
+
+	var children vegetav1alpha1.VegetaList
+	if err := r.List(ctx, &children, client.InNamespace(req.Namespace), client.MatchingFields{vegetaOwnerKey: req.Name}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("List VegetaSchedule's child Vegeta resources: %v", err)
+	}
+
+	// Classify the Vegeta resources created by the schedule
+	var active, successful, failed []*vegetav1alpha1.Vegeta
+	var mostRecentTime *time.Time
+	for i := range children.Items {
+		vegeta := &children.Items[i]
+		switch vegeta.Status.Phase {
+		case vegetav1alpha1.CompletedPhase:
+			successful = append(successful, vegeta)
+		case vegetav1alpha1.FailedPhase, vegetav1alpha1.AbortedPhase:
+			failed = append(failed, vegeta)
+		default:
+			active = append(active, vegeta)
+		}
+		scheduledTime, err := getScheduledTime(vegeta)
+		if err != nil {
+			log.Error(err, "Unable to parse the schedule time of the Vegeta resource", "vegeta", vegeta.Name)
+			continue
+		}
+		if scheduledTime != nil && (mostRecentTime == nil || mostRecentTime.Before(*scheduledTime)) {
+			mostRecentTime = scheduledTime
+		}
+	}
+
+	if mostRecentTime != nil {
+		schedule.Status.LastScheduleTime = &metav1.Time{Time: *mostRecentTime}
+	} else {
+		schedule.Status.LastScheduleTime = nil
+	}
+	schedule.Status.Active = nil
+	for _, vegeta := range active {
+		vegetaRef, err := ref.GetReference(r.Scheme, vegeta)
+		if err != nil {
+			log.Error(err, "Unable to make a reference to the active Vegeta resource", "vegeta", vegeta.Name)
+			continue
+		}
+		schedule.Status.Active = append(schedule.Status.Active, *vegetaRef)
+	}
+	log.V(1).Info("Vegeta count", "active", len(active), "successful", len(successful), "failed", len(failed))
+	if err := r.Status().Update(ctx, schedule); err != nil {
+		return ctrl.Result{}, fmt.Errorf("Unable to update VegetaSchedule status: %v", err)
+	}
+
+	// Garbage collect the old Vegeta resources
+	r.deleteHistory(ctx, log, failed, schedule.Spec.FailedHistoryLimit, vegetav1alpha1.DefaultFailedHistoryLimit)
+	r.deleteHistory(ctx, log, successful, schedule.Spec.SuccessfulHistoryLimit, vegetav1alpha1.DefaultSuccessfulHistoryLimit)
+
+	if schedule.Spec.Suspend != nil && *schedule.Spec.Suspend {
+		log.V(1).Info("VegetaSchedule suspended, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	now := r.Now()
+	missedRun, nextRun, err := getNextSchedule(schedule, now)
+	if err != nil {
+		// The schedule is broken until it gets fixed, no need to requeue
+		log.Error(err, "Unable to figure out the schedule")
+		return ctrl.Result{}, nil
+	}
+	scheduledResult := ctrl.Result{RequeueAfter: nextRun.Sub(now)}
+	log = log.WithValues("now", now, "next run", nextRun)
+
+	if missedRun.IsZero() {
+		log.V(1).Info("No upcoming scheduled times, sleeping until next")
+		return scheduledResult, nil
+	}
+
+	log = log.WithValues("current run", missedRun)
+	if schedule.Spec.StartingDeadlineSeconds != nil && missedRun.Add(time.Duration(*schedule.Spec.StartingDeadlineSeconds)*time.Second).Before(now) {
+		log.V(0).Info("Missed starting deadline for last run, sleeping until next")
+		return scheduledResult, nil
+	}
+
+	switch schedule.Spec.ConcurrencyPolicy {
+	case vegetav1alpha1.ForbidConcurrent:
+		if len(active) > 0 {
+			log.V(0).Info("Concurrency policy blocks concurrent runs, skipping", "active", len(active))
+			return scheduledResult, nil
+		}
+	case vegetav1alpha1.ReplaceConcurrent:
+		for _, vegeta := range active {
+			// The attack pods get deleted together with the Vegeta resource
+			if err := r.Delete(ctx, vegeta, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, fmt.Errorf("Unable to delete active Vegeta resource %s: %v", vegeta.Name, err)
+			}
+		}
+	}
+
+	vegeta, err := r.constructVegetaForSchedule(schedule, missedRun)
+	if err != nil {
+		// The template is broken until it gets fixed, no need to requeue before the next run
+		log.Error(err, "Unable to construct the Vegeta resource from the template")
+		return scheduledResult, nil
+	}
+	if err := r.Create(ctx, vegeta); err != nil && !errors.IsAlreadyExists(err) {
+		return ctrl.Result{}, fmt.Errorf("Unable to create the Vegeta resource for the run: %v", err)
+	}
+	log.V(0).Info("Created Vegeta resource for the run", "vegeta", vegeta.Name)
+
+	return scheduledResult, nil
+}
+
+// deleteHistory deletes the oldest Vegeta resources beyond the history limit
+func (r *VegetaScheduleReconciler) deleteHistory(ctx context.Context, log logr.Logger, vegetas []*vegetav1alpha1.Vegeta, limit *int32, defaultLimit int) {
+	keep := defaultLimit
+	if limit != nil {
+		keep = int(*limit)
+	}
+	if len(vegetas) <= keep {
+		return
+	}
+	sort.Slice(vegetas, func(i, j int) bool {
+		return vegetas[i].CreationTimestamp.Before(&vegetas[j].CreationTimestamp)
+	})
+	for _, vegeta := range vegetas[:len(vegetas)-keep] {
+		if err := r.Delete(ctx, vegeta, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Unable to delete old Vegeta resource", "vegeta", vegeta.Name)
+		} else {
+			log.V(0).Info("Deleted old Vegeta resource", "vegeta", vegeta.Name)
+		}
+	}
+}
+
+// constructVegetaForSchedule generates the Vegeta resource of the run scheduled at the given time.
+// The name is deterministic so that a run doesn't get created twice.
+func (r *VegetaScheduleReconciler) constructVegetaForSchedule(schedule *vegetav1alpha1.VegetaSchedule, scheduledTime time.Time) (*vegetav1alpha1.Vegeta, error) {
+	vegeta := &vegetav1alpha1.Vegeta{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s-%d", schedule.Name, scheduledTime.Unix()),
+			Namespace:   schedule.Namespace,
+			Labels:      r.Labels.Merge(map[string]string{scheduleLabel: schedule.Name}),
+			Annotations: map[string]string{},
+		},
+		Spec: *schedule.Spec.VegetaTemplate.Spec.DeepCopy(),
+	}
+	for k, v := range schedule.Spec.VegetaTemplate.Metadata.Labels {
+		if _, ok := vegeta.Labels[k]; !ok {
+			vegeta.Labels[k] = v
+		}
+	}
+	for k, v := range schedule.Spec.VegetaTemplate.Metadata.Annotations {
+		vegeta.Annotations[k] = v
+	}
+	vegeta.Annotations[scheduledTimeAnnotation] = scheduledTime.Format(time.RFC3339)
+	if err := ctrl.SetControllerReference(schedule, vegeta, r.Scheme); err != nil {
+		return nil, err
+	}
+	return vegeta, nil
+}
+
+// getScheduledTime returns the time a Vegeta resource has been scheduled for by a VegetaSchedule
+func getScheduledTime(vegeta *vegetav1alpha1.Vegeta) (*time.Time, error) {
+	timeRaw := vegeta.Annotations[scheduledTimeAnnotation]
+	if len(timeRaw) == 0 {
+		return nil, nil
+	}
+	timeParsed, err := time.Parse(time.RFC3339, timeRaw)
+	if err != nil {
+		return nil, err
+	}
+	return &timeParsed, nil
+}
+
+// getNextSchedule returns the last run that should have been started and the time of the next run.
+// Only the latest missed run is returned: runs missed while the operator was down are not caught up.
+func getNextSchedule(schedule *vegetav1alpha1.VegetaSchedule, now time.Time) (lastMissed time.Time, next time.Time, err error) {
+	sched, err := cron.ParseStandard(schedule.Spec.Schedule)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("Unparseable schedule %q: %v", schedule.Spec.Schedule, err)
+	}
+
+	// Start from the last run, or from the creation of the schedule if there is none
+	var earliestTime time.Time
+	if schedule.Status.LastScheduleTime != nil {
+		earliestTime = schedule.Status.LastScheduleTime.Time
+	} else {
+		earliestTime = schedule.ObjectMeta.CreationTimestamp.Time
+	}
+	if schedule.Spec.StartingDeadlineSeconds != nil {
+		// Runs missed beyond the starting deadline don't need to be considered
+		schedulingDeadline := now.Add(-time.Second * time.Duration(*schedule.Spec.StartingDeadlineSeconds))
+		if schedulingDeadline.After(earliestTime) {
+			earliestTime = schedulingDeadline
+		}
+	}
+	if earliestTime.After(now) {
+		return time.Time{}, sched.Next(now), nil
+	}
+
+	starts := 0
+	for t := sched.Next(earliestTime); !t.After(now); t = sched.Next(t) {
+		lastMissed = t
+		starts++
+		if starts > maxMissedSchedules {
+			return time.Time{}, time.Time{}, fmt.Errorf("Too many missed start times (> %d). Set or decrease .spec.startingDeadlineSeconds or check clock skew", maxMissedSchedules)
+		}
+	}
+	return lastMissed, sched.Next(now), nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VegetaScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Clock == nil {
+		r.Clock = RealClock{}
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &vegetav1alpha1.Vegeta{}, vegetaOwnerKey, func(rawObj client.Object) []string {
+		vegeta := rawObj.(*vegetav1alpha1.Vegeta)
+		owner := metav1.GetControllerOf(vegeta)
+		if owner == nil {
+			return nil
+		}
+		if owner.APIVersion != apiGVStr || owner.Kind != "VegetaSchedule" {
+			return nil
+		}
+		return []string{owner.Name}
+	}); err != nil {
+		return fmt.Errorf("SetupWithManager: %v", err)
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vegetav1alpha1.VegetaSchedule{}).
+		Owns(&vegetav1alpha1.Vegeta{}).
+		Complete(r)
+}
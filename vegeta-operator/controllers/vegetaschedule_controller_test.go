@@ -0,0 +1,103 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newVegetaSchedule(name string, cronExpr string, created time.Time) *vegetav1alpha1.VegetaSchedule {
+	return &vegetav1alpha1.VegetaSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         TestNs,
+			UID:               "schedule-uid",
+			CreationTimestamp: metav1.Time{Time: created},
+		},
+		Spec: vegetav1alpha1.VegetaScheduleSpec{
+			Schedule: cronExpr,
+			VegetaTemplate: vegetav1alpha1.VegetaTemplateSpec{
+				Metadata: vegetav1alpha1.VegetaTemplateMeta{
+					Labels:      map[string]string{"test": "nightly"},
+					Annotations: map[string]string{"owner": "perf-team"},
+				},
+				Spec: newVegeta("template").Spec,
+			},
+		},
+	}
+}
+
+var _ = Describe("Vegeta schedule", func() {
+	created := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	Context("When the next schedule is computed", func() {
+		It("Should wait for the first run", func() {
+			schedule := newVegetaSchedule("first", "*/30 * * * *", created)
+			missed, next, err := getNextSchedule(schedule, created.Add(10*time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missed.IsZero()).To(BeTrue())
+			Expect(next).To(Equal(created.Add(30 * time.Minute)))
+		})
+		It("Should only return the latest missed run", func() {
+			schedule := newVegetaSchedule("missed", "*/30 * * * *", created)
+			schedule.Status.LastScheduleTime = &metav1.Time{Time: created}
+			missed, next, err := getNextSchedule(schedule, created.Add(95*time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missed).To(Equal(created.Add(90 * time.Minute)))
+			Expect(next).To(Equal(created.Add(120 * time.Minute)))
+		})
+		It("Should ignore runs missed beyond the starting deadline", func() {
+			schedule := newVegetaSchedule("deadline", "*/30 * * * *", created)
+			deadline := int64(60)
+			schedule.Spec.StartingDeadlineSeconds = &deadline
+			missed, _, err := getNextSchedule(schedule, created.Add(35*time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missed.IsZero()).To(BeTrue())
+		})
+		It("Should fail when too many runs have been missed", func() {
+			schedule := newVegetaSchedule("skew", "* * * * *", created)
+			_, _, err := getNextSchedule(schedule, created.Add(24*time.Hour))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("When a Vegeta resource is created for a run", func() {
+		It("Should be generated from the template and owned by the schedule", func() {
+			s := runtime.NewScheme()
+			Expect(vegetav1alpha1.AddToScheme(s)).To(Succeed())
+			r := &VegetaScheduleReconciler{Scheme: s}
+			schedule := newVegetaSchedule("nightly", "0 2 * * *", created)
+			scheduledTime := created.Add(16 * time.Hour)
+			vegeta, err := r.constructVegetaForSchedule(schedule, scheduledTime)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(vegeta.Name).To(Equal("nightly-1614650400"))
+			Expect(vegeta.Labels).To(HaveKeyWithValue("test", "nightly"))
+			Expect(vegeta.Labels).To(HaveKeyWithValue(scheduleLabel, "nightly"))
+			Expect(vegeta.Annotations).To(HaveKeyWithValue("owner", "perf-team"))
+			Expect(getScheduledTime(vegeta)).To(Equal(&scheduledTime))
+			Expect(vegeta.Spec).To(Equal(schedule.Spec.VegetaTemplate.Spec))
+			Expect(metav1.GetControllerOf(vegeta).Name).To(Equal("nightly"))
+		})
+	})
+})
@@ -0,0 +1,361 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	"github.com/fgiloux/vegeta-operator/operator"
+)
+
+const (
+	// suiteLabel records on the created Vegeta resources the name of the suite that created them
+	suiteLabel = "vegeta.testing.io/suite"
+	// stageLabel records on the created Vegeta resources the name of the stage of the suite they belong to
+	stageLabel = "vegeta.testing.io/stage"
+	// stageFailedReason means that an attack of a stage of the suite has failed or has been aborted
+	stageFailedReason = "StageFailed"
+)
+
+// suiteOwnerKey indexes the Vegeta resources by the name of the suite owning them. It is distinct from the index of the schedules.
+var suiteOwnerKey = ".metadata.suiteController"
+
+// VegetaSuiteReconciler reconciles a VegetaSuite object
+type VegetaSuiteReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	Labels operator.Labels
+	Clock
+	// APIReader reads the Vegeta resources recorded as created that are not in the cache yet. Defaulted to the uncached reader of the manager.
+	APIReader client.Reader
+}
+
+// +kubebuilder:rbac:groups=vegeta.testing.io,resources=vegetasuites,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vegeta.testing.io,resources=vegetasuites/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vegeta.testing.io,resources=vegetasuites/finalizers,verbs=update
+
+// Reconcile runs the stages of the suite one after the other by creating the Vegeta resources of their attacks and aggregates their outcome in the status.
+func (r *VegetaSuiteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("vegetasuite", req.NamespacedName)
+	log.V(1).Info("Starting reconciliation")
+
+	suite := &vegetav1alpha1.VegetaSuite{}
+	if err := r.Get(ctx, req.NamespacedName, suite); err != nil {
+		if errors.IsNotFound(err) {
+			// Owned Vegeta resources are automatically garbage collected
+			log.V(1).Info("VegetaSuite resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("Failed to get VegetaSuite resource: %v", err)
+	}
+	if suite.Status.Phase.IsTerminated() {
+		return ctrl.Result{}, nil
+	}
+
+	var children vegetav1alpha1.VegetaList
+	if err := r.List(ctx, &children, client.InNamespace(req.Namespace), client.MatchingFields{suiteOwnerKey: req.Name}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("List VegetaSuite's child Vegeta resources: %v", err)
+	}
+	byName := make(map[string]*vegetav1alpha1.Vegeta, len(children.Items))
+	for i := range children.Items {
+		// The index matches the name of the owner only, e.g. a previous suite with the same name
+		if metav1.IsControlledBy(&children.Items[i], suite) {
+			byName[children.Items[i].Name] = &children.Items[i]
+		}
+	}
+	if err := addMissingChildren(ctx, r.APIReader, suite, createdSuiteChildren(suite), byName); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status := suite.Status.DeepCopy()
+	toCreate, pause, err := r.advanceSuite(suite, byName, r.Now())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	requeue := false
+	for _, vegeta := range toCreate {
+		created, err := createChild(ctx, r.Client, r.APIReader, suite, vegeta)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("Unable to create the Vegeta resource %s of the suite: %v", vegeta.Name, err)
+		}
+		if !created {
+			// The attack fails rather than reporting the outcome of a resource the suite does not control
+			log.V(0).Info("Vegeta resource not controlled by the suite", "vegeta", vegeta.Name, "stage", vegeta.Labels[stageLabel])
+			setSuiteAttackSummary(suite, vegeta.Name, notControlledSummary(vegeta.Name, "suite", r.Now()))
+			requeue = true
+			continue
+		}
+		log.V(0).Info("Created Vegeta resource", "vegeta", vegeta.Name, "stage", vegeta.Labels[stageLabel])
+	}
+	if !equality.Semantic.DeepEqual(status, &suite.Status) {
+		if err := r.Status().Update(ctx, suite); err != nil {
+			return ctrl.Result{}, fmt.Errorf("Unable to update VegetaSuite status: %v", err)
+		}
+	}
+	if requeue {
+		return ctrl.Result{Requeue: true}, nil
+	}
+	if pause > 0 {
+		log.V(1).Info("Pausing before the next stage", "stage", suite.Status.CurrentStage, "pause", pause)
+		return ctrl.Result{RequeueAfter: pause}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// advanceSuite updates the status of the suite with the outcome of the attacks of the current stage and moves on to the next stage once they have all finished.
+// It returns the Vegeta resources to create for the current stage and how long to wait before the next stage can start.
+func (r *VegetaSuiteReconciler) advanceSuite(suite *vegetav1alpha1.VegetaSuite, children map[string]*vegetav1alpha1.Vegeta, now time.Time) ([]*vegetav1alpha1.Vegeta, time.Duration, error) {
+	status := &suite.Status
+	if status.Phase == "" {
+		status.StartTime = &metav1.Time{Time: now}
+		setSuitePhase(suite, vegetav1alpha1.PendingPhase, vegetav1alpha1.PendingReason, "No stage has started yet")
+	}
+	var failure string
+	for i := range suite.Spec.Stages {
+		stage := &suite.Spec.Stages[i]
+		if i < len(status.Stages) && status.Stages[i].Phase.IsTerminated() {
+			if status.Stages[i].Phase == vegetav1alpha1.FailedPhase && failure == "" {
+				failure = "Stage " + stage.Name + " has failed"
+			}
+			continue
+		}
+		status.CurrentStage = stage.Name
+		if i >= len(status.Stages) {
+			// The stage starts once the pause after the end of the previous stage is over
+			if stage.PauseBefore != "" {
+				pause, err := time.ParseDuration(stage.PauseBefore)
+				if err != nil {
+					return nil, 0, fmt.Errorf("Invalid pause %q before stage %s: %v", stage.PauseBefore, stage.Name, err)
+				}
+				previousEnd := status.StartTime.Time
+				if i > 0 && status.Stages[i-1].CompletionTime != nil {
+					previousEnd = status.Stages[i-1].CompletionTime.Time
+				}
+				if wait := previousEnd.Add(pause).Sub(now); wait > 0 {
+					return nil, wait, nil
+				}
+			}
+			status.Stages = append(status.Stages, vegetav1alpha1.SuiteStageStatus{
+				Name:      stage.Name,
+				Phase:     vegetav1alpha1.RunningPhase,
+				StartTime: &metav1.Time{Time: now},
+			})
+			setSuitePhase(suite, vegetav1alpha1.RunningPhase, vegetav1alpha1.RunningReason, "Stage "+stage.Name+" is running")
+		}
+
+		stageStatus := &status.Stages[i]
+		toCreate, done, stageFailure, err := r.trackStage(suite, stage, stageStatus, children, now)
+		if err != nil || !done {
+			return toCreate, 0, err
+		}
+		stageStatus.CompletionTime = stageCompletion(stageStatus, now)
+		if stageFailure != "" {
+			stageStatus.Phase = vegetav1alpha1.FailedPhase
+			if failure == "" {
+				failure = stageFailure
+			}
+			if suite.Spec.StopOnFailure {
+				break
+			}
+		} else {
+			stageStatus.Phase = vegetav1alpha1.CompletedPhase
+		}
+	}
+
+	status.CurrentStage = ""
+	status.CompletionTime = &metav1.Time{Time: now}
+	if failure != "" {
+		setSuitePhase(suite, vegetav1alpha1.FailedPhase, stageFailedReason, failure)
+	} else {
+		setSuitePhase(suite, vegetav1alpha1.CompletedPhase, vegetav1alpha1.CompletedReason, fmt.Sprintf("The %d stages have completed", len(suite.Spec.Stages)))
+	}
+	return nil, 0, nil
+}
+
+// trackStage records the summaries of the attacks of a running stage. It returns the Vegeta resources that still need to be created,
+// whether all the attacks have finished and, if so, why the stage has failed, if it did.
+// Vegeta resources recorded as created are never created again: the attack fails if they have been deleted before their outcome could be recorded.
+func (r *VegetaSuiteReconciler) trackStage(suite *vegetav1alpha1.VegetaSuite, stage *vegetav1alpha1.SuiteStage, stageStatus *vegetav1alpha1.SuiteStageStatus, children map[string]*vegetav1alpha1.Vegeta, now time.Time) ([]*vegetav1alpha1.Vegeta, bool, string, error) {
+	previous := make(map[string]vegetav1alpha1.SuiteAttackStatus, len(stageStatus.Attacks))
+	for _, a := range stageStatus.Attacks {
+		previous[a.Name] = a
+	}
+	var toCreate []*vegetav1alpha1.Vegeta
+	var failure string
+	done := true
+	stageStatus.Attacks = nil
+	for j := range stage.Attacks {
+		attack := &stage.Attacks[j]
+		attackStatus := vegetav1alpha1.SuiteAttackStatus{
+			Name:   attack.Name,
+			Vegeta: vegetav1alpha1.SuiteVegetaName(suite.Name, stage.Name, attack.Name),
+		}
+		if vegeta, ok := children[attackStatus.Vegeta]; ok {
+			attackStatus.RunSummary = childSummary(vegeta)
+		} else if p, ok := previous[attack.Name]; ok && p.Phase.IsTerminated() {
+			// The Vegeta resource has been deleted after it had finished, e.g. by its time to live
+			attackStatus = p
+		} else if ok && p.Phase != "" {
+			attackStatus.RunSummary = deletedSummary(attackStatus.Vegeta, now)
+		} else {
+			vegeta, err := r.constructVegetaForSuite(suite, stage, attack)
+			if err != nil {
+				return nil, false, "", fmt.Errorf("Unable to construct the Vegeta resource of attack %s of stage %s: %v", attack.Name, stage.Name, err)
+			}
+			toCreate = append(toCreate, vegeta)
+			attackStatus.Phase = vegetav1alpha1.PendingPhase
+		}
+		switch {
+		case !attackStatus.Phase.IsTerminated():
+			done = false
+		case attackStatus.Phase != vegetav1alpha1.CompletedPhase && failure == "":
+			failure = fmt.Sprintf("Attack %s of stage %s has ended in the %s phase", attack.Name, stage.Name, attackStatus.Phase)
+			if attackStatus.Message != "" {
+				failure += ": " + attackStatus.Message
+			}
+		}
+		stageStatus.Attacks = append(stageStatus.Attacks, attackStatus)
+	}
+	return toCreate, done, failure, nil
+}
+
+// createdSuiteChildren returns the names of the Vegeta resources recorded as created whose outcome has not been recorded yet
+func createdSuiteChildren(suite *vegetav1alpha1.VegetaSuite) []string {
+	var names []string
+	for i := range suite.Status.Stages {
+		for _, a := range suite.Status.Stages[i].Attacks {
+			if a.Phase != "" && !a.Phase.IsTerminated() {
+				names = append(names, a.Vegeta)
+			}
+		}
+	}
+	return names
+}
+
+// setSuiteAttackSummary records the summary of the attack run by the named Vegeta resource
+func setSuiteAttackSummary(suite *vegetav1alpha1.VegetaSuite, name string, summary vegetav1alpha1.RunSummary) {
+	for i := range suite.Status.Stages {
+		for j := range suite.Status.Stages[i].Attacks {
+			if suite.Status.Stages[i].Attacks[j].Vegeta == name {
+				suite.Status.Stages[i].Attacks[j].RunSummary = summary
+			}
+		}
+	}
+}
+
+// stageCompletion returns when the last attack of a finished stage has completed, so that the pause before the next stage does not depend on when the completion got noticed
+func stageCompletion(stageStatus *vegetav1alpha1.SuiteStageStatus, now time.Time) *metav1.Time {
+	var completion *metav1.Time
+	for i := range stageStatus.Attacks {
+		if t := stageStatus.Attacks[i].CompletionTime; t != nil && (completion == nil || completion.Before(t)) {
+			completion = t.DeepCopy()
+		}
+	}
+	if completion == nil {
+		completion = &metav1.Time{Time: now}
+	}
+	return completion
+}
+
+// constructVegetaForSuite generates the Vegeta resource of an attack of a stage
+func (r *VegetaSuiteReconciler) constructVegetaForSuite(suite *vegetav1alpha1.VegetaSuite, stage *vegetav1alpha1.SuiteStage, attack *vegetav1alpha1.SuiteAttack) (*vegetav1alpha1.Vegeta, error) {
+	vegeta := &vegetav1alpha1.Vegeta{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        vegetav1alpha1.SuiteVegetaName(suite.Name, stage.Name, attack.Name),
+			Namespace:   suite.Namespace,
+			Labels:      r.Labels.Merge(map[string]string{suiteLabel: suite.Name, stageLabel: stage.Name}),
+			Annotations: map[string]string{},
+		},
+		Spec: *attack.Spec.DeepCopy(),
+	}
+	for k, v := range attack.Metadata.Labels {
+		if _, ok := vegeta.Labels[k]; !ok {
+			vegeta.Labels[k] = v
+		}
+	}
+	for k, v := range attack.Metadata.Annotations {
+		vegeta.Annotations[k] = v
+	}
+	if err := ctrl.SetControllerReference(suite, vegeta, r.Scheme); err != nil {
+		return nil, err
+	}
+	return vegeta, nil
+}
+
+// setSuitePhase sets the phase of the suite together with the matching Ready, Complete and Failed conditions
+func setSuitePhase(suite *vegetav1alpha1.VegetaSuite, phase vegetav1alpha1.PhaseEnum, reason, message string) {
+	suite.Status.Phase = phase
+	set := func(condType string, status metav1.ConditionStatus) {
+		meta.SetStatusCondition(&suite.Status.Conditions, metav1.Condition{
+			Type:               condType,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: suite.Generation,
+		})
+	}
+	switch phase {
+	case vegetav1alpha1.CompletedPhase:
+		set(vegetav1alpha1.ReadyCondition, metav1.ConditionTrue)
+		set(vegetav1alpha1.CompleteCondition, metav1.ConditionTrue)
+	case vegetav1alpha1.FailedPhase:
+		set(vegetav1alpha1.ReadyCondition, metav1.ConditionFalse)
+		set(vegetav1alpha1.FailedCondition, metav1.ConditionTrue)
+	default:
+		set(vegetav1alpha1.ReadyCondition, metav1.ConditionFalse)
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VegetaSuiteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Clock == nil {
+		r.Clock = RealClock{}
+	}
+	if r.APIReader == nil {
+		r.APIReader = mgr.GetAPIReader()
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &vegetav1alpha1.Vegeta{}, suiteOwnerKey, func(rawObj client.Object) []string {
+		vegeta := rawObj.(*vegetav1alpha1.Vegeta)
+		owner := metav1.GetControllerOf(vegeta)
+		if owner == nil {
+			return nil
+		}
+		if owner.APIVersion != apiGVStr || owner.Kind != "VegetaSuite" {
+			return nil
+		}
+		return []string{owner.Name}
+	}); err != nil {
+		return fmt.Errorf("SetupWithManager: %v", err)
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vegetav1alpha1.VegetaSuite{}).
+		Owns(&vegetav1alpha1.Vegeta{}).
+		Complete(r)
+}
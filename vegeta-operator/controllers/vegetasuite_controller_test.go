@@ -0,0 +1,268 @@
+/*
+Copyright 2020.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vegetav1alpha1 "github.com/fgiloux/vegeta-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newVegetaSuite(name string) *vegetav1alpha1.VegetaSuite {
+	attack := func(name string) vegetav1alpha1.SuiteAttack {
+		return vegetav1alpha1.SuiteAttack{
+			Name: name,
+			VegetaTemplateSpec: vegetav1alpha1.VegetaTemplateSpec{
+				Metadata: vegetav1alpha1.VegetaTemplateMeta{
+					Labels:      map[string]string{"test": name},
+					Annotations: map[string]string{"owner": "perf-team"},
+				},
+				Spec: newVegeta("template").Spec,
+			},
+		}
+	}
+	return &vegetav1alpha1.VegetaSuite{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: TestNs,
+			UID:       "suite-uid",
+		},
+		Spec: vegetav1alpha1.VegetaSuiteSpec{
+			Stages: []vegetav1alpha1.SuiteStage{
+				{Name: "warmup", Attacks: []vegetav1alpha1.SuiteAttack{attack("healthz")}},
+				{Name: "load", PauseBefore: "30s", Attacks: []vegetav1alpha1.SuiteAttack{attack("healthz"), attack("version")}},
+			},
+		},
+	}
+}
+
+// finishedVegeta returns the Vegeta resource of an attack of the suite that has ended in the given phase
+func finishedVegeta(suite *vegetav1alpha1.VegetaSuite, stage, attack string, phase vegetav1alpha1.PhaseEnum, finished time.Time) *vegetav1alpha1.Vegeta {
+	v := newVegeta(vegetav1alpha1.SuiteVegetaName(suite.Name, stage, attack))
+	v.Status.Phase = phase
+	condType := vegetav1alpha1.ReadyCondition
+	if phase == vegetav1alpha1.FailedPhase {
+		condType = vegetav1alpha1.FailedCondition
+	}
+	v.Status.Conditions = []metav1.Condition{{
+		Type:               condType,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Test",
+		Message:            "The attack has ended",
+		LastTransitionTime: metav1.Time{Time: finished},
+	}}
+	return v
+}
+
+var _ = Describe("Vegeta suite", func() {
+	start := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	var r *VegetaSuiteReconciler
+	var suite *vegetav1alpha1.VegetaSuite
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(vegetav1alpha1.AddToScheme(s)).To(Succeed())
+		r = &VegetaSuiteReconciler{Scheme: s}
+		suite = newVegetaSuite("release")
+	})
+
+	Context("When the suite starts", func() {
+		It("Should create the Vegeta resources of the first stage only", func() {
+			toCreate, pause, err := r.advanceSuite(suite, nil, start)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pause).To(BeZero())
+			Expect(toCreate).To(HaveLen(1))
+			vegeta := toCreate[0]
+			Expect(vegeta.Name).To(Equal("release-warmup-healthz"))
+			Expect(vegeta.Labels).To(HaveKeyWithValue(suiteLabel, "release"))
+			Expect(vegeta.Labels).To(HaveKeyWithValue(stageLabel, "warmup"))
+			Expect(vegeta.Labels).To(HaveKeyWithValue("test", "healthz"))
+			Expect(vegeta.Annotations).To(HaveKeyWithValue("owner", "perf-team"))
+			Expect(vegeta.Spec).To(Equal(suite.Spec.Stages[0].Attacks[0].Spec))
+			Expect(metav1.GetControllerOf(vegeta).Name).To(Equal("release"))
+
+			Expect(suite.Status.Phase).To(Equal(vegetav1alpha1.RunningPhase))
+			Expect(suite.Status.CurrentStage).To(Equal("warmup"))
+			Expect(suite.Status.StartTime.Time).To(Equal(start))
+			Expect(suite.Status.Stages).To(HaveLen(1))
+			Expect(suite.Status.Stages[0].Phase).To(Equal(vegetav1alpha1.RunningPhase))
+			Expect(suite.Status.Stages[0].Attacks).To(HaveLen(1))
+			Expect(suite.Status.Stages[0].Attacks[0].Vegeta).To(Equal("release-warmup-healthz"))
+		})
+	})
+
+	Context("When the attacks of a stage are running", func() {
+		It("Should wait for them before moving on", func() {
+			_, _, err := r.advanceSuite(suite, nil, start)
+			Expect(err).ToNot(HaveOccurred())
+			running := newVegeta("release-warmup-healthz")
+			running.Status.Phase = vegetav1alpha1.RunningPhase
+			children := map[string]*vegetav1alpha1.Vegeta{running.Name: running}
+			toCreate, pause, err := r.advanceSuite(suite, children, start.Add(time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(toCreate).To(BeEmpty())
+			Expect(pause).To(BeZero())
+			Expect(suite.Status.Stages).To(HaveLen(1))
+			Expect(suite.Status.Stages[0].Attacks[0].Phase).To(Equal(vegetav1alpha1.RunningPhase))
+		})
+	})
+
+	Context("When a stage has completed", func() {
+		It("Should pause before starting the next stage in parallel", func() {
+			_, _, err := r.advanceSuite(suite, nil, start)
+			Expect(err).ToNot(HaveOccurred())
+			finished := start.Add(time.Minute)
+			warmup := finishedVegeta(suite, "warmup", "healthz", vegetav1alpha1.CompletedPhase, finished)
+			children := map[string]*vegetav1alpha1.Vegeta{warmup.Name: warmup}
+
+			toCreate, pause, err := r.advanceSuite(suite, children, finished.Add(10*time.Second))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(toCreate).To(BeEmpty())
+			Expect(pause).To(Equal(20 * time.Second))
+			Expect(suite.Status.Stages).To(HaveLen(1))
+			Expect(suite.Status.Stages[0].Phase).To(Equal(vegetav1alpha1.CompletedPhase))
+			Expect(suite.Status.Stages[0].Attacks[0].CompletionTime.Time).To(Equal(finished))
+			Expect(suite.Status.CurrentStage).To(Equal("load"))
+
+			toCreate, pause, err = r.advanceSuite(suite, children, finished.Add(40*time.Second))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pause).To(BeZero())
+			Expect(toCreate).To(HaveLen(2))
+			Expect(toCreate[0].Name).To(Equal("release-load-healthz"))
+			Expect(toCreate[1].Name).To(Equal("release-load-version"))
+			Expect(suite.Status.Stages).To(HaveLen(2))
+			Expect(suite.Status.Stages[1].Phase).To(Equal(vegetav1alpha1.RunningPhase))
+		})
+	})
+
+	Context("When all the stages have completed", func() {
+		It("Should complete the suite and keep the summaries of deleted Vegeta resources", func() {
+			_, _, err := r.advanceSuite(suite, nil, start)
+			Expect(err).ToNot(HaveOccurred())
+			warmup := finishedVegeta(suite, "warmup", "healthz", vegetav1alpha1.CompletedPhase, start.Add(time.Minute))
+			_, _, err = r.advanceSuite(suite, map[string]*vegetav1alpha1.Vegeta{warmup.Name: warmup}, start.Add(2*time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+
+			healthz := finishedVegeta(suite, "load", "healthz", vegetav1alpha1.CompletedPhase, start.Add(4*time.Minute))
+			version := newVegeta("release-load-version")
+			version.Status.Phase = vegetav1alpha1.RunningPhase
+			_, _, err = r.advanceSuite(suite, map[string]*vegetav1alpha1.Vegeta{healthz.Name: healthz, version.Name: version}, start.Add(4*time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+
+			// The finished Vegeta resource of the healthz attack has been deleted, e.g. by its time to live
+			version = finishedVegeta(suite, "load", "version", vegetav1alpha1.CompletedPhase, start.Add(5*time.Minute))
+			toCreate, _, err := r.advanceSuite(suite, map[string]*vegetav1alpha1.Vegeta{version.Name: version}, start.Add(6*time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(toCreate).To(BeEmpty())
+			Expect(suite.Status.Phase).To(Equal(vegetav1alpha1.CompletedPhase))
+			Expect(suite.Status.CurrentStage).To(BeEmpty())
+			Expect(suite.Status.CompletionTime.Time).To(Equal(start.Add(6 * time.Minute)))
+			Expect(suite.Status.Stages[0].Attacks[0].Phase).To(Equal(vegetav1alpha1.CompletedPhase))
+			Expect(suite.Status.Stages[1].Attacks).To(HaveLen(2))
+			Expect(suite.Status.Stages[1].Attacks[0].CompletionTime.Time).To(Equal(start.Add(4 * time.Minute)))
+			Expect(meta.IsStatusConditionTrue(suite.Status.Conditions, vegetav1alpha1.CompleteCondition)).To(BeTrue())
+		})
+	})
+
+	Context("When a Vegeta resource recorded as created is missing", func() {
+		It("Should fail the attack rather than create it again", func() {
+			_, _, err := r.advanceSuite(suite, nil, start)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(suite.Status.Stages[0].Attacks[0].Phase).To(Equal(vegetav1alpha1.PendingPhase))
+
+			// Deleted once finished, e.g. with a time to live of 0, before the suite could record its outcome
+			toCreate, _, err := r.advanceSuite(suite, nil, start.Add(time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(toCreate).ToNot(ContainElement(WithTransform(func(v *vegetav1alpha1.Vegeta) string { return v.Name }, Equal("release-warmup-healthz"))))
+			attack := suite.Status.Stages[0].Attacks[0]
+			Expect(attack.Phase).To(Equal(vegetav1alpha1.FailedPhase))
+			Expect(attack.Reason).To(Equal(vegetaDeletedReason))
+			Expect(suite.Status.Stages[0].Phase).To(Equal(vegetav1alpha1.FailedPhase))
+		})
+	})
+
+	Context("When a Vegeta resource with the name of an attack already exists", func() {
+		It("Should fail the attack if the suite does not control it", func() {
+			suite.Spec.StopOnFailure = true
+			foreign := newVegeta("release-warmup-healthz")
+			foreign.Status.Phase = vegetav1alpha1.CompletedPhase
+			c := fake.NewClientBuilder().WithScheme(r.Scheme).WithObjects(suite, foreign).Build()
+			r = &VegetaSuiteReconciler{Client: c, APIReader: c, Scheme: r.Scheme, Log: ctrl.Log.WithName("controllers").WithName("VegetaSuite"), Clock: RealClock{}}
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: suite.Name, Namespace: TestNs}}
+			result, err := r.Reconcile(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Requeue).To(BeTrue())
+			_, err = r.Reconcile(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updated := &vegetav1alpha1.VegetaSuite{}
+			Expect(c.Get(context.Background(), req.NamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Phase).To(Equal(vegetav1alpha1.FailedPhase))
+			Expect(updated.Status.Stages[0].Attacks[0].Reason).To(Equal(vegetaNotControlledReason))
+			Expect(updated.Status.Stages[0].Attacks[0].Message).To(Equal("The Vegeta resource release-warmup-healthz already exists and is not controlled by the suite"))
+		})
+	})
+
+	Context("When an attack fails", func() {
+		It("Should stop the suite when requested", func() {
+			suite.Spec.StopOnFailure = true
+			_, _, err := r.advanceSuite(suite, nil, start)
+			Expect(err).ToNot(HaveOccurred())
+			warmup := finishedVegeta(suite, "warmup", "healthz", vegetav1alpha1.AbortedPhase, start.Add(time.Minute))
+			toCreate, pause, err := r.advanceSuite(suite, map[string]*vegetav1alpha1.Vegeta{warmup.Name: warmup}, start.Add(2*time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(toCreate).To(BeEmpty())
+			Expect(pause).To(BeZero())
+			Expect(suite.Status.Phase).To(Equal(vegetav1alpha1.FailedPhase))
+			Expect(suite.Status.Stages).To(HaveLen(1))
+			Expect(suite.Status.Stages[0].Phase).To(Equal(vegetav1alpha1.FailedPhase))
+			failed := meta.FindStatusCondition(suite.Status.Conditions, vegetav1alpha1.FailedCondition)
+			Expect(failed).ToNot(BeNil())
+			Expect(failed.Reason).To(Equal(stageFailedReason))
+			Expect(failed.Message).To(ContainSubstring("Attack healthz of stage warmup"))
+		})
+		It("Should otherwise run the next stages and fail the suite at the end", func() {
+			_, _, err := r.advanceSuite(suite, nil, start)
+			Expect(err).ToNot(HaveOccurred())
+			warmup := finishedVegeta(suite, "warmup", "healthz", vegetav1alpha1.FailedPhase, start.Add(time.Minute))
+			children := map[string]*vegetav1alpha1.Vegeta{warmup.Name: warmup}
+			toCreate, _, err := r.advanceSuite(suite, children, start.Add(2*time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(toCreate).To(HaveLen(2))
+			Expect(suite.Status.Phase).To(Equal(vegetav1alpha1.RunningPhase))
+
+			for _, attack := range []string{"healthz", "version"} {
+				v := finishedVegeta(suite, "load", attack, vegetav1alpha1.CompletedPhase, start.Add(5*time.Minute))
+				children[v.Name] = v
+			}
+			_, _, err = r.advanceSuite(suite, children, start.Add(6*time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(suite.Status.Phase).To(Equal(vegetav1alpha1.FailedPhase))
+			Expect(suite.Status.Stages[1].Phase).To(Equal(vegetav1alpha1.CompletedPhase))
+		})
+	})
+})
@@ -70,6 +70,7 @@ func init() {
 			"Enabling this will ensure there is only one active controller manager.")
 	flagset.StringVar(&cfg.Namespaces, "namespaces", "", "Namespaces to scope the interaction of the Vegeta Operator and the apiserver (allow list).")
 	flagset.Var(&cfg.Labels, "labels", "Labels to be add to all resources created by the operator")
+	flagset.IntVar(&cfg.TTLSecondsAfterFinished, "ttl-seconds-after-finished", -1, "Default number of seconds after which the pods of finished runs are deleted, unless specified for the Vegeta resource. A negative value keeps them.")
 	// Add the zap logger flag set
 	zapOpts.BindFlags(flagset)
 
@@ -113,6 +114,11 @@ func main() {
 	}
 	setupLog.Info("manager created")
 
+	var ttl *int32
+	if cfg.TTLSecondsAfterFinished >= 0 {
+		seconds := int32(cfg.TTLSecondsAfterFinished)
+		ttl = &seconds
+	}
 	if err = (&controllers.VegetaReconciler{
 		Client: mgr.GetClient(),
 		Log:    ctrl.Log.WithName("controllers").WithName("Vegeta"),
@@ -120,11 +126,64 @@ func main() {
 		Labels: cfg.Labels,
 		// TODO: The image should be specified by SHA in the CSV file, which will be injected as environment variable.
 		// TODO: I could look at operator conditions (whether I can report operator start failures there, cf OpenShift doc)
-		Image: operator.RetrieveDefaultImg(),
+		Image:                   operator.RetrieveDefaultImg(),
+		APIReader:               mgr.GetAPIReader(),
+		TTLSecondsAfterFinished: ttl,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Vegeta")
 		os.Exit(1)
 	}
+	if err = (&controllers.VegetaScheduleReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("VegetaSchedule"),
+		Scheme: mgr.GetScheme(),
+		Labels: cfg.Labels,
+		Clock:  controllers.RealClock{},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VegetaSchedule")
+		os.Exit(1)
+	}
+	if err = (&controllers.VegetaSuiteReconciler{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("VegetaSuite"),
+		Scheme:    mgr.GetScheme(),
+		Labels:    cfg.Labels,
+		Clock:     controllers.RealClock{},
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VegetaSuite")
+		os.Exit(1)
+	}
+	if err = (&controllers.VegetaMatrixReconciler{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("VegetaMatrix"),
+		Scheme:    mgr.GetScheme(),
+		Labels:    cfg.Labels,
+		Clock:     controllers.RealClock{},
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VegetaMatrix")
+		os.Exit(1)
+	}
+	// Webhooks can be disabled when running the operator locally: make run ENABLE_WEBHOOKS=false
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&vegetav1alpha1.Vegeta{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Vegeta")
+			os.Exit(1)
+		}
+		if err = (&vegetav1alpha1.VegetaSchedule{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "VegetaSchedule")
+			os.Exit(1)
+		}
+		if err = (&vegetav1alpha1.VegetaSuite{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "VegetaSuite")
+			os.Exit(1)
+		}
+		if err = (&vegetav1alpha1.VegetaMatrix{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "VegetaMatrix")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("health", healthz.Ping); err != nil {
@@ -31,6 +31,8 @@ type Config struct {
 	EnableLeaderElection bool
 	Namespaces           string
 	Labels               Labels
+	// TTLSecondsAfterFinished is the default time to live of the pods of finished runs, a negative value keeps them
+	TTLSecondsAfterFinished int
 }
 
 // Labels defines the labels to be added to all resources created by the operator.